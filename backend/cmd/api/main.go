@@ -17,6 +17,7 @@ import (
 	"github.com/user/video-downloader-backend/internal/repository"
 	"github.com/user/video-downloader-backend/internal/service"
 	"github.com/user/video-downloader-backend/pkg/logger"
+	"github.com/user/video-downloader-backend/pkg/utils"
 )
 
 func main() {
@@ -29,6 +30,11 @@ func main() {
 
 	cfg := config.LoadConfig()
 
+	keyRing, err := utils.NewKeyRing(cfg.EncryptionKeys, cfg.EncryptionKeyID, cfg.EncryptionKey)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid encryption key ring")
+	}
+
 	db, err := infrastructure.NewPostgresClient(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to connect to database")
@@ -119,7 +125,7 @@ func main() {
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
 		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-API-Key, X-XSRF-TOKEN, X-Session-Id, X-Timestamp, X-Nonce, X-Signature",
 		AllowCredentials: true,
-		ExposeHeaders:    "Set-Cookie",
+		ExposeHeaders:    "Set-Cookie, X-Quota-Plan, X-Quota-Limit, X-Quota-Remaining, X-Quota-Used, X-Quota-Reset, Retry-After",
 		MaxAge:           12 * 3600,
 	}))
 
@@ -131,6 +137,7 @@ func main() {
 		Redis:         redisClient,
 		Cfg:           cfg,
 		StorageClient: storageClient,
+		KeyRing:       keyRing,
 	}
 	route.SetupRoutes(routeConfig)
 
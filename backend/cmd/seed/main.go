@@ -66,10 +66,10 @@ func main() {
 		}
 
 		query := `
-			INSERT INTO users (email, password_hash, full_name, role_id, is_active, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			INSERT INTO users (email, password_hash, full_name, role_id, is_active, created_at, updated_at, email_verified_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		`
-		_, err = dbPool.Exec(ctx, query, adminEmail, hashedPassword, adminName, roleID, true, time.Now(), time.Now())
+		_, err = dbPool.Exec(ctx, query, adminEmail, hashedPassword, adminName, roleID, true, time.Now(), time.Now(), time.Now())
 		if err != nil {
 			log.Fatalf("Failed to create admin user: %v", err)
 		}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+)
+
+// maxAnalyticsRange bounds a single aggregation so a typo cannot lock the
+// worker into years of work.
+const maxAnalyticsRange = 366 * 24 * time.Hour
+
+// aggregateAnalytics recomputes analytics_daily for every UTC day from from to to,
+// both inclusive.
+func aggregateAnalytics(ctx context.Context, analyticRepo repository.AnalyticRepository, from, to time.Time) (int, error) {
+	from, to = from.UTC().Truncate(24*time.Hour), to.UTC().Truncate(24*time.Hour)
+	if to.Before(from) {
+		return 0, fmt.Errorf("analytics range ends before it starts")
+	}
+	if to.Sub(from) > maxAnalyticsRange {
+		return 0, fmt.Errorf("analytics range is longer than %d days", int(maxAnalyticsRange.Hours()/24))
+	}
+
+	days := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return days, err
+		}
+		if err := analyticRepo.AggregateDay(ctx, day); err != nil {
+			return days, fmt.Errorf("%s: %w", day.Format("2006-01-02"), err)
+		}
+		days++
+	}
+	return days, nil
+}
+
+// handleAnalyticsAggregateTask runs a scheduled or enqueued aggregation. The
+// scheduler sends no payload, which refreshes yesterday, whose late downloads and
+// charges may still land, and today.
+func handleAnalyticsAggregateTask(ctx context.Context, analyticRepo repository.AnalyticRepository, payload []byte) error {
+	var task model.AnalyticsAggregateTask
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &task); err != nil {
+			return err
+		}
+	}
+	if task.To.IsZero() {
+		task.To = time.Now()
+	}
+	if task.From.IsZero() {
+		task.From = task.To.AddDate(0, 0, -1)
+	}
+
+	days, err := aggregateAnalytics(ctx, analyticRepo, task.From, task.To)
+	if err != nil {
+		return err
+	}
+	log.Debug().Int("days", days).Time("from", task.From).Time("to", task.To).Msg("Daily analytics aggregated")
+	return nil
+}
+
+// runAnalyticsBackfill recomputes analytics_daily for a past range. Each day is
+// rebuilt from scratch, so ranges may overlap earlier runs.
+//
+//	worker analytics-backfill -from 2024-01-01 [-to 2024-01-31]
+func runAnalyticsBackfill(ctx context.Context, args []string, analyticRepo repository.AnalyticRepository) error {
+	fs := flag.NewFlagSet("analytics-backfill", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "first day, YYYY-MM-DD")
+	toFlag := fs.String("to", "", "last day, YYYY-MM-DD (default today)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	from, err := time.Parse("2006-01-02", *fromFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	to := time.Now().UTC()
+	if *toFlag != "" {
+		if to, err = time.Parse("2006-01-02", *toFlag); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	log.Info().Str("from", from.Format("2006-01-02")).Str("to", to.Format("2006-01-02")).Msg("Starting analytics backfill")
+	days, err := aggregateAnalytics(ctx, analyticRepo, from, to)
+	if err != nil {
+		return err
+	}
+	log.Info().Int("days", days).Msg("Analytics backfill finished")
+	return nil
+}
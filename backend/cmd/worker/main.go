@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
@@ -23,6 +25,7 @@ import (
 	"github.com/user/video-downloader-backend/internal/infrastructure"
 	"github.com/user/video-downloader-backend/internal/model"
 	"github.com/user/video-downloader-backend/internal/repository"
+	"github.com/user/video-downloader-backend/internal/service"
 	"github.com/user/video-downloader-backend/pkg/logger"
 	"github.com/user/video-downloader-backend/pkg/utils"
 )
@@ -33,6 +36,11 @@ func main() {
 
 	cfg := config.LoadConfig()
 
+	keyRing, err := utils.NewKeyRing(cfg.EncryptionKeys, cfg.EncryptionKeyID, cfg.EncryptionKey)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid encryption key ring")
+	}
+
 	db, err := infrastructure.NewPostgresClient(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to connect to database")
@@ -40,6 +48,13 @@ func main() {
 	defer db.Pool.Close()
 
 	downloadRepo := repository.NewDownloadRepository(db.Pool)
+	downloadBatchRepo := repository.NewDownloadBatchRepository(db.Pool)
+	subscriptionRepo := repository.NewSubscriptionRepository(db.Pool)
+	applicationRepo := repository.NewApplicationRepository(db.Pool)
+	analyticRepo := repository.NewAnalyticRepository(db.Pool)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db.Pool)
+	twoFactorRepo := repository.NewTwoFactorRepository(db.Pool)
+	userRepo := repository.NewUserRepository(db.Pool)
 	downloader := infrastructure.NewFallbackDownloader()
 
 	storageClient, err := infrastructure.NewStorageClient(
@@ -57,6 +72,20 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to create minio bucket")
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		if err := runReencrypt(context.Background(), os.Args[2:], downloadRepo, twoFactorRepo, storageClient, cfg.MinioBucket, keyRing); err != nil {
+			log.Fatal().Err(err).Msg("re-encryption failed")
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "analytics-backfill" {
+		if err := runAnalyticsBackfill(context.Background(), os.Args[2:], analyticRepo); err != nil {
+			log.Fatal().Err(err).Msg("analytics backfill failed")
+		}
+		return
+	}
+
 	// Start Log Cleaner Cron Job
 	if strings.EqualFold(strings.TrimSpace(os.Getenv("ENABLE_DOCKER_LOG_CLEANER")), "true") {
 		go startLogCleanerCron()
@@ -73,22 +102,81 @@ func main() {
 	}
 	defer redisClient.Close()
 
+	downloader.SetHealthTracker(infrastructure.NewStrategyHealthTracker(redisClient))
+	downloader.SetInfoCache(infrastructure.NewVideoInfoCache(redisClient))
+
 	// Initialize Centrifugo Client
 	centrifugoClient := infrastructure.NewCentrifugoClient(cfg.CentrifugoURL, cfg.CentrifugoAPIKey)
 
+	// Store verification happens in the API; the worker only moves states.
+	subscriptionService := service.NewSubscriptionService(subscriptionRepo, applicationRepo, analyticRepo, nil, centrifugoClient, cfg)
+
+	scheduler := infrastructure.NewTaskScheduler(cfg.RedisAddr, cfg.RedisPassword)
+	if _, err := scheduler.Register("@every 1m", asynq.NewTask(infrastructure.TypeSubscriptionExpiry, nil),
+		asynq.Queue("default"), asynq.Unique(time.Minute), asynq.MaxRetry(0)); err != nil {
+		log.Fatal().Err(err).Msg("failed to register subscription expiry task")
+	}
+	if _, err := scheduler.Register("*/15 * * * *", asynq.NewTask(infrastructure.TypeAnalyticsAggregate, nil),
+		asynq.Queue("low"), asynq.Unique(15*time.Minute), asynq.Timeout(10*time.Minute)); err != nil {
+		log.Fatal().Err(err).Msg("failed to register analytics aggregation task")
+	}
+	if _, err := scheduler.Register("@every 1h", asynq.NewTask(infrastructure.TypeRefreshTokenCleanup, nil),
+		asynq.Queue("low"), asynq.Unique(time.Hour), asynq.MaxRetry(0)); err != nil {
+		log.Fatal().Err(err).Msg("failed to register refresh token cleanup task")
+	}
+	if _, err := scheduler.Register("@every 5m", asynq.NewTask(infrastructure.TypeAccountPurge, nil),
+		asynq.Queue("low"), asynq.Unique(5*time.Minute), asynq.MaxRetry(0), asynq.Timeout(30*time.Minute)); err != nil {
+		log.Fatal().Err(err).Msg("failed to register account purge task")
+	}
+	if err := scheduler.Start(); err != nil {
+		log.Fatal().Err(err).Msg("failed to start task scheduler")
+	}
+	defer scheduler.Shutdown()
+
 	mux := asynq.NewServeMux()
 
+	mux.HandleFunc(infrastructure.TypeSubscriptionExpiry, func(ctx context.Context, t *asynq.Task) error {
+		expired, err := subscriptionService.ExpireDue(ctx, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to expire subscriptions: %w", err)
+		}
+		if expired > 0 {
+			log.Info().Int("count", expired).Msg("Expired subscriptions")
+		}
+		return nil
+	})
+
+	mux.HandleFunc(infrastructure.TypeAnalyticsAggregate, func(ctx context.Context, t *asynq.Task) error {
+		return handleAnalyticsAggregateTask(ctx, analyticRepo, t.Payload())
+	})
+
+	mux.HandleFunc(infrastructure.TypeRefreshTokenCleanup, func(ctx context.Context, t *asynq.Task) error {
+		deleted, err := refreshTokenRepo.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			log.Info().Int64("count", deleted).Msg("Deleted expired refresh tokens")
+		}
+		return nil
+	})
+
+	mux.HandleFunc(infrastructure.TypeAccountPurge, func(ctx context.Context, t *asynq.Task) error {
+		return handleAccountPurgeTask(ctx, userRepo, downloadRepo, storageClient, cfg.MinioBucket)
+	})
+
 	mux.HandleFunc(infrastructure.TypeVideoDownload, func(ctx context.Context, t *asynq.Task) error {
 		var task model.DownloadTask
 		if err := json.Unmarshal(t.Payload(), &task); err != nil {
 			return err
 		}
 
-		if err := handleVideoDownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, cfg.MinioBucket, cfg.EncryptionKey, &task); err != nil {
-			return err
+		err := handleVideoDownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, cfg.MinioBucket, keyRing, &task)
+		if task.BatchID != nil {
+			publishBatchProgressEvent(context.WithoutCancel(ctx), downloadBatchRepo, redisClient, centrifugoClient, *task.BatchID)
 		}
 
-		return nil
+		return downloadTaskResult(ctx, err)
 	})
 
 	mux.HandleFunc(infrastructure.TypeMp3Download, func(ctx context.Context, t *asynq.Task) error {
@@ -97,11 +185,12 @@ func main() {
 			return err
 		}
 
-		if err := handleMp3DownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, cfg.MinioBucket, cfg.EncryptionKey, &task); err != nil {
-			return err
+		err := handleMp3DownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, cfg.MinioBucket, keyRing, &task)
+		if task.BatchID != nil {
+			publishBatchProgressEvent(context.WithoutCancel(ctx), downloadBatchRepo, redisClient, centrifugoClient, *task.BatchID)
 		}
 
-		return nil
+		return downloadTaskResult(ctx, err)
 	})
 
 	if err := server.Run(mux); err != nil {
@@ -141,29 +230,130 @@ func startCleanupCron(ctx context.Context, downloadRepo repository.DownloadRepos
 					break
 				}
 
-				var idsToDelete []uuid.UUID
+				idsToDelete := make([]uuid.UUID, 0, len(tasks))
 				for _, task := range tasks {
-					// Delete files from MinIO
-					// Folder structure: platform_type/task_id/
-					prefix := fmt.Sprintf("%s/%s/", task.PlatformType, task.ID.String())
-					if err := storageClient.DeleteFolder(ctx, bucketName, prefix); err != nil {
-						log.Error().Err(err).Str("task_id", task.ID.String()).Msg("Failed to delete folder from MinIO")
-						// We continue to delete from DB to avoid orphan records,
-						// or we could skip adding to idsToDelete.
-						// Let's delete from DB to keep it clean as requested.
-					}
 					idsToDelete = append(idsToDelete, task.ID)
 				}
 
-				if len(idsToDelete) > 0 {
-					if err := downloadRepo.BulkDelete(ctx, idsToDelete); err != nil {
-						log.Error().Err(err).Msg("Failed to bulk delete tasks from DB")
-					} else {
-						log.Info().Int("count", len(idsToDelete)).Msg("Deleted old tasks and files")
-					}
+				// Deleting the rows releases their references on stored objects first,
+				// so files shared with newer downloads survive.
+				if err := downloadRepo.BulkDelete(ctx, idsToDelete); err != nil {
+					log.Error().Err(err).Msg("Failed to bulk delete tasks from DB")
+					break
+				}
+				purgeUnreferencedObjects(ctx, downloadRepo, storageClient, bucketName)
+				deleteTaskFolders(ctx, downloadRepo, storageClient, bucketName, tasks)
+				log.Info().Int("count", len(idsToDelete)).Msg("Deleted old tasks and files")
+			}
+
+			// Downloads deleted through the API release their objects as well.
+			purgeUnreferencedObjects(ctx, downloadRepo, storageClient, bucketName)
+		}
+	}
+}
+
+// deleteTaskFolders removes the MinIO folders of deleted downloads, except
+// those still holding objects shared with other downloads.
+func deleteTaskFolders(ctx context.Context, downloadRepo repository.DownloadRepository, storageClient infrastructure.StorageClient, bucketName string, tasks []*model.DownloadTask) {
+	for _, task := range tasks {
+		// Folder structure: platform_type/task_id/
+		prefix := fmt.Sprintf("%s/%s/", task.PlatformType, task.ID.String())
+		remaining, err := downloadRepo.CountStoredObjectsByPrefix(ctx, prefix)
+		if err != nil {
+			log.Error().Err(err).Str("task_id", task.ID.String()).Msg("Failed to check stored objects before folder cleanup")
+			continue
+		}
+		if remaining > 0 {
+			log.Info().Str("task_id", task.ID.String()).Int("objects", remaining).Msg("Keeping folder still referenced by other downloads")
+			continue
+		}
+		if err := storageClient.DeleteFolder(ctx, bucketName, prefix); err != nil {
+			log.Error().Err(err).Str("task_id", task.ID.String()).Msg("Failed to delete folder from MinIO")
+		}
+	}
+}
+
+// handleAccountPurgeTask deletes the downloads, download files and avatar of
+// accounts their owners deleted. An account is only marked purged once all of
+// its downloads are gone, so a failed run is picked up by the next one.
+func handleAccountPurgeTask(ctx context.Context, userRepo repository.UserRepository, downloadRepo repository.DownloadRepository, storageClient infrastructure.StorageClient, bucketName string) error {
+	users, err := userRepo.FindPendingPurge(ctx, 50)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := purgeAccountDownloads(ctx, downloadRepo, storageClient, bucketName, user.ID); err != nil {
+			log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to purge downloads of deleted account")
+			continue
+		}
+
+		if user.AvatarURL != nil {
+			if objectName, ok := infrastructure.ObjectNameFromURL(*user.AvatarURL, bucketName); ok {
+				if err := storageClient.DeleteFile(ctx, bucketName, objectName); err != nil {
+					log.Error().Err(err).Str("user_id", user.ID.String()).Str("object", objectName).Msg("Failed to delete avatar of deleted account")
+					continue
 				}
 			}
 		}
+
+		if err := userRepo.MarkDataPurged(ctx, user.ID); err != nil {
+			return err
+		}
+		log.Info().Str("user_id", user.ID.String()).Msg("Purged data of deleted account")
+	}
+	return nil
+}
+
+func purgeAccountDownloads(ctx context.Context, downloadRepo repository.DownloadRepository, storageClient infrastructure.StorageClient, bucketName string, userID uuid.UUID) error {
+	for {
+		// Every round deletes what it found, so the first page is always the next.
+		tasks, err := downloadRepo.FindByUserID(ctx, userID, 100, 0)
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(tasks))
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		if err := downloadRepo.BulkDelete(ctx, ids); err != nil {
+			return err
+		}
+		purgeUnreferencedObjects(ctx, downloadRepo, storageClient, bucketName)
+		deleteTaskFolders(ctx, downloadRepo, storageClient, bucketName, tasks)
+	}
+}
+
+// purgeUnreferencedObjects removes stored objects no download references anymore.
+func purgeUnreferencedObjects(ctx context.Context, downloadRepo repository.DownloadRepository, storageClient infrastructure.StorageClient, bucketName string) {
+	for {
+		objects, err := downloadRepo.FindUnreferencedObjects(ctx, 100)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to find unreferenced stored objects")
+			return
+		}
+		if len(objects) == 0 {
+			return
+		}
+
+		for _, object := range objects {
+			deleted, err := downloadRepo.DeleteStoredObject(ctx, object.ObjectName)
+			if err != nil {
+				log.Error().Err(err).Str("object", object.ObjectName).Msg("Failed to delete stored object record")
+				return
+			}
+			if !deleted {
+				// Picked up again by a new download in the meantime.
+				continue
+			}
+			if err := storageClient.DeleteFile(ctx, bucketName, object.ObjectName); err != nil {
+				log.Error().Err(err).Str("object", object.ObjectName).Msg("Failed to delete stored object from MinIO")
+			}
+		}
 	}
 }
 
@@ -189,7 +379,46 @@ func publishDownloadEvent(ctx context.Context, redisClient infrastructure.RedisC
 	return nil
 }
 
-func handleVideoDownloadTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, encryptionKey string, task *model.DownloadTask) error {
+func publishBatchProgressEvent(ctx context.Context, batchRepo repository.DownloadBatchRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, batchID uuid.UUID) {
+	batch, err := batchRepo.RefreshProgress(ctx, batchID)
+	if err != nil {
+		log.Error().Err(err).Str("batch_id", batchID.String()).Msg("failed to refresh batch progress")
+		return
+	}
+	if batch == nil {
+		return
+	}
+
+	eventType := "batch.processing"
+	switch batch.Status {
+	case "completed":
+		eventType = "batch.completed"
+	case "failed":
+		eventType = "batch.failed"
+	}
+
+	progress := batch.Progress()
+	event := &model.DownloadEvent{
+		Type:      eventType,
+		TaskID:    batch.ID,
+		BatchID:   &batch.ID,
+		UserID:    batch.UserID,
+		Status:    batch.Status,
+		Progress:  &progress,
+		Message:   fmt.Sprintf("%d/%d completed, %d failed", batch.CompletedItems, batch.TotalItems, batch.FailedItems),
+		CreatedAt: time.Now(),
+	}
+
+	if err := publishDownloadEvent(ctx, redisClient, centrifugoClient, event); err != nil {
+		log.Error().Err(err).Str("batch_id", batchID.String()).Msg("failed to publish batch progress event")
+	}
+}
+
+func handleVideoDownloadTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, keyRing *utils.KeyRing, task *model.DownloadTask) error {
+	if canceledBeforeStart(ctx, downloadRepo, task) {
+		return nil
+	}
+
 	task.Status = "processing"
 	if err := downloadRepo.Update(ctx, task); err != nil {
 		log.Error().Err(err).Str("task_id", task.ID.String()).Msg("failed to update task to processing")
@@ -200,7 +429,13 @@ func handleVideoDownloadTask(ctx context.Context, downloadRepo repository.Downlo
 		log.Error().Err(err).Msg("failed to publish start event")
 	}
 
-	if err := processDownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, encryptionKey, task); err != nil {
+	if err := processDownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, keyRing, task); err != nil {
+		if taskFailure(ctx, err).Code == infrastructure.ErrCodeCanceled {
+			if cancelErr := markTaskCanceled(ctx, downloadRepo, redisClient, centrifugoClient, storageClient, bucketName, task); cancelErr != nil {
+				log.Error().Err(cancelErr).Str("task_id", task.ID.String()).Msg("failed to mark task as canceled")
+			}
+			return err
+		}
 		failErr := markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, err)
 		if failErr != nil {
 			log.Error().Err(failErr).Str("task_id", task.ID.String()).Msg("failed to mark task as failed")
@@ -211,7 +446,11 @@ func handleVideoDownloadTask(ctx context.Context, downloadRepo repository.Downlo
 	return nil
 }
 
-func handleMp3DownloadTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, encryptionKey string, task *model.DownloadTask) error {
+func handleMp3DownloadTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, keyRing *utils.KeyRing, task *model.DownloadTask) error {
+	if canceledBeforeStart(ctx, downloadRepo, task) {
+		return nil
+	}
+
 	task.Status = "processing"
 	if err := downloadRepo.Update(ctx, task); err != nil {
 		log.Error().Err(err).Str("task_id", task.ID.String()).Msg("failed to update mp3 task to processing")
@@ -222,7 +461,13 @@ func handleMp3DownloadTask(ctx context.Context, downloadRepo repository.Download
 		log.Error().Err(err).Msg("failed to publish mp3 start event")
 	}
 
-	if err := processMp3DownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, encryptionKey, task); err != nil {
+	if err := processMp3DownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, keyRing, task); err != nil {
+		if taskFailure(ctx, err).Code == infrastructure.ErrCodeCanceled {
+			if cancelErr := markTaskCanceled(ctx, downloadRepo, redisClient, centrifugoClient, storageClient, bucketName, task); cancelErr != nil {
+				log.Error().Err(cancelErr).Str("task_id", task.ID.String()).Msg("failed to mark mp3 task as canceled")
+			}
+			return err
+		}
 		failErr := markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, err)
 		if failErr != nil {
 			log.Error().Err(failErr).Str("task_id", task.ID.String()).Msg("failed to mark mp3 task as failed")
@@ -233,7 +478,7 @@ func handleMp3DownloadTask(ctx context.Context, downloadRepo repository.Download
 	return nil
 }
 
-func processMp3DownloadTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, encryptionKey string, task *model.DownloadTask) error {
+func processMp3DownloadTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, keyRing *utils.KeyRing, task *model.DownloadTask) error {
 	if err := publishProgressEvent(ctx, redisClient, centrifugoClient, task, 10); err != nil {
 		log.Error().Err(err).Str("task_id", task.ID.String()).Int("progress", 10).Msg("failed to publish mp3 progress event (start)")
 	}
@@ -281,6 +526,11 @@ func processMp3DownloadTask(ctx context.Context, downloadRepo repository.Downloa
 		log.Error().Err(err).Str("task_id", task.ID.String()).Int("progress", 30).Msg("failed to publish mp3 progress event (metadata)")
 	}
 
+	contentKey := storedObjectKey(task, "best.mp3")
+	if file := reuseStoredFile(ctx, downloadRepo, task, contentKey); file != nil {
+		return completeWithStoredFile(ctx, downloadRepo, redisClient, centrifugoClient, task, file, "mp3")
+	}
+
 	tempFile, err := os.CreateTemp("", "audio-")
 	if err != nil {
 		return err
@@ -301,8 +551,13 @@ func processMp3DownloadTask(ctx context.Context, downloadRepo repository.Downloa
 		strings.Contains(lowerPlatform, "tiktok") ||
 		strings.Contains(lowerPlatform, "twitter")
 	downloadedByYtDlp := false
+	// Whether the input holds only the requested clip; otherwise the conversion
+	// cuts it.
+	inputClipped := false
+	downloadCtx := trackProgress(ctx, redisClient, centrifugoClient, task, 30, 70)
 	tryDownloadWithYtDlp := func(format string) error {
-		if err := downloader.DownloadToPath(ctx, task.OriginalURL, format, inputPath, nil); err != nil {
+		clipped, err := fetchClipToPath(downloadCtx, downloader, task, task.OriginalURL, format, inputPath)
+		if err != nil {
 			return err
 		}
 		fi, err := os.Stat(inputPath)
@@ -312,6 +567,7 @@ func processMp3DownloadTask(ctx context.Context, downloadRepo repository.Downloa
 		if fi.Size() == 0 {
 			return fmt.Errorf("downloaded source file is empty")
 		}
+		inputClipped = clipped
 		return nil
 	}
 
@@ -339,6 +595,7 @@ func processMp3DownloadTask(ctx context.Context, downloadRepo repository.Downloa
 	}
 
 	if !downloadedByYtDlp && resolvedSourceURL != task.OriginalURL {
+		inputClipped = false
 		if err := downloadURLToPath(ctx, resolvedSourceURL, referer, inputPath); err != nil {
 			log.Warn().Err(err).Str("url", sourceURL).Msg("Direct source download failed, falling back to downloader")
 			if err2 := tryDownloadWithYtDlp(""); err2 != nil {
@@ -371,15 +628,25 @@ func processMp3DownloadTask(ctx context.Context, downloadRepo repository.Downloa
 	ffmpegArgs := []string{
 		"-y",
 		"-loglevel", "error",
-		"-i", inputPath,
+	}
+	if task.Clip != nil && !inputClipped {
+		ffmpegArgs = append(ffmpegArgs, "-ss", strconv.FormatFloat(task.Clip.Start, 'f', -1, 64))
+	}
+	ffmpegArgs = append(ffmpegArgs, "-i", inputPath)
+	if task.Clip != nil && !inputClipped {
+		ffmpegArgs = append(ffmpegArgs, "-t", strconv.FormatFloat(task.Clip.Length(), 'f', -1, 64))
+	}
+	ffmpegArgs = append(ffmpegArgs,
 		"-vn",
 		"-map", "0:a:0",
 		"-acodec", "libmp3lame",
 		"-q:a", "0",
 		outputPath,
-	}
+	)
 
-	ffmpegCmd := exec.CommandContext(ctx, "ffmpeg", ffmpegArgs...)
+	convertCtx := trackProgress(ctx, redisClient, centrifugoClient, task, 70, 80)
+	ffmpegCmd := infrastructure.NewCommand(ctx, "ffmpeg", append(infrastructure.FFmpegProgressArgs(convertCtx), ffmpegArgs...)...)
+	infrastructure.AttachFFmpegProgress(convertCtx, ffmpegCmd)
 	var ffmpegStderr bytes.Buffer
 	ffmpegCmd.Stderr = &ffmpegStderr
 	if err := ffmpegCmd.Run(); err != nil {
@@ -411,7 +678,7 @@ func processMp3DownloadTask(ctx context.Context, downloadRepo repository.Downloa
 	objectName := fmt.Sprintf("%s/%s/%s.%s", baseFolder, task.ID.String(), "best", "mp3")
 	minioURL, err := storageClient.UploadFile(ctx, bucketName, objectName, f, fi.Size(), "audio/mpeg")
 	if err != nil {
-		return err
+		return storageFailure(err)
 	}
 
 	size := fi.Size()
@@ -426,8 +693,10 @@ func processMp3DownloadTask(ctx context.Context, downloadRepo repository.Downloa
 		Extension:     &ext,
 		FileSize:      &size,
 		EncryptedData: nil,
+		ObjectName:    &objectName,
 	}
 	_ = downloadRepo.AddFile(ctx, downloadFile)
+	registerStoredFile(ctx, downloadRepo, contentKey, downloadFile)
 
 	task.FilePath = &minioURL
 	task.FileSize = &size
@@ -658,7 +927,7 @@ func downloadURLToPath(ctx context.Context, fileURL string, refererURL string, o
 }
 
 func hasAudioStream(ctx context.Context, inputPath string) bool {
-	cmd := exec.CommandContext(ctx, "ffprobe",
+	cmd := infrastructure.NewCommand(ctx, "ffprobe",
 		"-v", "error",
 		"-select_streams", "a",
 		"-show_entries", "stream=index",
@@ -674,7 +943,7 @@ func hasAudioStream(ctx context.Context, inputPath string) bool {
 	return strings.TrimSpace(out.String()) != ""
 }
 
-func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, encryptionKey string, task *model.DownloadTask) error {
+func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, keyRing *utils.KeyRing, task *model.DownloadTask) error {
 	forceYouTubeDirect := strings.EqualFold(strings.TrimSpace(os.Getenv("YOUTUBE_FORCE_DIRECT")), "true")
 	isYouTubeTask := strings.EqualFold(task.PlatformType, "youtube") || strings.Contains(strings.ToLower(task.OriginalURL), "youtube.com") || strings.Contains(strings.ToLower(task.OriginalURL), "youtu.be")
 	isMp3Task := strings.HasSuffix(strings.ToLower(strings.TrimSpace(task.PlatformType)), "-to-mp3")
@@ -685,7 +954,7 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 
 	if isMp3Task {
 		log.Info().Str("task_id", task.ID.String()).Str("platform", task.PlatformType).Msg("Routing task to MP3 pipeline")
-		return processMp3DownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, encryptionKey, task)
+		return processMp3DownloadTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, keyRing, task)
 	}
 
 	if strings.Contains(strings.ToLower(task.OriginalURL), "twitch.tv") {
@@ -700,7 +969,17 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			log.Error().Err(err).Str("task_id", task.ID.String()).Int("progress", 30).Msg("failed to publish progress event (youtube)")
 		}
 
-		tempFile, err := os.CreateTemp("", "youtube-*.mp4")
+		name, ext, selector := "best", "mp4", ""
+		if task.Selection != nil {
+			name, ext, selector = task.Selection.Name(), task.Selection.Container, formatSelector(task.Selection)
+		}
+
+		contentKey := storedObjectKey(task, name+"."+ext)
+		if file := reuseStoredFile(ctx, downloadRepo, task, contentKey); file != nil {
+			return completeWithStoredFile(ctx, downloadRepo, redisClient, centrifugoClient, task, file, name)
+		}
+
+		tempFile, err := os.CreateTemp("", "youtube-*."+ext)
 		if err != nil {
 			return err
 		}
@@ -709,7 +988,8 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 		_ = os.Remove(tempPath)
 		defer os.Remove(tempPath)
 
-		if err := downloader.DownloadToPath(ctx, task.OriginalURL, "", tempPath, nil); err != nil {
+		dlCtx := trackProgress(ctx, redisClient, centrifugoClient, task, 30, 80)
+		if err := downloadClipToPath(dlCtx, downloader, task, task.OriginalURL, selector, tempPath); err != nil {
 			return err
 		}
 
@@ -723,17 +1003,19 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			return fmt.Errorf("downloaded file is empty")
 		}
 
-		objectName := fmt.Sprintf("%s/%s/%s.%s", "youtube", task.ID.String(), "best", "mp4")
-		minioURL, err := storageClient.UploadFile(ctx, bucketName, objectName, f, fi.Size(), "video/mp4")
+		objectName := fmt.Sprintf("%s/%s/%s.%s", "youtube", task.ID.String(), name, ext)
+		minioURL, err := storageClient.UploadFile(ctx, bucketName, objectName, f, fi.Size(), videoContentType(ext))
 		f.Close()
 		if err != nil {
-			return err
+			return storageFailure(err)
 		}
 
 		size := fi.Size()
-		ext := "mp4"
-		fID := "best"
+		fID := name
 		res := "best"
+		if task.Selection != nil && task.Selection.Height > 0 {
+			res = fmt.Sprintf("%dp", task.Selection.Height)
+		}
 		downloadFile := &model.DownloadFile{
 			DownloadID:    task.ID,
 			URL:           minioURL,
@@ -742,8 +1024,10 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			Extension:     &ext,
 			FileSize:      &size,
 			EncryptedData: nil,
+			ObjectName:    &objectName,
 		}
 		_ = downloadRepo.AddFile(ctx, downloadFile)
+		registerStoredFile(ctx, downloadRepo, contentKey, downloadFile)
 
 		task.FilePath = &minioURL
 		task.FileSize = &size
@@ -790,9 +1074,11 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 		log.Error().Err(err).Str("task_id", task.ID.String()).Int("progress", 30).Msg("failed to publish progress event (metadata)")
 	}
 
-	if isYouTubeTask && forceYouTubeDirect {
+	// Direct links always point at the whole video, so clips are downloaded and
+	// cut like any other source.
+	if isYouTubeTask && forceYouTubeDirect && task.Clip == nil {
 		log.Info().Str("task_id", task.ID.String()).Str("url", task.OriginalURL).Msg("Processing YouTube as direct download (forced)")
-		return processDirectLinkTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, task, info, encryptionKey)
+		return processDirectLinkTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, task, info, keyRing)
 	}
 
 	isDailymotion := strings.Contains(strings.ToLower(task.OriginalURL), "dailymotion.com") || strings.Contains(strings.ToLower(task.OriginalURL), "dai.ly")
@@ -812,12 +1098,12 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 	isTiktok := strings.ToLower(task.PlatformType) == "tiktok" ||
 		strings.Contains(strings.ToLower(task.OriginalURL), "tiktok.com")
 
-	if strings.ToLower(task.PlatformType) == "facebook" ||
+	if (strings.ToLower(task.PlatformType) == "facebook" ||
 		isTwitter ||
 		isInstagram ||
-		isTiktok {
+		isTiktok) && task.Clip == nil {
 		log.Info().Str("platform", task.PlatformType).Msg("Processing as direct download (no-upload)")
-		return processDirectLinkTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, task, info, encryptionKey)
+		return processDirectLinkTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, task, info, keyRing)
 	}
 
 	if isTwitch {
@@ -827,6 +1113,11 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 	if isDailymotion {
 		log.Info().Str("url", task.OriginalURL).Msg("Processing Dailymotion with Chromedp + ffmpeg (upload)")
 
+		contentKey := storedObjectKey(task, "best.mp4")
+		if file := reuseStoredFile(ctx, downloadRepo, task, contentKey); file != nil {
+			return completeWithStoredFile(ctx, downloadRepo, redisClient, centrifugoClient, task, file, "best")
+		}
+
 		outboundProxy := sanitizeProxyURL(os.Getenv("OUTBOUND_PROXY_URL"))
 		tempFile, err := os.CreateTemp("", "dailymotion-*.mp4")
 		if err != nil {
@@ -838,10 +1129,11 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 
 		downloaded := false
 		ua := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/132.0.0.0 Safari/537.36"
+		dlCtx := trackProgress(ctx, redisClient, centrifugoClient, task, 30, 80)
 
 		if videoID := extractDailymotionID(task.OriginalURL); videoID != "" {
 			if m3u8URL, title, thumb, dur, err := fetchDailymotionMasterPlaylist(ctx, videoID, task.OriginalURL, outboundProxy); err == nil && m3u8URL != "" {
-				if err := downloadHLSWithFFmpeg(ctx, m3u8URL, ua, task.OriginalURL, "", tempPath); err == nil {
+				if err := downloadHLSWithFFmpeg(dlCtx, m3u8URL, ua, task.OriginalURL, "", tempPath); err == nil {
 					if title != "" {
 						t := title
 						task.Title = &t
@@ -866,11 +1158,6 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 				defer os.Remove(cookieFilePath)
 			}
 			if err != nil {
-				task.Status = "failed"
-				msg := err.Error()
-				task.ErrorMessage = &msg
-				_ = downloadRepo.Update(ctx, task)
-				_ = markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, err)
 				return err
 			}
 			if chromedpUA != "" {
@@ -883,7 +1170,8 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			}
 
 			run := func(args []string) (string, error) {
-				cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+				cmd := infrastructure.NewCommand(dlCtx, "yt-dlp", args...)
+				infrastructure.AttachYtDlpProgress(dlCtx, cmd)
 				var stderr bytes.Buffer
 				cmd.Stderr = &stderr
 				err := cmd.Run()
@@ -901,6 +1189,7 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 				"--add-header", fmt.Sprintf("User-Agent: %s", ua),
 				"--add-header", "Origin: https://www.dailymotion.com",
 			}
+			pageArgs = append(pageArgs, infrastructure.YtDlpProgressArgs(dlCtx)...)
 			pageArgsWithImp := append(append([]string{}, pageArgs...), "--impersonate", imp)
 			if outboundProxy != "" {
 				pageArgs = append(pageArgs, "--proxy", outboundProxy)
@@ -931,12 +1220,7 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 
 			if !downloaded {
 				cookieHeader := netscapeCookiesToHeader(cookieFilePath, []string{"dailymotion.com"})
-				if err := downloadHLSWithFFmpeg(ctx, m3u8URL, ua, task.OriginalURL, cookieHeader, tempPath); err != nil {
-					task.Status = "failed"
-					msg := err.Error()
-					task.ErrorMessage = &msg
-					_ = downloadRepo.Update(ctx, task)
-					_ = markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, err)
+				if err := downloadHLSWithFFmpeg(dlCtx, m3u8URL, ua, task.OriginalURL, cookieHeader, tempPath); err != nil {
 					return err
 				}
 				// downloaded = true // already set above
@@ -946,13 +1230,13 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 		fiLocal, err := os.Stat(tempPath)
 		if err != nil || fiLocal.Size() == 0 {
 			e := fmt.Errorf("downloaded file is empty")
-			task.Status = "failed"
-			msg := e.Error()
-			task.ErrorMessage = &msg
-			_ = downloadRepo.Update(ctx, task)
-			_ = markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, e)
 			return e
 		}
+		if task.Clip != nil {
+			if err := cutClip(ctx, task.Clip, tempPath); err != nil {
+				return err
+			}
+		}
 
 		{
 			b, err := os.ReadFile(tempPath)
@@ -965,20 +1249,10 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 				s := strings.ToLower(strings.TrimSpace(string(head)))
 				if strings.HasPrefix(s, "<!doctype") || strings.HasPrefix(s, "<html") || strings.HasPrefix(s, "#extm3u") {
 					e := fmt.Errorf("downloaded file is not mp4")
-					task.Status = "failed"
-					msg := e.Error()
-					task.ErrorMessage = &msg
-					_ = downloadRepo.Update(ctx, task)
-					_ = markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, e)
 					return e
 				}
 				if len(head) >= 12 && string(head[4:8]) != "ftyp" {
 					e := fmt.Errorf("downloaded file is not mp4")
-					task.Status = "failed"
-					msg := e.Error()
-					task.ErrorMessage = &msg
-					_ = downloadRepo.Update(ctx, task)
-					_ = markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, e)
 					return e
 				}
 			}
@@ -993,11 +1267,7 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 		minioURL, err := storageClient.UploadFile(ctx, bucketName, objectName, f, fi.Size(), "video/mp4")
 		f.Close()
 		if err != nil {
-			task.Status = "failed"
-			msg := err.Error()
-			task.ErrorMessage = &msg
-			_ = downloadRepo.Update(ctx, task)
-			_ = markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, err)
+			err = storageFailure(err)
 			return err
 		}
 
@@ -1013,8 +1283,10 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			Extension:     &ext,
 			FileSize:      &size,
 			EncryptedData: nil,
+			ObjectName:    &objectName,
 		}
 		_ = downloadRepo.AddFile(ctx, downloadFile)
+		registerStoredFile(ctx, downloadRepo, contentKey, downloadFile)
 
 		task.FilePath = &minioURL
 		task.FileSize = &size
@@ -1044,6 +1316,16 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 	if isYouTube {
 		selectedFormats = []infrastructure.FormatInfo{{Ext: "mp4"}}
 	}
+	if task.Selection != nil && !isSnapchat {
+		selected := infrastructure.FormatInfo{
+			FormatID: formatSelector(task.Selection),
+			Ext:      task.Selection.Container,
+		}
+		if task.Selection.Height > 0 {
+			selected.Height = intPtr(task.Selection.Height)
+		}
+		selectedFormats = []infrastructure.FormatInfo{selected}
+	}
 
 	// If no formats found but we have a download URL, use it
 	if len(selectedFormats) == 0 && info.DownloadURL != "" {
@@ -1054,6 +1336,7 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 	}
 
 	downloadedAny := false
+	var lastErr error
 	for i, fmtInfo := range selectedFormats {
 		progress := 30 + int(float64(i)/float64(len(selectedFormats))*50)
 		if err := publishProgressEvent(ctx, redisClient, centrifugoClient, task, progress); err != nil {
@@ -1067,6 +1350,31 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			extForFile = "mp4"
 		}
 
+		resolution := ""
+		if fmtInfo.Height != nil && *fmtInfo.Height > 0 {
+			resolution = fmt.Sprintf("%dp", *fmtInfo.Height)
+		}
+
+		// Use resolution for filename to avoid special chars from format selector
+		safeName := resolution
+		if safeName == "" {
+			safeName = "best"
+		}
+		if task.Selection != nil && !isSnapchat {
+			safeName = task.Selection.Name()
+		}
+
+		contentKey := storedObjectKey(task, safeName+"."+extForFile)
+		if file := reuseStoredFile(ctx, downloadRepo, task, contentKey); file != nil {
+			downloadedAny = true
+			if i == 0 {
+				task.FilePath = &file.URL
+				task.FileSize = file.FileSize
+				task.Format = file.FormatID
+			}
+			continue
+		}
+
 		tempPattern := fmt.Sprintf("vid-%dp-*.%s", fmtInfo.Height, extForFile)
 		if fmtInfo.Height == nil || *fmtInfo.Height == 0 {
 			tempPattern = fmt.Sprintf("vid-best-*.%s", extForFile)
@@ -1086,9 +1394,11 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 		if !isSnapchat && fmtInfo.URL != "" && strings.TrimSpace(fmtInfo.FormatID) == "" {
 			downloadURL = fmtInfo.URL
 		}
-		err = downloader.DownloadToPath(ctx, downloadURL, fmtInfo.FormatID, tempPath, nil)
+		dlCtx := trackProgress(ctx, redisClient, centrifugoClient, task, progress, 30+int(float64(i+1)/float64(len(selectedFormats))*50))
+		err = downloadClipToPath(dlCtx, downloader, task, downloadURL, fmtInfo.FormatID, tempPath)
 		if err != nil {
 			log.Error().Err(err).Str("format", fmtInfo.FormatID).Msg("failed to download format")
+			lastErr = err
 			continue
 		}
 
@@ -1105,28 +1415,14 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			log.Error().Str("path", tempPath).Msg("downloaded file is empty")
 			continue
 		}
-		resolution := ""
-		if fmtInfo.Height != nil && *fmtInfo.Height > 0 {
-			resolution = fmt.Sprintf("%dp", *fmtInfo.Height)
-		}
-
-		// Use resolution for filename to avoid special chars from format selector
-		safeName := resolution
-		if safeName == "" {
-			safeName = "best"
-		}
 		extForObject := extForFile
 		objectName := fmt.Sprintf("%s/%s/%s.%s", task.PlatformType, task.ID.String(), safeName, extForObject)
 
-		contentType := "video/mp4"
-		if extForObject == "webm" {
-			contentType = "video/webm"
-		}
-
-		minioURL, err := storageClient.UploadFile(ctx, bucketName, objectName, f, fi.Size(), contentType)
+		minioURL, err := storageClient.UploadFile(ctx, bucketName, objectName, f, fi.Size(), videoContentType(extForObject))
 		f.Close()
 		if err != nil {
 			log.Error().Err(err).Msg("failed to upload to minio")
+			lastErr = storageFailure(err)
 			continue
 		}
 		downloadedAny = true
@@ -1146,10 +1442,12 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			Extension:     &ext,
 			FileSize:      &size,
 			EncryptedData: nil,
+			ObjectName:    &objectName,
 		}
 		if err := downloadRepo.AddFile(ctx, downloadFile); err != nil {
 			log.Error().Err(err).Msg("failed to add download file record")
 		}
+		registerStoredFile(ctx, downloadRepo, contentKey, downloadFile)
 
 		// Set primary file path to the first (usually best) format
 		if i == 0 {
@@ -1170,19 +1468,22 @@ func processDownloadTask(ctx context.Context, downloadRepo repository.DownloadRe
 			log.Error().Err(err).Msg("failed to publish complete event")
 		}
 	} else {
-		if isYouTube {
+		if isYouTube && task.Clip == nil {
 			log.Warn().
 				Str("task_id", task.ID.String()).
 				Str("url", task.OriginalURL).
 				Msg("YouTube upload failed; falling back to direct link mode")
-			return processDirectLinkTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, task, info, encryptionKey)
+			return processDirectLinkTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, task, info, keyRing)
 		}
 
-		task.Status = "failed"
-		msg := "all formats failed to download"
-		task.ErrorMessage = &msg
-		_ = markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, fmt.Errorf("%s", msg))
-		return fmt.Errorf("%s", msg)
+		// Keep the cause of the last format's failure so a private video is not
+		// reported, and retried, as a generic error.
+		failErr := infrastructure.NewDownloadError(infrastructure.ErrCodeFormatUnavailable, "", fmt.Errorf("all formats failed to download"))
+		if lastErr != nil {
+			failure := infrastructure.ClassifyError(lastErr)
+			failErr = infrastructure.NewDownloadError(failure.Code, failure.Message, fmt.Errorf("all formats failed to download: %w", lastErr))
+		}
+		return failErr
 	}
 
 	return nil
@@ -1427,6 +1728,17 @@ func processTwitchLimitedTask(ctx context.Context, downloadRepo repository.Downl
 	start := "00:00:00"
 	end := toHMS(sec)
 	section := fmt.Sprintf("*%s-%s", start, end)
+	if task.Clip != nil {
+		// A requested clip is still held to the cap.
+		clip := *task.Clip
+		clip.End = min(clip.End, clip.Start+float64(sec))
+		section = clip.Section()
+	}
+
+	contentKey := storedObjectKey(task, fmt.Sprintf("clip-%ds.mp4", sec))
+	if file := reuseStoredFile(ctx, downloadRepo, task, contentKey); file != nil {
+		return completeWithStoredFile(ctx, downloadRepo, redisClient, centrifugoClient, task, file, "clip")
+	}
 
 	outboundProxy := sanitizeProxyURL(os.Getenv("OUTBOUND_PROXY_URL"))
 	jsRuntime := os.Getenv("YTDLP_JS_RUNTIME")
@@ -1460,6 +1772,8 @@ func processTwitchLimitedTask(ctx context.Context, downloadRepo repository.Downl
 		"--download-sections", section,
 		"-o", tempPath,
 	}
+	dlCtx := trackProgress(ctx, redisClient, centrifugoClient, task, 30, 80)
+	args = append(args, infrastructure.YtDlpProgressArgs(dlCtx)...)
 	if outboundProxy != "" {
 		args = append(args, "--proxy", outboundProxy)
 	}
@@ -1468,33 +1782,34 @@ func processTwitchLimitedTask(ctx context.Context, downloadRepo repository.Downl
 	}
 	args = append(args, task.OriginalURL)
 
-	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	cmd := infrastructure.NewCommand(dlCtx, "yt-dlp", args...)
+	infrastructure.AttachYtDlpProgress(dlCtx, cmd)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
 		if strings.Contains(stderr.String(), "not currently live") {
-			return markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, fmt.Errorf("twitch channel is not live; use VOD/clip URL"))
+			return infrastructure.NewDownloadError(infrastructure.ErrCodeUnsupportedURL, "twitch channel is not live; use VOD/clip URL", nil)
 		}
-		return markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, fmt.Errorf("twitch yt-dlp failed: %w, stderr: %s", err, stderr.String()))
+		return infrastructure.NewDownloadError(infrastructure.ClassifyMessage(stderr.String()), "", fmt.Errorf("twitch yt-dlp failed: %w, stderr: %s", err, stderr.String()))
 	}
 
 	fi, err := os.Stat(tempPath)
 	if err != nil {
-		return markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, err)
+		return err
 	}
 	if fi.Size() == 0 {
-		return markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, fmt.Errorf("downloaded file is empty"))
+		return fmt.Errorf("downloaded file is empty")
 	}
 
 	f, err := os.Open(tempPath)
 	if err != nil {
-		return markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, err)
+		return err
 	}
 	objectName := fmt.Sprintf("%s/%s/%s.%s", "twitch", task.ID.String(), "clip", "mp4")
 	minioURL, err := storageClient.UploadFile(ctx, bucketName, objectName, f, fi.Size(), "video/mp4")
 	f.Close()
 	if err != nil {
-		return markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, err)
+		return storageFailure(err)
 	}
 
 	ext := "mp4"
@@ -1509,8 +1824,10 @@ func processTwitchLimitedTask(ctx context.Context, downloadRepo repository.Downl
 		Extension:     &ext,
 		FileSize:      &size,
 		EncryptedData: nil,
+		ObjectName:    &objectName,
 	}
 	_ = downloadRepo.AddFile(ctx, downloadFile)
+	registerStoredFile(ctx, downloadRepo, contentKey, downloadFile)
 
 	task.FilePath = &minioURL
 	task.FileSize = &size
@@ -1573,7 +1890,7 @@ try:
 finally:
   r.close()
 `
-			cmd := exec.CommandContext(ctx, py, "-c", pyCode, m3u8URL, userAgent, referer, cookieHeader, manifestPath, outboundProxy)
+			cmd := infrastructure.NewCommand(ctx, py, "-c", pyCode, m3u8URL, userAgent, referer, cookieHeader, manifestPath, outboundProxy)
 			var stderr bytes.Buffer
 			cmd.Stderr = &stderr
 			if err := cmd.Run(); err != nil {
@@ -1635,7 +1952,8 @@ finally:
 		outPath,
 	)
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", ffmpegArgs...)
+	cmd := infrastructure.NewCommand(ctx, "ffmpeg", append(infrastructure.FFmpegProgressArgs(ctx), ffmpegArgs...)...)
+	infrastructure.AttachFFmpegProgress(ctx, cmd)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
@@ -1651,7 +1969,7 @@ finally:
 	return nil
 }
 
-func processDirectLinkTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, task *model.DownloadTask, info *infrastructure.VideoInfo, encryptionKey string) error {
+func processDirectLinkTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, task *model.DownloadTask, info *infrastructure.VideoInfo, keyRing *utils.KeyRing) error {
 	// 0. Ensure platform type is correct before we start
 	// This helps with Twitter detection if it was missed earlier
 	lowerURL := strings.ToLower(task.OriginalURL)
@@ -1686,7 +2004,7 @@ func processDirectLinkTask(ctx context.Context, downloadRepo repository.Download
 		strings.Contains(lowerURL, "tiktok.com")
 
 	if isTiktok {
-		return processTikTokEncryptedTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, task, info, encryptionKey)
+		return processTikTokEncryptedTask(ctx, downloadRepo, redisClient, centrifugoClient, downloader, storageClient, bucketName, task, info, keyRing)
 	}
 
 	// Helper function to clean URLs
@@ -1874,7 +2192,7 @@ func processDirectLinkTask(ctx context.Context, downloadRepo repository.Download
 			log.Error().Str("url", *task.FilePath).Msg(errMsg)
 
 			// Mark as failed instead of completed
-			return markTaskFailed(ctx, downloadRepo, redisClient, centrifugoClient, task, fmt.Errorf("%s", errMsg))
+			return fmt.Errorf("%s", errMsg)
 		}
 	}
 
@@ -1941,10 +2259,184 @@ func intPtr(v int) *int {
 	return &v
 }
 
+// formatSelector turns a requested format selection into a yt-dlp -f expression.
+// A codec preference falls back to any codec at the same height, and the short
+// bv/ba/b forms are used because YouTube downloads rewrite "bestaudio" selectors.
+func formatSelector(sel *model.FormatSelection) string {
+	if sel.FormatID != "" {
+		if sel.MergeAudio {
+			return sel.FormatID + "+ba/" + sel.FormatID
+		}
+		return sel.FormatID
+	}
+
+	height := ""
+	if sel.MaxHeight > 0 {
+		height = fmt.Sprintf("[height<=%d]", sel.MaxHeight)
+	}
+
+	codec := ""
+	switch sel.Codec {
+	case "h264":
+		codec = "[vcodec^=avc1]"
+	case "hevc":
+		codec = "[vcodec~='^(hvc1|hev1)']"
+	case "vp9":
+		codec = "[vcodec~='^vp0?9']"
+	case "av1":
+		codec = "[vcodec^=av01]"
+	}
+
+	var parts []string
+	if codec != "" {
+		parts = append(parts, "bv"+height+codec+"+ba", "b"+height+codec)
+	}
+	parts = append(parts, "bv"+height+"+ba", "b"+height)
+	if height != "" {
+		parts = append(parts, "b")
+	}
+	return strings.Join(parts, "/")
+}
+
+func videoContentType(ext string) string {
+	switch ext {
+	case "webm":
+		return "video/webm"
+	case "mkv":
+		return "video/x-matroska"
+	default:
+		return "video/mp4"
+	}
+}
+
+// storedObjectKey identifies a stored file by the canonical video it was produced
+// from and its variant, e.g. "youtube:<id>|720p.mp4", followed by the clip for
+// downloads of part of the video, e.g. "youtube:<id>|720p.mp4|clip-90-150".
+func storedObjectKey(task *model.DownloadTask, variant string) string {
+	key := infrastructure.CanonicalVideoKey(task.OriginalURL) + "|" + variant
+	if task.Clip != nil {
+		key += "|" + task.Clip.Name()
+	}
+	return key
+}
+
+// downloadClipToPath downloads url into outputPath, limited to the clip of task
+// when it has one.
+func downloadClipToPath(ctx context.Context, downloader infrastructure.DownloaderClient, task *model.DownloadTask, url, formatID, outputPath string) error {
+	clipped, err := fetchClipToPath(ctx, downloader, task, url, formatID, outputPath)
+	if err != nil || clipped || task.Clip == nil {
+		return err
+	}
+	return cutClip(ctx, task.Clip, outputPath)
+}
+
+// fetchClipToPath has yt-dlp fetch only the clip of task where the downloader
+// can, and downloads the whole video otherwise. It reports whether the file
+// holds just the clip.
+func fetchClipToPath(ctx context.Context, downloader infrastructure.DownloaderClient, task *model.DownloadTask, url, formatID, outputPath string) (bool, error) {
+	if task.Clip != nil {
+		sectioned, ok := downloader.(interface {
+			DownloadSectionToPath(ctx context.Context, url string, formatID string, outputPath string, section string) error
+		})
+		if ok {
+			err := sectioned.DownloadSectionToPath(ctx, url, formatID, outputPath, task.Clip.Section())
+			if err == nil {
+				return true, nil
+			}
+			if ctx.Err() != nil {
+				return false, err
+			}
+			log.Warn().Err(err).Str("task_id", task.ID.String()).Msg("Section download failed, downloading the whole video to cut it")
+		}
+	}
+	return false, downloader.DownloadToPath(ctx, url, formatID, outputPath, nil)
+}
+
+// cutClip trims the file at path to clip in place. The streams are copied, not
+// re-encoded, so the clip starts at the keyframe at or before clip.Start.
+func cutClip(ctx context.Context, clip *model.ClipRange, path string) error {
+	ext := filepath.Ext(path)
+	clipPath := strings.TrimSuffix(path, ext) + "-clip" + ext
+	defer os.Remove(clipPath)
+
+	args := []string{
+		"-y",
+		"-loglevel", "error",
+		"-ss", strconv.FormatFloat(clip.Start, 'f', -1, 64),
+		"-i", path,
+		"-t", strconv.FormatFloat(clip.Length(), 'f', -1, 64),
+		"-map", "0",
+		"-c", "copy",
+		"-avoid_negative_ts", "make_zero",
+		clipPath,
+	}
+	cmd := infrastructure.NewCommand(ctx, "ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg clip cut failed: %w, stderr: %s", err, stderr.String())
+	}
+	return os.Rename(clipPath, path)
+}
+
+// reuseStoredFile attaches a live stored copy of the same video variant to the task,
+// returning nil when none exists and the file has to be downloaded.
+func reuseStoredFile(ctx context.Context, downloadRepo repository.DownloadRepository, task *model.DownloadTask, contentKey string) *model.DownloadFile {
+	file := &model.DownloadFile{DownloadID: task.ID}
+	acquired, err := downloadRepo.AcquireStoredObject(ctx, contentKey, file)
+	if err != nil {
+		log.Warn().Err(err).Str("task_id", task.ID.String()).Str("content_key", contentKey).Msg("failed to look up stored object")
+		return nil
+	}
+	if !acquired {
+		return nil
+	}
+
+	log.Info().Str("task_id", task.ID.String()).Str("content_key", contentKey).Str("object", getValueOrEmpty(file.ObjectName)).Msg("Reusing stored object")
+	return file
+}
+
+// registerStoredFile makes an uploaded file available for reuse by later downloads.
+func registerStoredFile(ctx context.Context, downloadRepo repository.DownloadRepository, contentKey string, file *model.DownloadFile) {
+	if file.ID == uuid.Nil || file.ObjectName == nil {
+		return
+	}
+	object := &model.StoredObject{
+		ObjectName: *file.ObjectName,
+		ContentKey: contentKey,
+		URL:        file.URL,
+		FormatID:   file.FormatID,
+		Resolution: file.Resolution,
+		Extension:  file.Extension,
+		FileSize:   file.FileSize,
+	}
+	if err := downloadRepo.RegisterStoredObject(ctx, object); err != nil {
+		log.Warn().Err(err).Str("object", object.ObjectName).Msg("failed to register stored object")
+	}
+}
+
+// completeWithStoredFile finishes a single-file task whose file was reused.
+func completeWithStoredFile(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, task *model.DownloadTask, file *model.DownloadFile, format string) error {
+	task.FilePath = &file.URL
+	task.FileSize = file.FileSize
+	task.Format = &format
+	task.Status = "completed"
+	if err := downloadRepo.Update(ctx, task); err != nil {
+		log.Error().Err(err).Str("task_id", task.ID.String()).Msg("failed to update task to completed")
+	}
+
+	task.DownloadFiles = []model.DownloadFile{*file}
+	if err := publishCompletionEvent(ctx, redisClient, centrifugoClient, task); err != nil {
+		log.Error().Err(err).Msg("failed to publish complete event")
+	}
+	return nil
+}
+
 func publishStartEvent(ctx context.Context, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, task *model.DownloadTask) error {
 	event := &model.DownloadEvent{
 		Type:      "download.processing",
 		TaskID:    task.ID,
+		BatchID:   task.BatchID,
 		UserID:    task.UserID,
 		Status:    "processing",
 		CreatedAt: time.Now(),
@@ -1953,7 +2445,100 @@ func publishStartEvent(ctx context.Context, redisClient infrastructure.RedisClie
 	return publishDownloadEvent(ctx, redisClient, centrifugoClient, event)
 }
 
-func processTikTokEncryptedTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, task *model.DownloadTask, info *infrastructure.VideoInfo, encryptionKey string) error {
+// uploadEncryptedStream encrypts src into the chunked AEAD format while it is being
+// uploaded, so the plaintext is never held in memory as a whole. It returns the
+// number of plaintext bytes read from src.
+func uploadEncryptedStream(ctx context.Context, storageClient infrastructure.StorageClient, bucketName, objectName string, src io.Reader, size int64, keyRing *utils.KeyRing) (int64, error) {
+	keyID, keyHex, err := keyRing.ActiveKey()
+	if err != nil {
+		return 0, err
+	}
+	cipherSize := int64(-1)
+	if size >= 0 {
+		cipherSize = utils.ChunkedCiphertextSize(size, keyID)
+	}
+
+	pr, pw := io.Pipe()
+	written := make(chan int64, 1)
+
+	go func() {
+		var n int64
+		enc, err := utils.NewChunkedEncryptWriter(pw, keyHex, keyID)
+		if err == nil {
+			n, err = io.Copy(enc, src)
+			if closeErr := enc.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		written <- n
+		pw.CloseWithError(err)
+	}()
+
+	if _, err := storageClient.UploadFile(ctx, bucketName, objectName, pr, cipherSize, "application/octet-stream"); err != nil {
+		pr.CloseWithError(err)
+		<-written
+		return 0, fmt.Errorf("failed to upload encrypted file: %w", err)
+	}
+	return <-written, nil
+}
+
+// encryptedObjectName names an encrypted object after its key, so re-encrypting it
+// never overwrites the object while it is still being read.
+func encryptedObjectName(folder, ext, keyID string) string {
+	return fmt.Sprintf("%s/video.%s.%s.enc", folder, ext, keyID)
+}
+
+// completeEncryptedTask stores src encrypted in object storage and completes the
+// task with a file pointing at it.
+func completeEncryptedTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, storageClient infrastructure.StorageClient, bucketName string, task *model.DownloadTask, src io.Reader, size int64, ext string, keyRing *utils.KeyRing) error {
+	objectName := encryptedObjectName(fmt.Sprintf("%s/%s", task.PlatformType, task.ID.String()), ext, keyRing.ActiveID())
+	fileSize, err := uploadEncryptedStream(ctx, storageClient, bucketName, objectName, src, size, keyRing)
+	if err != nil {
+		return err
+	}
+	if fileSize == 0 {
+		_ = storageClient.DeleteFile(ctx, bucketName, objectName)
+		return fmt.Errorf("downloaded empty file")
+	}
+
+	fID := "encrypted"
+	resolution := "original"
+	scheme := utils.ChunkedEncryptionScheme
+	// Clients fetch encrypted files through the proxy endpoint, never from storage.
+	dummyURL := fmt.Sprintf("encrypted://%s/video.%s", task.PlatformType, ext)
+
+	dlFile := &model.DownloadFile{
+		DownloadID: task.ID,
+		URL:        dummyURL,
+		FormatID:   &fID,
+		Resolution: &resolution,
+		Extension:  &ext,
+		FileSize:   &fileSize,
+		ObjectName: &objectName,
+		Encryption: &scheme,
+	}
+
+	if err := downloadRepo.AddFile(ctx, dlFile); err != nil {
+		return err
+	}
+
+	task.FilePath = &dummyURL
+	task.FileSize = &fileSize
+	task.Format = &ext
+	task.Status = "completed"
+
+	if err := downloadRepo.Update(ctx, task); err != nil {
+		return err
+	}
+
+	task.DownloadFiles = []model.DownloadFile{*dlFile}
+	if err := publishCompletionEvent(ctx, redisClient, centrifugoClient, task); err != nil {
+		log.Error().Err(err).Msg("failed to publish complete event")
+	}
+	return nil
+}
+
+func processTikTokEncryptedTask(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, downloader infrastructure.DownloaderClient, storageClient infrastructure.StorageClient, bucketName string, task *model.DownloadTask, info *infrastructure.VideoInfo, keyRing *utils.KeyRing) error {
 	log.Info().Str("task_id", task.ID.String()).Msg("Processing TikTok encrypted task")
 
 	outboundProxy := sanitizeProxyURL(os.Getenv("OUTBOUND_PROXY_URL"))
@@ -2001,7 +2586,7 @@ func processTikTokEncryptedTask(ctx context.Context, downloadRepo repository.Dow
 				}
 				args = append(args, task.OriginalURL)
 
-				cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+				cmd := infrastructure.NewCommand(ctx, "yt-dlp", args...)
 				var stderr bytes.Buffer
 				cmd.Stderr = &stderr
 				err := cmd.Run()
@@ -2018,50 +2603,18 @@ func processTikTokEncryptedTask(ctx context.Context, downloadRepo repository.Dow
 					}
 
 					if fi, err := os.Stat(tempPath); err == nil && fi.Size() > 0 {
-						if b, err := os.ReadFile(tempPath); err == nil && len(b) > 0 {
-							encryptedData, err := utils.EncryptData(b, encryptionKey)
-							if err != nil {
-								return fmt.Errorf("failed to encrypt data: %w", err)
-							}
-
-							fileSize := int64(len(b))
-							fID := "encrypted"
-							resolution := "original"
-							ext := "mp4"
-							dummyURL := fmt.Sprintf("encrypted://%s/video.%s", task.PlatformType, ext)
-
-							dlFile := &model.DownloadFile{
-								DownloadID:    task.ID,
-								URL:           dummyURL,
-								FormatID:      &fID,
-								Resolution:    &resolution,
-								Extension:     &ext,
-								FileSize:      &fileSize,
-								EncryptedData: &encryptedData,
-							}
-
-							if err := downloadRepo.AddFile(ctx, dlFile); err != nil {
-								return err
-							}
-
-							task.EncryptedData = &encryptedData
-							task.FilePath = &dummyURL
-							task.FileSize = &fileSize
-							task.Format = &ext
-							task.Status = "completed"
-
-							if err := downloadRepo.Update(ctx, task); err != nil {
-								return err
-							}
-
-							task.DownloadFiles = []model.DownloadFile{*dlFile}
-							if err := publishCompletionEvent(ctx, redisClient, centrifugoClient, task); err != nil {
-								log.Error().Err(err).Msg("failed to publish complete event")
-							}
-
-							log.Info().Str("task_id", task.ID.String()).Int64("original_size", fileSize).Msg("TikTok encrypted task completed (yt-dlp)")
-							return nil
+						f, err := os.Open(tempPath)
+						if err != nil {
+							return fmt.Errorf("failed to open TikTok downloaded file: %w", err)
 						}
+						err = completeEncryptedTask(ctx, downloadRepo, redisClient, centrifugoClient, storageClient, bucketName, task, f, fi.Size(), "mp4", keyRing)
+						f.Close()
+						if err != nil {
+							return err
+						}
+
+						log.Info().Str("task_id", task.ID.String()).Int64("original_size", fi.Size()).Msg("TikTok encrypted task completed (yt-dlp)")
+						return nil
 					}
 				}
 
@@ -2225,7 +2778,7 @@ finally:
     r.close()
 `
 			outboundProxy := sanitizeProxyURL(os.Getenv("OUTBOUND_PROXY_URL"))
-			curlReq := exec.CommandContext(ctx, py, "-c", pyCode, targetURL, userAgent, "https://www.tiktok.com/", cookieHeader, tempPath, outboundProxy)
+			curlReq := infrastructure.NewCommand(ctx, py, "-c", pyCode, targetURL, userAgent, "https://www.tiktok.com/", cookieHeader, tempPath, outboundProxy)
 			var curlStderr bytes.Buffer
 			curlReq.Stderr = &curlStderr
 			var curlStdout bytes.Buffer
@@ -2235,121 +2788,47 @@ finally:
 				return fmt.Errorf("failed to download TikTok video: status %d", resp.StatusCode)
 			}
 
-			data, err := os.ReadFile(tempPath)
+			f, err := os.Open(tempPath)
 			if err != nil {
 				return fmt.Errorf("failed to read TikTok downloaded file: %w", err)
 			}
-			if len(data) == 0 {
-				return fmt.Errorf("downloaded empty file")
-			}
-
-			encryptedData, err := utils.EncryptData(data, encryptionKey)
+			defer f.Close()
+			fi, err := f.Stat()
 			if err != nil {
-				return fmt.Errorf("failed to encrypt data: %w", err)
-			}
-
-			fileSize := int64(len(data))
-			fID := "encrypted"
-			resolution := "original"
-			dummyURL := fmt.Sprintf("encrypted://%s/video.%s", task.PlatformType, ext)
-
-			dlFile := &model.DownloadFile{
-				DownloadID:    task.ID,
-				URL:           dummyURL,
-				FormatID:      &fID,
-				Resolution:    &resolution,
-				Extension:     &ext,
-				FileSize:      &fileSize,
-				EncryptedData: &encryptedData,
+				return fmt.Errorf("failed to read TikTok downloaded file: %w", err)
 			}
 
-			if err := downloadRepo.AddFile(ctx, dlFile); err != nil {
+			if err := completeEncryptedTask(ctx, downloadRepo, redisClient, centrifugoClient, storageClient, bucketName, task, f, fi.Size(), ext, keyRing); err != nil {
 				return err
 			}
 
-			task.EncryptedData = &encryptedData
-			task.FilePath = &dummyURL
-			task.FileSize = &fileSize
-			task.Format = &ext
-			task.Status = "completed"
-
-			if err := downloadRepo.Update(ctx, task); err != nil {
-				return err
-			}
-
-			task.DownloadFiles = []model.DownloadFile{*dlFile}
-			if err := publishCompletionEvent(ctx, redisClient, centrifugoClient, task); err != nil {
-				log.Error().Err(err).Msg("failed to publish complete event")
-			}
-
-			log.Info().Str("task_id", task.ID.String()).Int64("original_size", fileSize).Msg("TikTok encrypted task completed (curl_cffi)")
+			log.Info().Str("task_id", task.ID.String()).Int64("original_size", *task.FileSize).Msg("TikTok encrypted task completed (curl_cffi)")
 			return nil
 		}
 		return fmt.Errorf("failed to download TikTok video: status %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read TikTok response body: %w", err)
-	}
-
+	var body io.Reader = resp.Body
 	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(strings.ToLower(contentType), "text/html") && len(body) < 200*1024 {
-		return fmt.Errorf("failed to download TikTok video: got html response status %d", resp.StatusCode)
-	}
-
-	data := body
-
-	if len(data) == 0 {
-		return fmt.Errorf("downloaded empty file")
-	}
-
-	encryptedData, err := utils.EncryptData(data, encryptionKey)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt data: %w", err)
-	}
-
-	// 4. Save to DB
-	// We store original file size for metadata, but the data is encrypted
-	fileSize := int64(len(data))
-
-	fID := "encrypted"
-	resolution := "original"
-	// Use a dummy URL that indicates it's encrypted
-	dummyURL := fmt.Sprintf("encrypted://%s/video.%s", task.PlatformType, ext)
-
-	dlFile := &model.DownloadFile{
-		DownloadID:    task.ID,
-		URL:           dummyURL,
-		FormatID:      &fID,
-		Resolution:    &resolution,
-		Extension:     &ext,
-		FileSize:      &fileSize,
-		EncryptedData: &encryptedData,
+	if strings.Contains(strings.ToLower(contentType), "text/html") {
+		// Small HTML bodies are block pages rather than media; peek before streaming.
+		head, err := io.ReadAll(io.LimitReader(resp.Body, 200*1024))
+		if err != nil {
+			return fmt.Errorf("failed to read TikTok response body: %w", err)
+		}
+		if len(head) < 200*1024 {
+			return fmt.Errorf("failed to download TikTok video: got html response status %d", resp.StatusCode)
+		}
+		body = io.MultiReader(bytes.NewReader(head), resp.Body)
 	}
 
-	if err := downloadRepo.AddFile(ctx, dlFile); err != nil {
+	// The response is encrypted chunk by chunk as it arrives and uploaded alongside.
+	// ContentLength is -1 when unknown, e.g. for a transparently decompressed body.
+	if err := completeEncryptedTask(ctx, downloadRepo, redisClient, centrifugoClient, storageClient, bucketName, task, body, resp.ContentLength, ext, keyRing); err != nil {
 		return err
 	}
 
-	task.EncryptedData = &encryptedData
-	task.FilePath = &dummyURL
-	task.FileSize = &fileSize
-	task.Format = &ext
-	task.Status = "completed"
-
-	if err := downloadRepo.Update(ctx, task); err != nil {
-		return err
-	}
-
-	// Publish completion
-	// Need to populate DownloadFiles for event
-	task.DownloadFiles = []model.DownloadFile{*dlFile}
-	if err := publishCompletionEvent(ctx, redisClient, centrifugoClient, task); err != nil {
-		log.Error().Err(err).Msg("failed to publish complete event")
-	}
-
-	log.Info().Str("task_id", task.ID.String()).Int64("original_size", fileSize).Msg("TikTok encrypted task completed")
+	log.Info().Str("task_id", task.ID.String()).Int64("original_size", *task.FileSize).Msg("TikTok encrypted task completed")
 	return nil
 }
 
@@ -2357,6 +2836,7 @@ func publishProgressEvent(ctx context.Context, redisClient infrastructure.RedisC
 	event := &model.DownloadEvent{
 		Type:      "download.processing",
 		TaskID:    task.ID,
+		BatchID:   task.BatchID,
 		UserID:    task.UserID,
 		Status:    "processing",
 		Progress:  &progress,
@@ -2366,6 +2846,60 @@ func publishProgressEvent(ctx context.Context, redisClient infrastructure.RedisC
 	return publishDownloadEvent(ctx, redisClient, centrifugoClient, event)
 }
 
+// progressInterval is the least time between two transfer progress events of
+// a task; yt-dlp prints several updates a second.
+const progressInterval = time.Second
+
+// trackProgress returns a context whose yt-dlp and ffmpeg runs publish their
+// transfer progress for task, scaled into the from-to part of the overall
+// progress. The overall value never moves back, also when yt-dlp starts over on
+// the audio of a merged format.
+func trackProgress(ctx context.Context, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, task *model.DownloadTask, from, to int) context.Context {
+	var duration time.Duration
+	if task.Clip != nil {
+		duration = time.Duration(task.Clip.Length() * float64(time.Second))
+	} else if task.Duration != nil {
+		duration = time.Duration(*task.Duration) * time.Second
+	}
+
+	var lastPublished time.Time
+	overall := from
+	return infrastructure.WithProgress(ctx, duration, func(p infrastructure.TransferProgress) {
+		if p.Percent >= 0 {
+			overall = max(overall, from+int(p.Percent*float64(to-from)/100))
+		}
+		now := time.Now()
+		if now.Sub(lastPublished) < progressInterval && p.Percent < 100 {
+			return
+		}
+		lastPublished = now
+
+		progress := overall
+		event := &model.DownloadEvent{
+			Type:            "download.processing",
+			TaskID:          task.ID,
+			BatchID:         task.BatchID,
+			UserID:          task.UserID,
+			Status:          "processing",
+			Progress:        &progress,
+			DownloadedBytes: &p.DownloadedBytes,
+			CreatedAt:       now,
+		}
+		if p.TotalBytes > 0 {
+			event.TotalBytes = &p.TotalBytes
+		}
+		if p.Speed > 0 {
+			event.Speed = &p.Speed
+		}
+		if p.ETA >= 0 {
+			event.ETA = &p.ETA
+		}
+		if err := publishDownloadEvent(ctx, redisClient, centrifugoClient, event); err != nil {
+			log.Error().Err(err).Str("task_id", task.ID.String()).Msg("failed to publish transfer progress event")
+		}
+	})
+}
+
 func publishCompletionEvent(ctx context.Context, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, task *model.DownloadTask) error {
 	var payload *model.DownloadPayload
 
@@ -2432,6 +2966,7 @@ func publishCompletionEvent(ctx context.Context, redisClient infrastructure.Redi
 	event := &model.DownloadEvent{
 		Type:      "download.completed",
 		TaskID:    task.ID,
+		BatchID:   task.BatchID,
 		UserID:    task.UserID,
 		Status:    "completed",
 		Payload:   payload,
@@ -2448,10 +2983,75 @@ func getValueOrEmpty(ptr *string) string {
 	return *ptr
 }
 
+// downloadTaskResult stops asynq from retrying failures that would fail the
+// same way again, such as private or removed videos.
+func downloadTaskResult(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if dlErr := taskFailure(ctx, err); !dlErr.Retryable() {
+		return fmt.Errorf("%w: %w", asynq.SkipRetry, err)
+	}
+	return err
+}
+
+// taskFailure classifies err, trusting the task context over the message when
+// the task was canceled or ran out of time: a killed ffmpeg only says "signal:
+// killed".
+func taskFailure(ctx context.Context, err error) *infrastructure.DownloadError {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return infrastructure.NewDownloadError(infrastructure.ErrCodeCanceled, "", err)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.As(err, new(*infrastructure.DownloadError)) {
+		return infrastructure.ClassifyError(errors.Join(ctxErr, err))
+	}
+	return infrastructure.ClassifyError(err)
+}
+
+// willRetry reports whether asynq runs the task again after this failure. Outside
+// a task handler there is nothing to retry.
+func willRetry(ctx context.Context, dlErr *infrastructure.DownloadError) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	maxRetry, ok2 := asynq.GetMaxRetry(ctx)
+	return ok && ok2 && dlErr.Retryable() && retried < maxRetry
+}
+
+// storageFailure marks an upload error as a storage failure, which is retried:
+// the file was fetched fine and only MinIO failed.
+func storageFailure(err error) error {
+	return infrastructure.NewDownloadError(infrastructure.ErrCodeStorage, "", fmt.Errorf("failed to upload to storage: %w", err))
+}
+
+// markTaskFailed records a failed attempt. While asynq still has retries left
+// the download goes back to queued and clients get a download.retrying event;
+// only the final attempt marks it failed.
 func markTaskFailed(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, task *model.DownloadTask, err error) error {
-	task.Status = "failed"
 	errMsg := err.Error()
 	task.ErrorMessage = &errMsg
+	dlErr := taskFailure(ctx, err)
+	task.ErrorCode = &dlErr.Code
+	// A canceled or timed out task still has to record why it stopped.
+	ctx = context.WithoutCancel(ctx)
+
+	if willRetry(ctx, dlErr) {
+		task.Status = "queued"
+		if updateErr := downloadRepo.Update(ctx, task); updateErr != nil {
+			return updateErr
+		}
+		return publishDownloadEvent(ctx, redisClient, centrifugoClient, &model.DownloadEvent{
+			Type:      "download.retrying",
+			TaskID:    task.ID,
+			BatchID:   task.BatchID,
+			UserID:    task.UserID,
+			Status:    "queued",
+			Message:   dlErr.Message,
+			Error:     errMsg,
+			ErrorCode: dlErr.Code,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	task.Status = "failed"
 
 	if updateErr := downloadRepo.Update(ctx, task); updateErr != nil {
 		return updateErr
@@ -2460,15 +3060,81 @@ func markTaskFailed(ctx context.Context, downloadRepo repository.DownloadReposit
 	event := &model.DownloadEvent{
 		Type:      "download.failed",
 		TaskID:    task.ID,
+		BatchID:   task.BatchID,
 		UserID:    task.UserID,
 		Status:    "failed",
+		Message:   dlErr.Message,
 		Error:     errMsg,
+		ErrorCode: dlErr.Code,
 		CreatedAt: time.Now(),
 	}
 
 	return publishDownloadEvent(ctx, redisClient, centrifugoClient, event)
 }
 
+// canceledBeforeStart reports whether the download was canceled while its task
+// was waiting, in which case there is nothing left to do. The payload carries
+// the download as it was enqueued, so the status is read again.
+func canceledBeforeStart(ctx context.Context, downloadRepo repository.DownloadRepository, task *model.DownloadTask) bool {
+	current, err := downloadRepo.FindByID(ctx, task.ID)
+	if err != nil {
+		log.Warn().Err(err).Str("task_id", task.ID.String()).Msg("failed to check download status before start")
+		return false
+	}
+	if current.Status != "canceled" {
+		return false
+	}
+	log.Info().Str("task_id", task.ID.String()).Msg("Skipping canceled download")
+	return true
+}
+
+// markTaskCanceled records a download stopped while it was running. Whatever it
+// had already uploaded is released and removed from MinIO unless another
+// download shares it; the temp files go with the deferred cleanups.
+func markTaskCanceled(ctx context.Context, downloadRepo repository.DownloadRepository, redisClient infrastructure.RedisClient, centrifugoClient infrastructure.CentrifugoClient, storageClient infrastructure.StorageClient, bucketName string, task *model.DownloadTask) error {
+	ctx = context.WithoutCancel(ctx)
+
+	// The owner's request has already marked it; this covers cancellations
+	// from the admin task console.
+	message := "canceled"
+	if _, err := downloadRepo.MarkCanceled(ctx, task.ID, message); err != nil {
+		return err
+	}
+	if current, err := downloadRepo.FindByID(ctx, task.ID); err == nil {
+		task = current
+	}
+
+	if err := downloadRepo.ReleaseFiles(ctx, task.ID); err != nil {
+		log.Error().Err(err).Str("task_id", task.ID.String()).Msg("failed to release files of canceled download")
+	} else {
+		purgeUnreferencedObjects(ctx, downloadRepo, storageClient, bucketName)
+	}
+
+	prefix := fmt.Sprintf("%s/%s/", task.PlatformType, task.ID.String())
+	remaining, err := downloadRepo.CountStoredObjectsByPrefix(ctx, prefix)
+	if err != nil {
+		log.Error().Err(err).Str("task_id", task.ID.String()).Msg("failed to check stored objects of canceled download")
+	} else if remaining == 0 {
+		if err := storageClient.DeleteFolder(ctx, bucketName, prefix); err != nil {
+			log.Error().Err(err).Str("task_id", task.ID.String()).Msg("failed to delete folder of canceled download")
+		}
+	}
+
+	if task.ErrorMessage != nil {
+		message = *task.ErrorMessage
+	}
+	return publishDownloadEvent(ctx, redisClient, centrifugoClient, &model.DownloadEvent{
+		Type:      "download.canceled",
+		TaskID:    task.ID,
+		BatchID:   task.BatchID,
+		UserID:    task.UserID,
+		Status:    "canceled",
+		Message:   message,
+		ErrorCode: infrastructure.ErrCodeCanceled,
+		CreatedAt: time.Now(),
+	})
+}
+
 /**
  * cleanLogs truncates the log files of the given containers to size 0, effectively cleaning them.
  *
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+	"github.com/user/video-downloader-backend/pkg/utils"
+)
+
+type reencryptStats struct {
+	scanned   int
+	rewritten int
+	failed    int
+}
+
+// runReencrypt moves every encrypted download and two-factor secret to the active
+// key of the ring, batch by batch. Data already under the active key is skipped,
+// so an interrupted run can simply be started again.
+//
+//	worker reencrypt [-batch 100]
+func runReencrypt(ctx context.Context, args []string, downloadRepo repository.DownloadRepository, twoFactorRepo repository.TwoFactorRepository, storageClient infrastructure.StorageClient, bucketName string, keyRing *utils.KeyRing) error {
+	fs := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+	batchSize := fs.Int("batch", 100, "rows per batch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	activeID, _, err := keyRing.ActiveKey()
+	if err != nil {
+		return err
+	}
+	log.Info().Str("active_key", activeID).Int("batch", *batchSize).Msg("Starting re-encryption")
+
+	var tasks reencryptStats
+	after := uuid.Nil
+	for {
+		batch, err := downloadRepo.FindEncryptedTasks(ctx, after, *batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		updates := make(map[uuid.UUID][]byte)
+		for _, task := range batch {
+			after = task.ID
+			tasks.scanned++
+			if data, ok := reencryptBlob(keyRing, *task.EncryptedData); ok {
+				updates[task.ID] = data
+			} else if data == nil {
+				log.Warn().Str("task_id", task.ID.String()).Msg("Failed to decrypt download data, leaving it untouched")
+				tasks.failed++
+			}
+		}
+		if err := downloadRepo.UpdateTaskEncryptedData(ctx, updates); err != nil {
+			return err
+		}
+		tasks.rewritten += len(updates)
+	}
+
+	var files reencryptStats
+	after = uuid.Nil
+	for {
+		batch, err := downloadRepo.FindEncryptedFiles(ctx, after, *batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		updates := make(map[uuid.UUID][]byte)
+		for _, file := range batch {
+			after = file.ID
+			files.scanned++
+
+			if file.EncryptedData != nil {
+				if data, ok := reencryptBlob(keyRing, *file.EncryptedData); ok {
+					updates[file.ID] = data
+				} else if data == nil {
+					log.Warn().Str("file_id", file.ID.String()).Msg("Failed to decrypt file data, leaving it untouched")
+					files.failed++
+				}
+			}
+
+			if file.Encryption != nil && file.ObjectName != nil {
+				rewritten, err := reencryptObject(ctx, downloadRepo, storageClient, bucketName, keyRing, file)
+				if err != nil {
+					log.Warn().Err(err).Str("file_id", file.ID.String()).Str("object", *file.ObjectName).Msg("Failed to re-encrypt stored object")
+					files.failed++
+				} else if rewritten {
+					files.rewritten++
+				}
+			}
+		}
+		if err := downloadRepo.UpdateFileEncryptedData(ctx, updates); err != nil {
+			return err
+		}
+		files.rewritten += len(updates)
+	}
+
+	var secrets reencryptStats
+	after = uuid.Nil
+	for {
+		batch, err := twoFactorRepo.FindSecrets(ctx, after, *batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		updates := make(map[uuid.UUID][]byte)
+		for _, secret := range batch {
+			after = secret.UserID
+			secrets.scanned++
+			if data, ok := reencryptBlob(keyRing, secret.Secret); ok {
+				updates[secret.UserID] = data
+			} else if data == nil {
+				log.Warn().Str("user_id", secret.UserID.String()).Msg("Failed to decrypt two-factor secret, leaving it untouched")
+				secrets.failed++
+			}
+		}
+		if err := twoFactorRepo.UpdateSecrets(ctx, updates); err != nil {
+			return err
+		}
+		secrets.rewritten += len(updates)
+	}
+
+	log.Info().
+		Int("downloads_scanned", tasks.scanned).
+		Int("downloads_rewritten", tasks.rewritten).
+		Int("downloads_failed", tasks.failed).
+		Int("files_scanned", files.scanned).
+		Int("files_rewritten", files.rewritten).
+		Int("files_failed", files.failed).
+		Int("two_factor_secrets_scanned", secrets.scanned).
+		Int("two_factor_secrets_rewritten", secrets.rewritten).
+		Int("two_factor_secrets_failed", secrets.failed).
+		Msg("Re-encryption finished")
+
+	if failed := tasks.failed + files.failed + secrets.failed; failed > 0 {
+		return fmt.Errorf("%d rows could not be re-encrypted", failed)
+	}
+	return nil
+}
+
+// reencryptBlob returns the blob sealed with the active key and true when it had to
+// be rewritten. A blob already under the active key yields (data, false); one that
+// cannot be decrypted yields (nil, false).
+func reencryptBlob(keyRing *utils.KeyRing, data []byte) ([]byte, bool) {
+	if utils.BlobKeyID(data) == keyRing.ActiveID() {
+		return data, false
+	}
+
+	plain, err := keyRing.Decrypt(data)
+	if err != nil {
+		return nil, false
+	}
+	sealed, err := keyRing.Encrypt(plain)
+	if err != nil {
+		return nil, false
+	}
+	return sealed, true
+}
+
+// reencryptObject streams a chunked object through decryption with its old key and
+// encryption with the active one into a new object, then swaps the file over to it.
+func reencryptObject(ctx context.Context, downloadRepo repository.DownloadRepository, storageClient infrastructure.StorageClient, bucketName string, keyRing *utils.KeyRing, file *model.DownloadFile) (bool, error) {
+	objectName := *file.ObjectName
+	info, err := storageClient.StatFile(ctx, bucketName, objectName)
+	if err != nil {
+		return false, err
+	}
+
+	headLen := int64(utils.ChunkedHeaderMaxSize)
+	if info.Size < headLen {
+		headLen = info.Size
+	}
+	rc, err := storageClient.GetFileRange(ctx, bucketName, objectName, 0, headLen)
+	if err != nil {
+		return false, err
+	}
+	head := make([]byte, headLen)
+	_, err = io.ReadFull(rc, head)
+	rc.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+
+	header, err := utils.ParseChunkedHeader(head)
+	if err != nil {
+		return false, err
+	}
+	if header.KeyID == keyRing.ActiveID() {
+		return false, nil
+	}
+	oldKey, err := keyRing.Key(header.KeyID)
+	if err != nil {
+		return false, err
+	}
+	plainSize, err := header.PlaintextSize(info.Size)
+	if err != nil {
+		return false, err
+	}
+
+	cipherOffset, cipherLength := header.ChunkSpan(0, plainSize, info.Size)
+	body, err := storageClient.GetFileRange(ctx, bucketName, objectName, cipherOffset, cipherLength)
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	plain, err := utils.NewChunkedDecryptReader(body, oldKey, header, info.Size, 0, plainSize)
+	if err != nil {
+		return false, err
+	}
+
+	ext := "mp4"
+	if file.Extension != nil && *file.Extension != "" {
+		ext = *file.Extension
+	}
+	newObjectName := encryptedObjectName(path.Dir(objectName), ext, keyRing.ActiveID())
+	if _, err := uploadEncryptedStream(ctx, storageClient, bucketName, newObjectName, plain, plainSize, keyRing); err != nil {
+		return false, err
+	}
+
+	replaced, err := downloadRepo.ReplaceFileObject(ctx, file.ID, objectName, newObjectName)
+	if err != nil || !replaced {
+		// The file was deleted or changed meanwhile; drop the copy instead.
+		_ = storageClient.DeleteFile(ctx, bucketName, newObjectName)
+		return false, err
+	}
+	if err := storageClient.DeleteFile(ctx, bucketName, objectName); err != nil {
+		log.Warn().Err(err).Str("object", objectName).Msg("Failed to delete object encrypted with the retired key")
+	}
+	return true, nil
+}
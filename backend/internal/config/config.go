@@ -21,7 +21,10 @@ type Config struct {
 	RedisAddr     string
 	RedisPassword string
 	JWTSecret     string
-	JWTExpiryHour string
+	// JWTAccessTTLMinutes defaults to JWT_EXPIRY_HOUR (24h) until every client
+	// renews its access token with a refresh token; then it can be shortened.
+	JWTAccessTTLMinutes int
+	JWTRefreshTTLDays   int
 	// MinIO Config
 	MinioEndpoint  string
 	MinioAccessKey string
@@ -31,6 +34,10 @@ type Config struct {
 	// Encryption Config
 	BCryptCost    int
 	EncryptionKey string
+	// EncryptionKeys is a key ring of the form "id:hex,id:hex"; EncryptionKeyID
+	// selects the key new data is written with.
+	EncryptionKeys  string
+	EncryptionKeyID string
 	// Centrifugo Config
 	CentrifugoURL         string
 	CentrifugoAPIKey      string
@@ -48,6 +55,30 @@ type Config struct {
 	YoutubePlayerClient   string
 	YoutubeCustomDisabled bool
 
+	// Free plan download quota; zero means unlimited
+	QuotaFreeDailyDownloads int
+	QuotaFreeMaxHeight      int
+	QuotaFreeMaxDuration    int
+
+	// Receipt verification; ReceiptVerifier "fake" accepts any purchase and is
+	// meant for development only
+	ReceiptVerifier           string
+	GooglePlayCredentialsFile string
+	AppStoreIssuerID          string
+	AppStoreKeyID             string
+	AppStorePrivateKeyFile    string
+	AppStoreRootCertFile      string
+	AppStoreSandbox           bool
+
+	// Store webhooks. Google Play RTDN pushes are authenticated by the Pub/Sub
+	// OIDC token when an audience is set, otherwise by a shared ?token=
+	GooglePlayPubSubAudience       string
+	GooglePlayPubSubServiceAccount string
+	GooglePlayWebhookToken         string
+
+	// GeoIPDatabase is an IP-to-country CSV used by the admin analytics
+	GeoIPDatabase string
+
 	// Telegram bot
 	TelegramBotToken      string
 	TelegramChatID        string
@@ -71,7 +102,8 @@ func LoadConfig() *Config {
 		RedisAddr:             getEnv("REDIS_ADDR", "localhost:6379"),
 		RedisPassword:         getEnv("REDIS_PASSWORD", ""),
 		JWTSecret:             getEnv("JWT_SECRET", "secret"),
-		JWTExpiryHour:         getEnv("JWT_EXPIRY_HOUR", "24"),
+		JWTAccessTTLMinutes:   getEnvInt("JWT_ACCESS_TTL_MINUTES", getEnvInt("JWT_EXPIRY_HOUR", 24)*60),
+		JWTRefreshTTLDays:     getEnvInt("JWT_REFRESH_TTL_DAYS", 30),
 		MinioEndpoint:         getEnv("MINIO_ENDPOINT", "localhost:9000"),
 		MinioAccessKey:        getEnv("MINIO_ACCESS_KEY", "minioadmin"),
 		MinioSecretKey:        getEnv("MINIO_SECRET_KEY", "minioadmin"),
@@ -79,6 +111,8 @@ func LoadConfig() *Config {
 		MinioUseSSL:           getEnvBool("MINIO_USE_SSL", false),
 		BCryptCost:            getEnvInt("BCRYPT_COST", 10),
 		EncryptionKey:         getEnv("ENCRYPTION_KEY", "secret"),
+		EncryptionKeys:        getEnv("ENCRYPTION_KEYS", ""),
+		EncryptionKeyID:       getEnv("ENCRYPTION_KEY_ID", ""),
 		CentrifugoURL:         getEnv("CENTRIFUGE_URL", "ws://infrastructure-centrifugo:8000/connection/websocket"),
 		CentrifugoAPIKey:      getEnv("CENTRIFUGO_API_KEY", ""),
 		CentrifugoTokenSecret: getEnv("CENTRIFUGO_TOKEN_SECRET", ""),
@@ -94,6 +128,25 @@ func LoadConfig() *Config {
 		YoutubeUseCookies:     getEnv("YOUTUBE_USE_COOKIES", ""),
 		YoutubePlayerClient:   getEnv("YOUTUBE_PLAYER_CLIENT", ""),
 		YoutubeCustomDisabled: getEnvBool("YOUTUBE_CUSTOM_DISABLED", false),
+
+		QuotaFreeDailyDownloads: getEnvInt("QUOTA_FREE_DAILY_DOWNLOADS", 10),
+		QuotaFreeMaxHeight:      getEnvInt("QUOTA_FREE_MAX_HEIGHT", 720),
+		QuotaFreeMaxDuration:    getEnvInt("QUOTA_FREE_MAX_DURATION_SECONDS", 3600),
+
+		ReceiptVerifier:           getEnv("RECEIPT_VERIFIER", "store"),
+		GooglePlayCredentialsFile: getEnv("GOOGLE_PLAY_CREDENTIALS_FILE", ""),
+		AppStoreIssuerID:          getEnv("APP_STORE_ISSUER_ID", ""),
+		AppStoreKeyID:             getEnv("APP_STORE_KEY_ID", ""),
+		AppStorePrivateKeyFile:    getEnv("APP_STORE_PRIVATE_KEY_FILE", ""),
+		AppStoreRootCertFile:      getEnv("APP_STORE_ROOT_CERT_FILE", ""),
+		AppStoreSandbox:           getEnvBool("APP_STORE_SANDBOX", false),
+
+		GooglePlayPubSubAudience:       getEnv("GOOGLE_PLAY_PUBSUB_AUDIENCE", ""),
+		GooglePlayPubSubServiceAccount: getEnv("GOOGLE_PLAY_PUBSUB_SERVICE_ACCOUNT", ""),
+		GooglePlayWebhookToken:         getEnv("GOOGLE_PLAY_WEBHOOK_TOKEN", ""),
+
+		GeoIPDatabase: getEnv("GEOIP_DATABASE", ""),
+
 		// Telegram bot
 		TelegramBotToken:      getEnv("TELEGRAM_BOT_TOKEN", ""),
 		TelegramChatID:        getEnv("TELEGRAM_CHAT_ID", ""),
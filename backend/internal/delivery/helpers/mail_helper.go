@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/user/video-downloader-backend/internal/dto"
 	"github.com/user/video-downloader-backend/internal/model"
@@ -16,6 +17,9 @@ import (
 
 type MailHelper interface {
 	SendResetPasswordEmail(ctx context.Context, email, resetToken string) error
+	// SendVerificationEmail sends the link and the code that confirm an email
+	// address; both expire after expiresIn.
+	SendVerificationEmail(ctx context.Context, email, verifyToken, code string, expiresIn time.Duration) error
 	SendContactEmail(ctx context.Context, payload *dto.ContactRequest) error
 }
 
@@ -33,28 +37,46 @@ func (m *mailHelper) SendResetPasswordEmail(ctx context.Context, email, resetTok
 		return err
 	}
 
-	settingEmail := setting.EMAIL
-	if !settingEmail.SMTPEnabled {
-		return fmt.Errorf("SMTP is disabled in settings")
+	resetURL := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(setting.WEBSITE.SiteURL, "/"), resetToken)
+	siteName := setting.WEBSITE.SiteName
+	if siteName == "" {
+		siteName = "Simontok"
 	}
 
-	// Validate required SMTP settings
-	if settingEmail.SMTPHost == "" || settingEmail.SMTPPort == 0 || settingEmail.SMTPUser == "" || settingEmail.SMTPPassword == "" {
-		return fmt.Errorf("incomplete SMTP configuration")
+	subject := fmt.Sprintf("Reset Password - %s", siteName)
+	return m.send(setting.EMAIL, email, subject, m.getResetPasswordHTML(siteName, resetURL))
+}
+
+func (m *mailHelper) SendVerificationEmail(ctx context.Context, email, verifyToken, code string, expiresIn time.Duration) error {
+	setting, err := m.GetPublicSettings(ctx)
+	if err != nil {
+		return err
 	}
 
-	resetURL := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(setting.WEBSITE.SiteURL, "/"), resetToken)
+	verifyURL := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(setting.WEBSITE.SiteURL, "/"), verifyToken)
 	siteName := setting.WEBSITE.SiteName
 	if siteName == "" {
 		siteName = "Simontok"
 	}
 
-	subject := fmt.Sprintf("Reset Password - %s", siteName)
-	body := m.getResetPasswordHTML(siteName, resetURL)
+	subject := fmt.Sprintf("Verify Your Email - %s", siteName)
+	return m.send(setting.EMAIL, email, subject, m.getVerificationHTML(siteName, verifyURL, code, expiresIn))
+}
+
+// send delivers an HTML email to a single recipient with the SMTP settings.
+func (m *mailHelper) send(settingEmail model.SettingEmail, to, subject, body string) error {
+	if !settingEmail.SMTPEnabled {
+		return fmt.Errorf("SMTP is disabled in settings")
+	}
+
+	// Validate required SMTP settings
+	if settingEmail.SMTPHost == "" || settingEmail.SMTPPort == 0 || settingEmail.SMTPUser == "" || settingEmail.SMTPPassword == "" {
+		return fmt.Errorf("incomplete SMTP configuration")
+	}
 
 	headers := make(map[string]string)
 	headers["From"] = fmt.Sprintf("%s <%s>", settingEmail.FromName, settingEmail.FromEmail)
-	headers["To"] = email
+	headers["To"] = to
 	headers["Subject"] = subject
 	headers["MIME-Version"] = "1.0"
 	headers["Content-Type"] = "text/html; charset=\"UTF-8\""
@@ -73,7 +95,6 @@ func (m *mailHelper) SendResetPasswordEmail(ctx context.Context, email, resetTok
 
 	// If port is 465, use implicit TLS
 	if settingEmail.SMTPPort == 465 {
-		// Handle TLS config for non-localhost
 		tlsConfig := &tls.Config{
 			InsecureSkipVerify: false,
 			ServerName:         settingEmail.SMTPHost,
@@ -98,7 +119,7 @@ func (m *mailHelper) SendResetPasswordEmail(ctx context.Context, email, resetTok
 			return fmt.Errorf("failed to set sender: %w", err)
 		}
 
-		if err = c.Rcpt(email); err != nil {
+		if err = c.Rcpt(to); err != nil {
 			return fmt.Errorf("failed to set recipient: %w", err)
 		}
 
@@ -107,24 +128,20 @@ func (m *mailHelper) SendResetPasswordEmail(ctx context.Context, email, resetTok
 			return fmt.Errorf("failed to create data writer: %w", err)
 		}
 
-		_, err = w.Write([]byte(message))
-		if err != nil {
+		if _, err = w.Write([]byte(message)); err != nil {
 			return fmt.Errorf("failed to write body: %w", err)
 		}
 
-		err = w.Close()
-		if err != nil {
+		if err = w.Close(); err != nil {
 			return fmt.Errorf("failed to close data writer: %w", err)
 		}
 
 		return nil
+	}
 
-	} else {
-		// Standard smtp.SendMail for port 587 (STARTTLS) or 25 (Plain)
-		err = smtp.SendMail(addr, auth, settingEmail.FromEmail, []string{email}, []byte(message))
-		if err != nil {
-			return fmt.Errorf("failed to send email: %w", err)
-		}
+	// Standard smtp.SendMail for port 587 (STARTTLS) or 25 (Plain)
+	if err := smtp.SendMail(addr, auth, settingEmail.FromEmail, []string{to}, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
 	}
 
 	return nil
@@ -279,6 +296,50 @@ func (m *mailHelper) getResetPasswordHTML(siteName, resetURL string) string {
 `, siteName, resetURL, resetURL, resetURL, 2025, siteName) // Hardcoded year for simplicity or use time.Now().Year()
 }
 
+func (m *mailHelper) getVerificationHTML(siteName, verifyURL, code string, expiresIn time.Duration) string {
+	return fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; background-color: #f9f9f9; }
+        .header { background-color: #007bff; color: white; padding: 20px; text-align: center; border-radius: 5px 5px 0 0; }
+        .content { background-color: white; padding: 30px; border-radius: 0 0 5px 5px; box-shadow: 0 2px 5px rgba(0,0,0,0.1); }
+        .button { display: inline-block; padding: 12px 24px; background-color: #007bff; color: white; text-decoration: none; border-radius: 4px; margin-top: 20px; font-weight: bold; }
+        .code { font-size: 32px; font-weight: bold; letter-spacing: 8px; text-align: center; margin: 20px 0; }
+        .footer { text-align: center; margin-top: 20px; font-size: 12px; color: #666; }
+        p { margin-bottom: 15px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1 style="margin:0;">%s</h1>
+        </div>
+        <div class="content">
+            <h2>Verify Your Email</h2>
+            <p>Hello,</p>
+            <p>Thanks for signing up. Enter this code in the app to confirm your email address:</p>
+            <p class="code">%s</p>
+            <p>Or click the button below:</p>
+            <div style="text-align: center;">
+                <a href="%s" class="button">Verify Email</a>
+            </div>
+            <p style="margin-top: 30px; font-size: 14px;">Or copy and paste this link into your browser:</p>
+            <p style="font-size: 13px; color: #007bff; word-break: break-all;"><a href="%s">%s</a></p>
+            <p>The code and the link expire in %d hours. If you didn't create an account, you can safely ignore this email.</p>
+        </div>
+        <div class="footer">
+            <p>&copy; %d %s. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, siteName, code, verifyURL, verifyURL, verifyURL, int(expiresIn.Hours()), time.Now().Year(), siteName)
+}
+
 func (m *mailHelper) getContactHTML(siteName, siteURL string, payload *dto.ContactRequest) string {
 	return fmt.Sprintf(`
 <!DOCTYPE html>
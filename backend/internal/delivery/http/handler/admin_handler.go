@@ -19,6 +19,7 @@ import (
 	"github.com/user/video-downloader-backend/internal/service"
 	"github.com/user/video-downloader-backend/pkg/logger"
 	"github.com/user/video-downloader-backend/pkg/response"
+	"github.com/user/video-downloader-backend/pkg/utils"
 )
 
 type AdminHandler struct {
@@ -227,30 +228,12 @@ func adminActorIdentity(c *fiber.Ctx) string {
 	apiKey := strings.TrimSpace(c.Get("X-API-Key"))
 	apiKeyMasked := "-"
 	if apiKey != "" {
-		apiKeyMasked = maskSecret(apiKey)
+		apiKeyMasked = utils.MaskSecret(apiKey)
 	}
 
 	return fmt.Sprintf("user_id=%s email=%s role=%s api_key=%s", userID, email, role, apiKeyMasked)
 }
 
-func maskSecret(s string) string {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return "-"
-	}
-	if len(s) <= 10 {
-		return s[:min(3, len(s))] + "..."
-	}
-	return s[:6] + "..." + s[len(s)-4:]
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 func getCookiesFilePath() string {
 	if v := strings.TrimSpace(os.Getenv("COOKIES_FILE_PATH")); v != "" {
 		return v
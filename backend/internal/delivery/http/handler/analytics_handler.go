@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/user/video-downloader-backend/internal/middleware"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/service"
+	"github.com/user/video-downloader-backend/pkg/response"
+)
+
+type AnalyticsHandler struct {
+	svc service.AnalyticsService
+}
+
+func NewAnalyticsHandler(svc service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{svc: svc}
+}
+
+// DownloadSeries serves download counts bucketed by interval and grouped by
+// group_by. date_from and date_to take RFC 3339 times or YYYY-MM-DD days, a day
+// in date_to being included whole. format=csv returns the points as a CSV file.
+func (h *AnalyticsHandler) DownloadSeries(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	q := model.AnalyticsQuery{
+		Interval:     c.Query("interval"),
+		GroupBy:      c.Query("group_by"),
+		PlatformType: c.Query("platform_type"),
+		Status:       c.Query("status"),
+	}
+
+	var err error
+	if q.From, err = parseAnalyticsTime(c.Query("date_from"), false); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid date_from", err.Error())
+	}
+	if q.To, err = parseAnalyticsTime(c.Query("date_to"), true); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid date_to", err.Error())
+	}
+	if v := c.Query("app_id"); v != "" {
+		appID, err := uuid.Parse(v)
+		if err != nil {
+			return response.Error(c, fiber.StatusBadRequest, "Invalid app_id", err.Error())
+		}
+		q.AppID = &appID
+	}
+
+	series, err := h.svc.DownloadSeries(ctx, q)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAnalyticsQuery) {
+			return response.Error(c, fiber.StatusBadRequest, "Invalid analytics query", err.Error())
+		}
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to get download analytics", err.Error())
+	}
+
+	if c.Query("format") == "csv" {
+		return sendAnalyticsCSV(c, series)
+	}
+	return response.Success(c, "Download analytics retrieved successfully", series)
+}
+
+// parseAnalyticsTime reads an RFC 3339 time or a YYYY-MM-DD day. A bare day used
+// as an end bound moves to the next midnight so the day is included.
+func parseAnalyticsTime(v string, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 or YYYY-MM-DD, got %q", v)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func sendAnalyticsCSV(c *fiber.Ctx, series *model.AnalyticsSeries) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"bucket", series.Query.GroupBy, "total", "completed", "failed", "failure_rate"})
+	for _, p := range series.Points {
+		_ = w.Write([]string{
+			p.Bucket.UTC().Format(time.RFC3339),
+			p.Group,
+			strconv.FormatInt(p.Total, 10),
+			strconv.FormatInt(p.Completed, 10),
+			strconv.FormatInt(p.Failed, 10),
+			strconv.FormatFloat(p.FailureRate, 'f', 4, 64),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to write CSV", err.Error())
+	}
+
+	filename := fmt.Sprintf("downloads-%s-%s-%s.csv", series.Query.GroupBy,
+		series.Query.From.UTC().Format("20060102"), series.Query.To.UTC().Format("20060102"))
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return c.Send(buf.Bytes())
+}
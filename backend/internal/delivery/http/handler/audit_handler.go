@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/user/video-downloader-backend/internal/middleware"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/service"
+	"github.com/user/video-downloader-backend/pkg/response"
+)
+
+type AuditHandler struct {
+	svc service.AuditService
+}
+
+func NewAuditHandler(svc service.AuditService) *AuditHandler {
+	return &AuditHandler{svc: svc}
+}
+
+func (h *AuditHandler) Search(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	query := model.AuditEventQuery{
+		Search:   c.Query("search"),
+		Action:   c.Query("action"),
+		TargetID: c.Query("target_id"),
+		Page:     page,
+		Limit:    limit,
+	}
+
+	if v := c.Query("actor_id"); v != "" {
+		actorID, err := uuid.Parse(v)
+		if err != nil {
+			return response.Error(c, fiber.StatusBadRequest, "Invalid actor ID", err.Error())
+		}
+		query.ActorID = &actorID
+	}
+	if dateFrom := c.Query("date_from"); dateFrom != "" {
+		if t, err := time.Parse(time.RFC3339, dateFrom); err == nil {
+			query.DateFrom = t
+		}
+	}
+	if dateTo := c.Query("date_to"); dateTo != "" {
+		if t, err := time.Parse(time.RFC3339, dateTo); err == nil {
+			query.DateTo = t
+		}
+	}
+
+	events, pagination, err := h.svc.Search(ctx, query)
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to fetch audit events", err.Error())
+	}
+
+	return response.SuccessWithMeta(c, "Audit events retrieved successfully", events, pagination)
+}
@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -36,17 +39,18 @@ func (h *AuthHandler) GoogleLogin(c *fiber.Ctx) error {
 		return response.Error(c, fiber.StatusBadRequest, "Credential is required", nil)
 	}
 
-	user, accessToken, err := h.authService.VerifyGoogleToken(ctx, req.Credential)
+	user, tokens, err := h.authService.VerifyGoogleToken(ctx, req.Credential, deviceInfo(c))
+	var challenge *service.TwoFactorChallengeError
+	if errors.As(err, &challenge) {
+		return response.Success(c, "Two-factor authentication required", challenge.Challenge)
+	}
 	if err != nil {
 		// Log the error for debugging purposes since 401 doesn't show details in standard logger
 		fmt.Printf("❌ Google Login Failed: %v\n", err)
 		return response.Error(c, fiber.StatusUnauthorized, "Authentication failed: "+err.Error(), nil)
 	}
 
-	return response.Success(c, "Login successful", fiber.Map{
-		"access_token": accessToken,
-		"user":         user,
-	})
+	return response.Success(c, "Login successful", authResponse(user, tokens))
 }
 
 func (h *AuthHandler) LoginEmail(c *fiber.Ctx) error {
@@ -61,15 +65,16 @@ func (h *AuthHandler) LoginEmail(c *fiber.Ctx) error {
 		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
 	}
 
-	user, accessToken, err := h.authService.LoginEmail(ctx, req.Email, req.Password)
+	user, tokens, err := h.authService.LoginEmail(ctx, req.Email, req.Password, deviceInfo(c))
+	var challenge *service.TwoFactorChallengeError
+	if errors.As(err, &challenge) {
+		return response.Success(c, "Two-factor authentication required", challenge.Challenge)
+	}
 	if err != nil {
 		return response.Error(c, fiber.StatusUnauthorized, "Authentication failed: "+err.Error(), nil)
 	}
 
-	return response.Success(c, "Login successful", fiber.Map{
-		"access_token": accessToken,
-		"user":         user,
-	})
+	return response.Success(c, "Login successful", authResponse(user, tokens))
 }
 
 func (h *AuthHandler) RegisterEmail(c *fiber.Ctx) error {
@@ -84,30 +89,117 @@ func (h *AuthHandler) RegisterEmail(c *fiber.Ctx) error {
 		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
 	}
 
-	user, accessToken, err := h.authService.RegisterEmail(ctx, req.FullName, req.Email, req.Password)
+	user, tokens, err := h.authService.RegisterEmail(ctx, req.FullName, req.Email, req.Password, deviceInfo(c))
 	if err != nil {
 		return response.Error(c, fiber.StatusBadRequest, "Registration failed: "+err.Error(), nil)
 	}
 
-	return response.Success(c, "Registration successful", fiber.Map{
-		"access_token": accessToken,
-		"user":         user,
-	})
+	return response.Success(c, "Registration successful", authResponse(user, tokens))
+}
+
+func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	var req model.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	user, tokens, err := h.authService.Refresh(ctx, req.RefreshToken, deviceInfo(c))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			return response.Error(c, fiber.StatusUnauthorized, "Session expired, please sign in again", err.Error())
+		}
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to refresh token", err.Error())
+	}
+
+	return response.Success(c, "Token refreshed", authResponse(user, tokens))
+}
+
+// SetupTwoFactorChallenge returns the secret to enroll for a sign-in whose
+// challenge requires enrollment.
+func (h *AuthHandler) SetupTwoFactorChallenge(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	var req model.TwoFactorChallengeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	setup, err := h.authService.SetupTwoFactorChallenge(ctx, req.ChallengeToken)
+	if err != nil {
+		return twoFactorError(c, err, "Failed to set up two-factor authentication")
+	}
+
+	return response.Success(c, "Scan the code with your authenticator app", setup)
+}
+
+// VerifyTwoFactorChallenge completes a sign-in with a code from the
+// authenticator app or a recovery code.
+func (h *AuthHandler) VerifyTwoFactorChallenge(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	var req model.TwoFactorChallengeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+	if req.Code == "" {
+		return response.Error(c, fiber.StatusBadRequest, "Code is required", nil)
+	}
+
+	user, tokens, recoveryCodes, err := h.authService.VerifyTwoFactorChallenge(ctx, req.ChallengeToken, req.Code, deviceInfo(c))
+	if err != nil {
+		return twoFactorError(c, err, "Authentication failed")
+	}
+
+	resp := authResponse(user, tokens)
+	if len(recoveryCodes) > 0 {
+		// Enrolled by this sign-in; the codes are not shown again.
+		resp["recovery_codes"] = recoveryCodes
+	}
+	return response.Success(c, "Login successful", resp)
 }
 
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	ctx := middleware.HandlerContext(c)
 
+	if _, ok := c.Locals("user_id").(uuid.UUID); !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+	sessionID, _ := c.Locals("session_id").(uuid.UUID)
+	jti, _ := c.Locals("jti").(string)
+	expiresAt, _ := c.Locals("token_exp").(time.Time)
+
+	if err := h.authService.Logout(ctx, sessionID, jti, expiresAt); err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "Logout failed", err.Error())
+	}
+
+	return response.Success(c, "Logout successful", nil)
+}
+
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
 	userID, ok := c.Locals("user_id").(uuid.UUID)
 	if !ok {
 		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
 	}
 
-	if err := h.authService.Logout(ctx, userID); err != nil {
+	if err := h.authService.LogoutAll(ctx, userID); err != nil {
 		return response.Error(c, fiber.StatusInternalServerError, "Logout failed", err.Error())
 	}
 
-	return response.Success(c, "Logout successful", nil)
+	return response.Success(c, "Logged out from all devices", nil)
 }
 
 func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
@@ -152,3 +244,128 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 
 	return response.Success(c, "Password has been reset successfully", nil)
 }
+
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	var req model.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	if err := h.authService.VerifyEmail(ctx, req.Token); err != nil {
+		return verificationError(c, err, "Failed to verify email")
+	}
+
+	return response.Success(c, "Email verified, refresh your session to apply it", nil)
+}
+
+func (h *AuthHandler) VerifyEmailCode(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	var req model.VerifyEmailCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	if err := h.authService.VerifyEmailCode(ctx, userID, req.Code); err != nil {
+		return verificationError(c, err, "Failed to verify email")
+	}
+
+	return response.Success(c, "Email verified, refresh your session to apply it", nil)
+}
+
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	if err := h.authService.ResendVerification(ctx, userID); err != nil {
+		return verificationError(c, err, "Failed to send verification email")
+	}
+
+	return response.Success(c, "Verification email sent", nil)
+}
+
+func (h *AuthHandler) DeleteAccount(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	var req model.DeleteAccountRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+		}
+	}
+
+	if err := h.authService.DeleteAccount(ctx, userID, req); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.Error(c, fiber.StatusNotFound, "User not found", nil)
+		case errors.Is(err, service.ErrIncorrectPassword),
+			errors.Is(err, service.ErrReauthenticationRequired):
+			return response.Error(c, fiber.StatusBadRequest, "Failed to delete account", err.Error())
+		}
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to delete account", err.Error())
+	}
+
+	return response.Success(c, "Account deleted", nil)
+}
+
+func verificationError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return response.Error(c, fiber.StatusNotFound, "User not found", nil)
+	case errors.Is(err, service.ErrInvalidVerificationToken),
+		errors.Is(err, service.ErrInvalidVerificationCode),
+		errors.Is(err, service.ErrVerificationExpired):
+		return response.Error(c, fiber.StatusBadRequest, message, err.Error())
+	case errors.Is(err, service.ErrEmailAlreadyVerified):
+		return response.Error(c, fiber.StatusConflict, message, err.Error())
+	case errors.Is(err, service.ErrTooManyVerificationAttempts),
+		errors.Is(err, service.ErrVerificationResendTooSoon):
+		return response.Error(c, fiber.StatusTooManyRequests, message, err.Error())
+	}
+	return response.Error(c, fiber.StatusInternalServerError, message, err.Error())
+}
+
+func authResponse(user *model.User, tokens *model.AuthTokens) fiber.Map {
+	return fiber.Map{
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          user,
+	}
+}
+
+// deviceInfo describes the client of an auth request. Clients that send a
+// stable X-Device-Id get one session per device.
+func deviceInfo(c *fiber.Ctx) model.DeviceInfo {
+	deviceID := strings.TrimSpace(c.Get("X-Device-Id"))
+	if len(deviceID) > 255 {
+		deviceID = deviceID[:255]
+	}
+	return model.DeviceInfo{
+		DeviceID:  deviceID,
+		UserAgent: c.Get("User-Agent"),
+		IPAddress: c.IP(),
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/pkg/response"
+)
+
+// downloadErrorStatuses maps download error codes to HTTP statuses. Codes not
+// listed here are server-side failures and answer 500.
+var downloadErrorStatuses = map[string]int{
+	infrastructure.ErrCodePrivateVideo:      fiber.StatusUnprocessableEntity,
+	infrastructure.ErrCodeLoginRequired:     fiber.StatusUnprocessableEntity,
+	infrastructure.ErrCodeAgeRestricted:     fiber.StatusUnprocessableEntity,
+	infrastructure.ErrCodeGeoBlocked:        fiber.StatusUnprocessableEntity,
+	infrastructure.ErrCodeVideoUnavailable:  fiber.StatusUnprocessableEntity,
+	infrastructure.ErrCodeLiveStream:        fiber.StatusUnprocessableEntity,
+	infrastructure.ErrCodeDRMProtected:      fiber.StatusUnprocessableEntity,
+	infrastructure.ErrCodeFormatUnavailable: fiber.StatusUnprocessableEntity,
+	infrastructure.ErrCodeUnsupportedURL:    fiber.StatusBadRequest,
+	infrastructure.ErrCodePlatformDisabled:  fiber.StatusForbidden,
+	infrastructure.ErrCodeAppUnavailable:    fiber.StatusForbidden,
+	infrastructure.ErrCodeCanceled:          fiber.StatusConflict,
+	infrastructure.ErrCodeRateLimited:       fiber.StatusServiceUnavailable,
+	infrastructure.ErrCodeTimeout:           fiber.StatusGatewayTimeout,
+	infrastructure.ErrCodeNetwork:           fiber.StatusBadGateway,
+	infrastructure.ErrCodeUpstream:          fiber.StatusBadGateway,
+	infrastructure.ErrCodeExtractionFailed:  fiber.StatusBadGateway,
+}
+
+// downloadErrorResponse answers a failed download request with the error's code,
+// so clients can tell a private video from an outage.
+func downloadErrorResponse(c *fiber.Ctx, dlErr *infrastructure.DownloadError) error {
+	status, ok := downloadErrorStatuses[dlErr.Code]
+	if !ok {
+		status = fiber.StatusInternalServerError
+	}
+	return response.Error(c, status, dlErr.Message, fiber.Map{
+		"code":      dlErr.Code,
+		"retryable": dlErr.Retryable(),
+		"detail":    dlErr.Error(),
+	})
+}
@@ -23,7 +23,6 @@ import (
 	"github.com/gofiber/websocket/v2"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"github.com/user/video-downloader-backend/internal/config"
 	"github.com/user/video-downloader-backend/internal/infrastructure"
 	"github.com/user/video-downloader-backend/internal/middleware"
 	"github.com/user/video-downloader-backend/internal/model"
@@ -33,8 +32,10 @@ import (
 )
 
 type DownloadHandler struct {
-	svc     service.DownloadService
-	userSvc service.UserService
+	svc        service.DownloadService
+	storage    infrastructure.StorageClient
+	bucketName string
+	keyRing    *utils.KeyRing
 }
 
 type ytcontentStatusResponse struct {
@@ -215,8 +216,26 @@ func resolveYTContentFileURL(ctx context.Context, rawURL string) (string, string
 	}
 }
 
-func NewDownloadHandler(svc service.DownloadService, userSvc service.UserService) *DownloadHandler {
-	return &DownloadHandler{svc: svc, userSvc: userSvc}
+func NewDownloadHandler(svc service.DownloadService, storage infrastructure.StorageClient, bucketName string, keyRing *utils.KeyRing) *DownloadHandler {
+	return &DownloadHandler{svc: svc, storage: storage, bucketName: bucketName, keyRing: keyRing}
+}
+
+// downloadUser returns the signed-in user a download counts against, or nil
+// for guests. Quotas and plans follow the token only: the user_id older
+// clients still send in the body is accepted when it names that same user and
+// rejected otherwise.
+func downloadUser(c *fiber.Ctx, claimed *string) (*uuid.UUID, bool) {
+	userID, signedIn := c.Locals("user_id").(uuid.UUID)
+	if claimed != nil && *claimed != "" {
+		id, err := uuid.Parse(*claimed)
+		if err != nil || !signedIn || id != userID {
+			return nil, false
+		}
+	}
+	if !signedIn {
+		return nil, true
+	}
+	return &userID, true
 }
 
 func (h *DownloadHandler) DownloadVideo(c *fiber.Ctx) error {
@@ -240,18 +259,9 @@ func (h *DownloadHandler) DownloadVideo(c *fiber.Ctx) error {
 		Str("type", req.Type).
 		Msg("Received download request")
 
-	var userID *uuid.UUID
-	if req.UserID != nil {
-		id, err := uuid.Parse(*req.UserID)
-		if err != nil {
-			return response.Error(c, fiber.StatusBadRequest, "Invalid user ID", err.Error())
-		}
-		user, err := h.userSvc.FindByID(ctx, id)
-		if err != nil {
-			log.Error().Err(err).Str("user_id", id.String()).Msg("Failed to find user")
-			return response.Error(c, fiber.StatusBadRequest, "Invalid user ID", err.Error())
-		}
-		userID = &user.ID
+	userID, ok := downloadUser(c, req.UserID)
+	if !ok {
+		return response.Error(c, fiber.StatusForbidden, "user_id does not match the signed-in user", nil)
 	}
 
 	ip := c.IP()
@@ -260,6 +270,20 @@ func (h *DownloadHandler) DownloadVideo(c *fiber.Ctx) error {
 
 	result, err := h.svc.ProcessDownload(ctx, req, userID, ip)
 	if err != nil {
+		var quotaErr *service.QuotaError
+		if errors.As(err, &quotaErr) {
+			return quotaErrorResponse(c, quotaErr)
+		}
+		var dlErr *infrastructure.DownloadError
+		if errors.As(err, &dlErr) {
+			return downloadErrorResponse(c, dlErr)
+		}
+		if errors.Is(err, service.ErrInvalidFormatSelection) {
+			return response.Error(c, fiber.StatusBadRequest, "Invalid format selection", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidClip) {
+			return response.Error(c, fiber.StatusBadRequest, "Invalid clip range", err.Error())
+		}
 		log.Error().Err(err).Str("url", req.URL).Msg("Failed to process download request")
 		return response.Error(c, fiber.StatusInternalServerError, "Failed to process download", err.Error())
 	}
@@ -269,6 +293,7 @@ func (h *DownloadHandler) DownloadVideo(c *fiber.Ctx) error {
 		Str("task_id", result.ID.String()).
 		Dur("processing_time", time.Since(start)).
 		Msg("Download request processed successfully")
+	setQuotaHeaders(c, result.Quota)
 
 	event := &model.DownloadEvent{
 		Type:      "download.queued",
@@ -312,18 +337,9 @@ func (h *DownloadHandler) DownloadVideoToMp3(c *fiber.Ctx) error {
 		Str("type", req.Type).
 		Msg("Received mp3 download request")
 
-	var userID *uuid.UUID
-	if req.UserID != nil {
-		id, err := uuid.Parse(*req.UserID)
-		if err != nil {
-			return response.Error(c, fiber.StatusBadRequest, "Invalid user ID", err.Error())
-		}
-		user, err := h.userSvc.FindByID(ctx, id)
-		if err != nil {
-			log.Error().Err(err).Str("user_id", id.String()).Msg("Failed to find user")
-			return response.Error(c, fiber.StatusBadRequest, "Invalid user ID", err.Error())
-		}
-		userID = &user.ID
+	userID, ok := downloadUser(c, req.UserID)
+	if !ok {
+		return response.Error(c, fiber.StatusForbidden, "user_id does not match the signed-in user", nil)
 	}
 
 	ip := c.IP()
@@ -331,6 +347,17 @@ func (h *DownloadHandler) DownloadVideoToMp3(c *fiber.Ctx) error {
 
 	result, err := h.svc.ProcessDownloadMp3(ctx, req, userID, ip)
 	if err != nil {
+		var quotaErr *service.QuotaError
+		if errors.As(err, &quotaErr) {
+			return quotaErrorResponse(c, quotaErr)
+		}
+		var dlErr *infrastructure.DownloadError
+		if errors.As(err, &dlErr) {
+			return downloadErrorResponse(c, dlErr)
+		}
+		if errors.Is(err, service.ErrInvalidClip) {
+			return response.Error(c, fiber.StatusBadRequest, "Invalid clip range", err.Error())
+		}
 		log.Error().Err(err).Str("url", req.URL).Msg("Failed to process mp3 download request")
 		return response.Error(c, fiber.StatusInternalServerError, "Failed to process download", err.Error())
 	}
@@ -340,6 +367,7 @@ func (h *DownloadHandler) DownloadVideoToMp3(c *fiber.Ctx) error {
 		Str("task_id", result.ID.String()).
 		Dur("processing_time", time.Since(start)).
 		Msg("MP3 download request processed successfully")
+	setQuotaHeaders(c, result.Quota)
 
 	event := &model.DownloadEvent{
 		Type:      "download.queued",
@@ -356,6 +384,113 @@ func (h *DownloadHandler) DownloadVideoToMp3(c *fiber.Ctx) error {
 	return response.Success(c, "Download processed successfully", result)
 }
 
+func (h *DownloadHandler) DownloadBatch(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	var req model.BatchDownloadRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	log.Info().
+		Str("url", req.URL).
+		Str("type", req.Type).
+		Msg("Received batch download request")
+
+	userID, ok := downloadUser(c, req.UserID)
+	if !ok {
+		return response.Error(c, fiber.StatusForbidden, "user_id does not match the signed-in user", nil)
+	}
+
+	ip := c.IP()
+	start := time.Now()
+
+	batch, err := h.svc.ProcessBatch(ctx, req, userID, ip)
+	if err != nil {
+		var quotaErr *service.QuotaError
+		if errors.As(err, &quotaErr) {
+			return quotaErrorResponse(c, quotaErr)
+		}
+		var dlErr *infrastructure.DownloadError
+		if errors.As(err, &dlErr) {
+			return downloadErrorResponse(c, dlErr)
+		}
+		log.Error().Err(err).Str("url", req.URL).Msg("Failed to process batch download request")
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to process batch download", err.Error())
+	}
+
+	log.Info().
+		Str("url", req.URL).
+		Str("batch_id", batch.ID.String()).
+		Int("items", batch.TotalItems).
+		Dur("processing_time", time.Since(start)).
+		Msg("Batch download request processed successfully")
+	setQuotaHeaders(c, batch.Quota)
+
+	progress := 0
+	event := &model.DownloadEvent{
+		Type:      "batch.queued",
+		TaskID:    batch.ID,
+		BatchID:   &batch.ID,
+		UserID:    batch.UserID,
+		Status:    batch.Status,
+		Progress:  &progress,
+		CreatedAt: time.Now(),
+	}
+
+	go func(e *model.DownloadEvent) {
+		defaultDownloadEventHub.Broadcast(e)
+	}(event)
+
+	return response.Success(c, "Batch download processed successfully", batch)
+}
+
+func (h *DownloadHandler) FindBatchByID(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid ID", err.Error())
+	}
+
+	batch, err := h.svc.FindBatchByID(ctx, id)
+	if err != nil {
+		return response.Error(c, fiber.StatusNotFound, "Download batch not found", err.Error())
+	}
+
+	return response.Success(c, "Download batch fetched successfully", batch)
+}
+
+// FindOwnedBatch serves batch progress to the client that started it. Signed in
+// users own their batches; anonymous ones belong to the requesting IP.
+func (h *DownloadHandler) FindOwnedBatch(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid ID", err.Error())
+	}
+
+	var userID *uuid.UUID
+	if v, ok := c.Locals("user_id").(uuid.UUID); ok {
+		userID = &v
+	}
+
+	batch, err := h.svc.FindBatchForOwner(ctx, id, userID, c.IP())
+	if err != nil {
+		if errors.Is(err, service.ErrDownloadNotOwned) {
+			return response.Error(c, fiber.StatusForbidden, "Forbidden", nil)
+		}
+		return response.Error(c, fiber.StatusNotFound, "Download batch not found", err.Error())
+	}
+
+	return response.Success(c, "Download batch fetched successfully", batch)
+}
+
 func (h *DownloadHandler) GetHistory(c *fiber.Ctx) error {
 	ctx := middleware.HandlerContext(c)
 	userID, ok := c.Locals("user_id").(uuid.UUID)
@@ -419,6 +554,37 @@ func (h *DownloadHandler) FindByIDForCurrentUser(c *fiber.Ctx) error {
 	return response.Success(c, "Download fetched successfully", task)
 }
 
+// CancelDownload lets the owner stop a download that has not finished. Signed
+// in users own their downloads; anonymous ones belong to the requesting IP.
+func (h *DownloadHandler) CancelDownload(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid ID", err.Error())
+	}
+
+	var userID *uuid.UUID
+	if v, ok := c.Locals("user_id").(uuid.UUID); ok {
+		userID = &v
+	}
+
+	task, err := h.svc.Cancel(ctx, id, userID, c.IP())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrDownloadNotFound):
+			return response.Error(c, fiber.StatusNotFound, "Download not found", nil)
+		case errors.Is(err, service.ErrDownloadNotOwned):
+			return response.Error(c, fiber.StatusForbidden, "Forbidden", nil)
+		case errors.Is(err, service.ErrDownloadNotCancelable):
+			return response.Error(c, fiber.StatusConflict, "Download can no longer be canceled", err.Error())
+		}
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to cancel download", err.Error())
+	}
+
+	return response.Success(c, "Download canceled successfully", task)
+}
+
 func (h *DownloadHandler) GetDownloads(c *fiber.Ctx) error {
 	ctx := middleware.HandlerContext(c)
 
@@ -549,40 +715,36 @@ func (h *DownloadHandler) ProxyDownload(c *fiber.Ctx) error {
 			}
 
 			if targetFile != nil {
-				// Check if file is encrypted
-				if targetFile.EncryptedData != nil {
-					log.Info().Str("task_id", task.ID.String()).Msg("Found encrypted file in DB, decrypting and streaming")
+				ext := "mp4"
+				if targetFile.Extension != nil && *targetFile.Extension != "" {
+					ext = *targetFile.Extension
+				}
 
-					cfg := config.LoadConfig()
-					decrypted, err := utils.DecryptData(*targetFile.EncryptedData, cfg.EncryptionKey)
+				if targetFile.Encryption != nil {
+					src, err := h.encryptedRangeSource(ctx, targetFile, "video/"+ext, downloadFilename(filename, task, ext))
 					if err != nil {
-						log.Error().Err(err).Msg("Failed to decrypt video")
+						log.Error().Err(err).Str("task_id", task.ID.String()).Msg("Failed to open encrypted video")
 						return response.Error(c, fiber.StatusInternalServerError, "Failed to decrypt video", err.Error())
 					}
+					return serveRangeSource(c, src)
+				}
 
-					// Stream decrypted data
-					c.Set("Content-Type", "video/mp4")
-					c.Set("Content-Length", fmt.Sprintf("%d", len(decrypted)))
+				// Legacy rows keep the whole ciphertext in the database.
+				if targetFile.EncryptedData != nil {
+					log.Info().Str("task_id", task.ID.String()).Msg("Found encrypted file in DB, decrypting and streaming")
 
-					// Use filename from query or task title
-					finalFilename := filename
-					if finalFilename == "" {
-						if task.Title != nil {
-							finalFilename = *task.Title
-						} else {
-							finalFilename = "download"
-						}
-					}
-					if !strings.HasSuffix(strings.ToLower(finalFilename), ".mp4") {
-						finalFilename += ".mp4"
+					decrypted, err := h.keyRing.Decrypt(*targetFile.EncryptedData)
+					if err != nil {
+						log.Error().Err(err).Msg("Failed to decrypt video")
+						return response.Error(c, fiber.StatusInternalServerError, "Failed to decrypt video", err.Error())
 					}
-					// Sanitize filename
-					finalFilename = strings.ReplaceAll(finalFilename, `"`, `\"`)
-					encodedFilename := url.QueryEscape(finalFilename)
 
-					c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, finalFilename, encodedFilename))
+					return serveRangeSource(c, bytesRangeSource(targetFile, decrypted, "video/mp4", downloadFilename(filename, task, "mp4")))
+				}
 
-					return c.Status(http.StatusOK).SendStream(bytes.NewReader(decrypted))
+				// Files uploaded by the worker are streamed from storage so Range requests work.
+				if src := h.storageRangeSource(ctx, targetFile, "video/"+ext, downloadFilename(filename, task, ext)); src != nil {
+					return serveRangeSource(c, src)
 				}
 
 				if targetFile.URL != "" {
@@ -1590,32 +1752,24 @@ func (h *DownloadHandler) ProxyDownloadMp3(c *fiber.Ctx) error {
 	}
 
 	targetFile := &task.DownloadFiles[0]
+	if targetFile.Encryption != nil {
+		src, err := h.encryptedRangeSource(ctx, targetFile, "audio/mpeg", downloadFilename(filename, task, "mp3"))
+		if err != nil {
+			return response.Error(c, fiber.StatusInternalServerError, "Failed to decrypt audio", err.Error())
+		}
+		return serveRangeSource(c, src)
+	}
 	if targetFile.EncryptedData != nil {
-		cfg := config.LoadConfig()
-		decrypted, err := utils.DecryptData(*targetFile.EncryptedData, cfg.EncryptionKey)
+		decrypted, err := h.keyRing.Decrypt(*targetFile.EncryptedData)
 		if err != nil {
 			return response.Error(c, fiber.StatusInternalServerError, "Failed to decrypt audio", err.Error())
 		}
 
-		c.Set("Content-Type", "audio/mpeg")
-		c.Set("Content-Length", fmt.Sprintf("%d", len(decrypted)))
-
-		finalFilename := filename
-		if finalFilename == "" {
-			if task.Title != nil {
-				finalFilename = *task.Title
-			} else {
-				finalFilename = "download"
-			}
-		}
-		if !strings.HasSuffix(strings.ToLower(finalFilename), ".mp3") {
-			finalFilename += ".mp3"
-		}
-		finalFilename = strings.ReplaceAll(finalFilename, `"`, `\"`)
-		encodedFilename := url.QueryEscape(finalFilename)
-		c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, finalFilename, encodedFilename))
+		return serveRangeSource(c, bytesRangeSource(targetFile, decrypted, "audio/mpeg", downloadFilename(filename, task, "mp3")))
+	}
 
-		return c.Status(http.StatusOK).SendStream(bytes.NewReader(decrypted))
+	if src := h.storageRangeSource(ctx, targetFile, "audio/mpeg", downloadFilename(filename, task, "mp3")); src != nil {
+		return serveRangeSource(c, src)
 	}
 
 	finalURL := strings.TrimSpace(targetFile.URL)
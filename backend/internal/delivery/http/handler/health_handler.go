@@ -10,16 +10,18 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
 	"github.com/user/video-downloader-backend/pkg/response"
 )
 
 type HealthHandler struct {
-	db    *pgxpool.Pool
-	redis *redis.Client
+	db             *pgxpool.Pool
+	redis          *redis.Client
+	strategyHealth infrastructure.StrategyHealthTracker
 }
 
-func NewHealthHandler(db *pgxpool.Pool, redis *redis.Client) *HealthHandler {
-	return &HealthHandler{db: db, redis: redis}
+func NewHealthHandler(db *pgxpool.Pool, redis *redis.Client, strategyHealth infrastructure.StrategyHealthTracker) *HealthHandler {
+	return &HealthHandler{db: db, redis: redis, strategyHealth: strategyHealth}
 }
 
 func (h *HealthHandler) Check(c *fiber.Ctx) error {
@@ -51,6 +53,51 @@ func (h *HealthHandler) Check(c *fiber.Ctx) error {
 	return response.Success(c, "System is healthy", data)
 }
 
+func (h *HealthHandler) GetStrategyHealth(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	stats, err := h.strategyHealth.Snapshot(ctx)
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to fetch strategy health", err.Error())
+	}
+
+	platform := c.Query("platform")
+	if platform != "" {
+		filtered := make([]infrastructure.StrategyHealthStats, 0, len(stats))
+		for _, s := range stats {
+			if s.Platform == platform {
+				filtered = append(filtered, s)
+			}
+		}
+		stats = filtered
+	}
+
+	return response.Success(c, "Strategy health fetched successfully", stats)
+}
+
+func (h *HealthHandler) ResetStrategyBreaker(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var req struct {
+		Platform string `json:"platform"`
+		Strategy string `json:"strategy"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+	if req.Platform == "" || req.Strategy == "" {
+		return response.Error(c, fiber.StatusBadRequest, "Platform and strategy are required", nil)
+	}
+
+	if err := h.strategyHealth.Reset(ctx, req.Platform, req.Strategy); err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to reset strategy breaker", err.Error())
+	}
+
+	return response.Success(c, "Strategy breaker reset successfully", nil)
+}
+
 func (h *HealthHandler) GetLogger(c *fiber.Ctx) error {
 	_, cancel := context.WithTimeout(c.Context(), 10*time.Second)
 	defer cancel()
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
 	"github.com/user/video-downloader-backend/internal/middleware"
 	"github.com/user/video-downloader-backend/internal/model"
 	"github.com/user/video-downloader-backend/internal/service"
@@ -113,6 +114,10 @@ func (h *PlatformHandler) CreatePlatform(c *fiber.Ctx) error {
 		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
 	}
 
+	if _, err := infrastructure.ParseStrategyRouting(platform.Config); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid downloader config", err.Error())
+	}
+
 	if err := h.service.CreatePlatform(ctx, &platform); err != nil {
 		return response.Error(c, fiber.StatusInternalServerError, "Failed to create platform", err.Error())
 	}
@@ -138,6 +143,10 @@ func (h *PlatformHandler) UpdatePlatform(c *fiber.Ctx) error {
 	}
 	platform.ID = id
 
+	if _, err := infrastructure.ParseStrategyRouting(platform.Config); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid downloader config", err.Error())
+	}
+
 	if err := h.service.UpdatePlatform(ctx, &platform); err != nil {
 		return response.Error(c, fiber.StatusInternalServerError, "Failed to update platform", err.Error())
 	}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/service"
+	"github.com/user/video-downloader-backend/pkg/response"
+)
+
+// setQuotaHeaders reports the caller's daily download quota. Limit and remaining
+// are omitted when the plan has no daily limit.
+func setQuotaHeaders(c *fiber.Ctx, q *model.QuotaStatus) {
+	if q == nil {
+		return
+	}
+	c.Set("X-Quota-Plan", q.Plan)
+	c.Set("X-Quota-Used", strconv.Itoa(q.Used))
+	if q.DailyDownloads > 0 {
+		c.Set("X-Quota-Limit", strconv.Itoa(q.DailyDownloads))
+		c.Set("X-Quota-Remaining", strconv.Itoa(q.Remaining))
+	}
+	c.Set("X-Quota-Reset", strconv.FormatInt(q.ResetAt.Unix(), 10))
+}
+
+// quotaErrorResponse answers a request rejected by the caller's plan: 429 once the
+// daily limit is used up, 403 for anything the plan does not include.
+func quotaErrorResponse(c *fiber.Ctx, quotaErr *service.QuotaError) error {
+	setQuotaHeaders(c, quotaErr.Quota)
+
+	status := fiber.StatusForbidden
+	if quotaErr.Reason == service.QuotaReasonDailyLimit {
+		status = fiber.StatusTooManyRequests
+		if quotaErr.Quota != nil {
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(int64(time.Until(quotaErr.Quota.ResetAt).Seconds())+1, 10))
+		}
+	}
+	return response.Error(c, status, quotaErr.Message, quotaErr.Reason)
+}
@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/pkg/response"
+	"github.com/user/video-downloader-backend/pkg/utils"
+)
+
+// rangeSource is a payload of known size that can be read from any offset, served
+// with Range, If-Range and ETag support so download managers can resume.
+type rangeSource struct {
+	Size         int64
+	ETag         string // quoted strong validator
+	LastModified time.Time
+	ContentType  string
+	Filename     string
+	Open         func(offset, length int64) (io.ReadCloser, error)
+}
+
+// attachmentDisposition builds a Content-Disposition header for filename. Control
+// characters are dropped so a name cannot split the header, and names outside ASCII
+// get an RFC 5987 filename* next to an ASCII fallback.
+func attachmentDisposition(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, filename)
+	if strings.TrimSpace(filename) == "" {
+		filename = "download"
+	}
+
+	ascii := true
+	fallback := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			ascii = false
+			return '_'
+		}
+		return r
+	}, filename)
+	fallback = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(fallback)
+
+	if ascii {
+		return fmt.Sprintf(`attachment; filename="%s"`, fallback)
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback, encodeExtValue(filename))
+}
+
+// encodeExtValue percent-encodes s as the value-chars of an RFC 5987 ext-value.
+func encodeExtValue(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < utf8.RuneSelf && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(attrChars, c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// parseByteRange parses a single "bytes=" range against size and returns the inclusive
+// bounds. ok is false when the header must be ignored (other unit, several ranges or
+// malformed), and satisfiable is false when the range lies outside the payload.
+func parseByteRange(header string, size int64) (start, end int64, ok, satisfiable bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
+	}
+	first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+	if first == "" {
+		// Suffix range: the last N bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, true, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, false
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return 0, 0, true, false
+	}
+	return start, end, true, true
+}
+
+func etagMatches(header string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifRangeAllows reports whether a Range request may be answered partially. If-Range
+// carries either a strong ETag or an HTTP date; a mismatch means the client's partial
+// copy is stale and the full payload must be sent.
+func ifRangeAllows(header string, src *rangeSource) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return true
+	}
+	if strings.HasPrefix(header, `"`) {
+		return src.ETag != "" && header == src.ETag
+	}
+	if strings.HasPrefix(header, "W/") {
+		return false
+	}
+	t, err := http.ParseTime(header)
+	if err != nil || src.LastModified.IsZero() {
+		return false
+	}
+	return src.LastModified.Truncate(time.Second).Equal(t)
+}
+
+func serveRangeSource(c *fiber.Ctx, src *rangeSource) error {
+	c.Set("Accept-Ranges", "bytes")
+	c.Set("Content-Type", src.ContentType)
+	c.Set("Content-Disposition", attachmentDisposition(src.Filename))
+	if src.ETag != "" {
+		c.Set("ETag", src.ETag)
+	}
+	if !src.LastModified.IsZero() {
+		c.Set("Last-Modified", src.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.Get("If-None-Match"); inm != "" && etagMatches(inm, src.ETag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	start, length, status := int64(0), src.Size, fiber.StatusOK
+	if rh := c.Get("Range"); rh != "" && ifRangeAllows(c.Get("If-Range"), src) {
+		s, e, ok, satisfiable := parseByteRange(rh, src.Size)
+		if ok && !satisfiable {
+			c.Set("Content-Range", fmt.Sprintf("bytes */%d", src.Size))
+			return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+		}
+		if ok {
+			start, length, status = s, e-s+1, fiber.StatusPartialContent
+			c.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", s, e, src.Size))
+		}
+	}
+
+	c.Status(status)
+	if c.Method() == fiber.MethodHead || length == 0 {
+		c.Response().SkipBody = c.Method() == fiber.MethodHead
+		c.Response().Header.SetContentLength(int(length))
+		return nil
+	}
+
+	body, err := src.Open(start, length)
+	if err != nil {
+		c.Response().Header.Del("Content-Range")
+		return response.Error(c, fiber.StatusBadGateway, "Failed to open file", err.Error())
+	}
+	return c.SendStream(body, int(length))
+}
+
+// bytesRangeSource serves an in-memory payload, identified by the download file ID.
+func bytesRangeSource(file *model.DownloadFile, data []byte, contentType, filename string) *rangeSource {
+	return &rangeSource{
+		Size:         int64(len(data)),
+		ETag:         fmt.Sprintf(`"%s-%d"`, file.ID.String(), len(data)),
+		LastModified: file.CreatedAt,
+		ContentType:  contentType,
+		Filename:     filename,
+		Open: func(offset, length int64) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+		},
+	}
+}
+
+// storedObjectName returns the object storage key of a download file, either recorded
+// by the worker or derived from a bucket URL of older rows.
+func storedObjectName(file *model.DownloadFile, bucketName string) (string, bool) {
+	if file.ObjectName != nil && *file.ObjectName != "" {
+		return *file.ObjectName, true
+	}
+	u, err := url.Parse(strings.TrimSpace(file.URL))
+	if err != nil || bucketName == "" {
+		return "", false
+	}
+	objectName, found := strings.CutPrefix(u.Path, "/"+bucketName+"/")
+	if !found || objectName == "" {
+		return "", false
+	}
+	return objectName, true
+}
+
+// storageRangeSource serves an object straight from object storage. It returns nil
+// when the object cannot be found so callers can fall back to redirecting.
+func (h *DownloadHandler) storageRangeSource(ctx context.Context, file *model.DownloadFile, contentType, filename string) *rangeSource {
+	if h.storage == nil {
+		return nil
+	}
+	objectName, ok := storedObjectName(file, h.bucketName)
+	if !ok {
+		return nil
+	}
+	info, err := h.storage.StatFile(ctx, h.bucketName, objectName)
+	if err != nil {
+		return nil
+	}
+
+	etag := strings.Trim(info.ETag, `"`)
+	if etag == "" {
+		etag = fmt.Sprintf("%s-%d", objectName, info.Size)
+	}
+	if info.ContentType != "" && info.ContentType != "application/octet-stream" {
+		contentType = info.ContentType
+	}
+
+	// The body is written after the handler returns, so the read must not be bound
+	// to the request context.
+	streamCtx := context.WithoutCancel(ctx)
+	return &rangeSource{
+		Size:         info.Size,
+		ETag:         `"` + etag + `"`,
+		LastModified: info.LastModified,
+		ContentType:  contentType,
+		Filename:     filename,
+		Open: func(offset, length int64) (io.ReadCloser, error) {
+			return h.storage.GetFileRange(streamCtx, h.bucketName, objectName, offset, length)
+		},
+	}
+}
+
+// encryptedRangeSource serves an object written in the chunked AEAD format. Only the
+// chunks covering the requested range are fetched and decrypted.
+func (h *DownloadHandler) encryptedRangeSource(ctx context.Context, file *model.DownloadFile, contentType, filename string) (*rangeSource, error) {
+	if h.storage == nil || file.ObjectName == nil {
+		return nil, fmt.Errorf("encrypted file has no stored object")
+	}
+	objectName := *file.ObjectName
+
+	info, err := h.storage.StatFile(ctx, h.bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	headLen := int64(utils.ChunkedHeaderMaxSize)
+	if info.Size < headLen {
+		headLen = info.Size
+	}
+	rc, err := h.storage.GetFileRange(ctx, h.bucketName, objectName, 0, headLen)
+	if err != nil {
+		return nil, err
+	}
+	head, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	header, err := utils.ParseChunkedHeader(head)
+	if err != nil {
+		return nil, err
+	}
+	keyHex, err := h.keyRing.Key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	size, err := header.PlaintextSize(info.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	streamCtx := context.WithoutCancel(ctx)
+	return &rangeSource{
+		Size:         size,
+		ETag:         fmt.Sprintf(`"%s-%d"`, strings.Trim(info.ETag, `"`), size),
+		LastModified: info.LastModified,
+		ContentType:  contentType,
+		Filename:     filename,
+		Open: func(offset, length int64) (io.ReadCloser, error) {
+			cipherOffset, cipherLength := header.ChunkSpan(offset, length, info.Size)
+			body, err := h.storage.GetFileRange(streamCtx, h.bucketName, objectName, cipherOffset, cipherLength)
+			if err != nil {
+				return nil, err
+			}
+			plain, err := utils.NewChunkedDecryptReader(body, keyHex, header, info.Size, offset, length)
+			if err != nil {
+				body.Close()
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{plain, body}, nil
+		},
+	}, nil
+}
+
+// downloadFilename picks the attachment name of a task file with the given extension.
+func downloadFilename(requested string, task *model.DownloadTask, ext string) string {
+	name := requested
+	if name == "" {
+		if task.Title != nil && *task.Title != "" {
+			name = *task.Title
+		} else {
+			name = "download"
+		}
+	}
+	if !strings.HasSuffix(strings.ToLower(name), "."+ext) {
+		name += "." + ext
+	}
+	return name
+}
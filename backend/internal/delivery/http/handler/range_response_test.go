@@ -0,0 +1,69 @@
+package handler
+
+import "testing"
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		name            string
+		header          string
+		size            int64
+		wantStart       int64
+		wantEnd         int64
+		wantOK          bool
+		wantSatisfiable bool
+	}{
+		{"closed range", "bytes=0-99", 1000, 0, 99, true, true},
+		{"open ended", "bytes=500-", 1000, 500, 999, true, true},
+		{"end past the payload is clamped", "bytes=900-5000", 1000, 900, 999, true, true},
+		{"single byte", "bytes=999-999", 1000, 999, 999, true, true},
+		{"suffix", "bytes=-100", 1000, 900, 999, true, true},
+		{"suffix longer than the payload", "bytes=-5000", 1000, 0, 999, true, true},
+		{"surrounding spaces", "  bytes= 10 - 20 ", 1000, 10, 20, true, true},
+		{"start at the end", "bytes=1000-", 1000, 0, 0, true, false},
+		{"start past the end", "bytes=2000-3000", 1000, 0, 0, true, false},
+		{"empty suffix", "bytes=-0", 1000, 0, 0, true, false},
+		{"empty payload", "bytes=0-", 0, 0, 0, true, false},
+		{"suffix of an empty payload", "bytes=-10", 0, 0, 0, true, false},
+		{"other unit", "items=0-10", 1000, 0, 0, false, false},
+		{"several ranges", "bytes=0-10,20-30", 1000, 0, 0, false, false},
+		{"no dash", "bytes=10", 1000, 0, 0, false, false},
+		{"end before start", "bytes=20-10", 1000, 0, 0, false, false},
+		{"negative start", "bytes=--5", 1000, 0, 0, false, false},
+		{"not a number", "bytes=a-b", 1000, 0, 0, false, false},
+		{"empty", "", 1000, 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok, satisfiable := parseByteRange(tt.header, tt.size)
+			if ok != tt.wantOK || satisfiable != tt.wantSatisfiable {
+				t.Fatalf("parseByteRange(%q, %d) ok=%v satisfiable=%v, want ok=%v satisfiable=%v",
+					tt.header, tt.size, ok, satisfiable, tt.wantOK, tt.wantSatisfiable)
+			}
+			if satisfiable && (start != tt.wantStart || end != tt.wantEnd) {
+				t.Errorf("parseByteRange(%q, %d) = %d-%d, want %d-%d",
+					tt.header, tt.size, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestAttachmentDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{"plain", "video.mp4", `attachment; filename="video.mp4"`},
+		{"quotes and backslashes", `a "b" \c.mp4`, `attachment; filename="a \"b\" \\c.mp4"`},
+		{"header injection", "a\r\nSet-Cookie: x=1.mp4", `attachment; filename="aSet-Cookie: x=1.mp4"`},
+		{"only control characters", "\x00\x1f\x7f", `attachment; filename="download"`},
+		{"non-ASCII", "vidéo 日本.mp4", `attachment; filename="vid_o __.mp4"; filename*=UTF-8''vid%C3%A9o%20%E6%97%A5%E6%9C%AC.mp4`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attachmentDisposition(tt.filename); got != tt.want {
+				t.Errorf("attachmentDisposition(%q) = %s, want %s", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
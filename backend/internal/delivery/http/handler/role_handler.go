@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/user/video-downloader-backend/internal/dto"
+	"github.com/user/video-downloader-backend/internal/middleware"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/service"
+	"github.com/user/video-downloader-backend/pkg/response"
+	"github.com/user/video-downloader-backend/pkg/utils"
+)
+
+type RoleHandler struct {
+	svc service.RoleService
+}
+
+func NewRoleHandler(svc service.RoleService) *RoleHandler {
+	return &RoleHandler{svc: svc}
+}
+
+func (h *RoleHandler) GetRoles(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	roles, err := h.svc.FindAll(ctx)
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to fetch roles", err.Error())
+	}
+
+	return response.Success(c, "Roles", roles)
+}
+
+// GetPermissions lists the permissions roles can be granted.
+func (h *RoleHandler) GetPermissions(c *fiber.Ctx) error {
+	return response.Success(c, "Permissions", model.Permissions)
+}
+
+func (h *RoleHandler) GetRole(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	roleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid role ID", err.Error())
+	}
+
+	role, err := h.svc.FindByID(ctx, roleID)
+	if err != nil {
+		return roleError(c, err, "Failed to fetch role")
+	}
+
+	return response.Success(c, "Role", role)
+}
+
+func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	var req dto.RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request payload", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	role, err := h.svc.Create(ctx, actorPermissions(c), req.Name, req.Permissions)
+	if err != nil {
+		return roleError(c, err, "Failed to create role")
+	}
+
+	return response.Success(c, "Role created", role)
+}
+
+func (h *RoleHandler) UpdateRole(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	roleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid role ID", err.Error())
+	}
+
+	var req dto.RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request payload", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	role, err := h.svc.Update(ctx, actorPermissions(c), roleID, req.Name, req.Permissions)
+	if err != nil {
+		return roleError(c, err, "Failed to update role")
+	}
+
+	return response.Success(c, "Role updated", role)
+}
+
+func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	roleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid role ID", err.Error())
+	}
+
+	if err := h.svc.Delete(ctx, roleID); err != nil {
+		return roleError(c, err, "Failed to delete role")
+	}
+
+	return response.Success(c, "Role deleted", nil)
+}
+
+func (h *RoleHandler) AssignRole(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	actorID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid user ID", err.Error())
+	}
+
+	var req dto.AssignRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request payload", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+	roleID, err := uuid.Parse(req.RoleID)
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid role ID", err.Error())
+	}
+
+	user, err := h.svc.AssignRole(ctx, actorID, actorPermissions(c), userID, roleID)
+	if err != nil {
+		return roleError(c, err, "Failed to assign role")
+	}
+
+	return response.Success(c, "Role assigned", user)
+}
+
+func roleError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound):
+		return response.Error(c, fiber.StatusNotFound, "Role not found", nil)
+	case errors.Is(err, service.ErrUserNotFound):
+		return response.Error(c, fiber.StatusNotFound, "User not found", nil)
+	case errors.Is(err, service.ErrUnknownPermission):
+		return response.Error(c, fiber.StatusBadRequest, message, err.Error())
+	case errors.Is(err, service.ErrRoleNameTaken),
+		errors.Is(err, service.ErrRoleInUse),
+		errors.Is(err, service.ErrProtectedRole):
+		return response.Error(c, fiber.StatusConflict, message, err.Error())
+	case errors.Is(err, service.ErrOwnRole), errors.Is(err, service.ErrPermissionNotHeld):
+		return response.Error(c, fiber.StatusForbidden, message, err.Error())
+	}
+	return response.Error(c, fiber.StatusInternalServerError, message, err.Error())
+}
+
+// actorPermissions returns what the token of the request grants, as set by
+// JWTMiddleware.
+func actorPermissions(c *fiber.Ctx) map[string]bool {
+	permissions, _ := c.Locals("permissions").(map[string]bool)
+	return permissions
+}
@@ -95,3 +95,24 @@ func (h *SettingHandler) GetAllSettings(c *fiber.Ctx) error {
 	}
 	return response.Success(c, "All settings fetched", settings)
 }
+
+// AuditSnapshot returns the settings of the request scope by key, for the
+// audit log to diff settings changes.
+func (h *SettingHandler) AuditSnapshot(c *fiber.Ctx) (any, error) {
+	ctx := middleware.HandlerContext(c)
+	scope := c.Query("scope")
+	if scope == "" {
+		scope = middleware.GetSettingsScope(c)
+	}
+
+	settings, err := h.svc.GetAllSettings(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.Key] = s.Value
+	}
+	return values, nil
+}
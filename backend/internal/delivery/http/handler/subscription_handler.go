@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"github.com/user/video-downloader-backend/internal/dto"
 	"github.com/user/video-downloader-backend/internal/middleware"
 	"github.com/user/video-downloader-backend/internal/model"
@@ -70,6 +73,10 @@ func (h *SubscriptionHandler) UpsertMobile(c *fiber.Ctx) error {
 
 	out, err := h.svc.Upsert(ctx, sub)
 	if err != nil {
+		if errors.Is(err, service.ErrPurchaseRejected) {
+			logger.NotifyTelegram("[sub] purchase rejected user=%s product=%s err=%s", userID.String(), req.ProductID, err.Error())
+			return response.Error(c, fiber.StatusUnprocessableEntity, "Purchase could not be verified", err.Error())
+		}
 		logger.NotifyTelegram("[sub] upsert failed user=%s product=%s err=%s", userID.String(), req.ProductID, err.Error())
 		return response.Error(c, fiber.StatusInternalServerError, "Failed to save subscription", err.Error())
 	}
@@ -78,6 +85,43 @@ func (h *SubscriptionHandler) UpsertMobile(c *fiber.Ctx) error {
 	return response.Success(c, "Subscription saved", out)
 }
 
+// GooglePlayNotification receives Real-time developer notifications pushed by
+// Pub/Sub. Any non-2xx answer makes Pub/Sub deliver the message again.
+func (h *SubscriptionHandler) GooglePlayNotification(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	bearer := strings.TrimSpace(strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "))
+	err := h.svc.ProcessGooglePlayNotification(ctx, bearer, c.Query("token"), c.Body())
+	if err != nil {
+		return storeNotificationError(c, "google_play", err)
+	}
+	return response.Success(c, "Notification processed", nil)
+}
+
+// AppStoreNotification receives App Store Server Notifications v2.
+func (h *SubscriptionHandler) AppStoreNotification(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	if err := h.svc.ProcessAppStoreNotification(ctx, c.Body()); err != nil {
+		return storeNotificationError(c, "app_store", err)
+	}
+	return response.Success(c, "Notification processed", nil)
+}
+
+func storeNotificationError(c *fiber.Ctx, provider string, err error) error {
+	switch {
+	case errors.Is(err, service.ErrWebhookUnauthorized):
+		log.Warn().Err(err).Str("provider", provider).Str("ip", c.IP()).Msg("Rejected unauthenticated store notification")
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	case errors.Is(err, service.ErrWebhookMalformed):
+		return response.Error(c, fiber.StatusBadRequest, "Invalid notification", err.Error())
+	case errors.Is(err, service.ErrWebhookInProgress):
+		return response.Error(c, fiber.StatusConflict, "Notification is being processed", nil)
+	}
+	logger.NotifyTelegram("[sub] %s notification failed err=%s", provider, err.Error())
+	return response.Error(c, fiber.StatusInternalServerError, "Failed to process notification", err.Error())
+}
+
 func (h *SubscriptionHandler) GetCurrentMobile(c *fiber.Ctx) error {
 	ctx := middleware.HandlerContext(c)
 
@@ -154,6 +198,36 @@ func (h *SubscriptionHandler) Delete(c *fiber.Ctx) error {
 	return response.Success(c, "Subscription deleted", nil)
 }
 
+func (h *SubscriptionHandler) UpdateStatus(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	subID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid subscription ID", err.Error())
+	}
+
+	var req dto.UpdateSubscriptionStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request payload", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	sub, err := h.svc.Transition(ctx, subID, req.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSubscriptionNotFound):
+			return response.Error(c, fiber.StatusNotFound, "Subscription not found", nil)
+		case errors.Is(err, service.ErrInvalidSubscriptionTransition):
+			return response.Error(c, fiber.StatusConflict, "Subscription cannot move to this status", err.Error())
+		}
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to update subscription status", err.Error())
+	}
+
+	return response.Success(c, "Subscription status updated", sub)
+}
+
 func (h *SubscriptionHandler) BulkDelete(c *fiber.Ctx) error {
 	ctx := middleware.HandlerContext(c)
 
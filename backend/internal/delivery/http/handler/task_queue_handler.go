@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/user/video-downloader-backend/internal/middleware"
+	"github.com/user/video-downloader-backend/internal/service"
+	"github.com/user/video-downloader-backend/pkg/response"
+)
+
+type TaskQueueHandler struct {
+	svc service.TaskQueueService
+}
+
+func NewTaskQueueHandler(svc service.TaskQueueService) *TaskQueueHandler {
+	return &TaskQueueHandler{svc: svc}
+}
+
+func (h *TaskQueueHandler) Queues(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	queues, err := h.svc.Queues(ctx)
+	if err != nil {
+		return response.Error(c, fiber.StatusInternalServerError, "Failed to get queues", err.Error())
+	}
+	return response.Success(c, "Queues retrieved successfully", queues)
+}
+
+// ListTasks lists the tasks of a queue in one state, pending by default.
+func (h *TaskQueueHandler) ListTasks(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	tasks, pagination, err := h.svc.ListTasks(ctx, c.Params("queue"), c.Query("state"), page, limit)
+	if err != nil {
+		return taskQueueErrorResponse(c, err, "Failed to list tasks")
+	}
+	return response.SuccessWithMeta(c, "Tasks", tasks, pagination)
+}
+
+func (h *TaskQueueHandler) GetTask(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	task, err := h.svc.GetTask(ctx, c.Params("queue"), c.Params("id"))
+	if err != nil {
+		return taskQueueErrorResponse(c, err, "Failed to get task")
+	}
+	return response.Success(c, "Task retrieved successfully", task)
+}
+
+func (h *TaskQueueHandler) RetryTask(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	if err := h.svc.RetryTask(ctx, c.Params("queue"), c.Params("id")); err != nil {
+		return taskQueueErrorResponse(c, err, "Failed to retry task")
+	}
+	return response.Success(c, "Task scheduled to run now", nil)
+}
+
+func (h *TaskQueueHandler) CancelTask(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	if err := h.svc.CancelTask(ctx, c.Params("queue"), c.Params("id")); err != nil {
+		return taskQueueErrorResponse(c, err, "Failed to cancel task")
+	}
+	return response.Success(c, "Task canceled successfully", nil)
+}
+
+func (h *TaskQueueHandler) ArchiveTask(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	if err := h.svc.ArchiveTask(ctx, c.Params("queue"), c.Params("id")); err != nil {
+		return taskQueueErrorResponse(c, err, "Failed to archive task")
+	}
+	return response.Success(c, "Task archived successfully", nil)
+}
+
+func taskQueueErrorResponse(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrQueueTaskNotFound):
+		return response.Error(c, fiber.StatusNotFound, "Task not found", err.Error())
+	case errors.Is(err, service.ErrInvalidQueueTaskState):
+		return response.Error(c, fiber.StatusConflict, message, err.Error())
+	}
+	return response.Error(c, fiber.StatusInternalServerError, message, err.Error())
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/user/video-downloader-backend/internal/middleware"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/service"
+	"github.com/user/video-downloader-backend/pkg/response"
+	"github.com/user/video-downloader-backend/pkg/utils"
+)
+
+type TwoFactorHandler struct {
+	svc service.TwoFactorService
+}
+
+func NewTwoFactorHandler(svc service.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{svc: svc}
+}
+
+func (h *TwoFactorHandler) Status(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	status, err := h.svc.Status(ctx, userID)
+	if err != nil {
+		return twoFactorError(c, err, "Failed to fetch two-factor status")
+	}
+
+	return response.Success(c, "Two-factor status", status)
+}
+
+func (h *TwoFactorHandler) Setup(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	setup, err := h.svc.Setup(ctx, userID)
+	if err != nil {
+		return twoFactorError(c, err, "Failed to set up two-factor authentication")
+	}
+
+	return response.Success(c, "Scan the code with your authenticator app", setup)
+}
+
+func (h *TwoFactorHandler) Enable(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	var req model.TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	codes, err := h.svc.Enable(ctx, userID, req.Code)
+	if err != nil {
+		return twoFactorError(c, err, "Failed to enable two-factor authentication")
+	}
+
+	return response.Success(c, "Two-factor authentication enabled", model.TwoFactorRecoveryCodes{RecoveryCodes: codes})
+}
+
+func (h *TwoFactorHandler) Disable(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	var req model.TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	if err := h.svc.Disable(ctx, userID, req.Code); err != nil {
+		return twoFactorError(c, err, "Failed to disable two-factor authentication")
+	}
+
+	return response.Success(c, "Two-factor authentication disabled", nil)
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	ctx := middleware.HandlerContext(c)
+
+	userID, ok := c.Locals("user_id").(uuid.UUID)
+	if !ok {
+		return response.Error(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+	}
+
+	var req model.TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.Error(c, fiber.StatusBadRequest, "Invalid request body", err.Error())
+	}
+	if errs := utils.ValidateStruct(req); len(errs) > 0 {
+		return response.Error(c, fiber.StatusBadRequest, response.ValidationErrors{Errors: errs}.Error(), nil)
+	}
+
+	codes, err := h.svc.RegenerateRecoveryCodes(ctx, userID, req.Code)
+	if err != nil {
+		return twoFactorError(c, err, "Failed to regenerate recovery codes")
+	}
+
+	return response.Success(c, "Recovery codes regenerated", model.TwoFactorRecoveryCodes{RecoveryCodes: codes})
+}
+
+func twoFactorError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorChallenge):
+		return response.Error(c, fiber.StatusUnauthorized, "Sign-in expired, please sign in again", err.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		return response.Error(c, fiber.StatusNotFound, "User not found", nil)
+	case errors.Is(err, service.ErrInvalidTwoFactorCode),
+		errors.Is(err, service.ErrTwoFactorSetupExpired),
+		errors.Is(err, service.ErrTwoFactorNotEnabled):
+		return response.Error(c, fiber.StatusBadRequest, message, err.Error())
+	case errors.Is(err, service.ErrTooManyTwoFactorAttempts):
+		return response.Error(c, fiber.StatusTooManyRequests, message, err.Error())
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		return response.Error(c, fiber.StatusConflict, message, err.Error())
+	case errors.Is(err, service.ErrTwoFactorMandatory), errors.Is(err, service.ErrTwoFactorNotAvailable):
+		return response.Error(c, fiber.StatusForbidden, message, err.Error())
+	}
+	return response.Error(c, fiber.StatusInternalServerError, message, err.Error())
+}
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"context"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,6 +14,7 @@ import (
 	"github.com/user/video-downloader-backend/internal/middleware"
 	"github.com/user/video-downloader-backend/internal/repository"
 	"github.com/user/video-downloader-backend/internal/service"
+	"github.com/user/video-downloader-backend/pkg/utils"
 )
 
 type RouteConfig struct {
@@ -21,6 +23,7 @@ type RouteConfig struct {
 	Redis         *redis.Client
 	Cfg           *config.Config
 	StorageClient infrastructure.StorageClient
+	KeyRing       *utils.KeyRing
 }
 
 func SetupRoutes(c *RouteConfig) {
@@ -30,11 +33,18 @@ func SetupRoutes(c *RouteConfig) {
 	adminRepo := repository.NewAdminRepository(c.DB.Pool)
 	applicationRepo := repository.NewApplicationRepository(c.DB.Pool)
 	downloadRepo := repository.NewDownloadRepository(c.DB.Pool)
+	downloadBatchRepo := repository.NewDownloadBatchRepository(c.DB.Pool)
 	subscriptionRepo := repository.NewSubscriptionRepository(c.DB.Pool)
+	analyticRepo := repository.NewAnalyticRepository(c.DB.Pool)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(c.DB.Pool)
+	roleRepo := repository.NewRoleRepository(c.DB.Pool)
+	auditRepo := repository.NewAuditRepository(c.DB.Pool)
+	twoFactorRepo := repository.NewTwoFactorRepository(c.DB.Pool)
 
-	tokenService := service.NewTokenService(c.Cfg)
+	tokenService := service.NewTokenService(c.Cfg, c.Redis)
 	mailHelper := helpers.NewMailHelper(settingRepo)
-	authService := service.NewAuthService(userRepo, mailHelper, tokenService, c.Redis)
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, userRepo, settingRepo, c.KeyRing, c.Redis)
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, mailHelper, tokenService, twoFactorService, c.Redis)
 
 	settingService := service.NewSettingService(settingRepo, c.StorageClient, c.Cfg)
 	userService := service.NewUserService(userRepo, c.StorageClient, c.Cfg)
@@ -42,22 +52,35 @@ func SetupRoutes(c *RouteConfig) {
 	adminService := service.NewAdminService(adminRepo)
 	applicationService := service.NewApplicationService(applicationRepo)
 	webService := service.NewWebService(mailHelper)
+	roleService := service.NewRoleService(roleRepo, userRepo, refreshTokenRepo, tokenService)
+	auditService := service.NewAuditService(auditRepo)
 
+	strategyHealth := infrastructure.NewStrategyHealthTracker(c.Redis)
 	downloader := infrastructure.NewFallbackDownloader()
+	downloader.SetHealthTracker(strategyHealth)
+	downloader.SetInfoCache(infrastructure.NewVideoInfoCache(c.Redis))
 	taskClient := infrastructure.NewTaskClient(c.Cfg.RedisAddr, c.Cfg.RedisPassword)
+	centrifugoClient := infrastructure.NewCentrifugoClient(c.Cfg.CentrifugoURL, c.Cfg.CentrifugoAPIKey)
+	quotaService := service.NewQuotaService(subscriptionRepo, applicationRepo, c.Redis, c.Cfg)
 	downloadService := service.NewDownloadService(
 		downloadRepo,
+		downloadBatchRepo,
 		applicationRepo,
 		platformRepo,
 		downloader,
 		taskClient,
 		c.Redis,
+		centrifugoClient,
+		quotaService,
 	)
 
-	subscriptionService := service.NewSubscriptionService(subscriptionRepo)
+	receiptVerifiers := service.NewReceiptVerifiers(context.Background(), c.Cfg)
+	analyticsService := service.NewAnalyticsService(analyticRepo, service.LoadGeoIP(c.Cfg))
+	subscriptionService := service.NewSubscriptionService(subscriptionRepo, applicationRepo, analyticRepo, receiptVerifiers, centrifugoClient, c.Cfg)
+	taskQueueService := service.NewTaskQueueService(infrastructure.NewTaskInspector(c.Cfg.RedisAddr, c.Cfg.RedisPassword), downloadRepo)
 
 	// Handlers
-	healthHandler := handler.NewHealthHandler(c.DB.Pool, c.Redis)
+	healthHandler := handler.NewHealthHandler(c.DB.Pool, c.Redis, strategyHealth)
 	authHandler := handler.NewAuthHandler(authService)
 	bootstrapHandler := handler.NewBootstrapHandler(c.Redis)
 	mobileErrorHandler := handler.NewMobileErrorHandler()
@@ -66,14 +89,22 @@ func SetupRoutes(c *RouteConfig) {
 	platformHandler := handler.NewPlatformHandler(platformService) // Added Platform
 	adminHandler := handler.NewAdminHandler(adminService)
 	applicationHandler := handler.NewApplicationHandler(applicationService)
-	downloadHandler := handler.NewDownloadHandler(downloadService, userService)
+	downloadHandler := handler.NewDownloadHandler(downloadService, c.StorageClient, c.Cfg.MinioBucket, c.KeyRing)
 	webHandler := handler.NewWebHandler(webService)
 	centrifugoHandler := handler.NewCentrifugoHandler(tokenService)
 	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsService)
+	taskQueueHandler := handler.NewTaskQueueHandler(taskQueueService)
+	roleHandler := handler.NewRoleHandler(roleService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService)
 
 	credentialLimiter := middleware.CredentialAttemptLimiter(c.Redis)
+	refreshLimiter := middleware.RefreshAttemptLimiter(c.Redis)
 	rateLimitDownload := middleware.RateLimitDownloadRedis(c.Redis)
 	csrfMiddleware := middleware.NewCSRF(c.Redis)
+	// Downloads are open to guests; a token only decides whose quota and plan apply.
+	optionalJWT := middleware.OptionalJWTMiddleware(tokenService)
 
 	api := c.App.Group("/api/v1")
 
@@ -97,100 +128,165 @@ func SetupRoutes(c *RouteConfig) {
 	publicProxy := api.Group("/public-proxy")
 	publicMobile := api.Group("/mobile-client")
 	publicMobile.Use(middleware.MobileSignatureMiddleware(c.Redis))
+	webhooks := api.Group("/webhooks")
 
 	publicAdmin.Post("/auth/google", credentialLimiter, authHandler.GoogleLogin)
 	publicAdmin.Post("/auth/email", credentialLimiter, authHandler.LoginEmail)
 	publicAdmin.Post("/auth/forgot-password", credentialLimiter, authHandler.ForgotPassword)
 	publicAdmin.Post("/auth/reset-password", credentialLimiter, authHandler.ResetPassword)
+	publicAdmin.Post("/auth/refresh", refreshLimiter, authHandler.RefreshToken)
+	publicAdmin.Post("/auth/2fa/setup", credentialLimiter, authHandler.SetupTwoFactorChallenge)
+	publicAdmin.Post("/auth/2fa/verify", credentialLimiter, authHandler.VerifyTwoFactorChallenge)
 	publicAdmin.Post("/auth/logout", authHandler.Logout)
 
 	publicAdmin.Get("/settings/public", settingHandler.GetPublicSettings)
 	publicWeb.Get("/settings/public", settingHandler.GetPublicSettings)
 
 	// Protected Admin Routes
-	protectedAdmin := api.Group("/protected-admin", middleware.JWTMiddleware(tokenService), middleware.AdminMiddleware())
+	protectedAdmin := api.Group("/protected-admin", middleware.AdminJWTMiddleware(tokenService), middleware.AdminMiddleware())
+
+	// audit records a mutating admin route, refused attempts included.
+	audit := func(action string, load middleware.AuditLoader) fiber.Handler {
+		return middleware.Audit(auditService, action, load)
+	}
+	currentUser := middleware.AuditCurrentUser(userService.FindByID)
+	userByID := middleware.AuditByID(userService.FindByID)
+	roleByID := middleware.AuditByID(roleService.FindByID)
+	platformByID := middleware.AuditByID(platformService.GetPlatformByID)
+	applicationByID := middleware.AuditByID(applicationService.FindByID)
+	downloadByID := middleware.AuditByID(downloadService.FindByID)
+	subscriptionByID := middleware.AuditByID(subscriptionService.FindByID)
 
 	protectedAdmin.Post("/auth/logout", authHandler.Logout)
+	protectedAdmin.Post("/auth/logout-all", authHandler.LogoutAll)
+	protectedAdmin.Get("/auth/2fa", twoFactorHandler.Status)
+	protectedAdmin.Post("/auth/2fa/setup", csrfMiddleware, twoFactorHandler.Setup)
+	protectedAdmin.Post("/auth/2fa/enable", audit("two_factor.enable", nil), csrfMiddleware, twoFactorHandler.Enable)
+	protectedAdmin.Post("/auth/2fa/disable", audit("two_factor.disable", nil), csrfMiddleware, twoFactorHandler.Disable)
+	protectedAdmin.Post("/auth/2fa/recovery-codes", audit("two_factor.recovery_codes", nil), csrfMiddleware, twoFactorHandler.RegenerateRecoveryCodes)
 
 	// Settings
-	protectedAdmin.Get("/settings", settingHandler.GetAllSettings)
-	protectedAdmin.Put("/settings/bulk", csrfMiddleware, settingHandler.UpdateSettingsBulk)
-	protectedAdmin.Post("/settings/upload", csrfMiddleware, settingHandler.UploadFile)
+	protectedAdmin.Get("/settings", middleware.RequirePermission("settings:read"), settingHandler.GetAllSettings)
+	protectedAdmin.Put("/settings/bulk", audit("settings.update", settingHandler.AuditSnapshot), middleware.RequirePermission("settings:write"), csrfMiddleware, settingHandler.UpdateSettingsBulk)
+	protectedAdmin.Post("/settings/upload", audit("settings.upload", settingHandler.AuditSnapshot), middleware.RequirePermission("settings:write"), csrfMiddleware, settingHandler.UploadFile)
 
 	// Admin users
 	protectedAdmin.Get("/users/current", userHandler.GetCurrentUser)
-	protectedAdmin.Put("/users/profile", csrfMiddleware, userHandler.UpdateProfile)
-	protectedAdmin.Put("/users/password", csrfMiddleware, userHandler.UpdatePassword)
-	protectedAdmin.Post("/users/avatar", csrfMiddleware, userHandler.UploadAvatar)
-	protectedAdmin.Get("/users/search", userHandler.FindAll)
-	protectedAdmin.Get("/users/find/:id", userHandler.FindByID)
-	protectedAdmin.Delete("/users/bulk", csrfMiddleware, userHandler.BulkDelete)
-	protectedAdmin.Delete("/users/:id", csrfMiddleware, userHandler.Delete)
+	protectedAdmin.Put("/users/profile", audit("user.profile_update", currentUser), csrfMiddleware, userHandler.UpdateProfile)
+	protectedAdmin.Put("/users/password", audit("user.password_update", nil), csrfMiddleware, userHandler.UpdatePassword)
+	protectedAdmin.Post("/users/avatar", audit("user.avatar_upload", currentUser), csrfMiddleware, userHandler.UploadAvatar)
+	protectedAdmin.Get("/users/search", middleware.RequirePermission("users:read"), userHandler.FindAll)
+	protectedAdmin.Get("/users/find/:id", middleware.RequirePermission("users:read"), userHandler.FindByID)
+	protectedAdmin.Delete("/users/bulk", audit("user.bulk_delete", nil), middleware.RequirePermission("users:delete"), csrfMiddleware, userHandler.BulkDelete)
+	protectedAdmin.Delete("/users/:id", audit("user.delete", userByID), middleware.RequirePermission("users:delete"), csrfMiddleware, userHandler.Delete)
+	protectedAdmin.Put("/users/:id/role", audit("user.role_assign", userByID), middleware.RequirePermission("roles:assign"), csrfMiddleware, roleHandler.AssignRole)
+
+	// Roles
+	protectedAdmin.Get("/roles", middleware.RequirePermission("roles:read"), roleHandler.GetRoles)
+	protectedAdmin.Get("/roles/permissions", middleware.RequirePermission("roles:read"), roleHandler.GetPermissions)
+	protectedAdmin.Get("/roles/:id", middleware.RequirePermission("roles:read"), roleHandler.GetRole)
+	protectedAdmin.Post("/roles", audit("role.create", nil), middleware.RequirePermission("roles:write"), csrfMiddleware, roleHandler.CreateRole)
+	protectedAdmin.Put("/roles/:id", audit("role.update", roleByID), middleware.RequirePermission("roles:write"), csrfMiddleware, roleHandler.UpdateRole)
+	protectedAdmin.Delete("/roles/:id", audit("role.delete", roleByID), middleware.RequirePermission("roles:write"), csrfMiddleware, roleHandler.DeleteRole)
 
 	// Dashboard
-	protectedAdmin.Get("/dashboard", adminHandler.GetDashboardData)
+	protectedAdmin.Get("/dashboard", middleware.RequirePermission("dashboard:read"), adminHandler.GetDashboardData)
+	protectedAdmin.Get("/analytics/downloads", middleware.RequirePermission("analytics:read"), analyticsHandler.DownloadSeries)
 
 	// Platforms (Added CRUD routes)
-	protectedAdmin.Get("/platforms", platformHandler.GetPlatforms)
-	protectedAdmin.Get("/platforms/:id", platformHandler.GetPlatformByID)
-	protectedAdmin.Get("/platforms/type/:type", platformHandler.GetPlatformByType)
-	protectedAdmin.Get("/platforms/slug/:slug", platformHandler.GetPlatformBySlug)
-	protectedAdmin.Post("/platforms", csrfMiddleware, platformHandler.CreatePlatform)
-	protectedAdmin.Put("/platforms/:id", csrfMiddleware, platformHandler.UpdatePlatform)
-	protectedAdmin.Post("/platforms/thumbnail/:id", csrfMiddleware, platformHandler.UploadThumbnail)
-	protectedAdmin.Delete("/platforms/:id", csrfMiddleware, platformHandler.DeletePlatform)
-	protectedAdmin.Delete("/platforms/bulk", csrfMiddleware, platformHandler.BulkDeletePlatforms)
+	protectedAdmin.Get("/platforms", middleware.RequirePermission("platforms:read"), platformHandler.GetPlatforms)
+	protectedAdmin.Get("/platforms/:id", middleware.RequirePermission("platforms:read"), platformHandler.GetPlatformByID)
+	protectedAdmin.Get("/platforms/type/:type", middleware.RequirePermission("platforms:read"), platformHandler.GetPlatformByType)
+	protectedAdmin.Get("/platforms/slug/:slug", middleware.RequirePermission("platforms:read"), platformHandler.GetPlatformBySlug)
+	protectedAdmin.Post("/platforms", audit("platform.create", nil), middleware.RequirePermission("platforms:write"), csrfMiddleware, platformHandler.CreatePlatform)
+	protectedAdmin.Put("/platforms/:id", audit("platform.update", platformByID), middleware.RequirePermission("platforms:write"), csrfMiddleware, platformHandler.UpdatePlatform)
+	protectedAdmin.Post("/platforms/thumbnail/:id", audit("platform.thumbnail_upload", platformByID), middleware.RequirePermission("platforms:write"), csrfMiddleware, platformHandler.UploadThumbnail)
+	protectedAdmin.Delete("/platforms/:id", audit("platform.delete", platformByID), middleware.RequirePermission("platforms:delete"), csrfMiddleware, platformHandler.DeletePlatform)
+	protectedAdmin.Delete("/platforms/bulk", audit("platform.bulk_delete", nil), middleware.RequirePermission("platforms:delete"), csrfMiddleware, platformHandler.BulkDeletePlatforms)
 
 	// Application
-	protectedAdmin.Get("/applications", applicationHandler.GetApplications)
-	protectedAdmin.Get("/applications/:id", applicationHandler.FindByID)
-	protectedAdmin.Post("/applications", csrfMiddleware, applicationHandler.RegisterApp)
-	protectedAdmin.Delete("/applications/bulk", csrfMiddleware, applicationHandler.BulkDeleteApps)
-	protectedAdmin.Put("/applications/:id", csrfMiddleware, applicationHandler.UpdateApp)
-	protectedAdmin.Delete("/applications/:id", csrfMiddleware, applicationHandler.DeleteApp)
+	protectedAdmin.Get("/applications", middleware.RequirePermission("applications:read"), applicationHandler.GetApplications)
+	protectedAdmin.Get("/applications/:id", middleware.RequirePermission("applications:read"), applicationHandler.FindByID)
+	protectedAdmin.Post("/applications", audit("application.create", nil), middleware.RequirePermission("applications:write"), csrfMiddleware, applicationHandler.RegisterApp)
+	protectedAdmin.Delete("/applications/bulk", audit("application.bulk_delete", nil), middleware.RequirePermission("applications:delete"), csrfMiddleware, applicationHandler.BulkDeleteApps)
+	protectedAdmin.Put("/applications/:id", audit("application.update", applicationByID), middleware.RequirePermission("applications:write"), csrfMiddleware, applicationHandler.UpdateApp)
+	protectedAdmin.Delete("/applications/:id", audit("application.delete", applicationByID), middleware.RequirePermission("applications:delete"), csrfMiddleware, applicationHandler.DeleteApp)
 
 	// Downloads
-	protectedAdmin.Get("/downloads", downloadHandler.GetDownloads)
-	protectedAdmin.Get("/downloads/:id", downloadHandler.FindByID)
-	protectedAdmin.Delete("/downloads/bulk", csrfMiddleware, downloadHandler.BulkDeleteDownloads)
-	protectedAdmin.Put("/downloads/:id", csrfMiddleware, downloadHandler.UpdateDownload)
-	protectedAdmin.Delete("/downloads/:id", csrfMiddleware, downloadHandler.DeleteDownload)
+	protectedAdmin.Get("/downloads", middleware.RequirePermission("downloads:read"), downloadHandler.GetDownloads)
+	protectedAdmin.Get("/downloads/batch/:id", middleware.RequirePermission("downloads:read"), downloadHandler.FindBatchByID)
+	protectedAdmin.Get("/downloads/:id", middleware.RequirePermission("downloads:read"), downloadHandler.FindByID)
+	protectedAdmin.Delete("/downloads/bulk", audit("download.bulk_delete", nil), middleware.RequirePermission("downloads:delete"), csrfMiddleware, downloadHandler.BulkDeleteDownloads)
+	protectedAdmin.Put("/downloads/:id", audit("download.update", downloadByID), middleware.RequirePermission("downloads:write"), csrfMiddleware, downloadHandler.UpdateDownload)
+	protectedAdmin.Delete("/downloads/:id", audit("download.delete", downloadByID), middleware.RequirePermission("downloads:delete"), csrfMiddleware, downloadHandler.DeleteDownload)
+
+	// Task queues
+	protectedAdmin.Get("/tasks/queues", middleware.RequirePermission("tasks:read"), taskQueueHandler.Queues)
+	protectedAdmin.Get("/tasks/:queue", middleware.RequirePermission("tasks:read"), taskQueueHandler.ListTasks)
+	protectedAdmin.Get("/tasks/:queue/:id", middleware.RequirePermission("tasks:read"), taskQueueHandler.GetTask)
+	protectedAdmin.Post("/tasks/:queue/:id/retry", audit("task.retry", nil), middleware.RequirePermission("tasks:write"), csrfMiddleware, taskQueueHandler.RetryTask)
+	protectedAdmin.Post("/tasks/:queue/:id/cancel", audit("task.cancel", nil), middleware.RequirePermission("tasks:write"), csrfMiddleware, taskQueueHandler.CancelTask)
+	protectedAdmin.Post("/tasks/:queue/:id/archive", audit("task.archive", nil), middleware.RequirePermission("tasks:write"), csrfMiddleware, taskQueueHandler.ArchiveTask)
 
 	// subscription
-	protectedAdmin.Get("/subscriptions", subscriptionHandler.FindAll)
-	protectedAdmin.Get("/subscriptions/:id", subscriptionHandler.FindByID)
-	protectedAdmin.Delete("/subscriptions/bulk", csrfMiddleware, subscriptionHandler.BulkDelete)
-	protectedAdmin.Delete("/subscriptions/:id", csrfMiddleware, subscriptionHandler.Delete)
+	protectedAdmin.Get("/subscriptions", middleware.RequirePermission("subscriptions:read"), subscriptionHandler.FindAll)
+	protectedAdmin.Get("/subscriptions/:id", middleware.RequirePermission("subscriptions:read"), subscriptionHandler.FindByID)
+	protectedAdmin.Put("/subscriptions/:id/status", audit("subscription.status_update", subscriptionByID), middleware.RequirePermission("subscriptions:write"), csrfMiddleware, subscriptionHandler.UpdateStatus)
+	protectedAdmin.Delete("/subscriptions/bulk", audit("subscription.bulk_delete", nil), middleware.RequirePermission("subscriptions:delete"), csrfMiddleware, subscriptionHandler.BulkDelete)
+	protectedAdmin.Delete("/subscriptions/:id", audit("subscription.delete", subscriptionByID), middleware.RequirePermission("subscriptions:delete"), csrfMiddleware, subscriptionHandler.Delete)
 
 	// Health Check
-	protectedAdmin.Get("/health/check", healthHandler.Check)
-	protectedAdmin.Get("/health/log", healthHandler.GetLogger)
-	protectedAdmin.Post("/health/log", csrfMiddleware, healthHandler.ClearLogs)
+	protectedAdmin.Get("/health/check", middleware.RequirePermission("health:read"), healthHandler.Check)
+	protectedAdmin.Get("/health/strategies", middleware.RequirePermission("health:read"), healthHandler.GetStrategyHealth)
+	protectedAdmin.Post("/health/strategies/reset", audit("health.breaker_reset", nil), middleware.RequirePermission("health:write"), csrfMiddleware, healthHandler.ResetStrategyBreaker)
+	protectedAdmin.Get("/health/log", middleware.RequirePermission("health:read"), healthHandler.GetLogger)
+	protectedAdmin.Post("/health/log", audit("health.logs_clear", nil), middleware.RequirePermission("health:write"), csrfMiddleware, healthHandler.ClearLogs)
 
 	// cookies
-	protectedAdmin.Get("/cookies", adminHandler.GetCookies)
-	protectedAdmin.Put("/cookies", csrfMiddleware, adminHandler.UpdateCookies)
+	protectedAdmin.Get("/cookies", middleware.RequirePermission("cookies:read"), adminHandler.GetCookies)
+	protectedAdmin.Put("/cookies", audit("cookies.update", nil), middleware.RequirePermission("cookies:write"), csrfMiddleware, adminHandler.UpdateCookies)
+
+	// audit
+	protectedAdmin.Get("/audit", middleware.RequirePermission("audit:read"), auditHandler.Search)
 
 	// Web Client Routes
 	publicWeb.Get("/centrifugo/token", centrifugoHandler.GetToken)
 	publicWeb.Post("/contact", csrfMiddleware, webHandler.Contact)
+	publicWeb.Post("/auth/refresh", refreshLimiter, csrfMiddleware, authHandler.RefreshToken)
+	publicWeb.Post("/auth/verify-email", credentialLimiter, csrfMiddleware, authHandler.VerifyEmail)
 	publicWeb.Post("/report/errors", csrfMiddleware, webHandler.ReportError)
 	publicWeb.Get("/platforms", platformHandler.GetAll)
 	publicWeb.Get("/platforms/:id", platformHandler.GetPlatformByID)
 	publicWeb.Get("/platforms/type/:type", platformHandler.GetPlatformByType)
 	publicWeb.Get("/platforms/slug/:slug", platformHandler.GetPlatformBySlug)
 	publicWeb.Get("/platforms/category/:category", platformHandler.GetPlatformsByCategory)
-	publicWeb.Post("/download/process/video", rateLimitDownload, csrfMiddleware, downloadHandler.DownloadVideo)
-	publicWeb.Post("/download/process/mp3", rateLimitDownload, csrfMiddleware, downloadHandler.DownloadVideoToMp3)
+	publicWeb.Post("/download/process/video", optionalJWT, rateLimitDownload, csrfMiddleware, downloadHandler.DownloadVideo)
+	publicWeb.Post("/download/process/mp3", optionalJWT, rateLimitDownload, csrfMiddleware, downloadHandler.DownloadVideoToMp3)
+	publicWeb.Post("/download/process/batch", optionalJWT, rateLimitDownload, csrfMiddleware, downloadHandler.DownloadBatch)
+	publicWeb.Get("/download/batch/:id", optionalJWT, downloadHandler.FindOwnedBatch)
+	publicWeb.Post("/download/:id/cancel", optionalJWT, csrfMiddleware, downloadHandler.CancelDownload)
 	publicProxy.Get("/downloads/file/video", downloadHandler.ProxyDownload)
 	publicProxy.Get("/downloads/file/mp3", downloadHandler.ProxyDownloadMp3)
 
 	protectedUserWeb := publicWeb.Group("/protected-web", middleware.JWTMiddleware(tokenService))
 
+	// Accounts that have not verified their email may only sign out, verify,
+	// look at themselves and delete themselves.
+	verifiedEmail := middleware.RequireVerifiedEmail()
+
+	protectedUserWeb.Post("/auth/logout", csrfMiddleware, authHandler.Logout)
+	protectedUserWeb.Post("/auth/logout-all", csrfMiddleware, authHandler.LogoutAll)
+	protectedUserWeb.Post("/auth/verify-email/code", credentialLimiter, csrfMiddleware, authHandler.VerifyEmailCode)
+	protectedUserWeb.Post("/auth/resend-verification", csrfMiddleware, authHandler.ResendVerification)
 	protectedUserWeb.Get("/users/current", userHandler.GetCurrentUser)
-	protectedUserWeb.Put("/users/profile", csrfMiddleware, userHandler.UpdateProfile)
-	protectedUserWeb.Put("/users/password", csrfMiddleware, userHandler.UpdatePassword)
-	protectedUserWeb.Post("/users/avatar", csrfMiddleware, userHandler.UploadAvatar)
+	protectedUserWeb.Put("/users/profile", verifiedEmail, csrfMiddleware, userHandler.UpdateProfile)
+	protectedUserWeb.Put("/users/password", verifiedEmail, csrfMiddleware, userHandler.UpdatePassword)
+	protectedUserWeb.Post("/users/avatar", verifiedEmail, csrfMiddleware, userHandler.UploadAvatar)
+	protectedUserWeb.Delete("/users/account", credentialLimiter, csrfMiddleware, authHandler.DeleteAccount)
+
+	// Store server notifications
+	webhooks.Post("/google-play", subscriptionHandler.GooglePlayNotification)
+	webhooks.Post("/app-store", subscriptionHandler.AppStoreNotification)
 
 	// Mobile client
 	publicMobile.Post("/bootstrap", bootstrapHandler.Bootstrap)
@@ -203,6 +299,10 @@ func SetupRoutes(c *RouteConfig) {
 	publicMobile.Post("/auth/register", credentialLimiter, authHandler.RegisterEmail)
 	publicMobile.Post("/auth/forgot-password", credentialLimiter, authHandler.ForgotPassword)
 	publicMobile.Post("/auth/reset-password", credentialLimiter, authHandler.ResetPassword)
+	publicMobile.Post("/auth/refresh", refreshLimiter, authHandler.RefreshToken)
+	publicMobile.Post("/auth/2fa/setup", credentialLimiter, authHandler.SetupTwoFactorChallenge)
+	publicMobile.Post("/auth/2fa/verify", credentialLimiter, authHandler.VerifyTwoFactorChallenge)
+	publicMobile.Post("/auth/verify-email", credentialLimiter, authHandler.VerifyEmail)
 
 	publicMobile.Get("/settings/public", settingHandler.GetPublicSettings)
 	publicMobile.Get("/centrifugo/token", middleware.OptionalJWTMiddleware(tokenService), centrifugoHandler.GetToken)
@@ -213,18 +313,25 @@ func SetupRoutes(c *RouteConfig) {
 	publicMobile.Get("/platforms/slug/:slug", platformHandler.GetPlatformBySlug)
 	publicMobile.Get("/platforms/category/:category", platformHandler.GetPlatformsByCategory)
 
-	publicMobile.Post("/download/process/video", rateLimitDownload, downloadHandler.DownloadVideo)
-	publicMobile.Post("/download/process/mp3", rateLimitDownload, downloadHandler.DownloadVideoToMp3)
+	publicMobile.Post("/download/process/video", optionalJWT, rateLimitDownload, downloadHandler.DownloadVideo)
+	publicMobile.Post("/download/process/mp3", optionalJWT, rateLimitDownload, downloadHandler.DownloadVideoToMp3)
+	publicMobile.Post("/download/process/batch", optionalJWT, rateLimitDownload, downloadHandler.DownloadBatch)
+	publicMobile.Get("/downloads/batch/:id", optionalJWT, downloadHandler.FindOwnedBatch)
 	publicMobile.Get("/downloads/:id", downloadHandler.FindByID)
+	publicMobile.Post("/downloads/:id/cancel", optionalJWT, downloadHandler.CancelDownload)
 
 	protectedUserMobile := publicMobile.Group("/protected-mobile", middleware.JWTMiddleware(tokenService))
 	protectedUserMobile.Get("/users/current", userHandler.GetCurrentUser)
 	protectedUserMobile.Get("/downloads", downloadHandler.GetHistory)
 	protectedUserMobile.Get("/downloads/:id", downloadHandler.FindByIDForCurrentUser)
 	protectedUserMobile.Post("/auth/logout", authHandler.Logout)
-	protectedUserMobile.Post("/subscriptions", subscriptionHandler.UpsertMobile)
+	protectedUserMobile.Post("/auth/logout-all", authHandler.LogoutAll)
+	protectedUserMobile.Post("/auth/verify-email/code", credentialLimiter, authHandler.VerifyEmailCode)
+	protectedUserMobile.Post("/auth/resend-verification", authHandler.ResendVerification)
+	protectedUserMobile.Post("/subscriptions", verifiedEmail, subscriptionHandler.UpsertMobile)
 	protectedUserMobile.Get("/subscriptions/current", subscriptionHandler.GetCurrentMobile)
-	protectedUserMobile.Put("/users/profile", userHandler.UpdateProfile)
-	protectedUserMobile.Put("/users/password", userHandler.UpdatePassword)
-	protectedUserMobile.Post("/users/avatar", userHandler.UploadAvatar)
+	protectedUserMobile.Put("/users/profile", verifiedEmail, userHandler.UpdateProfile)
+	protectedUserMobile.Put("/users/password", verifiedEmail, userHandler.UpdatePassword)
+	protectedUserMobile.Post("/users/avatar", verifiedEmail, userHandler.UploadAvatar)
+	protectedUserMobile.Delete("/users/account", credentialLimiter, authHandler.DeleteAccount)
 }
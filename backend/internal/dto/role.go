@@ -0,0 +1,10 @@
+package dto
+
+type RoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=50"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type AssignRoleRequest struct {
+	RoleID string `json:"role_id" validate:"required,uuid4"`
+}
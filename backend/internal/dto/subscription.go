@@ -11,3 +11,7 @@ type MobileSubscriptionUpsertRequest struct {
 	AutoRenew             bool   `json:"auto_renew" validate:"omitempty"`
 }
 
+
+type UpdateSubscriptionStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=trial active grace on_hold paused canceled expired refunded"`
+}
@@ -0,0 +1,122 @@
+package infrastructure
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Marker extensions Apple puts on the certificates that sign App Store data.
+var (
+	appStoreLeafOID         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	appStoreIntermediateOID = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+// LoadAppStoreRootCert reads Apple Root CA - G3 in DER or PEM form, as
+// downloaded from https://www.apple.com/certificateauthority/.
+func LoadAppStoreRootCert(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app store root certificate: %w", err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse app store root certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// VerifyAppStoreJWS checks a JWS signed by the App Store (signedPayload,
+// signedTransactionInfo, signedRenewalInfo) against root and decodes its payload
+// into out. The x5c chain must lead to root and carry Apple's marker extensions.
+func VerifyAppStoreJWS(token string, root *x509.Certificate, out any) error {
+	if root == nil {
+		return errors.New("app store root certificate is not configured")
+	}
+
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"ES256"}), jwt.WithoutClaimsValidation())
+	_, err := parser.Parse(token, func(t *jwt.Token) (any, error) {
+		chain, ok := t.Header["x5c"].([]any)
+		if !ok || len(chain) < 2 {
+			return nil, errors.New("missing x5c certificate chain")
+		}
+		certs := make([]*x509.Certificate, 0, len(chain))
+		for _, c := range chain {
+			s, ok := c.(string)
+			if !ok {
+				return nil, errors.New("invalid x5c entry")
+			}
+			der, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid x5c entry: %w", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid x5c certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+
+		if !hasExtension(certs[0], appStoreLeafOID) || !hasExtension(certs[1], appStoreIntermediateOID) {
+			return nil, errors.New("certificate chain is not issued for the app store")
+		}
+
+		roots := x509.NewCertPool()
+		roots.AddCert(root)
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   time.Now(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return nil, fmt.Errorf("untrusted certificate chain: %w", err)
+		}
+		return certs[0].PublicKey, nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid app store signature: %w", err)
+	}
+	return decodeJWSPayload(token, out)
+}
+
+// decodeJWSPayload decodes the payload of a JWS without checking its signature.
+// It is only used on data received directly from Apple over TLS.
+func decodeJWSPayload(token string, out any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed jws")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed jws payload: %w", err)
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf("failed to decode jws payload: %w", err)
+	}
+	return nil
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	appStoreProductionURL = "https://api.storekit.itunes.apple.com"
+	appStoreSandboxURL    = "https://api.storekit-sandbox.itunes.apple.com"
+)
+
+// AppStoreTransaction is the decoded payload of a signedTransactionInfo.
+type AppStoreTransaction struct {
+	TransactionID         string `json:"transactionId"`
+	OriginalTransactionID string `json:"originalTransactionId"`
+	BundleID              string `json:"bundleId"`
+	ProductID             string `json:"productId"`
+	PurchaseDate          int64  `json:"purchaseDate"`
+	OriginalPurchaseDate  int64  `json:"originalPurchaseDate"`
+	ExpiresDate           int64  `json:"expiresDate"`
+	RevocationDate        int64  `json:"revocationDate"`
+	Type                  string `json:"type"`
+	AppAccountToken       string `json:"appAccountToken"`
+	Environment           string `json:"environment"`
+	Price                 int64  `json:"price"` // milliunits of Currency
+	Currency              string `json:"currency"`
+	OfferType             int    `json:"offerType"`
+	OfferDiscountType     string `json:"offerDiscountType"`
+}
+
+// AppStoreRenewalInfo is the decoded payload of a signedRenewalInfo.
+type AppStoreRenewalInfo struct {
+	OriginalTransactionID string `json:"originalTransactionId"`
+	AutoRenewProductID    string `json:"autoRenewProductId"`
+	AutoRenewStatus       int    `json:"autoRenewStatus"`
+	IsInBillingRetry      bool   `json:"isInBillingRetryPeriod"`
+	GracePeriodExpiresAt  int64  `json:"gracePeriodExpiresDate"`
+}
+
+type AppStoreVerifierConfig struct {
+	IssuerID       string
+	KeyID          string
+	PrivateKeyFile string // the .p8 key of an In-App Purchase API key
+	// RootCert, when set, is used to check the signatures of the transactions
+	// returned by the API as well.
+	RootCert *x509.Certificate
+	Sandbox  bool
+}
+
+type appStoreVerifier struct {
+	cfg        AppStoreVerifierConfig
+	key        *ecdsa.PrivateKey
+	httpClient *http.Client
+}
+
+// NewAppStoreVerifier checks subscriptions with the App Store Server API.
+// Production is asked first and the sandbox on a miss, so TestFlight purchases
+// verify against the same deployment.
+func NewAppStoreVerifier(cfg AppStoreVerifierConfig) (ReceiptVerifier, error) {
+	if cfg.IssuerID == "" || cfg.KeyID == "" {
+		return nil, errors.New("app store issuer id and key id are required")
+	}
+	pemData, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app store private key: %w", err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pemData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse app store private key: %w", err)
+	}
+	return &appStoreVerifier{
+		cfg:        cfg,
+		key:        key,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (v *appStoreVerifier) Verify(ctx context.Context, req ReceiptRequest) (*ReceiptVerification, error) {
+	transactionID := req.TransactionID
+	if transactionID == "" {
+		transactionID = req.PurchaseToken
+	}
+	if transactionID == "" || req.PackageName == "" {
+		return nil, invalidReceipt("bundle id and transaction id are required")
+	}
+
+	var (
+		body map[string]any
+		err  error
+	)
+	if v.cfg.Sandbox {
+		body, err = v.getSubscriptionStatuses(ctx, appStoreSandboxURL, req.PackageName, transactionID)
+	} else {
+		body, err = v.getSubscriptionStatuses(ctx, appStoreProductionURL, req.PackageName, transactionID)
+		if errors.Is(err, ErrReceiptInvalid) {
+			body, err = v.getSubscriptionStatuses(ctx, appStoreSandboxURL, req.PackageName, transactionID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses struct {
+		BundleID string `json:"bundleId"`
+		Data     []struct {
+			LastTransactions []struct {
+				OriginalTransactionID string `json:"originalTransactionId"`
+				Status                int    `json:"status"`
+				SignedTransactionInfo string `json:"signedTransactionInfo"`
+				SignedRenewalInfo     string `json:"signedRenewalInfo"`
+			} `json:"lastTransactions"`
+		} `json:"data"`
+	}
+	if err := remarshal(body, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to decode app store response: %w", err)
+	}
+	if statuses.BundleID != req.PackageName {
+		return nil, invalidReceipt("purchase belongs to bundle %q", statuses.BundleID)
+	}
+
+	for _, group := range statuses.Data {
+		for _, last := range group.LastTransactions {
+			var tx AppStoreTransaction
+			if err := v.decode(last.SignedTransactionInfo, &tx); err != nil {
+				return nil, err
+			}
+			if tx.ProductID != req.ProductID {
+				continue
+			}
+			var renewal AppStoreRenewalInfo
+			if last.SignedRenewalInfo != "" {
+				if err := v.decode(last.SignedRenewalInfo, &renewal); err != nil {
+					return nil, err
+				}
+			}
+
+			out := AppStoreVerification(&tx, &renewal, last.Status)
+			out.Raw = body
+			return out, nil
+		}
+	}
+	return nil, invalidReceipt("purchase does not contain product %q", req.ProductID)
+}
+
+// AppStoreVerification maps a transaction, its renewal info and an App Store
+// subscription status (1 active, 2 expired, 3 billing retry, 4 grace period,
+// 5 revoked) onto a ReceiptVerification.
+func AppStoreVerification(tx *AppStoreTransaction, renewal *AppStoreRenewalInfo, status int) *ReceiptVerification {
+	out := &ReceiptVerification{
+		Provider:              ReceiptProviderAppStore,
+		OriginalTransactionID: tx.OriginalTransactionID,
+		ProviderTransactionID: tx.TransactionID,
+		ProductID:             tx.ProductID,
+		StartTime:             time.UnixMilli(tx.OriginalPurchaseDate),
+		EndTime:               time.UnixMilli(tx.ExpiresDate),
+		AutoRenew:             renewal != nil && renewal.AutoRenewStatus == 1,
+		Amount:                float64(tx.Price) / 1000,
+		Currency:              tx.Currency,
+		Test:                  tx.Environment == "Sandbox",
+	}
+	if tx.OriginalPurchaseDate == 0 {
+		out.StartTime = time.UnixMilli(tx.PurchaseDate)
+	}
+
+	switch status {
+	case 1:
+		out.Status = "active"
+		if tx.OfferType == 1 && tx.OfferDiscountType == "FREE_TRIAL" {
+			out.Status = "trial"
+		}
+		if !out.AutoRenew {
+			out.Status = "canceled"
+		}
+	case 3:
+		out.Status = "on_hold"
+	case 4:
+		out.Status = "grace"
+		if renewal != nil && renewal.GracePeriodExpiresAt > tx.ExpiresDate {
+			out.EndTime = time.UnixMilli(renewal.GracePeriodExpiresAt)
+		}
+	default:
+		out.Status = "expired"
+	}
+	if tx.RevocationDate > 0 {
+		out.Status = "expired"
+		out.Refunded = true
+		out.EndTime = time.UnixMilli(tx.RevocationDate)
+	}
+	return out
+}
+
+func (v *appStoreVerifier) decode(token string, out any) error {
+	if v.cfg.RootCert != nil {
+		return VerifyAppStoreJWS(token, v.cfg.RootCert, out)
+	}
+	return decodeJWSPayload(token, out)
+}
+
+func (v *appStoreVerifier) getSubscriptionStatuses(ctx context.Context, baseURL, bundleID, transactionID string) (map[string]any, error) {
+	token, err := v.authToken(bundleID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/inApps/v1/subscriptions/"+url.PathEscape(transactionID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call app store server api: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app store response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusNotFound:
+		return nil, invalidReceipt("app store server api returned %d: %s", resp.StatusCode, string(data))
+	default:
+		return nil, fmt.Errorf("app store server api returned %d: %s", resp.StatusCode, string(data))
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to decode app store response: %w", err)
+	}
+	return body, nil
+}
+
+func (v *appStoreVerifier) authToken(bundleID string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": v.cfg.IssuerID,
+		"iat": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+		"aud": "appstoreconnect-v1",
+		"bid": bundleID,
+	})
+	token.Header["kid"] = v.cfg.KeyID
+	signed, err := token.SignedString(v.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign app store api token: %w", err)
+	}
+	return signed, nil
+}
+
+func remarshal(in any, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -3,9 +3,14 @@ package infrastructure
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
 	"github.com/user/video-downloader-backend/internal/model"
 )
 
@@ -13,24 +18,80 @@ const (
 	TypeVideoDownload    = "video:download"
 	TypeMp3Download      = "mp3:download"
 	DownloadEventChannel = "download:events"
+
+	// TypeSubscriptionExpiry is enqueued by the worker scheduler to expire
+	// subscriptions whose end time has passed.
+	TypeSubscriptionExpiry = "subscription:expire"
+	// TypeAnalyticsAggregate recomputes analytics_daily for a range of days.
+	TypeAnalyticsAggregate = "analytics:aggregate"
+	// TypeRefreshTokenCleanup deletes refresh tokens past their expiry.
+	TypeRefreshTokenCleanup = "auth:refresh-token-cleanup"
+	// TypeAccountPurge removes the downloads and files of deleted accounts.
+	TypeAccountPurge = "account:purge"
+)
+
+// Queues served by the task server, highest priority first.
+const (
+	QueueCritical = "critical"
+	QueueDefault  = "default"
+	QueueLow      = "low"
 )
 
+// taskPolicy is how one task type is queued and retried. Retries back off
+// exponentially from retryDelay up to maxRetryDelay.
+type taskPolicy struct {
+	queue         string
+	maxRetry      int
+	timeout       time.Duration
+	retention     time.Duration
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+}
+
+var taskPolicies = map[string]taskPolicy{
+	TypeVideoDownload: {
+		queue:         QueueDefault,
+		maxRetry:      3,
+		timeout:       30 * time.Minute,
+		retention:     24 * time.Hour,
+		retryDelay:    30 * time.Second,
+		maxRetryDelay: 10 * time.Minute,
+	},
+	TypeMp3Download: {
+		queue:         QueueLow,
+		maxRetry:      3,
+		timeout:       15 * time.Minute,
+		retention:     24 * time.Hour,
+		retryDelay:    30 * time.Second,
+		maxRetryDelay: 10 * time.Minute,
+	},
+}
+
+// TaskClient enqueues download tasks. Premium downloads skip ahead of everyone
+// else on the critical queue.
 type TaskClient interface {
-	EnqueueVideoDownload(task *model.DownloadTask) error
-	EnqueueMp3Download(task *model.DownloadTask) error
+	EnqueueVideoDownload(task *model.DownloadTask, premium bool) error
+	EnqueueMp3Download(task *model.DownloadTask, premium bool) error
+	// CancelDownload stops the task of a download. A running task is signaled
+	// and reports running; a waiting one is removed from its queue.
+	CancelDownload(downloadID uuid.UUID) (running bool, err error)
 }
 
 type asynqTaskClient struct {
-	client *asynq.Client
+	client    *asynq.Client
+	inspector *asynq.Inspector
 }
 
 func NewTaskClient(redisAddr string, redisPassword string) TaskClient {
-	client := asynq.NewClient(asynq.RedisClientOpt{
+	opt := asynq.RedisClientOpt{
 		Addr:     redisAddr,
 		DB:       1,
 		Password: redisPassword,
-	})
-	return &asynqTaskClient{client: client}
+	}
+	return &asynqTaskClient{
+		client:    asynq.NewClient(opt),
+		inspector: asynq.NewInspector(opt),
+	}
 }
 
 func NewTaskServer(redisAddr string, redisPassword string) *asynq.Server {
@@ -43,34 +104,127 @@ func NewTaskServer(redisAddr string, redisPassword string) *asynq.Server {
 		asynq.Config{
 			Concurrency: 10,
 			Queues: map[string]int{
-				"critical": 6,
-				"default":  3,
-				"low":      1,
+				QueueCritical: 6,
+				QueueDefault:  3,
+				QueueLow:      1,
 			},
+			RetryDelayFunc: TaskRetryDelay,
+			ErrorHandler:   asynq.ErrorHandlerFunc(logDeadTask),
 		},
 	)
 }
 
-func (c *asynqTaskClient) EnqueueVideoDownload(task *model.DownloadTask) error {
+// NewTaskScheduler enqueues periodic tasks on the same Redis database as the
+// task server.
+func NewTaskScheduler(redisAddr string, redisPassword string) *asynq.Scheduler {
+	return asynq.NewScheduler(
+		asynq.RedisClientOpt{
+			Addr:     redisAddr,
+			DB:       1,
+			Password: redisPassword,
+		},
+		&asynq.SchedulerOpts{Location: time.UTC},
+	)
+}
+
+// NewTaskInspector reads and manages the queues of the task server.
+func NewTaskInspector(redisAddr string, redisPassword string) *asynq.Inspector {
+	return asynq.NewInspector(asynq.RedisClientOpt{
+		Addr:     redisAddr,
+		DB:       1,
+		Password: redisPassword,
+	})
+}
+
+func (c *asynqTaskClient) EnqueueVideoDownload(task *model.DownloadTask, premium bool) error {
+	return c.enqueueDownload(TypeVideoDownload, task, premium)
+}
+
+func (c *asynqTaskClient) EnqueueMp3Download(task *model.DownloadTask, premium bool) error {
+	return c.enqueueDownload(TypeMp3Download, task, premium)
+}
+
+// enqueueDownload uses the download ID as the task ID, so enqueueing the same
+// download twice is a no-op and the task can be looked up by it.
+func (c *asynqTaskClient) enqueueDownload(taskType string, task *model.DownloadTask, premium bool) error {
 	payload, err := json.Marshal(task)
 	if err != nil {
 		return err
 	}
 
-	t := asynq.NewTask(TypeVideoDownload, payload)
-	_, err = c.client.Enqueue(t)
+	policy := taskPolicies[taskType]
+	queue := policy.queue
+	if premium {
+		queue = QueueCritical
+	}
+
+	t := asynq.NewTask(taskType, payload)
+	_, err = c.client.Enqueue(t,
+		asynq.TaskID(task.ID.String()),
+		asynq.Queue(queue),
+		asynq.MaxRetry(policy.maxRetry),
+		asynq.Timeout(policy.timeout),
+		asynq.Retention(policy.retention),
+	)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
 	return err
 }
 
-func (c *asynqTaskClient) EnqueueMp3Download(task *model.DownloadTask) error {
-	payload, err := json.Marshal(task)
-	if err != nil {
-		return err
+// CancelDownload looks the task up by download ID, which only finds tasks
+// enqueued with it as their task ID. Unknown or finished tasks are left alone.
+func (c *asynqTaskClient) CancelDownload(downloadID uuid.UUID) (bool, error) {
+	id := downloadID.String()
+	for _, queue := range []string{QueueCritical, QueueDefault, QueueLow} {
+		info, err := c.inspector.GetTaskInfo(queue, id)
+		if errors.Is(err, asynq.ErrQueueNotFound) || errors.Is(err, asynq.ErrTaskNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		switch info.State {
+		case asynq.TaskStateActive:
+			return true, c.inspector.CancelProcessing(id)
+		case asynq.TaskStatePending, asynq.TaskStateScheduled, asynq.TaskStateRetry:
+			return false, c.inspector.DeleteTask(queue, id)
+		}
+		return false, nil
+	}
+	return false, nil
+}
+
+// TaskRetryDelay backs off by task type. Rate limited downloads wait four times
+// longer, since retrying early only extends the platform's block.
+func TaskRetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	policy, ok := taskPolicies[t.Type()]
+	if !ok {
+		return asynq.DefaultRetryDelayFunc(n, err, t)
 	}
 
-	t := asynq.NewTask(TypeMp3Download, payload)
-	_, err = c.client.Enqueue(t)
-	return err
+	delay := policy.retryDelay
+	if ErrorCode(err) == ErrCodeRateLimited {
+		delay *= 4
+	}
+	for i := 0; i < n && delay < policy.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, policy.maxRetryDelay)
+	return delay + time.Duration(rand.Int64N(int64(delay/4)+1))
+}
+
+// logDeadTask reports tasks that failed for the last time; asynq moves them to
+// the archived set, where admins can inspect and rerun them.
+func logDeadTask(ctx context.Context, t *asynq.Task, err error) {
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	taskID, _ := asynq.GetTaskID(ctx)
+	if retried < maxRetry && !errors.Is(err, asynq.SkipRetry) {
+		log.Warn().Err(err).Str("type", t.Type()).Str("task_id", taskID).Int("retried", retried).Msg("Task failed, will retry")
+		return
+	}
+	log.Error().Err(err).Str("type", t.Type()).Str("task_id", taskID).Int("retried", retried).Msg("Task archived after its final attempt")
 }
 
 type RedisClient interface {
@@ -0,0 +1,213 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Download error codes. Content and request errors are permanent: the same
+// request fails again however often it is retried. The others are transient.
+const (
+	ErrCodePrivateVideo      = "private_video"
+	ErrCodeLoginRequired     = "login_required"
+	ErrCodeAgeRestricted     = "age_restricted"
+	ErrCodeGeoBlocked        = "geo_blocked"
+	ErrCodeVideoUnavailable  = "video_unavailable"
+	ErrCodeLiveStream        = "live_stream"
+	ErrCodeDRMProtected      = "drm_protected"
+	ErrCodeUnsupportedURL    = "unsupported_url"
+	ErrCodeFormatUnavailable = "format_unavailable"
+	ErrCodePlatformDisabled  = "platform_disabled"
+	ErrCodeAppUnavailable    = "app_unavailable"
+	ErrCodeCanceled          = "canceled"
+
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeTimeout          = "timeout"
+	ErrCodeNetwork          = "network_error"
+	ErrCodeUpstream         = "upstream_error"
+	ErrCodeExtractionFailed = "extraction_failed"
+	ErrCodeStorage          = "storage_error"
+	ErrCodeInternal         = "internal_error"
+)
+
+var permanentErrorCodes = map[string]bool{
+	ErrCodePrivateVideo:      true,
+	ErrCodeLoginRequired:     true,
+	ErrCodeAgeRestricted:     true,
+	ErrCodeGeoBlocked:        true,
+	ErrCodeVideoUnavailable:  true,
+	ErrCodeLiveStream:        true,
+	ErrCodeDRMProtected:      true,
+	ErrCodeUnsupportedURL:    true,
+	ErrCodeFormatUnavailable: true,
+	ErrCodePlatformDisabled:  true,
+	ErrCodeAppUnavailable:    true,
+	ErrCodeCanceled:          true,
+}
+
+// contentErrorCodes describe the video itself rather than the strategy that
+// looked at it, so one strategy reporting them is enough to trust them.
+var contentErrorCodes = map[string]bool{
+	ErrCodePrivateVideo:     true,
+	ErrCodeLoginRequired:    true,
+	ErrCodeAgeRestricted:    true,
+	ErrCodeGeoBlocked:       true,
+	ErrCodeVideoUnavailable: true,
+	ErrCodeLiveStream:       true,
+	ErrCodeDRMProtected:     true,
+}
+
+var errorCodeMessages = map[string]string{
+	ErrCodePrivateVideo:      "This video is private",
+	ErrCodeLoginRequired:     "This video requires signing in",
+	ErrCodeAgeRestricted:     "This video is age restricted",
+	ErrCodeGeoBlocked:        "This video is not available in the server's region",
+	ErrCodeVideoUnavailable:  "This video is unavailable or has been removed",
+	ErrCodeLiveStream:        "Live streams cannot be downloaded",
+	ErrCodeDRMProtected:      "This video is DRM protected",
+	ErrCodeUnsupportedURL:    "This URL is not supported",
+	ErrCodeFormatUnavailable: "The requested format is not available",
+	ErrCodePlatformDisabled:  "This platform is not available",
+	ErrCodeAppUnavailable:    "This application is not available",
+	ErrCodeCanceled:          "The download was canceled",
+	ErrCodeRateLimited:       "The platform is rate limiting downloads, try again later",
+	ErrCodeTimeout:           "The platform took too long to respond",
+	ErrCodeNetwork:           "Could not reach the platform",
+	ErrCodeUpstream:          "The platform returned an error",
+	ErrCodeExtractionFailed:  "Could not read the video from the page",
+	ErrCodeStorage:           "Could not store the downloaded file",
+	ErrCodeInternal:          "The download failed",
+}
+
+// DownloadError is a download failure with a stable code for clients. Error
+// keeps the full underlying error for logs; Message is safe to show to users.
+type DownloadError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func NewDownloadError(code, message string, err error) *DownloadError {
+	if message == "" {
+		message = errorCodeMessages[code]
+	}
+	return &DownloadError{Code: code, Message: message, Err: err}
+}
+
+func (e *DownloadError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether trying the same download again may succeed.
+func (e *DownloadError) Retryable() bool {
+	return !permanentErrorCodes[e.Code]
+}
+
+// errorPatterns maps yt-dlp stderr and other strategy messages to codes. The
+// first match wins, so the specific sign-in walls come before the generic one.
+// Permanent codes skip every retry, so their patterns stick to the wording
+// platforms and yt-dlp use for the video, never to a bare "not found" that a
+// missing binary or storage object reports too.
+var errorPatterns = []struct {
+	code    string
+	pattern *regexp.Regexp
+}{
+	{ErrCodeRateLimited, regexp.MustCompile(`(?i)not a bot|http error 429|too many requests|rate.?limit`)},
+	{ErrCodeAgeRestricted, regexp.MustCompile(`(?i)confirm your age|age.?restricted|inappropriate for some users`)},
+	{ErrCodePrivateVideo, regexp.MustCompile(`(?i)private video|video is private|is private|has been made private`)},
+	{ErrCodeLoginRequired, regexp.MustCompile(`(?i)sign in|log ?in|login required|requires authentication|members.only|join this channel|use --cookies`)},
+	{ErrCodeGeoBlocked, regexp.MustCompile(`(?i)available in your country|geo.?restrict|geo.?block|blocked it in your country|not available from your location`)},
+	{ErrCodeLiveStream, regexp.MustCompile(`(?i)live event will begin|premieres in|is a live stream|is currently live|live stream recording is not available`)},
+	{ErrCodeDRMProtected, regexp.MustCompile(`(?i)\bdrm\b`)},
+	{ErrCodeVideoUnavailable, regexp.MustCompile(`(?i)video unavailable|video (is )?(not|no longer) available|(video|post|content) (has been|was) (removed|deleted)|removed by the uploader|(video|post|content) does not exist|account .*terminated|http error 404|content isn'?t available`)},
+	{ErrCodeUnsupportedURL, regexp.MustCompile(`(?i)unsupported url|not a playlist or channel|invalid .*url|unable to extract video id`)},
+	{ErrCodeFormatUnavailable, regexp.MustCompile(`(?i)requested format is not available|no video formats found|format .*not available`)},
+	{ErrCodeTimeout, regexp.MustCompile(`(?i)timed out|timeout|deadline exceeded`)},
+	{ErrCodeUpstream, regexp.MustCompile(`(?i)http error 5\d\d|status:? 5\d\d|service unavailable|bad gateway|internal server error`)},
+	{ErrCodeNetwork, regexp.MustCompile(`(?i)connection (reset|refused|aborted)|network is unreachable|name resolution|no such host|\beof\b|tls: |tls handshake|\[ssl|sslerror|certificate verify failed|proxyerror|proxyconnect|unable to connect to proxy`)},
+	{ErrCodeExtractionFailed, regexp.MustCompile(`(?i)unable to extract|failed to extract|failed to parse|no m3u8|could not find|unable to download (webpage|json)`)},
+	{ErrCodeStorage, regexp.MustCompile(`(?i)minio|bucket|no space left`)},
+}
+
+// ClassifyMessage returns the code matching a failure message, or
+// ErrCodeInternal when none does.
+func ClassifyMessage(message string) string {
+	for _, p := range errorPatterns {
+		if p.pattern.MatchString(message) {
+			return p.code
+		}
+	}
+	return ErrCodeInternal
+}
+
+// ClassifyError returns err as a DownloadError, classifying it by its message
+// when it is not one already. It returns nil for a nil error.
+func ClassifyError(err error) *DownloadError {
+	if err == nil {
+		return nil
+	}
+	var dlErr *DownloadError
+	if errors.As(err, &dlErr) {
+		return dlErr
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return NewDownloadError(ErrCodeCanceled, "", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewDownloadError(ErrCodeTimeout, "", err)
+	}
+	return NewDownloadError(ClassifyMessage(err.Error()), "", err)
+}
+
+// ErrorCode returns the code of err, or "" for a nil error.
+func ErrorCode(err error) string {
+	if dlErr := ClassifyError(err); dlErr != nil {
+		return dlErr.Code
+	}
+	return ""
+}
+
+// ytDlpFailure turns a failed yt-dlp run into a DownloadError, classified by
+// its stderr and carrying the last ERROR line as the message.
+func ytDlpFailure(ctx context.Context, err error, stderr string) *DownloadError {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ClassifyError(errors.Join(ctxErr, err))
+	}
+	code := ClassifyMessage(stderr)
+	if code == ErrCodeInternal {
+		code = ClassifyMessage(err.Error())
+	}
+	return NewDownloadError(code, ytDlpErrorLine(stderr), err)
+}
+
+// ytDlpErrorLine returns the last "ERROR:" line of yt-dlp's stderr without the
+// prefix and the "[extractor] id:" tag.
+func ytDlpErrorLine(stderr string) string {
+	lines := strings.Split(stderr, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "ERROR:") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "ERROR:"))
+		if strings.HasPrefix(line, "[") {
+			if j := strings.Index(line, "]"); j > 0 {
+				line = strings.TrimSpace(line[j+1:])
+				if k := strings.Index(line, ": "); k > 0 && !strings.Contains(line[:k], " ") {
+					line = line[k+2:]
+				}
+			}
+		}
+		return line
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+package infrastructure
+
+import "testing"
+
+func TestClassifyMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"yt-dlp unavailable", "ERROR: [youtube] abc: Video unavailable", ErrCodeVideoUnavailable},
+		{"removed by the uploader", "ERROR: [youtube] abc: This video has been removed by the uploader", ErrCodeVideoUnavailable},
+		{"http 404", "ERROR: [generic] Unable to download webpage: HTTP Error 404: Not Found", ErrCodeVideoUnavailable},
+		{"private", "ERROR: [youtube] abc: Private video. Sign in if you've been granted access", ErrCodePrivateVideo},
+		{"geo blocked", "ERROR: [youtube] abc: The uploader has not made this video available in your country", ErrCodeGeoBlocked},
+		{"rate limited", "ERROR: [youtube] abc: HTTP Error 429: Too Many Requests", ErrCodeRateLimited},
+		{"missing yt-dlp binary", `exec: "yt-dlp": executable file not found in $PATH`, ErrCodeInternal},
+		{"missing storage object", "The specified key does not exist.", ErrCodeInternal},
+		{"object not found", "object not found", ErrCodeInternal},
+		{"tls handshake", "remote error: tls: handshake failure", ErrCodeNetwork},
+		{"certificate", "[SSL: CERTIFICATE_VERIFY_FAILED] certificate verify failed", ErrCodeNetwork},
+		{"proxy", "Unable to connect to proxy", ErrCodeNetwork},
+		{"proxy in a url", "ERROR: https://proxy.example.com/v.mp4 returned nothing", ErrCodeInternal},
+		{"upstream", "HTTP Error 503: Service Unavailable", ErrCodeUpstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyMessage(tt.message); got != tt.want {
+				t.Errorf("ClassifyMessage(%q) = %s, want %s", tt.message, got, tt.want)
+			}
+		})
+	}
+}
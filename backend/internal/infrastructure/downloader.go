@@ -66,6 +66,22 @@ type FormatInfo struct {
 	Tbr      *float64 `json:"tbr,omitempty"`
 }
 
+// PlaylistEntry is a single item of a playlist or channel as reported by a flat extraction.
+type PlaylistEntry struct {
+	ID       string   `json:"id"`
+	URL      string   `json:"url"`
+	Title    string   `json:"title"`
+	Duration *float64 `json:"duration,omitempty"`
+}
+
+type PlaylistInfo struct {
+	ID        string          `json:"id"`
+	Title     string          `json:"title"`
+	Extractor string          `json:"extractor"`
+	Kind      string          `json:"kind"` // playlist, channel
+	Entries   []PlaylistEntry `json:"entries"`
+}
+
 type DownloaderClient interface {
 	GetVideoInfo(ctx context.Context, url string) (*VideoInfo, error)
 	DownloadVideo(ctx context.Context, url string) (*VideoInfo, error)
@@ -213,7 +229,7 @@ func (c *ytDlpClient) GetVideoInfo(ctx context.Context, url string) (*VideoInfo,
 	}
 
 	tryRun := func(a []string) ([]byte, error) {
-		cmd := exec.CommandContext(subCtx, c.executablePath, a...)
+		cmd := NewCommand(subCtx, c.executablePath, a...)
 		return cmd.Output()
 	}
 
@@ -227,12 +243,14 @@ func (c *ytDlpClient) GetVideoInfo(ctx context.Context, url string) (*VideoInfo,
 					output = output2
 					goto Parse
 				}
+				stderr2 := ""
 				if exitErr2, ok2 := err2.(*exec.ExitError); ok2 {
-					log.Error().Str("url", url).Str("stderr", string(exitErr2.Stderr)).Err(err2).Msg("yt-dlp failed")
+					stderr2 = string(exitErr2.Stderr)
+					log.Error().Str("url", url).Str("stderr", stderr2).Err(err2).Msg("yt-dlp failed")
 				} else {
 					log.Error().Str("url", url).Err(err2).Msg("yt-dlp failed")
 				}
-				return nil, fmt.Errorf("failed to fetch video info: %w", err2)
+				return nil, ytDlpFailure(subCtx, fmt.Errorf("failed to fetch video info: %w", err2), stderr2)
 			}
 			if (strings.Contains(url, "youtube.com") || strings.Contains(url, "youtu.be")) && (strings.Contains(stderr, "Sign in to confirm") || strings.Contains(stderr, "not a bot")) {
 				legacyArgs := []string{
@@ -256,10 +274,10 @@ func (c *ytDlpClient) GetVideoInfo(ctx context.Context, url string) (*VideoInfo,
 				}
 			}
 			log.Error().Str("url", url).Str("stderr", stderr).Err(err).Msg("yt-dlp failed")
-		} else {
-			log.Error().Str("url", url).Err(err).Msg("yt-dlp failed")
+			return nil, ytDlpFailure(subCtx, fmt.Errorf("failed to fetch video info: %w", err), stderr)
 		}
-		return nil, fmt.Errorf("failed to fetch video info: %w", err)
+		log.Error().Str("url", url).Err(err).Msg("yt-dlp failed")
+		return nil, ytDlpFailure(subCtx, fmt.Errorf("failed to fetch video info: %w", err), "")
 	}
 
 Parse:
@@ -430,6 +448,103 @@ func pickBestFormat(formats []FormatInfo) *FormatInfo {
 	return best
 }
 
+// GetPlaylistEntries lists the entries of a playlist or channel without resolving each video.
+func (c *ytDlpClient) GetPlaylistEntries(ctx context.Context, url string, limit int) (*PlaylistInfo, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 60*time.Second)
+	defer cancel()
+
+	args := []string{
+		"-m", "yt_dlp",
+		"--js-runtimes", defaultJSRuntime(),
+		"--flat-playlist",
+		"--dump-single-json",
+		"--yes-playlist",
+		"--no-check-certificate",
+	}
+	if limit > 0 {
+		args = append(args, "--playlist-end", fmt.Sprintf("%d", limit))
+	}
+
+	if proxyURL := sanitizeEnvString(os.Getenv("OUTBOUND_PROXY_URL")); proxyURL != "" && shouldUseProxyForURL(url) {
+		args = append(args, "--proxy", proxyURL)
+	}
+
+	cookiePath := sanitizeEnvString(os.Getenv("COOKIES_FILE_PATH"))
+	if cookiePath == "" {
+		cookiePath = "/app/cookies.txt"
+	}
+	if shouldUseCookiesForURL(url, cookiePath) {
+		args = append(args, "--cookies", cookiePath)
+	}
+
+	args = append(args, url)
+
+	cmd := NewCommand(subCtx, c.executablePath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		log.Error().Str("url", url).Str("stderr", stderr.String()).Err(err).Msg("yt-dlp playlist extraction failed")
+		return nil, fmt.Errorf("failed to fetch playlist entries: %w", err)
+	}
+
+	var raw struct {
+		ID           string `json:"id"`
+		Title        string `json:"title"`
+		Type         string `json:"_type"`
+		Extractor    string `json:"extractor"`
+		ExtractorKey string `json:"extractor_key"`
+		WebpageURL   string `json:"webpage_url"`
+		Entries      []struct {
+			ID       string   `json:"id"`
+			URL      string   `json:"url"`
+			Title    string   `json:"title"`
+			Duration *float64 `json:"duration"`
+			IEKey    string   `json:"ie_key"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp playlist output: %w", err)
+	}
+	if raw.Type != "playlist" {
+		return nil, fmt.Errorf("url is not a playlist or channel")
+	}
+
+	isYouTube := strings.Contains(url, "youtube.com") || strings.Contains(url, "youtu.be")
+
+	info := &PlaylistInfo{
+		ID:        raw.ID,
+		Title:     raw.Title,
+		Extractor: raw.Extractor,
+		Kind:      "playlist",
+	}
+	lowerKey := strings.ToLower(raw.ExtractorKey + " " + raw.WebpageURL + " " + url)
+	if strings.Contains(lowerKey, "channel") || strings.Contains(lowerKey, "/@") || strings.Contains(lowerKey, "/user/") || strings.Contains(lowerKey, "/c/") {
+		info.Kind = "channel"
+	}
+
+	for _, e := range raw.Entries {
+		entryURL := strings.TrimSpace(e.URL)
+		if isYouTube && e.ID != "" && !strings.HasPrefix(entryURL, "http") {
+			entryURL = "https://www.youtube.com/watch?v=" + e.ID
+		}
+		if !strings.HasPrefix(entryURL, "http") {
+			continue
+		}
+		info.Entries = append(info.Entries, PlaylistEntry{
+			ID:       e.ID,
+			URL:      entryURL,
+			Title:    e.Title,
+			Duration: e.Duration,
+		})
+		if limit > 0 && len(info.Entries) >= limit {
+			break
+		}
+	}
+
+	return info, nil
+}
+
 func (c *ytDlpClient) DownloadVideo(ctx context.Context, url string) (*VideoInfo, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 25*time.Second)
 	defer cancel()
@@ -443,6 +558,16 @@ func (c *ytDlpClient) DownloadVideo(ctx context.Context, url string) (*VideoInfo
 }
 
 func (c *ytDlpClient) DownloadToPath(ctx context.Context, url string, formatID string, outputPath string, cookies map[string]string) error {
+	return c.downloadToPath(ctx, url, formatID, outputPath, cookies, "")
+}
+
+// DownloadSectionToPath downloads only part of the video. section uses yt-dlp's
+// --download-sections syntax, e.g. "*90-150".
+func (c *ytDlpClient) DownloadSectionToPath(ctx context.Context, url string, formatID string, outputPath string, section string) error {
+	return c.downloadToPath(ctx, url, formatID, outputPath, nil, section)
+}
+
+func (c *ytDlpClient) downloadToPath(ctx context.Context, url string, formatID string, outputPath string, cookies map[string]string, section string) error {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 20*time.Minute) // Increased timeout for large downloads
 	defer cancel()
 
@@ -469,6 +594,10 @@ func (c *ytDlpClient) DownloadToPath(ctx context.Context, url string, formatID s
 		"--no-part",
 		"-o", outputPath,
 	}
+	args = append(args, YtDlpProgressArgs(subCtx)...)
+	if section != "" {
+		args = append(args, "--download-sections", section)
+	}
 
 	if isYouTube {
 		outputExt := strings.ToLower(strings.TrimPrefix(filepath.Ext(outputPath), "."))
@@ -483,6 +612,13 @@ func (c *ytDlpClient) DownloadToPath(ctx context.Context, url string, formatID s
 		}
 	}
 
+	// Merged selections must land in the container the caller asked for via the output path.
+	if outputExt := strings.ToLower(strings.TrimPrefix(filepath.Ext(outputPath), ".")); strings.Contains(formatID, "+") {
+		if outputExt == "webm" || outputExt == "mkv" || (outputExt == "mp4" && !isYouTube) {
+			args = append(args, "--merge-output-format", outputExt)
+		}
+	}
+
 	if proxyURL := sanitizeEnvString(os.Getenv("OUTBOUND_PROXY_URL")); proxyURL != "" && shouldUseProxyForURL(url) {
 		args = append(args, "--proxy", proxyURL)
 	}
@@ -552,7 +688,8 @@ func (c *ytDlpClient) DownloadToPath(ctx context.Context, url string, formatID s
 	}
 
 	run := func(a []string) (string, error) {
-		cmd := exec.CommandContext(subCtx, c.executablePath, a...)
+		cmd := NewCommand(subCtx, c.executablePath, a...)
+		AttachYtDlpProgress(subCtx, cmd)
 		var stderr bytes.Buffer
 		cmd.Stderr = &stderr
 		err := cmd.Run()
@@ -565,7 +702,7 @@ func (c *ytDlpClient) DownloadToPath(ctx context.Context, url string, formatID s
 			return nil
 		} else if strings.Contains(stderr, "Impersonate target") {
 			if stderr2, err2 := run(args); err2 != nil {
-				return fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err2, stderr2)
+				return ytDlpFailure(subCtx, fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err2, stderr2), stderr2)
 			}
 			return nil
 		} else if (strings.Contains(url, "youtube.com") || strings.Contains(url, "youtu.be")) && (strings.Contains(stderr, "Sign in to confirm") || strings.Contains(stderr, "not a bot")) {
@@ -580,15 +717,18 @@ func (c *ytDlpClient) DownloadToPath(ctx context.Context, url string, formatID s
 				"-f", "18",
 				url,
 			}
+			if section != "" {
+				legacyArgs = append(legacyArgs[:len(legacyArgs)-1], "--download-sections", section, legacyArgs[len(legacyArgs)-1])
+			}
 			if proxyURL := sanitizeEnvString(os.Getenv("OUTBOUND_PROXY_URL")); proxyURL != "" && shouldUseProxyForURL(url) {
 				legacyArgs = append(legacyArgs[:len(legacyArgs)-1], "--proxy", proxyURL, legacyArgs[len(legacyArgs)-1])
 			}
 			if stderr2, err2 := run(legacyArgs); err2 != nil {
-				return fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err2, stderr2)
+				return ytDlpFailure(subCtx, fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err2, stderr2), stderr2)
 			}
 			return nil
 		} else {
-			return fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err, stderr)
+			return ytDlpFailure(subCtx, fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err, stderr), stderr)
 		}
 	}
 
@@ -602,11 +742,11 @@ func (c *ytDlpClient) DownloadToPath(ctx context.Context, url string, formatID s
 					_ = stderr2
 					return nil
 				} else {
-					return fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err2, stderr2)
+					return ytDlpFailure(subCtx, fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err2, stderr2), stderr2)
 				}
 			}
 		}
-		return fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err, stderr)
+		return ytDlpFailure(subCtx, fmt.Errorf("yt-dlp download failed: %w, stderr: %s", err, stderr), stderr)
 	}
 
 	return nil
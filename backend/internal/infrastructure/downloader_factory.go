@@ -3,8 +3,11 @@ package infrastructure
 import (
 	"context"
 	"fmt"
+	"maps"
+	neturl "net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -13,13 +16,23 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
 	"github.com/user/video-downloader-backend/internal/infrastructure/scrapper"
+	"golang.org/x/sync/singleflight"
 )
 
+// DownloaderStrategy resolves video info for a URL. Failures are classified
+// with ClassifyError, so strategies may return a *DownloadError to set the code
+// themselves instead of relying on the message patterns.
 type DownloaderStrategy interface {
 	GetVideoInfo(ctx context.Context, url string) (*VideoInfo, error)
 	Name() string
 }
 
+// PlaylistStrategy is implemented by strategies that can enumerate playlist and channel entries.
+type PlaylistStrategy interface {
+	GetPlaylistEntries(ctx context.Context, url string, limit int) (*PlaylistInfo, error)
+	Name() string
+}
+
 type YtDlpStrategy struct {
 	client *ytDlpClient
 }
@@ -28,6 +41,10 @@ func (s *YtDlpStrategy) GetVideoInfo(ctx context.Context, url string) (*VideoInf
 	return s.client.GetVideoInfo(ctx, url)
 }
 
+func (s *YtDlpStrategy) GetPlaylistEntries(ctx context.Context, url string, limit int) (*PlaylistInfo, error) {
+	return s.client.GetPlaylistEntries(ctx, url, limit)
+}
+
 func (s *YtDlpStrategy) Name() string {
 	return "yt-dlp"
 }
@@ -242,6 +259,9 @@ func (s *YTDownStrategy) Name() string {
 
 type FallbackDownloader struct {
 	strategies []DownloaderStrategy
+	health     StrategyHealthTracker
+	infoCache  VideoInfoCache
+	inflight   singleflight.Group
 }
 
 func NewFallbackDownloader() *FallbackDownloader {
@@ -260,19 +280,168 @@ func NewFallbackDownloader() *FallbackDownloader {
 	}
 }
 
+// SetHealthTracker enables per-strategy health scoring and circuit breaking.
+func (f *FallbackDownloader) SetHealthTracker(health StrategyHealthTracker) {
+	f.health = health
+}
+
+// SetInfoCache enables caching of resolved video metadata keyed by canonical video URL.
+func (f *FallbackDownloader) SetInfoCache(cache VideoInfoCache) {
+	f.infoCache = cache
+}
+
+// videoInfoTimeout bounds a video info lookup across all its strategies.
+const videoInfoTimeout = 25 * time.Second
+
 func (f *FallbackDownloader) GetVideoInfo(ctx context.Context, url string) (*VideoInfo, error) {
-	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 25*time.Second)
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, videoInfoTimeout)
 	defer cancel()
 
 	return f.GetVideoInfoWithType(subCtx, url, "")
 }
 
 func (f *FallbackDownloader) GetVideoInfoWithType(ctx context.Context, url string, downloadType string) (*VideoInfo, error) {
-	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 25*time.Second)
+	return f.GetVideoInfoWithRouting(ctx, url, downloadType, nil)
+}
+
+// GetVideoInfoWithRouting runs the strategy chain using the platform's routing config.
+// A nil routing keeps the built-in ordering for the download type.
+func (f *FallbackDownloader) GetVideoInfoWithRouting(ctx context.Context, url string, downloadType string, routing *StrategyRouting) (*VideoInfo, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, videoInfoTimeout)
 	defer cancel()
 
+	if f.infoCache == nil {
+		return f.resolveVideoInfo(subCtx, url, downloadType, routing)
+	}
+
+	key := CanonicalVideoKey(url)
+	if info, ok := f.infoCache.Get(subCtx, key); ok {
+		log.Info().Str("key", key).Msg("Video info served from cache")
+		return info, nil
+	}
+
+	// Concurrent requests for the same video share one strategy run. It runs
+	// detached from whichever caller started it, so that caller giving up does
+	// not fail the others; each caller still stops waiting at its own deadline.
+	ch := f.inflight.DoChan(key, func() (interface{}, error) {
+		runCtx, cancelRun := context.WithTimeout(context.WithoutCancel(subCtx), videoInfoTimeout)
+		defer cancelRun()
+
+		info, err := f.resolveVideoInfo(runCtx, url, downloadType, routing)
+		if err != nil {
+			return nil, err
+		}
+		f.infoCache.Set(runCtx, key, info)
+		return info, nil
+	})
+
+	select {
+	case <-subCtx.Done():
+		return nil, subCtx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		info := res.Val.(*VideoInfo)
+		if res.Shared {
+			return info.clone(), nil
+		}
+		return info, nil
+	}
+}
+
+// clone copies info deep enough for callers sharing one resolution to edit
+// their copy, formats and cookies included.
+func (info *VideoInfo) clone() *VideoInfo {
+	copied := *info
+	copied.Formats = slices.Clone(info.Formats)
+	copied.Cookies = maps.Clone(info.Cookies)
+	return &copied
+}
+
+func (f *FallbackDownloader) resolveVideoInfo(subCtx context.Context, url string, downloadType string, routing *StrategyRouting) (*VideoInfo, error) {
+
+	strategies := routing.Apply(f.defaultStrategies(url, downloadType), f.strategies)
+	if len(strategies) == 0 {
+		return nil, fmt.Errorf("no download strategies enabled for %q", downloadType)
+	}
+
+	healthKey := strategyHealthPlatformKey(url, downloadType)
+	if f.health != nil {
+		allowed := make([]DownloaderStrategy, 0, len(strategies))
+		for _, strategy := range strategies {
+			if f.health.Allow(subCtx, healthKey, strategy.Name()) {
+				allowed = append(allowed, strategy)
+				continue
+			}
+			log.Warn().Str("strategy", strategy.Name()).Str("platform", healthKey).Msg("Skipping strategy: circuit breaker open")
+		}
+		// With every breaker open a degraded attempt still beats failing outright.
+		if len(allowed) > 0 {
+			strategies = allowed
+		}
+	}
+
 	var lastErr error
+	var contentErr *DownloadError
+	for _, strategy := range strategies {
+		strategyCtx, cancelStrategy := subCtx, context.CancelFunc(func() {})
+		if timeout := routing.TimeoutFor(strategy.Name()); timeout > 0 {
+			strategyCtx, cancelStrategy = context.WithTimeout(subCtx, timeout)
+		}
 
+		log.Info().Str("strategy", strategy.Name()).Str("url", url).Msg("Attempting download with strategy")
+		start := time.Now()
+		info, err := strategy.GetVideoInfo(strategyCtx, url)
+		latency := time.Since(start)
+		cancelStrategy()
+		if err == nil {
+			log.Info().Str("strategy", strategy.Name()).Msg("Download info success")
+			if f.health != nil {
+				f.health.RecordSuccess(context.WithoutCancel(subCtx), healthKey, strategy.Name(), latency)
+			}
+			return info, nil
+		}
+		log.Error().Err(err).Str("strategy", strategy.Name()).Msg("Strategy failed")
+		lastErr = err
+		dlErr := ClassifyError(err)
+		if contentErrorCodes[dlErr.Code] {
+			if contentErr == nil {
+				contentErr = dlErr
+			}
+			// The video is at fault, not the strategy; counting it would let
+			// links to private videos open the breakers of a whole platform.
+			continue
+		}
+		// A cancelled caller says nothing about the strategy itself.
+		if f.health != nil && subCtx.Err() == nil {
+			f.health.RecordFailure(context.WithoutCancel(subCtx), healthKey, strategy.Name(), latency, err)
+		}
+	}
+
+	// A private or removed video fails the same way on every strategy, but the
+	// last one to run may only report a generic extraction error.
+	failure := ClassifyError(lastErr)
+	if contentErr != nil {
+		failure = contentErr
+	}
+	return nil, NewDownloadError(failure.Code, failure.Message, fmt.Errorf("all download strategies failed: %w", lastErr))
+}
+
+// strategyHealthPlatformKey buckets health stats by platform, falling back to the URL host
+// when the caller did not supply a download type (e.g. the worker).
+func strategyHealthPlatformKey(rawURL string, downloadType string) string {
+	if t := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(downloadType)), "-to-mp3"); t != "" {
+		return t
+	}
+	if u, err := neturl.Parse(strings.TrimSpace(rawURL)); err == nil && u.Hostname() != "" {
+		return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	}
+	return "unknown"
+}
+
+// defaultStrategies returns the hardcoded strategy ordering used when a platform has no routing config.
+func (f *FallbackDownloader) defaultStrategies(url string, downloadType string) []DownloaderStrategy {
 	normalizedType := strings.ToLower(downloadType)
 	isYoutube := normalizedType == "youtube" || normalizedType == "youtube-to-mp3" || strings.Contains(url, "youtube.com") || strings.Contains(url, "youtu.be")
 	isRumble := normalizedType == "rumble" || strings.Contains(url, "rumble.com")
@@ -413,18 +582,36 @@ func (f *FallbackDownloader) GetVideoInfoWithType(ctx context.Context, url strin
 		}
 	}
 
-	for _, strategy := range strategies {
-		log.Info().Str("strategy", strategy.Name()).Str("url", url).Msg("Attempting download with strategy")
-		info, err := strategy.GetVideoInfo(subCtx, url)
-		if err == nil {
-			log.Info().Str("strategy", strategy.Name()).Msg("Download info success")
+	return strategies
+}
+
+func (f *FallbackDownloader) GetPlaylistEntries(ctx context.Context, url string, limit int) (*PlaylistInfo, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 60*time.Second)
+	defer cancel()
+
+	var lastErr error
+	for _, strategy := range f.strategies {
+		playlistStrategy, ok := strategy.(PlaylistStrategy)
+		if !ok {
+			continue
+		}
+		log.Info().Str("strategy", playlistStrategy.Name()).Str("url", url).Msg("Attempting playlist extraction with strategy")
+		info, err := playlistStrategy.GetPlaylistEntries(subCtx, url, limit)
+		if err == nil && info != nil && len(info.Entries) > 0 {
 			return info, nil
 		}
-		log.Error().Err(err).Str("strategy", strategy.Name()).Msg("Strategy failed")
+		if err == nil {
+			err = fmt.Errorf("no playlist entries found")
+		}
+		log.Error().Err(err).Str("strategy", playlistStrategy.Name()).Msg("Playlist strategy failed")
 		lastErr = err
 	}
 
-	return nil, fmt.Errorf("all download strategies failed: %w", lastErr)
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no playlist-capable strategy configured")
+	}
+	failure := ClassifyError(lastErr)
+	return nil, NewDownloadError(failure.Code, failure.Message, fmt.Errorf("all playlist strategies failed: %w", lastErr))
 }
 
 func (f *FallbackDownloader) DownloadVideo(ctx context.Context, url string) (*VideoInfo, error) {
@@ -438,7 +625,8 @@ func (f *FallbackDownloader) DownloadToPath(ctx context.Context, url string, for
 	isYoutube := strings.Contains(url, "youtube.com") || strings.Contains(url, "youtu.be")
 	if isYoutube {
 		client := &ytDlpClient{executablePath: "python3"}
-		if err := client.DownloadToPath(ctx, url, formatID, outputPath, cookies); err == nil {
+		ytDlpErr := client.DownloadToPath(ctx, url, formatID, outputPath, cookies)
+		if ytDlpErr == nil {
 			return nil
 		}
 
@@ -465,13 +653,22 @@ func (f *FallbackDownloader) DownloadToPath(ctx context.Context, url string, for
 				}
 			}
 		}
-		return fmt.Errorf("all YouTube download strategies failed")
+		// yt-dlp's stderr is the only failure here detailed enough to classify.
+		failure := ClassifyError(ytDlpErr)
+		return NewDownloadError(failure.Code, failure.Message, fmt.Errorf("all YouTube download strategies failed: %w", ytDlpErr))
 	}
 
 	client := &ytDlpClient{executablePath: "python3"}
 	return client.DownloadToPath(ctx, url, formatID, outputPath, cookies)
 }
 
+// DownloadSectionToPath downloads part of a video with yt-dlp, the only strategy
+// able to fetch a section. Callers fall back to cutting a full download.
+func (f *FallbackDownloader) DownloadSectionToPath(ctx context.Context, url string, formatID string, outputPath string, section string) error {
+	client := &ytDlpClient{executablePath: "python3"}
+	return client.DownloadSectionToPath(ctx, url, formatID, outputPath, section)
+}
+
 func pickBestYTDownItem(items []scrapper.YTDownMediaItem) *scrapper.YTDownMediaItem {
 	var best *scrapper.YTDownMediaItem
 	bestScore := -1
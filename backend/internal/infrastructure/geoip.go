@@ -0,0 +1,126 @@
+package infrastructure
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CountryUnknown is reported for addresses that are missing, private or not in
+// the database.
+const CountryUnknown = "unknown"
+
+// GeoIP resolves an IP address to an ISO 3166-1 alpha-2 country code.
+type GeoIP interface {
+	Country(ip string) string
+}
+
+type geoIPRange struct {
+	start   netip.Addr
+	end     netip.Addr
+	country string
+}
+
+type csvGeoIP struct {
+	ranges []geoIPRange
+}
+
+// NewGeoIPFromCSV loads an IP-to-country range database in CSV form, one
+// "start,end,country" row per range. Bounds may be addresses (DB-IP Lite) or
+// decimal integers (IP2Location LITE DB1); extra columns are ignored.
+func NewGeoIPFromCSV(path string) (GeoIP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoip database: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+
+	var ranges []geoIPRange
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read geoip database line %d: %w", line, err)
+		}
+		if len(record) < 3 {
+			continue
+		}
+		start, okStart := parseGeoIPBound(record[0])
+		end, okEnd := parseGeoIPBound(record[1])
+		if !okStart || !okEnd {
+			// Header rows and malformed lines.
+			continue
+		}
+		country := strings.ToUpper(strings.TrimSpace(record[2]))
+		if len(country) != 2 {
+			continue
+		}
+		ranges = append(ranges, geoIPRange{start: start, end: end, country: country})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("geoip database %s has no ranges", path)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	return &csvGeoIP{ranges: ranges}, nil
+}
+
+func (g *csvGeoIP) Country(ip string) string {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return CountryUnknown
+	}
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsUnspecified() {
+		return CountryUnknown
+	}
+
+	i := sort.Search(len(g.ranges), func(i int) bool { return addr.Less(g.ranges[i].start) })
+	if i == 0 {
+		return CountryUnknown
+	}
+	r := g.ranges[i-1]
+	if r.end.Less(addr) || r.start.BitLen() != addr.BitLen() {
+		return CountryUnknown
+	}
+	return r.country
+}
+
+// parseGeoIPBound parses an address or its decimal form. Decimal values above
+// the IPv4 space are read as IPv6, and IPv4-mapped ones as IPv4.
+func parseGeoIPBound(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return netip.Addr{}, false
+	}
+	if n.BitLen() <= 32 {
+		var b [4]byte
+		n.FillBytes(b[:])
+		return netip.AddrFrom4(b), true
+	}
+	var b [16]byte
+	n.FillBytes(b[:])
+	return netip.AddrFrom16(b).Unmap(), true
+}
+
+// NoGeoIP reports every address as unknown, for deployments without a database.
+type NoGeoIP struct{}
+
+func (NoGeoIP) Country(string) string { return CountryUnknown }
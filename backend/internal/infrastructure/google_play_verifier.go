@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/api/androidpublisher/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+type googlePlayVerifier struct {
+	svc *androidpublisher.Service
+}
+
+// NewGooglePlayVerifier checks subscription purchase tokens with the Play
+// Developer API, authenticated as the service account in credentialsFile. The
+// account needs the "View financial data" and "Manage orders" permissions in
+// the Play Console.
+func NewGooglePlayVerifier(ctx context.Context, credentialsFile string) (ReceiptVerifier, error) {
+	svc, err := androidpublisher.NewService(ctx,
+		option.WithAuthCredentialsFile(option.ServiceAccount, credentialsFile),
+		option.WithScopes(androidpublisher.AndroidpublisherScope),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create play developer client: %w", err)
+	}
+	return &googlePlayVerifier{svc: svc}, nil
+}
+
+func (v *googlePlayVerifier) Verify(ctx context.Context, req ReceiptRequest) (*ReceiptVerification, error) {
+	if req.PackageName == "" || req.PurchaseToken == "" {
+		return nil, invalidReceipt("package name and purchase token are required")
+	}
+
+	purchase, err := v.svc.Purchases.Subscriptionsv2.Get(req.PackageName, req.PurchaseToken).Context(ctx).Do()
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) {
+			switch apiErr.Code {
+			case http.StatusBadRequest, http.StatusNotFound, http.StatusGone:
+				return nil, invalidReceipt("play developer api: %s", apiErr.Message)
+			}
+		}
+		return nil, fmt.Errorf("failed to get play subscription purchase: %w", err)
+	}
+
+	var item *androidpublisher.SubscriptionPurchaseLineItem
+	for _, li := range purchase.LineItems {
+		if li != nil && li.ProductId == req.ProductID {
+			item = li
+			break
+		}
+	}
+	if item == nil {
+		return nil, invalidReceipt("purchase does not contain product %q", req.ProductID)
+	}
+
+	out := &ReceiptVerification{
+		Provider:              ReceiptProviderGooglePlay,
+		OriginalTransactionID: googlePlayBaseOrderID(purchase.LatestOrderId),
+		ProviderTransactionID: purchase.LatestOrderId,
+		ProductID:             item.ProductId,
+		Status:                googlePlayStatus(purchase.SubscriptionState),
+		Test:                  purchase.TestPurchase != nil,
+		Raw:                   JSONObject(purchase),
+	}
+	if out.OriginalTransactionID == "" {
+		out.OriginalTransactionID = req.TransactionID
+	}
+	if out.ProviderTransactionID == "" {
+		out.ProviderTransactionID = item.LatestSuccessfulOrderId
+	}
+	if t, err := time.Parse(time.RFC3339Nano, purchase.StartTime); err == nil {
+		out.StartTime = t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, item.ExpiryTime); err == nil {
+		out.EndTime = t
+	}
+	if plan := item.AutoRenewingPlan; plan != nil {
+		out.AutoRenew = plan.AutoRenewEnabled
+		if price := plan.RecurringPrice; price != nil {
+			out.Amount = float64(price.Units) + float64(price.Nanos)/1e9
+			out.Currency = price.CurrencyCode
+		}
+	}
+
+	// Play refunds purchases that stay unacknowledged for three days, so do it
+	// here in case the app did not.
+	if purchase.AcknowledgementState == "ACKNOWLEDGEMENT_STATE_PENDING" && out.Status != "pending" {
+		ackErr := v.svc.Purchases.Subscriptions.Acknowledge(req.PackageName, item.ProductId, req.PurchaseToken,
+			&androidpublisher.SubscriptionPurchasesAcknowledgeRequest{}).Context(ctx).Do()
+		if ackErr != nil {
+			log.Warn().Err(ackErr).Str("product_id", item.ProductId).Msg("Failed to acknowledge play subscription")
+		}
+	}
+
+	return out, nil
+}
+
+func googlePlayStatus(state string) string {
+	switch state {
+	case "SUBSCRIPTION_STATE_ACTIVE":
+		return "active"
+	case "SUBSCRIPTION_STATE_IN_GRACE_PERIOD":
+		return "grace"
+	case "SUBSCRIPTION_STATE_ON_HOLD":
+		return "on_hold"
+	case "SUBSCRIPTION_STATE_PAUSED":
+		return "paused"
+	case "SUBSCRIPTION_STATE_CANCELED":
+		return "canceled"
+	case "SUBSCRIPTION_STATE_PENDING":
+		return "pending"
+	default:
+		// EXPIRED and PENDING_PURCHASE_CANCELED.
+		return "expired"
+	}
+}
+
+// googlePlayBaseOrderID strips the renewal suffix from an order ID:
+// GPA.1234-5678-9012-34567..3 belongs to GPA.1234-5678-9012-34567.
+func googlePlayBaseOrderID(orderID string) string {
+	if i := strings.Index(orderID, ".."); i > 0 {
+		return orderID[:i]
+	}
+	return orderID
+}
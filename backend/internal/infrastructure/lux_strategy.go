@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -28,7 +27,7 @@ func (s *LuxStrategy) GetVideoInfo(ctx context.Context, url string) (*VideoInfo,
 	defer cancel()
 
 	// -i displays info
-	cmd := exec.CommandContext(ctx, "lux", "-i", url)
+	cmd := NewCommand(ctx, "lux", "-i", url)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -20,6 +21,15 @@ type StorageClient interface {
 	DeleteFile(ctx context.Context, bucketName string, objectName string) error
 	DeleteFolder(ctx context.Context, bucketName string, prefix string) error
 	CreateBucket(ctx context.Context, bucketName string) error
+	StatFile(ctx context.Context, bucketName string, objectName string) (*StorageObjectInfo, error)
+	GetFileRange(ctx context.Context, bucketName string, objectName string, offset, length int64) (io.ReadCloser, error)
+}
+
+type StorageObjectInfo struct {
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
 }
 
 type minioClient struct {
@@ -134,13 +144,25 @@ func (c *minioClient) CreateBucket(ctx context.Context, bucketName string) error
 	return nil
 }
 
+// Uploads of unknown size are sent in parts of streamPartSize; minio-go would
+// otherwise buffer parts sized for its 5TiB maximum object.
+const streamPartSize = 16 << 20
+
+// UploadFile stores reader under objectName. objectSize is -1 when unknown. Only
+// small uploads get the default timeout; media can take far longer to send and
+// is bounded by the caller's context instead.
 func (c *minioClient) UploadFile(ctx context.Context, bucketName string, objectName string, reader io.Reader, objectSize int64, contentType string) (string, error) {
-	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	subCtx, cancel := ctx, context.CancelFunc(func() {})
+	if objectSize >= 0 && objectSize <= streamPartSize {
+		subCtx, cancel = contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	}
 	defer cancel()
 
-	info, err := c.client.PutObject(subCtx, bucketName, objectName, reader, objectSize, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if objectSize < 0 {
+		opts.PartSize = streamPartSize
+	}
+	info, err := c.client.PutObject(subCtx, bucketName, objectName, reader, objectSize, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file: %w", err)
 	}
@@ -169,6 +191,39 @@ func (c *minioClient) GetFileURL(ctx context.Context, bucketName string, objectN
 	return presignedURL.String(), nil
 }
 
+func (c *minioClient) StatFile(ctx context.Context, bucketName string, objectName string) (*StorageObjectInfo, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	info, err := c.client.StatObject(subCtx, bucketName, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return &StorageObjectInfo{
+		Size:         info.Size,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// GetFileRange streams length bytes starting at offset. The reader outlives this call,
+// so ctx must stay valid until the caller has finished reading.
+func (c *minioClient) GetFileRange(ctx context.Context, bucketName string, objectName string, offset, length int64) (io.ReadCloser, error) {
+	opts := minio.GetObjectOptions{}
+	if length > 0 {
+		if err := opts.SetRange(offset, offset+length-1); err != nil {
+			return nil, fmt.Errorf("invalid range: %w", err)
+		}
+	}
+
+	object, err := c.client.GetObject(ctx, bucketName, objectName, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	return object, nil
+}
+
 func (c *minioClient) DeleteFile(ctx context.Context, bucketName string, objectName string) error {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
@@ -210,3 +265,17 @@ func (c *minioClient) DeleteFolder(ctx context.Context, bucketName string, prefi
 
 	return nil
 }
+
+// ObjectNameFromURL returns the object a URL of bucketName points at, path
+// style, and false for URLs elsewhere, such as avatars hosted by Google.
+func ObjectNameFromURL(rawURL, bucketName string) (string, bool) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	path := strings.TrimPrefix(parsedURL.Path, "/")
+	if !strings.HasPrefix(path, bucketName+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(path, bucketName+"/"), true
+}
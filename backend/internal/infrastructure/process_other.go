@@ -0,0 +1,17 @@
+//go:build !unix
+
+package infrastructure
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// NewCommand is exec.CommandContext with a bounded wait for the output pipes
+// once the context is canceled.
+func NewCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
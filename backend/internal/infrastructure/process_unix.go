@@ -0,0 +1,23 @@
+//go:build unix
+
+package infrastructure
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// NewCommand is exec.CommandContext for tools that spawn children of their own,
+// such as yt-dlp running ffmpeg. The command gets its own process group and a
+// canceled context kills the whole group, so no orphan keeps writing files.
+func NewCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
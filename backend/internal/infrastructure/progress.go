@@ -0,0 +1,232 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransferProgress is a snapshot of a running yt-dlp or ffmpeg transfer. Totals
+// and ETA are estimates and stay zero while the tool cannot tell them.
+type TransferProgress struct {
+	DownloadedBytes int64
+	TotalBytes      int64
+	// Speed is in bytes per second.
+	Speed float64
+	// ETA is the remaining time in seconds, -1 when unknown.
+	ETA int
+	// Percent runs from 0 to 100, -1 when unknown.
+	Percent float64
+}
+
+// ProgressFunc receives progress as it is parsed from the tool output. It is
+// called from the goroutine that copies the output, once per update line.
+type ProgressFunc func(TransferProgress)
+
+type progressKey struct{}
+
+type progressTracker struct {
+	fn       ProgressFunc
+	duration time.Duration
+}
+
+// WithProgress returns a context whose yt-dlp and ffmpeg runs report to fn.
+// duration is the length of the media being fetched or converted: ffmpeg only
+// reports how much of it is done. Pass zero when it is unknown.
+func WithProgress(ctx context.Context, duration time.Duration, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, &progressTracker{fn: fn, duration: duration})
+}
+
+func progressFromContext(ctx context.Context) *progressTracker {
+	tracker, _ := ctx.Value(progressKey{}).(*progressTracker)
+	return tracker
+}
+
+// ytDlpProgressPrefix marks the lines printed by ytDlpProgressTemplate.
+const ytDlpProgressPrefix = "[vds-progress]"
+
+const ytDlpProgressTemplate = "download:" + ytDlpProgressPrefix +
+	" %(progress.downloaded_bytes)s %(progress.total_bytes)s %(progress.total_bytes_estimate)s %(progress.speed)s %(progress.eta)s"
+
+// YtDlpProgressArgs returns the yt-dlp flags that print machine readable
+// progress, or nothing when ctx does not track progress.
+func YtDlpProgressArgs(ctx context.Context) []string {
+	if progressFromContext(ctx) == nil {
+		return nil
+	}
+	return []string{"--newline", "--progress", "--progress-template", ytDlpProgressTemplate}
+}
+
+// AttachYtDlpProgress parses the stdout of a yt-dlp command started with
+// YtDlpProgressArgs.
+func AttachYtDlpProgress(ctx context.Context, cmd *exec.Cmd) {
+	tracker := progressFromContext(ctx)
+	if tracker == nil {
+		return
+	}
+	cmd.Stdout = &lineWriter{onLine: func(line string) {
+		if p, ok := parseYtDlpProgress(line); ok {
+			tracker.fn(p)
+		}
+	}}
+}
+
+func parseYtDlpProgress(line string) (TransferProgress, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), ytDlpProgressPrefix)
+	if !ok {
+		return TransferProgress{}, false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) != 5 {
+		return TransferProgress{}, false
+	}
+	downloaded, ok := parseProgressNumber(fields[0])
+	if !ok {
+		return TransferProgress{}, false
+	}
+
+	p := TransferProgress{DownloadedBytes: int64(downloaded), ETA: -1, Percent: -1}
+	if total, ok := parseProgressNumber(fields[1]); ok && total > 0 {
+		p.TotalBytes = int64(total)
+	} else if estimate, ok := parseProgressNumber(fields[2]); ok && estimate > 0 {
+		p.TotalBytes = int64(estimate)
+	}
+	if speed, ok := parseProgressNumber(fields[3]); ok {
+		p.Speed = speed
+	}
+	if eta, ok := parseProgressNumber(fields[4]); ok {
+		p.ETA = int(eta)
+	}
+	if p.TotalBytes > 0 {
+		p.Percent = min(100, float64(p.DownloadedBytes)/float64(p.TotalBytes)*100)
+	}
+	return p, true
+}
+
+// FFmpegProgressArgs returns the ffmpeg global options that print progress to
+// stdout, or nothing when ctx does not track progress.
+func FFmpegProgressArgs(ctx context.Context) []string {
+	if progressFromContext(ctx) == nil {
+		return nil
+	}
+	return []string{"-progress", "pipe:1", "-nostats"}
+}
+
+// AttachFFmpegProgress parses the stdout of an ffmpeg command started with
+// FFmpegProgressArgs. ffmpeg prints a block of key=value lines per update,
+// closed by a progress= line.
+func AttachFFmpegProgress(ctx context.Context, cmd *exec.Cmd) {
+	tracker := progressFromContext(ctx)
+	if tracker == nil {
+		return
+	}
+	parser := &ffmpegProgressParser{duration: tracker.duration, started: time.Now()}
+	cmd.Stdout = &lineWriter{onLine: func(line string) {
+		if p, ok := parser.parseLine(line); ok {
+			tracker.fn(p)
+		}
+	}}
+}
+
+type ffmpegProgressParser struct {
+	duration time.Duration
+	started  time.Time
+	size     int64
+	outTime  time.Duration
+	speed    float64
+}
+
+func (f *ffmpegProgressParser) parseLine(line string) (TransferProgress, bool) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok {
+		return TransferProgress{}, false
+	}
+	switch key {
+	case "total_size":
+		if n, ok := parseProgressNumber(value); ok {
+			f.size = int64(n)
+		}
+	case "out_time_us":
+		if n, ok := parseProgressNumber(value); ok && n >= 0 {
+			f.outTime = time.Duration(n) * time.Microsecond
+		}
+	case "speed":
+		if n, ok := parseProgressNumber(strings.TrimSuffix(value, "x")); ok {
+			f.speed = n
+		}
+	case "progress":
+		return f.snapshot(value == "end"), true
+	}
+	return TransferProgress{}, false
+}
+
+func (f *ffmpegProgressParser) snapshot(done bool) TransferProgress {
+	p := TransferProgress{DownloadedBytes: f.size, ETA: -1, Percent: -1}
+	if elapsed := time.Since(f.started).Seconds(); elapsed > 0 {
+		p.Speed = float64(f.size) / elapsed
+	}
+	if done {
+		p.TotalBytes = f.size
+		p.ETA = 0
+		p.Percent = 100
+		return p
+	}
+	if f.duration <= 0 {
+		return p
+	}
+
+	fraction := min(1, f.outTime.Seconds()/f.duration.Seconds())
+	p.Percent = fraction * 100
+	if fraction > 0.01 {
+		p.TotalBytes = int64(float64(f.size) / fraction)
+	}
+	if f.speed > 0 {
+		p.ETA = int(math.Max(0, (f.duration-f.outTime).Seconds()/f.speed))
+	}
+	return p
+}
+
+// parseProgressNumber reads a number printed by yt-dlp or ffmpeg, which use
+// "NA" and "N/A" for values they do not know.
+func parseProgressNumber(s string) (float64, bool) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+// lineWriter hands every complete line written to it to onLine. yt-dlp ends
+// some lines with a carriage return only.
+type lineWriter struct {
+	mu     sync.Mutex
+	buf    []byte
+	onLine func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		if line := string(w.buf[:i]); line != "" {
+			w.onLine(line)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	// A tool that never ends its lines must not grow the buffer forever.
+	if len(w.buf) > 64*1024 {
+		w.buf = w.buf[:0]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrReceiptInvalid is returned when the store answered and the purchase is not
+// valid: unknown token, another app's purchase or another product. Any other
+// verification error means the store could not be asked.
+var ErrReceiptInvalid = errors.New("purchase receipt is invalid")
+
+const (
+	ReceiptProviderGooglePlay = "google_play"
+	ReceiptProviderAppStore   = "app_store"
+	ReceiptProviderFake       = "fake"
+)
+
+// ReceiptRequest is a purchase as reported by the mobile app.
+type ReceiptRequest struct {
+	PackageName   string // Android package name or iOS bundle ID
+	ProductID     string
+	PurchaseToken string
+	TransactionID string // App Store original transaction ID
+}
+
+// ReceiptVerification is the store's view of a subscription purchase. Status is
+// one of trial, active, grace, on_hold, paused, canceled, expired or pending; a
+// refund is reported through Refunded.
+type ReceiptVerification struct {
+	Provider              string
+	OriginalTransactionID string
+	// ProviderTransactionID identifies the latest charge (Google order ID or App
+	// Store transaction ID) so each renewal is recorded once.
+	ProviderTransactionID string
+	ProductID             string
+	StartTime             time.Time
+	EndTime               time.Time
+	Status                string
+	AutoRenew             bool
+	Amount                float64 // zero when the store does not report a price
+	Currency              string
+	Refunded              bool
+	Test                  bool
+	Raw                   map[string]any
+}
+
+type ReceiptVerifier interface {
+	Verify(ctx context.Context, req ReceiptRequest) (*ReceiptVerification, error)
+}
+
+func invalidReceipt(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrReceiptInvalid, fmt.Sprintf(format, args...))
+}
+
+// JSONObject turns v into the plain JSON object stored in JSONB columns, such
+// as transactions.provider_response. It returns nil when v is not an object.
+func JSONObject(v any) map[string]any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// FakeReceiptVerifier answers from purchases registered with Add. It is meant
+// for tests and for development setups without store credentials.
+type FakeReceiptVerifier struct {
+	mu        sync.RWMutex
+	purchases map[string]ReceiptVerification
+	// AcceptUnknown makes unregistered tokens verify as a month-long active
+	// subscription to the requested product instead of being invalid.
+	AcceptUnknown bool
+	// Err, when set, is returned by every call to simulate an unreachable store.
+	Err error
+}
+
+func NewFakeReceiptVerifier() *FakeReceiptVerifier {
+	return &FakeReceiptVerifier{purchases: make(map[string]ReceiptVerification)}
+}
+
+func (f *FakeReceiptVerifier) Add(purchaseToken string, v ReceiptVerification) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.purchases[purchaseToken] = v
+}
+
+func (f *FakeReceiptVerifier) Verify(ctx context.Context, req ReceiptRequest) (*ReceiptVerification, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	f.mu.RLock()
+	v, ok := f.purchases[req.PurchaseToken]
+	f.mu.RUnlock()
+
+	if !ok {
+		if !f.AcceptUnknown {
+			return nil, invalidReceipt("unknown purchase token")
+		}
+		now := time.Now()
+		v = ReceiptVerification{
+			OriginalTransactionID: req.TransactionID,
+			ProviderTransactionID: req.PurchaseToken,
+			ProductID:             req.ProductID,
+			StartTime:             now,
+			EndTime:               now.AddDate(0, 1, 0),
+			Status:                "active",
+			AutoRenew:             true,
+			Test:                  true,
+		}
+	}
+	if v.ProductID != req.ProductID {
+		return nil, invalidReceipt("purchase is for product %q", v.ProductID)
+	}
+
+	v.Provider = ReceiptProviderFake
+	if v.OriginalTransactionID == "" {
+		v.OriginalTransactionID = req.TransactionID
+	}
+	if v.Raw == nil {
+		v.Raw = map[string]any{"fake": true, "purchase_token": req.PurchaseToken, "product_id": v.ProductID}
+	}
+	return &v, nil
+}
@@ -0,0 +1,223 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	strategyHealthKeyPrefix  = "strategy:health:"
+	strategyBreakerKeyPrefix = "strategy:breaker:"
+	strategyHealthIndexKey   = "strategy:health:index"
+
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCoolDown         = 5 * time.Minute
+	strategyHealthTTL              = 7 * 24 * time.Hour
+)
+
+type StrategyHealthStats struct {
+	Platform            string     `json:"platform"`
+	Strategy            string     `json:"strategy"`
+	Successes           int64      `json:"successes"`
+	Failures            int64      `json:"failures"`
+	SuccessRate         float64    `json:"success_rate"`
+	AvgLatencyMs        int64      `json:"avg_latency_ms"`
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	BreakerOpen         bool       `json:"breaker_open"`
+	BreakerOpenUntil    *time.Time `json:"breaker_open_until,omitempty"`
+}
+
+// StrategyHealthTracker records per-platform strategy outcomes and trips a
+// circuit breaker after repeated consecutive failures.
+type StrategyHealthTracker interface {
+	Allow(ctx context.Context, platform, strategy string) bool
+	RecordSuccess(ctx context.Context, platform, strategy string, latency time.Duration)
+	RecordFailure(ctx context.Context, platform, strategy string, latency time.Duration, err error)
+	Snapshot(ctx context.Context) ([]StrategyHealthStats, error)
+	Reset(ctx context.Context, platform, strategy string) error
+}
+
+type redisStrategyHealthTracker struct {
+	client           *redis.Client
+	failureThreshold int64
+	coolDown         time.Duration
+}
+
+func NewStrategyHealthTracker(client *redis.Client) StrategyHealthTracker {
+	threshold := int64(defaultBreakerFailureThreshold)
+	if v := sanitizeEnvString(os.Getenv("STRATEGY_BREAKER_FAILURES")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			threshold = n
+		}
+	}
+
+	coolDown := defaultBreakerCoolDown
+	if v := sanitizeEnvString(os.Getenv("STRATEGY_BREAKER_COOLDOWN_SECONDS")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			coolDown = time.Duration(n) * time.Second
+		}
+	}
+
+	return &redisStrategyHealthTracker{
+		client:           client,
+		failureThreshold: threshold,
+		coolDown:         coolDown,
+	}
+}
+
+func strategyHealthMember(platform, strategy string) string {
+	return platform + "|" + strategy
+}
+
+func (t *redisStrategyHealthTracker) statsKey(platform, strategy string) string {
+	return strategyHealthKeyPrefix + platform + ":" + strategy
+}
+
+func (t *redisStrategyHealthTracker) breakerKey(platform, strategy string) string {
+	return strategyBreakerKeyPrefix + platform + ":" + strategy
+}
+
+func (t *redisStrategyHealthTracker) Allow(ctx context.Context, platform, strategy string) bool {
+	n, err := t.client.Exists(ctx, t.breakerKey(platform, strategy)).Result()
+	if err != nil {
+		// Fail open: a Redis hiccup must not take every strategy offline.
+		return true
+	}
+	return n == 0
+}
+
+func (t *redisStrategyHealthTracker) RecordSuccess(ctx context.Context, platform, strategy string, latency time.Duration) {
+	key := t.statsKey(platform, strategy)
+	pipe := t.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, "successes", 1)
+	pipe.HIncrBy(ctx, key, "latency_ms_total", latency.Milliseconds())
+	pipe.HSet(ctx, key, "consecutive_failures", 0, "last_success_at", time.Now().Unix())
+	pipe.Expire(ctx, key, strategyHealthTTL)
+	pipe.Del(ctx, t.breakerKey(platform, strategy))
+	pipe.SAdd(ctx, strategyHealthIndexKey, strategyHealthMember(platform, strategy))
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Warn().Err(err).Str("platform", platform).Str("strategy", strategy).Msg("Failed to record strategy success")
+	}
+}
+
+func (t *redisStrategyHealthTracker) RecordFailure(ctx context.Context, platform, strategy string, latency time.Duration, cause error) {
+	key := t.statsKey(platform, strategy)
+	lastError := ""
+	if cause != nil {
+		lastError = cause.Error()
+		if len(lastError) > 500 {
+			lastError = lastError[:500]
+		}
+	}
+
+	pipe := t.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, "failures", 1)
+	pipe.HIncrBy(ctx, key, "latency_ms_total", latency.Milliseconds())
+	consecutive := pipe.HIncrBy(ctx, key, "consecutive_failures", 1)
+	pipe.HSet(ctx, key, "last_error", lastError, "last_failure_at", time.Now().Unix())
+	pipe.Expire(ctx, key, strategyHealthTTL)
+	pipe.SAdd(ctx, strategyHealthIndexKey, strategyHealthMember(platform, strategy))
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Warn().Err(err).Str("platform", platform).Str("strategy", strategy).Msg("Failed to record strategy failure")
+		return
+	}
+
+	if consecutive.Val() >= t.failureThreshold {
+		if err := t.client.Set(ctx, t.breakerKey(platform, strategy), time.Now().Add(t.coolDown).Unix(), t.coolDown).Err(); err != nil {
+			log.Warn().Err(err).Str("platform", platform).Str("strategy", strategy).Msg("Failed to open strategy circuit breaker")
+			return
+		}
+		log.Warn().
+			Str("platform", platform).
+			Str("strategy", strategy).
+			Int64("consecutive_failures", consecutive.Val()).
+			Dur("cool_down", t.coolDown).
+			Msg("Strategy circuit breaker opened")
+	}
+}
+
+func (t *redisStrategyHealthTracker) Snapshot(ctx context.Context) ([]StrategyHealthStats, error) {
+	members, err := t.client.SMembers(ctx, strategyHealthIndexKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list strategy health: %w", err)
+	}
+	sort.Strings(members)
+
+	stats := make([]StrategyHealthStats, 0, len(members))
+	for _, member := range members {
+		parts := strings.SplitN(member, "|", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		platform, strategy := parts[0], parts[1]
+
+		values, err := t.client.HGetAll(ctx, t.statsKey(platform, strategy)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read strategy health: %w", err)
+		}
+		if len(values) == 0 {
+			// Stats expired; drop the stale index entry.
+			t.client.SRem(ctx, strategyHealthIndexKey, member)
+			continue
+		}
+
+		s := StrategyHealthStats{
+			Platform:            platform,
+			Strategy:            strategy,
+			Successes:           parseInt64(values["successes"]),
+			Failures:            parseInt64(values["failures"]),
+			ConsecutiveFailures: parseInt64(values["consecutive_failures"]),
+			LastError:           values["last_error"],
+			LastSuccessAt:       parseUnixTime(values["last_success_at"]),
+			LastFailureAt:       parseUnixTime(values["last_failure_at"]),
+		}
+		if total := s.Successes + s.Failures; total > 0 {
+			s.SuccessRate = float64(s.Successes) / float64(total)
+			s.AvgLatencyMs = parseInt64(values["latency_ms_total"]) / total
+		}
+
+		if until, err := t.client.Get(ctx, t.breakerKey(platform, strategy)).Result(); err == nil {
+			s.BreakerOpen = true
+			s.BreakerOpenUntil = parseUnixTime(until)
+		}
+
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
+
+func (t *redisStrategyHealthTracker) Reset(ctx context.Context, platform, strategy string) error {
+	pipe := t.client.TxPipeline()
+	pipe.Del(ctx, t.breakerKey(platform, strategy))
+	pipe.HSet(ctx, t.statsKey(platform, strategy), "consecutive_failures", 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to reset strategy breaker: %w", err)
+	}
+	return nil
+}
+
+func parseInt64(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func parseUnixTime(s string) *time.Time {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return nil
+	}
+	t := time.Unix(n, 0)
+	return &t
+}
@@ -0,0 +1,165 @@
+package infrastructure
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StrategyRoutingConfigKey is the Platform.Config key holding the downloader routing, e.g.
+//
+//	{"downloader": {"order": ["yt-dlp", "lux"], "disabled": ["chromedp"], "timeouts": {"yt-dlp": 30}}}
+//
+// Timeouts are expressed in seconds and none may exceed the overall video info
+// budget. Strategies run inside that budget, so when it runs out the chain stops
+// even if later strategies had time left.
+const StrategyRoutingConfigKey = "downloader"
+
+var knownStrategyNames = []string{"ytdown", "youtube-custom", "yt-dlp", "lux", "kkdai/youtube", "rumble-custom", "vimeo-custom", "chromedp"}
+
+type StrategyRouting struct {
+	Order    []string
+	Disabled map[string]bool
+	Timeouts map[string]time.Duration
+}
+
+func KnownStrategyNames() []string {
+	names := make([]string, len(knownStrategyNames))
+	copy(names, knownStrategyNames)
+	return names
+}
+
+func isKnownStrategy(name string) bool {
+	for _, n := range knownStrategyNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseStrategyRouting reads the downloader routing from a platform config.
+// It returns nil when the platform does not override the default ordering.
+func ParseStrategyRouting(cfg map[string]any) (*StrategyRouting, error) {
+	raw, ok := cfg[StrategyRoutingConfigKey]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	section, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s config must be an object", StrategyRoutingConfigKey)
+	}
+
+	routing := &StrategyRouting{
+		Disabled: make(map[string]bool),
+		Timeouts: make(map[string]time.Duration),
+	}
+
+	if v, ok := section["order"]; ok && v != nil {
+		names, err := parseStrategyNameList(v, "order")
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool, len(names))
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			routing.Order = append(routing.Order, name)
+		}
+	}
+
+	if v, ok := section["disabled"]; ok && v != nil {
+		names, err := parseStrategyNameList(v, "disabled")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			routing.Disabled[name] = true
+		}
+	}
+
+	if v, ok := section["timeouts"]; ok && v != nil {
+		timeouts, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s.timeouts must be an object", StrategyRoutingConfigKey)
+		}
+		for name, t := range timeouts {
+			name = strings.TrimSpace(name)
+			if !isKnownStrategy(name) {
+				return nil, fmt.Errorf("%s.timeouts: unknown strategy %q", StrategyRoutingConfigKey, name)
+			}
+			seconds, ok := t.(float64)
+			if !ok || seconds <= 0 {
+				return nil, fmt.Errorf("%s.timeouts.%s must be a positive number of seconds", StrategyRoutingConfigKey, name)
+			}
+			timeout := time.Duration(seconds * float64(time.Second))
+			if timeout > videoInfoTimeout {
+				return nil, fmt.Errorf("%s.timeouts.%s must not exceed %.0f seconds", StrategyRoutingConfigKey, name, videoInfoTimeout.Seconds())
+			}
+			routing.Timeouts[name] = timeout
+		}
+	}
+
+	return routing, nil
+}
+
+func parseStrategyNameList(v any, field string) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s must be a list of strategy names", StrategyRoutingConfigKey, field)
+	}
+	names := make([]string, 0, len(list))
+	for _, item := range list {
+		name, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a list of strategy names", StrategyRoutingConfigKey, field)
+		}
+		name = strings.TrimSpace(name)
+		if !isKnownStrategy(name) {
+			return nil, fmt.Errorf("%s.%s: unknown strategy %q", StrategyRoutingConfigKey, field, name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Apply reorders and filters the default strategy chain. When an explicit order is
+// configured it replaces the defaults, picking strategies from the full pool.
+func (r *StrategyRouting) Apply(defaults []DownloaderStrategy, pool []DownloaderStrategy) []DownloaderStrategy {
+	if r == nil {
+		return defaults
+	}
+
+	selected := defaults
+	if len(r.Order) > 0 {
+		byName := make(map[string]DownloaderStrategy, len(pool))
+		for _, s := range pool {
+			byName[s.Name()] = s
+		}
+		selected = make([]DownloaderStrategy, 0, len(r.Order))
+		for _, name := range r.Order {
+			if s, ok := byName[name]; ok {
+				selected = append(selected, s)
+			}
+		}
+	}
+
+	result := make([]DownloaderStrategy, 0, len(selected))
+	for _, s := range selected {
+		if r.Disabled[s.Name()] {
+			continue
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+func (r *StrategyRouting) TimeoutFor(name string) time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.Timeouts[name]
+}
@@ -0,0 +1,268 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	videoInfoCacheKeyPrefix = "videoinfo:"
+	defaultVideoInfoTTL     = 30 * time.Minute
+	// Signed format URLs must stay usable for a while after they are served from cache.
+	signedURLSafetyMargin = 2 * time.Minute
+	minVideoInfoTTL       = 30 * time.Second
+)
+
+type VideoInfoCache interface {
+	Get(ctx context.Context, key string) (*VideoInfo, bool)
+	Set(ctx context.Context, key string, info *VideoInfo)
+}
+
+type redisVideoInfoCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewVideoInfoCache returns nil when caching is disabled through VIDEO_INFO_CACHE_DISABLED.
+func NewVideoInfoCache(client *redis.Client) VideoInfoCache {
+	if strings.EqualFold(sanitizeEnvString(os.Getenv("VIDEO_INFO_CACHE_DISABLED")), "true") {
+		return nil
+	}
+
+	ttl := defaultVideoInfoTTL
+	if v := sanitizeEnvString(os.Getenv("VIDEO_INFO_CACHE_TTL_SECONDS")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			ttl = time.Duration(n) * time.Second
+		}
+	}
+
+	return &redisVideoInfoCache{client: client, ttl: ttl}
+}
+
+func (c *redisVideoInfoCache) Get(ctx context.Context, key string) (*VideoInfo, bool) {
+	val, err := c.client.Get(ctx, videoInfoCacheKeyPrefix+key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Warn().Err(err).Str("key", key).Msg("Failed to read video info cache")
+		}
+		return nil, false
+	}
+
+	var info VideoInfo
+	if err := json.Unmarshal(val, &info); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Failed to decode cached video info")
+		return nil, false
+	}
+	return &info, true
+}
+
+func (c *redisVideoInfoCache) Set(ctx context.Context, key string, info *VideoInfo) {
+	if info == nil {
+		return
+	}
+
+	ttl := c.ttl
+	if expiry, ok := earliestSignedURLExpiry(info); ok {
+		if remaining := time.Until(expiry) - signedURLSafetyMargin; remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if ttl < minVideoInfoTTL {
+		return
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return
+	}
+	if err := c.client.Set(ctx, videoInfoCacheKeyPrefix+key, data, ttl).Err(); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Failed to write video info cache")
+	}
+}
+
+var (
+	tiktokVideoIDPattern      = regexp.MustCompile(`tiktok\.com/.*/video/(\d+)`)
+	vimeoVideoIDPattern       = regexp.MustCompile(`vimeo\.com/(?:.*/)?(\d{5,})`)
+	dailymotionVideoIDPattern = regexp.MustCompile(`(?:dailymotion\.com/(?:embed/)?video/|dai\.ly/)([a-zA-Z0-9]+)`)
+	twitterStatusIDPattern    = regexp.MustCompile(`(?:twitter\.com|x\.com)/[^/]+/status(?:es)?/(\d+)`)
+	instagramMediaIDPattern   = regexp.MustCompile(`instagram\.com/(?:[^/]+/)?(?:p|reel|reels|tv)/([a-zA-Z0-9_-]+)`)
+	facebookVideoIDPattern    = regexp.MustCompile(`facebook\.com/(?:.*/)?(?:videos|reel)/(?:[^/]+/)?(\d+)`)
+	rumbleVideoIDPattern      = regexp.MustCompile(`rumble\.com/(?:embed/)?(v[a-zA-Z0-9]+)`)
+	twitchVideoIDPattern      = regexp.MustCompile(`(?:twitch\.tv/videos/(\d+)|twitch\.tv/[^/]+/clip/([a-zA-Z0-9_-]+)|clips\.twitch\.tv/([a-zA-Z0-9_-]+))`)
+)
+
+var trackingQueryParams = map[string]bool{
+	"si": true, "feature": true, "fbclid": true, "igsh": true, "igshid": true,
+	"is_from_webapp": true, "sender_device": true, "ref": true, "ref_src": true, "s": true, "t": true,
+}
+
+// CanonicalVideoKey maps equivalent URLs of the same video to one cache key,
+// e.g. youtu.be/<id> and youtube.com/watch?v=<id>&si=... both become "youtube:<id>".
+func CanonicalVideoKey(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	lower := strings.ToLower(rawURL)
+
+	switch {
+	case strings.Contains(lower, "youtube.com") || strings.Contains(lower, "youtu.be"):
+		if id := extractYouTubeID(rawURL); id != "" {
+			return "youtube:" + id
+		}
+	case strings.Contains(lower, "tiktok.com"):
+		if m := tiktokVideoIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+			return "tiktok:" + m[1]
+		}
+	case strings.Contains(lower, "vimeo.com"):
+		if m := vimeoVideoIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+			return "vimeo:" + m[1]
+		}
+	case strings.Contains(lower, "dailymotion.com") || strings.Contains(lower, "dai.ly"):
+		if m := dailymotionVideoIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+			return "dailymotion:" + m[1]
+		}
+	case strings.Contains(lower, "twitter.com") || strings.Contains(lower, "x.com"):
+		if m := twitterStatusIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+			return "twitter:" + m[1]
+		}
+	case strings.Contains(lower, "instagram.com"):
+		if m := instagramMediaIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+			return "instagram:" + m[1]
+		}
+	case strings.Contains(lower, "facebook.com"):
+		if m := facebookVideoIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+			return "facebook:" + m[1]
+		}
+		if u, err := url.Parse(rawURL); err == nil {
+			if v := u.Query().Get("v"); v != "" {
+				return "facebook:" + v
+			}
+		}
+	case strings.Contains(lower, "rumble.com"):
+		if m := rumbleVideoIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+			return "rumble:" + m[1]
+		}
+	case strings.Contains(lower, "twitch.tv"):
+		if m := twitchVideoIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+			for _, id := range m[1:] {
+				if id != "" {
+					return "twitch:" + id
+				}
+			}
+		}
+	}
+
+	return "url:" + normalizeVideoURL(rawURL)
+}
+
+func normalizeVideoURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "utm_") || trackingQueryParams[lk] {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(host)
+	b.WriteString(strings.TrimSuffix(u.EscapedPath(), "/"))
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteString("?")
+		} else {
+			b.WriteString("&")
+		}
+		b.WriteString(url.QueryEscape(k))
+		b.WriteString("=")
+		b.WriteString(url.QueryEscape(query.Get(k)))
+	}
+	return b.String()
+}
+
+// earliestSignedURLExpiry inspects the well-known expiry parameters of signed CDN
+// URLs (googlevideo, CloudFront/S3, TikTok, Meta) and returns the soonest one.
+func earliestSignedURLExpiry(info *VideoInfo) (time.Time, bool) {
+	var earliest time.Time
+	found := false
+
+	consider := func(raw string) {
+		if t, ok := signedURLExpiry(raw); ok && (!found || t.Before(earliest)) {
+			earliest = t
+			found = true
+		}
+	}
+
+	consider(info.DownloadURL)
+	for _, f := range info.Formats {
+		consider(f.URL)
+	}
+
+	return earliest, found
+}
+
+func signedURLExpiry(raw string) (time.Time, bool) {
+	if raw == "" {
+		return time.Time{}, false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	q := u.Query()
+
+	for _, name := range []string{"expire", "expires", "Expires", "x-expires", "X-Expires"} {
+		if v := q.Get(name); v != "" {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+				return time.Unix(n, 0), true
+			}
+		}
+	}
+
+	if v := q.Get("X-Amz-Date"); v != "" {
+		if signedAt, err := time.Parse("20060102T150405Z", v); err == nil {
+			if secs, err := strconv.Atoi(q.Get("X-Amz-Expires")); err == nil && secs > 0 {
+				return signedAt.Add(time.Duration(secs) * time.Second), true
+			}
+		}
+	}
+
+	// Facebook / Instagram CDNs encode the expiry as a hex unix timestamp.
+	if v := q.Get("oe"); v != "" {
+		if n, err := strconv.ParseInt(v, 16, 64); err == nil && n > 0 {
+			return time.Unix(n, 0), true
+		}
+	}
+
+	// YouTube and some HLS CDNs put the expiry into the path: /expire/<unix>/
+	if idx := strings.Index(u.Path, "/expire/"); idx >= 0 {
+		rest := strings.TrimPrefix(u.Path[idx:], "/expire/")
+		if end := strings.Index(rest, "/"); end > 0 {
+			rest = rest[:end]
+		}
+		if n, err := strconv.ParseInt(rest, 10, 64); err == nil && n > 0 {
+			return time.Unix(n, 0), true
+		}
+	}
+
+	return time.Time{}, false
+}
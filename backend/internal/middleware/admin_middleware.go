@@ -1,21 +1,48 @@
 package middleware
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/user/video-downloader-backend/internal/model"
 	"github.com/user/video-downloader-backend/pkg/response"
 )
 
+// AdminMiddleware admits users whose role holds at least one admin permission.
+// What they may do inside is decided per route by RequirePermission.
 func AdminMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		roleName, ok := c.Locals("role_name").(string)
+		permissions, ok := c.Locals("permissions").(map[string]bool)
 		if !ok {
 			return response.Error(c, fiber.StatusUnauthorized, "Unauthorized: Role information missing", nil)
 		}
 
-		if roleName != "admin" {
+		if !model.HasAdminAccess(permissions) {
 			return response.Error(c, fiber.StatusForbidden, "Forbidden: Admin access required", nil)
 		}
 
 		return c.Next()
 	}
 }
+
+// RequirePermission rejects requests whose token does not grant permission. It
+// must run after JWTMiddleware. Unknown keys panic at startup, so a typo in a
+// route cannot lock the route down to "all" holders only.
+func RequirePermission(permission string) fiber.Handler {
+	if !model.IsKnownPermission(permission) {
+		panic(fmt.Sprintf("middleware: unknown permission %q", permission))
+	}
+
+	return func(c *fiber.Ctx) error {
+		permissions, ok := c.Locals("permissions").(map[string]bool)
+		if !ok {
+			return response.Error(c, fiber.StatusUnauthorized, "Unauthorized: Role information missing", nil)
+		}
+
+		if !model.HasPermission(permissions, permission) {
+			return response.Error(c, fiber.StatusForbidden, "Forbidden: Missing permission "+permission, nil)
+		}
+
+		return c.Next()
+	}
+}
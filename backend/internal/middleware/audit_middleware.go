@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/service"
+)
+
+// AuditLoader returns the current state of what a request changes. It is
+// called before and after the handler to record the difference.
+type AuditLoader func(c *fiber.Ctx) (any, error)
+
+// AuditByID loads the record named by the :id route parameter.
+func AuditByID[T any](find func(ctx context.Context, id uuid.UUID) (T, error)) AuditLoader {
+	return func(c *fiber.Ctx) (any, error) {
+		id, err := uuid.Parse(c.Params("id"))
+		if err != nil {
+			return nil, nil
+		}
+		return find(HandlerContext(c), id)
+	}
+}
+
+// AuditCurrentUser loads the signed in user, for self-service routes.
+func AuditCurrentUser[T any](find func(ctx context.Context, id uuid.UUID) (T, error)) AuditLoader {
+	return func(c *fiber.Ctx) (any, error) {
+		userID, ok := c.Locals("user_id").(uuid.UUID)
+		if !ok {
+			return nil, nil
+		}
+		return find(HandlerContext(c), userID)
+	}
+}
+
+// Audit records the request in the audit log under action once the handler
+// has run, whatever its outcome. load may be nil when there is no state worth
+// diffing, as for bulk deletes whose targets are listed in the payload.
+func Audit(audit service.AuditService, action string, load AuditLoader) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var before any
+		if load != nil {
+			before = auditSnapshot(c, load, action)
+		}
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+
+		after := before
+		if load != nil && status < fiber.StatusBadRequest {
+			after = auditSnapshot(c, load, action)
+		}
+
+		event := &model.AuditEvent{
+			Action:     action,
+			Method:     c.Method(),
+			Path:       c.Path(),
+			TargetIDs:  auditTargets(c),
+			Payload:    auditPayload(c),
+			StatusCode: status,
+			IPAddress:  optionalString(c.IP()),
+			UserAgent:  optionalString(c.Get(fiber.HeaderUserAgent)),
+		}
+		if actorID, ok := c.Locals("user_id").(uuid.UUID); ok {
+			event.ActorID = &actorID
+		}
+		if email, ok := c.Locals("email").(string); ok {
+			event.ActorEmail = optionalString(email)
+		}
+		if role, ok := c.Locals("role_name").(string); ok {
+			event.ActorRole = optionalString(role)
+		}
+
+		// The action has happened by now; losing its trace must not fail it.
+		ctx := context.WithoutCancel(FromContext(c))
+		if recErr := audit.Record(ctx, event, before, after); recErr != nil {
+			log.Error().Err(recErr).Str("action", action).Str("path", event.Path).Msg("Failed to record audit event")
+		}
+
+		return err
+	}
+}
+
+func auditSnapshot(c *fiber.Ctx, load AuditLoader, action string) any {
+	state, err := load(c)
+	if err != nil {
+		// Deleted records are expected to be gone afterwards.
+		log.Debug().Err(err).Str("action", action).Msg("Audit snapshot unavailable")
+		return nil
+	}
+	return state
+}
+
+// auditTargets collects the :id route parameter and the "ids" of bulk
+// requests.
+func auditTargets(c *fiber.Ctx) []string {
+	var targets []string
+	if id := c.Params("id"); id != "" {
+		targets = append(targets, id)
+	}
+
+	var bulk struct {
+		IDs []string `json:"ids"`
+	}
+	if isJSONBody(c) && json.Unmarshal(c.Body(), &bulk) == nil {
+		targets = append(targets, bulk.IDs...)
+	}
+	return targets
+}
+
+// auditPayload returns the JSON request body, or nil for uploads and empty
+// bodies.
+func auditPayload(c *fiber.Ctx) any {
+	body := c.Body()
+	if len(body) == 0 || !isJSONBody(c) || !json.Valid(body) {
+		return nil
+	}
+	return json.RawMessage(append([]byte(nil), body...))
+}
+
+func isJSONBody(c *fiber.Ctx) bool {
+	return strings.HasPrefix(strings.ToLower(c.Get(fiber.HeaderContentType)), fiber.MIMEApplicationJSON)
+}
+
+func optionalString(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -15,7 +15,20 @@ type CredentialLimiterConfig struct {
 	BlockTime   time.Duration
 }
 
+// CredentialAttemptLimiter blocks an IP from a sign-in route after 3 failed
+// attempts in 15 minutes.
 func CredentialAttemptLimiter(redisClient *redis.Client) fiber.Handler {
+	return attemptLimiter(redisClient, CredentialLimiterConfig{MaxAttempts: 3, Window: 15 * time.Minute})
+}
+
+// RefreshAttemptLimiter guards token refresh. Refresh tokens cannot be guessed,
+// and an expired one is routine, so the limit only stops floods; clients sharing
+// an address must not lock each other out.
+func RefreshAttemptLimiter(redisClient *redis.Client) fiber.Handler {
+	return attemptLimiter(redisClient, CredentialLimiterConfig{MaxAttempts: 100, Window: 15 * time.Minute})
+}
+
+func attemptLimiter(redisClient *redis.Client, cfg CredentialLimiterConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx := FromContext(c)
 
@@ -26,7 +39,7 @@ func CredentialAttemptLimiter(redisClient *redis.Client) fiber.Handler {
 			return c.Next()
 		}
 
-		if attempts >= 3 {
+		if attempts >= cfg.MaxAttempts {
 			ttl, _ := redisClient.TTL(ctx, key).Result()
 			return response.Error(c, fiber.StatusTooManyRequests, fmt.Sprintf("Too many failed attempts. Please try again in %v", ttl), nil)
 		}
@@ -37,7 +50,7 @@ func CredentialAttemptLimiter(redisClient *redis.Client) fiber.Handler {
 			pipe := redisClient.Pipeline()
 			pipe.Incr(ctx, key)
 			if attempts == 0 {
-				pipe.Expire(ctx, key, 15*time.Minute)
+				pipe.Expire(ctx, key, cfg.Window)
 			}
 			_, _ = pipe.Exec(ctx)
 		} else if c.Response().StatusCode() == fiber.StatusOK {
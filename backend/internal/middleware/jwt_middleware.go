@@ -6,11 +6,27 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"github.com/user/video-downloader-backend/internal/service"
 	"github.com/user/video-downloader-backend/pkg/response"
 )
 
+// JWTMiddleware authenticates user routes with a bearer access token. When the
+// revocation list in Redis cannot be read, the token is accepted anyway: an
+// outage should not sign every user out, and a revoked token stays usable for
+// at most its remaining lifetime. AdminJWTMiddleware makes the opposite call.
 func JWTMiddleware(tokenService service.TokenService) fiber.Handler {
+	return jwtMiddleware(tokenService, false)
+}
+
+// AdminJWTMiddleware authenticates admin routes like JWTMiddleware, but rejects
+// the request when revocation cannot be checked, since a revoked admin token is
+// worth more than the availability of the dashboard during a Redis outage.
+func AdminJWTMiddleware(tokenService service.TokenService) fiber.Handler {
+	return jwtMiddleware(tokenService, true)
+}
+
+func jwtMiddleware(tokenService service.TokenService, requireRevocationCheck bool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -36,6 +52,17 @@ func JWTMiddleware(tokenService service.TokenService) fiber.Handler {
 			return response.Error(c, fiber.StatusUnauthorized, "Invalid token claims", nil)
 		}
 
+		revoked, err := tokenService.IsRevoked(FromContext(c), claims)
+		if err != nil {
+			if requireRevocationCheck {
+				log.Error().Err(err).Msg("Token revocation check failed")
+				return response.Error(c, fiber.StatusServiceUnavailable, "Unable to verify token", nil)
+			}
+			log.Warn().Err(err).Msg("Skipping token revocation check")
+		} else if revoked {
+			return response.Error(c, fiber.StatusUnauthorized, "Token has been revoked", nil)
+		}
+
 		if sub, ok := claims["sub"].(string); ok {
 			if userID, err := uuid.Parse(sub); err == nil {
 				c.Locals("user_id", userID)
@@ -58,6 +85,50 @@ func JWTMiddleware(tokenService service.TokenService) fiber.Handler {
 			c.Locals("role_name", roleName)
 		}
 
+		permissions := make(map[string]bool)
+		if perms, ok := claims["perms"].([]interface{}); ok {
+			for _, perm := range perms {
+				if key, ok := perm.(string); ok {
+					permissions[key] = true
+				}
+			}
+		}
+		c.Locals("permissions", permissions)
+
+		// Tokens signed before email verification existed carry no claim; their
+		// accounts were all marked verified.
+		emailVerified := true
+		if verified, ok := claims["email_verified"].(bool); ok {
+			emailVerified = verified
+		}
+		c.Locals("email_verified", emailVerified)
+
+		if jti, ok := claims["jti"].(string); ok {
+			c.Locals("jti", jti)
+		}
+
+		if sid, ok := claims["sid"].(string); ok {
+			if sessionID, err := uuid.Parse(sid); err == nil {
+				c.Locals("session_id", sessionID)
+			}
+		}
+
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Locals("token_exp", exp.Time)
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireVerifiedEmail keeps accounts that have not confirmed their email
+// address out of a route. It must run after JWTMiddleware; a token issued
+// before the address was verified passes once it is refreshed.
+func RequireVerifiedEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if verified, ok := c.Locals("email_verified").(bool); !ok || !verified {
+			return response.Error(c, fiber.StatusForbidden, "Verify your email address first", service.ErrEmailNotVerified.Error())
+		}
 		return c.Next()
 	}
 }
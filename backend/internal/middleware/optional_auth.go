@@ -30,6 +30,9 @@ func OptionalJWTMiddleware(tokenService service.TokenService) fiber.Handler {
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if ok {
+			if revoked, err := tokenService.IsRevoked(FromContext(c), claims); err == nil && revoked {
+				return c.Next()
+			}
 			if sub, ok := claims["sub"].(string); ok {
 				if userID, err := uuid.Parse(sub); err == nil {
 					c.Locals("user_id", userID)
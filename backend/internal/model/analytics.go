@@ -6,12 +6,65 @@ import (
 	"github.com/google/uuid"
 )
 
+// AnalyticsDaily holds the totals of one day. Rows with an empty PlatformType
+// and no AppID are the day's totals; the others break them down by platform,
+// application, or both.
 type AnalyticsDaily struct {
-	ID             uuid.UUID `json:"id" db:"id"`
-	Date           time.Time `json:"date" db:"date"`
-	TotalDownloads int       `json:"total_downloads" db:"total_downloads"`
-	TotalUsers     int       `json:"total_users" db:"total_users"`
-	ActiveUsers    int       `json:"active_users" db:"active_users"`
-	TotalRevenue   float64   `json:"total_revenue" db:"total_revenue"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	ID             uuid.UUID  `json:"id" db:"id"`
+	Date           time.Time  `json:"date" db:"date"`
+	PlatformType   string     `json:"platform_type,omitempty" db:"platform_type"`
+	AppID          *uuid.UUID `json:"app_id,omitempty" db:"app_id"`
+	TotalDownloads int        `json:"total_downloads" db:"total_downloads"`
+	TotalUsers     int        `json:"total_users" db:"total_users"`
+	NewUsers       int        `json:"new_users" db:"new_users"`
+	ActiveUsers    int        `json:"active_users" db:"active_users"`
+	// TotalRevenue only counts USD; RevenueByCurrency holds the revenue of every
+	// currency by its ISO 4217 code.
+	TotalRevenue      float64            `json:"total_revenue" db:"total_revenue"`
+	RevenueByCurrency map[string]float64 `json:"revenue_by_currency" db:"revenue_by_currency"`
+	UpdatedAt         time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// AnalyticsAggregateTask is the payload of an analytics aggregation task. Both
+// dates are UTC days and inclusive; an empty payload aggregates yesterday and
+// today.
+type AnalyticsAggregateTask struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+const (
+	AnalyticsGroupPlatform      = "platform_type"
+	AnalyticsGroupApplication   = "application"
+	AnalyticsGroupStatus        = "status"
+	AnalyticsGroupErrorCategory = "error_category"
+	AnalyticsGroupCountry       = "country"
+	AnalyticsGroupNone          = "none"
+)
+
+// AnalyticsQuery selects the downloads of an analytics series. Interval is hour,
+// day, week, month or all; From is inclusive and To exclusive.
+type AnalyticsQuery struct {
+	From         time.Time  `json:"from"`
+	To           time.Time  `json:"to"`
+	Interval     string     `json:"interval"`
+	GroupBy      string     `json:"group_by"`
+	PlatformType string     `json:"platform_type,omitempty"`
+	AppID        *uuid.UUID `json:"app_id,omitempty"`
+	Status       string     `json:"status,omitempty"`
+}
+
+// AnalyticsPoint is the number of downloads of one group in one time bucket.
+type AnalyticsPoint struct {
+	Bucket      time.Time `json:"bucket" db:"bucket"`
+	Group       string    `json:"group" db:"grp"`
+	Total       int64     `json:"total" db:"total"`
+	Completed   int64     `json:"completed" db:"completed"`
+	Failed      int64     `json:"failed" db:"failed"`
+	FailureRate float64   `json:"failure_rate" db:"-"`
+}
+
+type AnalyticsSeries struct {
+	Query  AnalyticsQuery   `json:"query"`
+	Points []AnalyticsPoint `json:"points"`
 }
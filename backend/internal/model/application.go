@@ -82,22 +82,47 @@ type Subscription struct {
 }
 
 type Transaction struct {
-	ID               uuid.UUID      `json:"id" db:"id"`
-	UserID           *uuid.UUID     `json:"user_id,omitempty" db:"user_id"`
-	AppID            *uuid.UUID     `json:"app_id,omitempty" db:"app_id"`
-	SubscriptionID   *uuid.UUID     `json:"subscription_id,omitempty" db:"subscription_id"`
-	Amount           float64        `json:"amount" db:"amount"`
-	Currency         string         `json:"currency" db:"currency"`
-	Provider         string         `json:"provider" db:"provider"`
-	Status           string         `json:"status" db:"status"`
-	ProviderResponse map[string]any `json:"provider_response" db:"provider_response"`
-	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
+	ID                    uuid.UUID      `json:"id" db:"id"`
+	UserID                *uuid.UUID     `json:"user_id,omitempty" db:"user_id"`
+	AppID                 *uuid.UUID     `json:"app_id,omitempty" db:"app_id"`
+	SubscriptionID        *uuid.UUID     `json:"subscription_id,omitempty" db:"subscription_id"`
+	Amount                float64        `json:"amount" db:"amount"`
+	Currency              string         `json:"currency" db:"currency"`
+	Provider              string         `json:"provider" db:"provider"`
+	ProviderTransactionID *string        `json:"provider_transaction_id,omitempty" db:"provider_transaction_id"`
+	Status                string         `json:"status" db:"status"`
+	ProviderResponse      map[string]any `json:"provider_response" db:"provider_response"`
+	CreatedAt             time.Time      `json:"created_at" db:"created_at"`
 
 	// Relations
 	User         *User         `json:"user,omitempty" db:"-"`
 	Subscription *Subscription `json:"subscription,omitempty" db:"-"`
 }
 
+// StoreNotification is a server notification received from a store webhook.
+type StoreNotification struct {
+	Provider         string         `json:"provider" db:"provider"`
+	NotificationID   string         `json:"notification_id" db:"notification_id"`
+	NotificationType string         `json:"notification_type" db:"notification_type"`
+	Payload          map[string]any `json:"payload" db:"payload"`
+	ReceivedAt       time.Time      `json:"received_at" db:"received_at"`
+}
+
+// SubscriptionEvent is published to the user's subscription channel whenever a
+// subscription changes status.
+type SubscriptionEvent struct {
+	Type           string     `json:"type"`
+	SubscriptionID uuid.UUID  `json:"subscription_id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	AppID          *uuid.UUID `json:"app_id,omitempty"`
+	ProductID      string     `json:"product_id"`
+	Status         string     `json:"status"`
+	PreviousStatus string     `json:"previous_status,omitempty"`
+	EndTime        time.Time  `json:"end_time"`
+	Entitled       bool       `json:"entitled"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
 type RegisterAppResponse struct {
 	Name        string `json:"name"`
 	PackageName string `json:"package_name"`
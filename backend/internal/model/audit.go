@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent records one mutating back-office request. Changes maps each
+// changed field to its "before" and "after" values.
+type AuditEvent struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	ActorID    *uuid.UUID     `json:"actor_id" db:"actor_id"`
+	ActorEmail *string        `json:"actor_email" db:"actor_email"`
+	ActorRole  *string        `json:"actor_role" db:"actor_role"`
+	Action     string         `json:"action" db:"action"`
+	Method     string         `json:"method" db:"method"`
+	Path       string         `json:"path" db:"path"`
+	TargetIDs  []string       `json:"target_ids" db:"target_ids"`
+	Changes    map[string]any `json:"changes" db:"changes"`
+	Payload    any            `json:"payload" db:"payload"`
+	StatusCode int            `json:"status_code" db:"status_code"`
+	IPAddress  *string        `json:"ip_address" db:"ip_address"`
+	UserAgent  *string        `json:"user_agent" db:"user_agent"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+type AuditEventQuery struct {
+	Search   string
+	Action   string
+	ActorID  *uuid.UUID
+	TargetID string
+	DateFrom time.Time
+	DateTo   time.Time
+	Page     int
+	Limit    int
+}
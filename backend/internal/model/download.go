@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -34,7 +35,11 @@ type DownloadTask struct {
 	Format        *string          `json:"format" db:"format"`
 	Status        string           `json:"status" db:"status"`
 	ErrorMessage  *string          `json:"error_message" db:"error_message"`
+	ErrorCode     *string          `json:"error_code" db:"error_code"`
 	IPAddress     *string          `json:"ip_address" db:"ip_address"`
+	BatchID       *uuid.UUID       `json:"batch_id,omitempty" db:"batch_id"`
+	Selection     *FormatSelection `json:"selection,omitempty" db:"format_selection"` // JSONB
+	Clip          *ClipRange       `json:"clip,omitempty" db:"clip"`                  // JSONB
 	CreatedAt     time.Time        `json:"created_at" db:"created_at"`
 	Formats       []DownloadFormat `json:"formats,omitempty" db:"-"`
 
@@ -42,6 +47,9 @@ type DownloadTask struct {
 	Application   *Application   `json:"application,omitempty" db:"-"`
 	Platform      *Platform      `json:"platform,omitempty" db:"-"`
 	DownloadFiles []DownloadFile `json:"download_files,omitempty" db:"-"`
+
+	// Quota is the caller's quota after this download was counted, sent as headers.
+	Quota *QuotaStatus `json:"-" db:"-"`
 }
 
 type DownloadFile struct {
@@ -53,11 +61,28 @@ type DownloadFile struct {
 	Extension     *string   `json:"extension,omitempty" db:"extension"`
 	FileSize      *int64    `json:"file_size,omitempty" db:"file_size"`
 	EncryptedData *[]byte   `json:"-" db:"encrypted_data"`
+	ObjectName    *string   `json:"-" db:"object_name"`
+	Encryption    *string   `json:"-" db:"encryption"` // scheme of an encrypted stored object
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 
 	DownloadTask *DownloadTask `json:"download_task,omitempty" db:"-"`
 }
 
+// StoredObject is a file kept in object storage that several downloads of the
+// same video and format share. It is removed once RefCount drops to zero.
+type StoredObject struct {
+	ObjectName string    `json:"object_name" db:"object_name"`
+	ContentKey string    `json:"content_key" db:"content_key"`
+	URL        string    `json:"url" db:"url"`
+	FormatID   *string   `json:"format_id,omitempty" db:"format_id"`
+	Resolution *string   `json:"resolution,omitempty" db:"resolution"`
+	Extension  *string   `json:"extension,omitempty" db:"extension"`
+	FileSize   *int64    `json:"file_size,omitempty" db:"file_size"`
+	RefCount   int       `json:"ref_count" db:"ref_count"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
 type Platform struct {
 	ID           uuid.UUID      `json:"id" db:"id"`
 	Name         string         `json:"name" db:"name"`
@@ -73,11 +98,127 @@ type Platform struct {
 }
 
 type DownloadRequest struct {
-	URL        string  `json:"url" validate:"required,url"`
-	Type       string  `json:"type" validate:"required,oneof=youtube facebook twitter tiktok instagram rumble vimeo dailymotion any-video-downloader linkedin pinterest snapchat twitch snackvideo youtube-to-mp3 facebook-to-mp3 instagram-to-mp3 twitter-to-mp3 tiktok-to-mp3 rumble-to-mp3 vimeo-to-mp3 dailymotion-to-mp3 linkedin-to-mp3 pinterest-to-mp3 snapchat-to-mp3 twitch-to-mp3 snackvideo-to-mp3"`
+	URL  string `json:"url" validate:"required,url"`
+	Type string `json:"type" validate:"required,oneof=youtube facebook twitter tiktok instagram rumble vimeo dailymotion any-video-downloader linkedin pinterest snapchat twitch snackvideo youtube-to-mp3 facebook-to-mp3 instagram-to-mp3 twitter-to-mp3 tiktok-to-mp3 rumble-to-mp3 vimeo-to-mp3 dailymotion-to-mp3 linkedin-to-mp3 pinterest-to-mp3 snapchat-to-mp3 twitch-to-mp3 snackvideo-to-mp3"`
+	// UserID is optional and must match the bearer token when sent; the token
+	// alone decides whose quota applies.
 	UserID     *string `json:"user_id,omitempty" validate:"omitempty"`
 	PlatformID *string `json:"platform_id,omitempty" validate:"omitempty"`
 	AppID      *string `json:"app_id,omitempty" validate:"omitempty"`
+	FormatID   *string `json:"format_id,omitempty" validate:"omitempty,max=100"`
+	MaxHeight  *int    `json:"max_height,omitempty" validate:"omitempty,min=144,max=4320"`
+	Container  *string `json:"container,omitempty" validate:"omitempty,oneof=mp4 webm mkv"`
+	Codec      *string `json:"codec,omitempty" validate:"omitempty,oneof=h264 hevc vp9 av1"`
+	// Start and End limit the download to a clip. Both take seconds ("90.5") or
+	// a timestamp ("1:30", "01:02:30").
+	Start *string `json:"start,omitempty" validate:"omitempty,max=16"`
+	End   *string `json:"end,omitempty" validate:"omitempty,max=16"`
+}
+
+// HasFormatSelection reports whether the client asked for a specific format instead
+// of letting the worker pick the resolutions to download.
+func (r *DownloadRequest) HasFormatSelection() bool {
+	return (r.FormatID != nil && *r.FormatID != "") || r.MaxHeight != nil ||
+		(r.Container != nil && *r.Container != "") || (r.Codec != nil && *r.Codec != "")
+}
+
+// FormatSelection is the validated format choice of a download request. Either
+// FormatID names an exact source format, or MaxHeight/Codec narrow the best one.
+type FormatSelection struct {
+	FormatID   string `json:"format_id,omitempty"`
+	MaxHeight  int    `json:"max_height,omitempty"`
+	Container  string `json:"container"`
+	Codec      string `json:"codec,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	MergeAudio bool   `json:"merge_audio,omitempty"`
+}
+
+// Name labels the produced file, e.g. "f137" or "720p-h264". Together with the
+// container it identifies stored copies of the same selection.
+func (s *FormatSelection) Name() string {
+	name := "best"
+	switch {
+	case s.FormatID != "":
+		name = "f" + s.FormatID
+	case s.MaxHeight > 0:
+		name = strconv.Itoa(s.MaxHeight) + "p"
+	}
+	if s.Codec != "" {
+		name += "-" + s.Codec
+	}
+	return name
+}
+
+// ClipRange is the part of a video a download is limited to, in seconds from
+// the start of the video.
+type ClipRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Length returns the length of the clip in seconds.
+func (c *ClipRange) Length() float64 {
+	return c.End - c.Start
+}
+
+// Name labels files cut to the clip, e.g. "clip-90-150.5", so stored copies of
+// different clips of one video never match.
+func (c *ClipRange) Name() string {
+	return "clip-" + formatSeconds(c.Start) + "-" + formatSeconds(c.End)
+}
+
+// Section returns the clip in yt-dlp's --download-sections syntax.
+func (c *ClipRange) Section() string {
+	return "*" + formatSeconds(c.Start) + "-" + formatSeconds(c.End)
+}
+
+func formatSeconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// DownloadBatch groups the child downloads created from a single playlist or channel URL.
+type DownloadBatch struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	AppID          *uuid.UUID `json:"app_id,omitempty" db:"app_id"`
+	PlatformID     *uuid.UUID `json:"platform_id,omitempty" db:"platform_id"`
+	PlatformType   string     `json:"platform_type" db:"platform_type"`
+	OriginalURL    string     `json:"original_url" db:"original_url"`
+	Title          *string    `json:"title" db:"title"`
+	Kind           string     `json:"kind" db:"kind"`
+	Format         string     `json:"format" db:"format"`
+	Status         string     `json:"status" db:"status"`
+	TotalItems     int        `json:"total_items" db:"total_items"`
+	CompletedItems int        `json:"completed_items" db:"completed_items"`
+	FailedItems    int        `json:"failed_items" db:"failed_items"`
+	IPAddress      *string    `json:"ip_address" db:"ip_address"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+
+	Tasks []*DownloadTask `json:"tasks,omitempty" db:"-"`
+	Quota *QuotaStatus    `json:"-" db:"-"`
+}
+
+// Progress returns the percentage of child downloads that reached a final state.
+func (b *DownloadBatch) Progress() int {
+	if b.TotalItems <= 0 {
+		return 0
+	}
+	done := b.CompletedItems + b.FailedItems
+	if done >= b.TotalItems {
+		return 100
+	}
+	return done * 100 / b.TotalItems
+}
+
+type BatchDownloadRequest struct {
+	URL      string `json:"url" validate:"required,url"`
+	Type     string `json:"type" validate:"required,oneof=youtube facebook twitter tiktok instagram rumble vimeo dailymotion any-video-downloader linkedin pinterest snapchat twitch snackvideo youtube-to-mp3 facebook-to-mp3 instagram-to-mp3 twitter-to-mp3 tiktok-to-mp3 rumble-to-mp3 vimeo-to-mp3 dailymotion-to-mp3 linkedin-to-mp3 pinterest-to-mp3 snapchat-to-mp3 twitch-to-mp3 snackvideo-to-mp3"`
+	Format   string `json:"format,omitempty" validate:"omitempty,oneof=mp4 mp3"`
+	MaxItems int    `json:"max_items,omitempty" validate:"omitempty,min=1,max=200"`
+	// UserID follows the same rule as in DownloadRequest.
+	UserID *string `json:"user_id,omitempty" validate:"omitempty"`
+	AppID  *string `json:"app_id,omitempty" validate:"omitempty"`
 }
 
 type DownloadPayload struct {
@@ -93,13 +234,19 @@ type DownloadPayload struct {
 }
 
 type DownloadEvent struct {
-	Type      string           `json:"type"`
-	TaskID    uuid.UUID        `json:"task_id"`
-	UserID    *uuid.UUID       `json:"user_id,omitempty"`
-	Status    string           `json:"status"`
-	Progress  *int             `json:"progress,omitempty"`
-	Message   string           `json:"message,omitempty"`
-	Error     string           `json:"error,omitempty"`
-	Payload   *DownloadPayload `json:"payload,omitempty"`
-	CreatedAt time.Time        `json:"created_at"`
+	Type            string           `json:"type"`
+	TaskID          uuid.UUID        `json:"task_id"`
+	BatchID         *uuid.UUID       `json:"batch_id,omitempty"`
+	UserID          *uuid.UUID       `json:"user_id,omitempty"`
+	Status          string           `json:"status"`
+	Progress        *int             `json:"progress,omitempty"`
+	Message         string           `json:"message,omitempty"`
+	Error           string           `json:"error,omitempty"`
+	ErrorCode       string           `json:"error_code,omitempty"`
+	DownloadedBytes *int64           `json:"downloaded_bytes,omitempty"`
+	TotalBytes      *int64           `json:"total_bytes,omitempty"`
+	Speed           *float64         `json:"speed,omitempty"` // bytes per second
+	ETA             *int             `json:"eta,omitempty"`   // seconds
+	Payload         *DownloadPayload `json:"payload,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
 }
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// PermissionAll grants every permission. The seeded admin role holds it.
+const PermissionAll = "all"
+
+// Permission is an entry of the permission catalog. Admin permissions open the
+// admin panel; the others only shape what a client account may do.
+type Permission struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	Admin       bool   `json:"admin"`
+}
+
+// Permissions lists every permission a role can be granted. Besides these keys
+// a role may hold "<resource>:*", which grants every permission of a resource.
+var Permissions = []Permission{
+	{Key: "dashboard:read", Description: "View the dashboard", Admin: true},
+	{Key: "analytics:read", Description: "View download analytics", Admin: true},
+	{Key: "settings:read", Description: "View settings", Admin: true},
+	{Key: "settings:write", Description: "Change settings and upload site files", Admin: true},
+	{Key: "users:read", Description: "Search and view users", Admin: true},
+	{Key: "users:delete", Description: "Delete users", Admin: true},
+	{Key: "roles:read", Description: "View roles", Admin: true},
+	{Key: "roles:write", Description: "Create, change and delete roles", Admin: true},
+	{Key: "roles:assign", Description: "Assign roles to users", Admin: true},
+	{Key: "platforms:read", Description: "View platforms", Admin: true},
+	{Key: "platforms:write", Description: "Create and change platforms", Admin: true},
+	{Key: "platforms:delete", Description: "Delete platforms", Admin: true},
+	{Key: "applications:read", Description: "View applications", Admin: true},
+	{Key: "applications:write", Description: "Register and change applications", Admin: true},
+	{Key: "applications:delete", Description: "Delete applications", Admin: true},
+	{Key: "downloads:read", Description: "View downloads", Admin: true},
+	{Key: "downloads:write", Description: "Change downloads", Admin: true},
+	{Key: "downloads:delete", Description: "Delete downloads", Admin: true},
+	{Key: "tasks:read", Description: "View task queues", Admin: true},
+	{Key: "tasks:write", Description: "Retry, cancel and archive tasks", Admin: true},
+	{Key: "subscriptions:read", Description: "View subscriptions", Admin: true},
+	{Key: "subscriptions:write", Description: "Change subscription status", Admin: true},
+	{Key: "subscriptions:delete", Description: "Delete subscriptions", Admin: true},
+	{Key: "health:read", Description: "View service health and logs", Admin: true},
+	{Key: "health:write", Description: "Reset strategy breakers and clear logs", Admin: true},
+	{Key: "cookies:read", Description: "View downloader cookies", Admin: true},
+	{Key: "cookies:write", Description: "Replace downloader cookies", Admin: true},
+	{Key: "audit:read", Description: "Search the audit log", Admin: true},
+	{Key: "download_basic", Description: "Download as a registered customer"},
+}
+
+func findPermission(key string) (Permission, bool) {
+	for _, p := range Permissions {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return Permission{}, false
+}
+
+// IsKnownPermission reports whether key can be granted to a role: a catalog
+// entry, PermissionAll or a wildcard over a resource of the catalog.
+func IsKnownPermission(key string) bool {
+	if key == PermissionAll {
+		return true
+	}
+	if resource, ok := strings.CutSuffix(key, ":*"); ok {
+		for _, p := range Permissions {
+			if strings.HasPrefix(p.Key, resource+":") {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := findPermission(key)
+	return ok
+}
+
+// HasPermission reports whether the granted set allows permission.
+func HasPermission(granted map[string]bool, permission string) bool {
+	if granted[PermissionAll] || granted[permission] {
+		return true
+	}
+	if resource, _, ok := strings.Cut(permission, ":"); ok && granted[resource+":*"] {
+		return true
+	}
+	return false
+}
+
+// HasAdminAccess reports whether the granted set holds any admin permission.
+func HasAdminAccess(granted map[string]bool) bool {
+	for key, ok := range granted {
+		if !ok {
+			continue
+		}
+		if key == PermissionAll || strings.HasSuffix(key, ":*") {
+			return true
+		}
+		if p, found := findPermission(key); found && p.Admin {
+			return true
+		}
+	}
+	return false
+}
+
+// Granted returns the keys the role grants, sorted.
+func (r *Role) Granted() []string {
+	if r == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(r.Permissions))
+	for key, ok := range r.Permissions {
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// QuotaLimits are the download limits of a caller. They come from the features of
+// the in-app product behind an active subscription, e.g.
+//
+//	{"daily_downloads": 100, "max_resolution": "1080p", "max_duration": 7200, "premium_platforms": true}
+//
+// or from the free plan defaults. Zero means unlimited.
+type QuotaLimits struct {
+	Plan             string `json:"plan"`
+	DailyDownloads   int    `json:"daily_downloads"`
+	MaxHeight        int    `json:"max_height"`
+	MaxDuration      int    `json:"max_duration"` // seconds
+	PremiumPlatforms bool   `json:"premium_platforms"`
+}
+
+// QuotaPlanFree is the plan of callers without an entitled subscription.
+const QuotaPlanFree = "free"
+
+// Premium reports whether the limits come from a paid plan.
+func (l QuotaLimits) Premium() bool {
+	return l.Plan != QuotaPlanFree
+}
+
+// QuotaStatus is the daily usage of a caller against its limits.
+type QuotaStatus struct {
+	QuotaLimits
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"` // -1 when the daily count is unlimited
+	ResetAt   time.Time `json:"reset_at"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Background task states as reported by the task queue. Archived tasks failed
+// their last attempt or were archived by an admin and stay until rerun or purged.
+const (
+	TaskStatePending   = "pending"
+	TaskStateActive    = "active"
+	TaskStateScheduled = "scheduled"
+	TaskStateRetry     = "retry"
+	TaskStateArchived  = "archived"
+	TaskStateCompleted = "completed"
+)
+
+// TaskQueue is a snapshot of one queue. Processed and Failed count today's tasks.
+type TaskQueue struct {
+	Queue     string `json:"queue"`
+	Size      int    `json:"size"`
+	Pending   int    `json:"pending"`
+	Active    int    `json:"active"`
+	Scheduled int    `json:"scheduled"`
+	Retry     int    `json:"retry"`
+	Archived  int    `json:"archived"`
+	Completed int    `json:"completed"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+	Paused    bool   `json:"paused"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// QueueTask is one task in a queue.
+type QueueTask struct {
+	ID             string          `json:"id"`
+	Queue          string          `json:"queue"`
+	Type           string          `json:"type"`
+	State          string          `json:"state"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	MaxRetry       int             `json:"max_retry"`
+	Retried        int             `json:"retried"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastFailedAt   *time.Time      `json:"last_failed_at,omitempty"`
+	NextProcessAt  *time.Time      `json:"next_process_at,omitempty"`
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+	TimeoutSeconds int64           `json:"timeout_seconds,omitempty"`
+}
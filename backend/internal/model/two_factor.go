@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SettingAdminTwoFactorRequired is the default-scope setting that makes every
+// admin panel user enroll a second factor before signing in.
+const SettingAdminTwoFactorRequired = "admin_two_factor_required"
+
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at"`
+	Required               bool       `json:"required"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorSetup is a secret waiting to be confirmed with a first code.
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// TwoFactorChallenge is handed out in place of tokens once the password is
+// verified. EnrollmentRequired tells the client to run the setup first, since
+// the account has no second factor yet but must have one.
+type TwoFactorChallenge struct {
+	ChallengeToken     string `json:"challenge_token"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	ExpiresIn          int    `json:"expires_in"`
+}
+
+// TwoFactorChallengeState is what the server keeps of a pending challenge.
+type TwoFactorChallengeState struct {
+	UserID             uuid.UUID `json:"user_id"`
+	EnrollmentRequired bool      `json:"enrollment_required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code"`
+}
+
+type TwoFactorRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorSecret is an enrolled secret as stored, encrypted with the key ring.
+type TwoFactorSecret struct {
+	UserID uuid.UUID
+	Secret []byte
+}
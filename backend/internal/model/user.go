@@ -15,9 +15,13 @@ type User struct {
 	RoleID       *uuid.UUID `json:"role_id" db:"role_id"`       // Changed to *uuid.UUID to match DB nullable
 	IsActive     bool       `json:"is_active" db:"is_active"`
 	LastLoginAt  *time.Time `json:"last_login_at" db:"last_login_at"`
-	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
-	DeletedAt    *time.Time `json:"deleted_at" db:"deleted_at"` // Soft delete
+	// TwoFactorEnabledAt is set while a TOTP second factor is enrolled.
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at" db:"two_factor_enabled_at"`
+	// EmailVerifiedAt is nil until the owner confirms the address.
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at" db:"deleted_at"` // Soft delete
 
 	// Relations
 	Role           *Role           `json:"role,omitempty" db:"-"`
@@ -64,8 +68,45 @@ type GoogleAuthRequest struct {
 }
 
 type AuthResponse struct {
-	AccessToken string `json:"access_token"`
-	User        User   `json:"user"`
+	AuthTokens
+	User User `json:"user"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// AuthTokens is the pair issued at sign-in and on every refresh. ExpiresIn is
+// the access token lifetime in seconds.
+type AuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// DeviceInfo identifies the client a session belongs to. DeviceID is chosen by
+// the client, sent as X-Device-Id, and is optional.
+type DeviceInfo struct {
+	DeviceID  string
+	UserAgent string
+	IPAddress string
+}
+
+// RefreshToken is one link of a rotation chain. FamilyID is shared by every
+// token of a session and doubles as the session id of its access tokens.
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	FamilyID   uuid.UUID  `json:"family_id" db:"family_id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	DeviceID   *string    `json:"device_id" db:"device_id"`
+	UserAgent  *string    `json:"user_agent" db:"user_agent"`
+	IPAddress  *string    `json:"ip_address" db:"ip_address"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+	ReplacedBy *uuid.UUID `json:"replaced_by" db:"replaced_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
 }
 
 type UpdateProfileRequest struct {
@@ -73,6 +114,31 @@ type UpdateProfileRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 }
 
+// EmailVerificationState is what the server keeps of the last verification
+// email sent to a user.
+type EmailVerificationState struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+// VerifyEmailRequest carries the token of the link in the verification email.
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// VerifyEmailCodeRequest carries the code of the verification email, typed in
+// by a signed-in user.
+type VerifyEmailCodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// DeleteAccountRequest confirms an account deletion. Accounts signed up with
+// Google have no password and send a freshly issued Google ID token instead.
+type DeleteAccountRequest struct {
+	Password   string `json:"password"`
+	Credential string `json:"credential"`
+}
+
 type UpdatePasswordRequest struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
 	NewPassword     string `json:"new_password" validate:"required,min=8"`
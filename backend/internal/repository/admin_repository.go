@@ -62,9 +62,10 @@ func (r *adminRepository) GetDashboardData(ctx context.Context, params model.Que
 	}
 
 	analyticsQuery := `
-		SELECT id, date, total_downloads, total_users, active_users, total_revenue, updated_at
+		SELECT id, date, total_downloads, total_users, new_users, active_users, total_revenue, revenue_by_currency, updated_at
 		FROM analytics_daily
 		WHERE date >= $1 AND date <= $2
+		  AND platform_type = '' AND app_id IS NULL
 		ORDER BY date ASC
 	`
 	rowsA, err := r.db.Query(subCtx, analyticsQuery, dateFrom, dateTo)
@@ -76,7 +77,7 @@ func (r *adminRepository) GetDashboardData(ctx context.Context, params model.Que
 	var analytics []model.AnalyticsDaily
 	for rowsA.Next() {
 		var a model.AnalyticsDaily
-		if err := rowsA.Scan(&a.ID, &a.Date, &a.TotalDownloads, &a.TotalUsers, &a.ActiveUsers, &a.TotalRevenue, &a.UpdatedAt); err != nil {
+		if err := rowsA.Scan(&a.ID, &a.Date, &a.TotalDownloads, &a.TotalUsers, &a.NewUsers, &a.ActiveUsers, &a.TotalRevenue, &a.RevenueByCurrency, &a.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan analytics: %w", err)
 		}
 		analytics = append(analytics, a)
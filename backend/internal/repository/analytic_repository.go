@@ -3,8 +3,11 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
 	"github.com/user/video-downloader-backend/internal/model"
@@ -13,6 +16,9 @@ import (
 type AnalyticRepository interface {
 	BaseRepository
 	Create(ctx context.Context, analytic *model.AnalyticsDaily) error
+	RefreshRevenue(ctx context.Context, day time.Time) error
+	AggregateDay(ctx context.Context, day time.Time) error
+	DownloadSeries(ctx context.Context, q model.AnalyticsQuery) ([]model.AnalyticsPoint, error)
 }
 
 type analyticRepository struct {
@@ -39,3 +45,235 @@ func (r *analyticRepository) Create(ctx context.Context, analytic *model.Analyti
 	}
 	return nil
 }
+
+// analyticsDimensionConflict is the ON CONFLICT target matching
+// idx_analytics_daily_dimensions.
+const analyticsDimensionConflict = `(date, platform_type, (COALESCE(app_id, '00000000-0000-0000-0000-000000000000'::uuid)))`
+
+// analyticsBaseCurrency is the only currency counted in total_revenue; amounts
+// in other currencies are kept apart in revenue_by_currency.
+const analyticsBaseCurrency = "USD"
+
+// revenueByCurrencyQuery sums the successful transactions between $2 and $3
+// per currency, for the day total and each application. A missing currency is
+// the column default, USD.
+const revenueByCurrencyQuery = `
+	SELECT app_id, currency, SUM(amount) AS revenue
+	FROM (
+		SELECT app_id, COALESCE(NULLIF(UPPER(currency), ''), 'USD') AS currency, amount
+		FROM transactions
+		WHERE status = 'success' AND created_at >= $2 AND created_at < $3
+	) t
+	GROUP BY GROUPING SETS ((currency), (app_id, currency))
+	HAVING NOT (GROUPING(app_id) = 0 AND app_id IS NULL)
+`
+
+// utcDay returns the bounds of the UTC day containing t.
+func utcDay(t time.Time) (time.Time, time.Time) {
+	y, m, d := t.UTC().Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// AggregateDay recomputes every analytics_daily row of the UTC day containing
+// day: the day total, one row per platform, per application and per platform
+// and application. Active users are the distinct users who downloaded that day;
+// new and total users only exist on the day total, and revenue has no platform.
+// total_revenue counts the base currency; revenue_by_currency has every currency.
+// Rows of dimensions that no longer have data are removed, so running it again
+// gives the same rows.
+func (r *analyticRepository) AggregateDay(ctx context.Context, day time.Time) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 60*time.Second)
+	defer cancel()
+
+	start, end := utcDay(day)
+	query := `
+		WITH downloads_by_dimension AS (
+			SELECT COALESCE(platform_type, '') AS platform_type, app_id,
+				COUNT(*) AS downloads, COUNT(DISTINCT user_id) AS active_users
+			FROM downloads
+			WHERE created_at >= $2 AND created_at < $3
+			GROUP BY GROUPING SETS ((), (platform_type), (app_id), (platform_type, app_id))
+			HAVING NOT (GROUPING(app_id) = 0 AND app_id IS NULL)
+		), revenue_by_currency AS (` + revenueByCurrencyQuery + `
+		), dimensions AS (
+			SELECT platform_type, app_id, downloads, active_users, NULL::text AS currency, 0 AS revenue FROM downloads_by_dimension
+			UNION ALL
+			SELECT '', app_id, 0, 0, currency, revenue FROM revenue_by_currency
+			UNION ALL
+			SELECT '', NULL, 0, 0, NULL, 0
+		)
+		INSERT INTO analytics_daily (date, platform_type, app_id, total_downloads, total_users, new_users, active_users, total_revenue, revenue_by_currency, updated_at)
+		SELECT $1::date, platform_type, app_id, SUM(downloads), 0, 0, SUM(active_users),
+			COALESCE(SUM(revenue) FILTER (WHERE currency = $5), 0),
+			COALESCE(jsonb_object_agg(currency, revenue) FILTER (WHERE currency IS NOT NULL), '{}'),
+			$4
+		FROM dimensions
+		GROUP BY platform_type, app_id
+		ON CONFLICT ` + analyticsDimensionConflict + ` DO UPDATE SET
+			total_downloads = EXCLUDED.total_downloads,
+			active_users = EXCLUDED.active_users,
+			total_revenue = EXCLUDED.total_revenue,
+			revenue_by_currency = EXCLUDED.revenue_by_currency,
+			updated_at = EXCLUDED.updated_at
+	`
+	usersQuery := `
+		UPDATE analytics_daily SET
+			new_users = (SELECT COUNT(*) FROM users WHERE created_at >= $2 AND created_at < $3),
+			total_users = (SELECT COUNT(*) FROM users WHERE created_at < $3 AND (deleted_at IS NULL OR deleted_at >= $3))
+		WHERE date = $1::date AND platform_type = '' AND app_id IS NULL
+	`
+	staleQuery := `DELETE FROM analytics_daily WHERE date = $1::date AND updated_at < $2`
+
+	date := start.Format("2006-01-02")
+	now := time.Now()
+	err := r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(subCtx, query, date, start, end, now, analyticsBaseCurrency); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(subCtx, usersQuery, date, start, end); err != nil {
+			return err
+		}
+		_, err := tx.Exec(subCtx, staleQuery, date, now)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to aggregate daily analytics: %w", err)
+	}
+	return nil
+}
+
+// RefreshRevenue recomputes total_revenue and revenue_by_currency of the UTC day
+// containing day from the successful transactions, for the day total and each
+// application, creating the rows when needed. Recomputing keeps the figure right when a charge is reported
+// twice or refunded later.
+func (r *analyticRepository) RefreshRevenue(ctx context.Context, day time.Time) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	start, end := utcDay(day)
+	resetQuery := `UPDATE analytics_daily SET total_revenue = 0, revenue_by_currency = '{}' WHERE date = $1::date`
+	query := `
+		WITH revenue_by_currency AS (` + revenueByCurrencyQuery + `)
+		INSERT INTO analytics_daily (date, platform_type, app_id, total_revenue, revenue_by_currency, updated_at)
+		SELECT $1::date, '', app_id,
+			COALESCE(SUM(revenue) FILTER (WHERE currency = $4), 0),
+			jsonb_object_agg(currency, revenue),
+			NOW()
+		FROM revenue_by_currency
+		GROUP BY app_id
+		ON CONFLICT ` + analyticsDimensionConflict + ` DO UPDATE SET
+			total_revenue = EXCLUDED.total_revenue,
+			revenue_by_currency = EXCLUDED.revenue_by_currency,
+			updated_at = EXCLUDED.updated_at
+	`
+	date := start.Format("2006-01-02")
+	err := r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(subCtx, resetQuery, date); err != nil {
+			return err
+		}
+		_, err := tx.Exec(subCtx, query, date, start, end, analyticsBaseCurrency)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to refresh daily revenue: %w", err)
+	}
+	return nil
+}
+
+// downloadErrorCategories classifies failed downloads by their error message,
+// first match wins.
+var downloadErrorCategories = []struct {
+	category string
+	pattern  string
+}{
+	{"private", `private|sign in|log ?in|login required|members.only|age.restricted`},
+	{"geo_blocked", `not available in your country|geo.?restrict|geo.?block`},
+	{"not_found", `unavailable|removed|not found|404|does not exist|deleted`},
+	{"rate_limited", `429|too many requests|rate.?limit`},
+	{"timeout", `timeout|timed out|deadline exceeded`},
+	{"unsupported", `unsupported url|not a playlist|no video formats|invalid .*url`},
+	{"extraction", `extract|no m3u8|parse|all formats failed`},
+	{"network", `connection|network|eof|tls|dns|proxy`},
+	{"storage", `minio|storage|upload|bucket`},
+}
+
+// errorCategoryExpr is the SQL expression giving the error category of a download
+// row d; downloads that did not fail have none. Rows failed before error codes
+// were recorded fall back to matching their message.
+func errorCategoryExpr() string {
+	var b strings.Builder
+	b.WriteString("CASE WHEN d.status <> 'failed' THEN 'none'")
+	b.WriteString(" WHEN d.error_code IS NOT NULL THEN d.error_code")
+	for _, c := range downloadErrorCategories {
+		fmt.Fprintf(&b, " WHEN d.error_message ~* '%s' THEN '%s'", c.pattern, c.category)
+	}
+	b.WriteString(" ELSE 'other' END")
+	return b.String()
+}
+
+// analyticsBuckets maps an interval to the date_trunc field, in UTC.
+var analyticsBuckets = map[string]string{
+	"hour":  "hour",
+	"day":   "day",
+	"week":  "week",
+	"month": "month",
+}
+
+// DownloadSeries counts downloads per time bucket and group. Grouping by country
+// returns one group per IP address, which the caller resolves to countries.
+func (r *analyticRepository) DownloadSeries(ctx context.Context, q model.AnalyticsQuery) ([]model.AnalyticsPoint, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 60*time.Second)
+	defer cancel()
+
+	// Without an interval every row falls in the bucket starting at From, which is
+	// the first argument below.
+	bucket := "$1::timestamptz"
+	if field, ok := analyticsBuckets[q.Interval]; ok {
+		bucket = fmt.Sprintf("date_trunc('%s', d.created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'", field)
+	}
+
+	var group string
+	switch q.GroupBy {
+	case model.AnalyticsGroupPlatform:
+		group = "d.platform_type"
+	case model.AnalyticsGroupApplication:
+		group = "COALESCE(a.name || COALESCE(' ' || a.version, ''), 'web')"
+	case model.AnalyticsGroupStatus:
+		group = "d.status"
+	case model.AnalyticsGroupErrorCategory:
+		group = errorCategoryExpr()
+	case model.AnalyticsGroupCountry:
+		group = "COALESCE(d.ip_address, '')"
+	default:
+		group = "'all'"
+	}
+
+	qb := NewQueryBuilder(fmt.Sprintf(`
+		SELECT %s AS bucket, %s AS grp,
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE d.status = 'completed') AS completed,
+			COUNT(*) FILTER (WHERE d.status = 'failed') AS failed
+		FROM downloads d
+		LEFT JOIN applications a ON a.id = d.app_id`, bucket, group))
+	qb.Where("d.created_at >= $?", q.From)
+	qb.Where("d.created_at < $?", q.To)
+	if q.PlatformType != "" {
+		qb.Where("d.platform_type = $?", q.PlatformType)
+	}
+	if q.AppID != nil {
+		qb.Where("d.app_id = $?", *q.AppID)
+	}
+	if q.Status != "" {
+		qb.Where("d.status = $?", q.Status)
+	}
+
+	query, args := qb.Build()
+	query += " GROUP BY 1, 2 ORDER BY 1, 3 DESC"
+
+	var out []model.AnalyticsPoint
+	if err := pgxscan.Select(subCtx, r.db, &out, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to query download series: %w", err)
+	}
+	return out, nil
+}
@@ -24,6 +24,7 @@ type ApplicationRepository interface {
 	BulkDelete(ctx context.Context, ids []uuid.UUID) error
 	FindAll(ctx context.Context, params model.QueryParamsRequest) ([]model.Application, model.Pagination, error)
 	GetAll(ctx context.Context) ([]*model.Application, error)
+	FindProduct(ctx context.Context, appID uuid.UUID, productID string) (*model.InAppProduct, error)
 }
 
 type applicationRepository struct {
@@ -283,3 +284,20 @@ func (r *applicationRepository) GetAll(ctx context.Context) ([]*model.Applicatio
 
 	return apps, nil
 }
+
+// FindProduct looks up an in-app product by the store product ID of an application.
+func (r *applicationRepository) FindProduct(ctx context.Context, appID uuid.UUID, productID string) (*model.InAppProduct, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT * FROM in_app_products WHERE app_id = $1 AND (product_id = $2 OR sku_code = $2) ORDER BY product_id = $2 DESC LIMIT 1`
+
+	var product model.InAppProduct
+	if err := pgxscan.Get(subCtx, r.db, &product, query, appID, productID); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find in-app product: %w", err)
+	}
+	return &product, nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+type AuditRepository interface {
+	BaseRepository
+	Create(ctx context.Context, event *model.AuditEvent) error
+	Search(ctx context.Context, query model.AuditEventQuery) ([]model.AuditEvent, model.Pagination, error)
+}
+
+type auditRepository struct {
+	*baseRepository
+}
+
+func NewAuditRepository(db *pgxpool.Pool) AuditRepository {
+	return &auditRepository{
+		baseRepository: NewBaseRepository(db).(*baseRepository),
+	}
+}
+
+func (r *auditRepository) Create(ctx context.Context, event *model.AuditEvent) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	if event.TargetIDs == nil {
+		event.TargetIDs = []string{}
+	}
+
+	query := `
+		INSERT INTO audit_events (actor_id, actor_email, actor_role, action, method, path, target_ids, changes, payload, status_code, ip_address, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
+	now := time.Now()
+	err := r.db.QueryRow(subCtx, query,
+		event.ActorID,
+		event.ActorEmail,
+		event.ActorRole,
+		event.Action,
+		event.Method,
+		event.Path,
+		event.TargetIDs,
+		event.Changes,
+		event.Payload,
+		event.StatusCode,
+		event.IPAddress,
+		event.UserAgent,
+		now,
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+
+	event.CreatedAt = now
+	return nil
+}
+
+func (r *auditRepository) Search(ctx context.Context, q model.AuditEventQuery) ([]model.AuditEvent, model.Pagination, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	qb := NewQueryBuilder(`SELECT * FROM audit_events`)
+
+	if q.Search != "" {
+		qb.Where("(action ILIKE $? OR actor_email ILIKE $? OR path ILIKE $?)",
+			"%"+q.Search+"%",
+			"%"+q.Search+"%",
+			"%"+q.Search+"%",
+		)
+	}
+	if q.Action != "" {
+		// "platform" matches every platform.* action.
+		qb.Where("(action = $? OR action LIKE $?)", q.Action, q.Action+".%")
+	}
+	if q.ActorID != nil {
+		qb.Where("actor_id = $?", *q.ActorID)
+	}
+	if q.TargetID != "" {
+		qb.Where("$? = ANY(target_ids)", q.TargetID)
+	}
+	if !q.DateFrom.IsZero() {
+		qb.Where("created_at >= $?", q.DateFrom)
+	}
+	if !q.DateTo.IsZero() {
+		qb.Where("created_at <= $?", q.DateTo)
+	}
+
+	countQuery, countArgs := qb.Clone().ChangeBase("SELECT COUNT(*) FROM audit_events").WithoutPagination().Build()
+
+	var totalItems int64
+	if err := pgxscan.Get(subCtx, r.db, &totalItems, countQuery, countArgs...); err != nil {
+		return nil, model.Pagination{}, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	qb.OrderByField("created_at", "DESC")
+	qb.WithLimit(q.Limit).WithOffset((q.Page - 1) * q.Limit)
+
+	query, args := qb.Build()
+	var events []model.AuditEvent
+	if err := pgxscan.Select(subCtx, r.db, &events, query, args...); err != nil {
+		return nil, model.Pagination{}, fmt.Errorf("failed to search audit events: %w", err)
+	}
+
+	totalPages := 0
+	if q.Limit > 0 {
+		totalPages = int((totalItems + int64(q.Limit) - 1) / int64(q.Limit))
+	}
+
+	pagination := model.Pagination{
+		CurrentPage: q.Page,
+		Limit:       q.Limit,
+		TotalItems:  totalItems,
+		TotalPages:  totalPages,
+		HasNext:     q.Page < totalPages,
+		HasPrev:     q.Page > 1,
+	}
+	return events, pagination, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+type DownloadBatchRepository interface {
+	BaseRepository
+	Create(ctx context.Context, batch *model.DownloadBatch) error
+	FindByID(ctx context.Context, id uuid.UUID) (*model.DownloadBatch, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, status string, totalItems int) error
+	RefreshProgress(ctx context.Context, id uuid.UUID) (*model.DownloadBatch, error)
+}
+
+type downloadBatchRepository struct {
+	*baseRepository
+}
+
+func NewDownloadBatchRepository(db *pgxpool.Pool) DownloadBatchRepository {
+	return &downloadBatchRepository{
+		baseRepository: NewBaseRepository(db).(*baseRepository),
+	}
+}
+
+func (r *downloadBatchRepository) Create(ctx context.Context, batch *model.DownloadBatch) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO download_batches (user_id, app_id, platform_id, platform_type, original_url, title, kind, format, status, total_items, ip_address, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
+	now := time.Now()
+	err := r.db.QueryRow(subCtx, query,
+		batch.UserID,
+		batch.AppID,
+		batch.PlatformID,
+		batch.PlatformType,
+		batch.OriginalURL,
+		batch.Title,
+		batch.Kind,
+		batch.Format,
+		batch.Status,
+		batch.TotalItems,
+		batch.IPAddress,
+		now,
+		now,
+	).Scan(&batch.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create download batch: %w", err)
+	}
+
+	batch.CreatedAt = now
+	batch.UpdatedAt = now
+	return nil
+}
+
+func (r *downloadBatchRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.DownloadBatch, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT * FROM download_batches WHERE id = $1`
+
+	var batch model.DownloadBatch
+	if err := pgxscan.Get(subCtx, r.db, &batch, query, id); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find download batch: %w", err)
+	}
+
+	return &batch, nil
+}
+
+func (r *downloadBatchRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string, totalItems int) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `UPDATE download_batches SET status = $1, total_items = $2, updated_at = $3 WHERE id = $4`
+	ct, err := r.db.Exec(subCtx, query, status, totalItems, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update download batch: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// RefreshProgress recounts the child downloads of a batch and derives the batch
+// status from them, so concurrent workers never race on increment counters.
+func (r *downloadBatchRepository) RefreshProgress(ctx context.Context, id uuid.UUID) (*model.DownloadBatch, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		WITH counts AS (
+			SELECT
+				COUNT(*) FILTER (WHERE status = 'completed') AS completed,
+				COUNT(*) FILTER (WHERE status IN ('failed', 'canceled')) AS failed
+			FROM downloads
+			WHERE batch_id = $1
+		)
+		UPDATE download_batches b
+		SET completed_items = counts.completed,
+			failed_items = counts.failed,
+			status = CASE
+				WHEN b.total_items > 0 AND counts.completed + counts.failed >= b.total_items THEN
+					CASE WHEN counts.completed = 0 THEN 'failed' ELSE 'completed' END
+				WHEN counts.completed + counts.failed > 0 THEN 'processing'
+				ELSE b.status
+			END,
+			updated_at = $2
+		FROM counts
+		WHERE b.id = $1
+		RETURNING b.*
+	`
+
+	var batch model.DownloadBatch
+	if err := pgxscan.Get(subCtx, r.db, &batch, query, id, time.Now()); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to refresh download batch progress: %w", err)
+	}
+
+	return &batch, nil
+}
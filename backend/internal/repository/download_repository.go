@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -14,6 +15,9 @@ import (
 	"github.com/user/video-downloader-backend/internal/model"
 )
 
+// ErrDownloadNotFound is returned by FindByID for an unknown download.
+var ErrDownloadNotFound = errors.New("download task not found")
+
 type DownloadRepository interface {
 	BaseRepository
 	Create(ctx context.Context, task *model.DownloadTask) error
@@ -25,6 +29,19 @@ type DownloadRepository interface {
 	BulkDelete(ctx context.Context, ids []uuid.UUID) error
 	AddFile(ctx context.Context, file *model.DownloadFile) error
 	FindOldAndCompleted(ctx context.Context, cutoff time.Time, limit int) ([]*model.DownloadTask, error)
+	FindByBatchID(ctx context.Context, batchID uuid.UUID) ([]*model.DownloadTask, error)
+	AcquireStoredObject(ctx context.Context, contentKey string, file *model.DownloadFile) (bool, error)
+	RegisterStoredObject(ctx context.Context, object *model.StoredObject) error
+	FindUnreferencedObjects(ctx context.Context, limit int) ([]*model.StoredObject, error)
+	DeleteStoredObject(ctx context.Context, objectName string) (bool, error)
+	CountStoredObjectsByPrefix(ctx context.Context, prefix string) (int, error)
+	FindEncryptedTasks(ctx context.Context, after uuid.UUID, limit int) ([]*model.DownloadTask, error)
+	FindEncryptedFiles(ctx context.Context, after uuid.UUID, limit int) ([]*model.DownloadFile, error)
+	UpdateTaskEncryptedData(ctx context.Context, updates map[uuid.UUID][]byte) error
+	UpdateFileEncryptedData(ctx context.Context, updates map[uuid.UUID][]byte) error
+	ReplaceFileObject(ctx context.Context, id uuid.UUID, oldObjectName, newObjectName string) (bool, error)
+	MarkCanceled(ctx context.Context, id uuid.UUID, message string) (bool, error)
+	ReleaseFiles(ctx context.Context, downloadID uuid.UUID) error
 }
 
 type downloadRepository struct {
@@ -42,8 +59,8 @@ func (r *downloadRepository) Create(ctx context.Context, task *model.DownloadTas
 	defer cancel()
 
 	query := `
-		INSERT INTO downloads (user_id, app_id, original_url, platform_id, platform_type, status, file_path, format, thumbnail_url, title, file_size, duration, encrypted_data, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		INSERT INTO downloads (user_id, app_id, original_url, platform_id, platform_type, status, file_path, format, thumbnail_url, title, file_size, duration, encrypted_data, batch_id, format_selection, clip, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 		RETURNING id
 	`
 	now := time.Now()
@@ -61,6 +78,9 @@ func (r *downloadRepository) Create(ctx context.Context, task *model.DownloadTas
 		task.FileSize,
 		task.Duration,
 		task.EncryptedData,
+		task.BatchID,
+		task.Selection,
+		task.Clip,
 		now,
 	).Scan(&task.ID)
 
@@ -122,7 +142,7 @@ func (r *downloadRepository) FindByID(ctx context.Context, id uuid.UUID) (*model
 	query := `
         SELECT 
             d.id, d.user_id, d.app_id, d.platform_id, d.original_url, d.platform_type, d.file_path, d.thumbnail_url, 
-            d.title, d.duration, d.file_size, d.encrypted_data, d.format, d.status, d.error_message, d.ip_address, d.created_at,
+            d.title, d.duration, d.file_size, d.encrypted_data, d.format, d.status, d.error_message, d.error_code, d.ip_address, d.batch_id, d.format_selection, d.clip, d.created_at,
             u.email as user_email,
             p.name as platform_name, p.slug as platform_slug, p.thumbnail_url as platform_thumbnail_url, 
             p.type as platform_type, p.is_active as platform_is_active, p.is_premium as platform_is_premium
@@ -140,14 +160,14 @@ func (r *downloadRepository) FindByID(ctx context.Context, id uuid.UUID) (*model
 	err := r.db.QueryRow(subCtx, query, id).Scan(
 		&task.ID, &task.UserID, &task.AppID, &task.PlatformID, &task.OriginalURL, &task.PlatformType,
 		&task.FilePath, &task.ThumbnailURL, &task.Title, &task.Duration, &task.FileSize, &task.EncryptedData, &task.Format,
-		&task.Status, &task.ErrorMessage, &task.IPAddress, &task.CreatedAt,
+		&task.Status, &task.ErrorMessage, &task.ErrorCode, &task.IPAddress, &task.BatchID, &task.Selection, &task.Clip, &task.CreatedAt,
 		&userEmail,
 		&platformName, &platformSlug, &platformThumbnailURL, &platformType, &platformIsActive, &platformIsPremium,
 	)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("download task not found")
+			return nil, ErrDownloadNotFound
 		}
 		return nil, err
 	}
@@ -172,7 +192,7 @@ func (r *downloadRepository) FindByID(ctx context.Context, id uuid.UUID) (*model
 	}
 
 	filesQuery := `
-        SELECT id, download_id, url, format_id, resolution, extension, file_size, encrypted_data, created_at
+        SELECT id, download_id, url, format_id, resolution, extension, file_size, encrypted_data, object_name, encryption, created_at
         FROM download_files
         WHERE download_id = $1
         ORDER BY created_at ASC
@@ -189,7 +209,7 @@ func (r *downloadRepository) FindByID(ctx context.Context, id uuid.UUID) (*model
 		var file model.DownloadFile
 		err = filesRows.Scan(
 			&file.ID, &file.DownloadID, &file.URL, &file.FormatID, &file.Resolution,
-			&file.Extension, &file.FileSize, &file.EncryptedData, &file.CreatedAt,
+			&file.Extension, &file.FileSize, &file.EncryptedData, &file.ObjectName, &file.Encryption, &file.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -250,7 +270,7 @@ func (r *downloadRepository) FindAll(ctx context.Context, params model.QueryPara
 			d.id, d.user_id, d.app_id, d.platform_id, d.original_url, 
 			d.file_path, d.thumbnail_url,  -- Kolom 6 & 7
 			d.title, d.duration, d.file_size, d.encrypted_data, d.format, 
-			d.status, d.error_message, d.ip_address, d.created_at,  -- Kolom 13-16
+			d.status, d.error_message, d.error_code, d.ip_address, d.created_at,  -- Kolom 13-17
 			u.email as user_email,
 			p.name as platform_name, p.slug as platform_slug, 
 			p.thumbnail_url as platform_thumbnail_url, p.type as platform_type, 
@@ -357,7 +377,7 @@ func (r *downloadRepository) FindAll(ctx context.Context, params model.QueryPara
 			&task.ID, &task.UserID, &task.AppID, &task.PlatformID, &task.OriginalURL,
 			&task.FilePath, &task.ThumbnailURL,
 			&task.Title, &task.Duration, &task.FileSize, &task.EncryptedData, &task.Format,
-			&task.Status, &task.ErrorMessage, &task.IPAddress, &task.CreatedAt,
+			&task.Status, &task.ErrorMessage, &task.ErrorCode, &task.IPAddress, &task.CreatedAt,
 			&userEmail,
 			&platformName, &platformSlug, &platformThumbnailURL, &platformType, &platformIsActive, &platformIsPremium,
 			&fileID, &downloadID, &url, &formatID, &resolution, &extension, &fileSize, &encryptedData, &fileCreatedAt,
@@ -433,12 +453,13 @@ func (r *downloadRepository) Update(ctx context.Context, task *model.DownloadTas
 	query := `
 		UPDATE downloads 
 		SET status = $1, file_path = $2, format = $3, thumbnail_url = $4, 
-			title = $5, file_size = $6, duration = $7, encrypted_data = $8, error_message = $9
-		WHERE id = $10
+			title = $5, file_size = $6, duration = $7, encrypted_data = $8, error_message = $9,
+			error_code = $10
+		WHERE id = $11
 	`
 	ct, err := r.db.Exec(subCtx, query,
 		task.Status, task.FilePath, task.Format, task.ThumbnailURL,
-		task.Title, task.FileSize, task.Duration, task.EncryptedData, task.ErrorMessage, task.ID)
+		task.Title, task.FileSize, task.Duration, task.EncryptedData, task.ErrorMessage, task.ErrorCode, task.ID)
 	if err != nil {
 		return err
 	}
@@ -449,21 +470,82 @@ func (r *downloadRepository) Update(ctx context.Context, task *model.DownloadTas
 }
 
 func (r *downloadRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.BulkDelete(ctx, []uuid.UUID{id})
+}
+
+// BulkDelete removes the downloads and releases the stored objects their files
+// reference. Objects left without references are purged by the cleanup cron.
+func (r *downloadRepository) BulkDelete(ctx context.Context, ids []uuid.UUID) error {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM downloads WHERE id = $1`
-	_, err := r.db.Exec(subCtx, query, id)
-	return err
+	return r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		releaseQuery := `
+			UPDATE stored_objects s
+			SET ref_count = GREATEST(s.ref_count - refs.n, 0)
+			FROM (
+				SELECT object_name, COUNT(*) AS n
+				FROM download_files
+				WHERE download_id = ANY($1) AND object_name IS NOT NULL
+				GROUP BY object_name
+			) refs
+			WHERE s.object_name = refs.object_name
+		`
+		if _, err := tx.Exec(subCtx, releaseQuery, ids); err != nil {
+			return fmt.Errorf("failed to release stored objects: %w", err)
+		}
+
+		if _, err := tx.Exec(subCtx, `DELETE FROM downloads WHERE id = ANY($1)`, ids); err != nil {
+			return err
+		}
+		return nil
+	})
 }
 
-func (r *downloadRepository) BulkDelete(ctx context.Context, ids []uuid.UUID) error {
+// MarkCanceled cancels a download that has not finished yet and reports whether
+// it did; completed and failed downloads keep their status.
+func (r *downloadRepository) MarkCanceled(ctx context.Context, id uuid.UUID, message string) (bool, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM downloads WHERE id = ANY($1)`
-	_, err := r.db.Exec(subCtx, query, ids)
-	return err
+	query := `
+		UPDATE downloads
+		SET status = 'canceled', error_code = 'canceled', error_message = $2
+		WHERE id = $1 AND status IN ('pending', 'queued', 'processing')
+	`
+	ct, err := r.db.Exec(subCtx, query, id, message)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel download: %w", err)
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+// ReleaseFiles drops the file records of a download and releases the stored
+// objects they reference, leaving the download itself in place.
+func (r *downloadRepository) ReleaseFiles(ctx context.Context, downloadID uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	return r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		releaseQuery := `
+			UPDATE stored_objects s
+			SET ref_count = GREATEST(s.ref_count - refs.n, 0)
+			FROM (
+				SELECT object_name, COUNT(*) AS n
+				FROM download_files
+				WHERE download_id = $1 AND object_name IS NOT NULL
+				GROUP BY object_name
+			) refs
+			WHERE s.object_name = refs.object_name
+		`
+		if _, err := tx.Exec(subCtx, releaseQuery, downloadID); err != nil {
+			return fmt.Errorf("failed to release stored objects: %w", err)
+		}
+		if _, err := tx.Exec(subCtx, `DELETE FROM download_files WHERE download_id = $1`, downloadID); err != nil {
+			return fmt.Errorf("failed to delete download files: %w", err)
+		}
+		return nil
+	})
 }
 
 func (r *downloadRepository) AddFile(ctx context.Context, file *model.DownloadFile) error {
@@ -472,8 +554,8 @@ func (r *downloadRepository) AddFile(ctx context.Context, file *model.DownloadFi
 
 	query := `
 		WITH inserted AS (
-			INSERT INTO download_files (download_id, url, format_id, resolution, extension, file_size, encrypted_data, created_at)
-			SELECT $1, $2, $3, $4, $5, $6, $7, $8
+			INSERT INTO download_files (download_id, url, format_id, resolution, extension, file_size, encrypted_data, object_name, encryption, created_at)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 			WHERE EXISTS (SELECT 1 FROM downloads WHERE id = $1)
 			RETURNING id
 		)
@@ -488,6 +570,8 @@ func (r *downloadRepository) AddFile(ctx context.Context, file *model.DownloadFi
 		file.Extension,
 		file.FileSize,
 		file.EncryptedData,
+		file.ObjectName,
+		file.Encryption,
 		now,
 	).Scan(&file.ID)
 	if err != nil {
@@ -505,7 +589,7 @@ func (r *downloadRepository) FindOldAndCompleted(ctx context.Context, cutoff tim
 		SELECT id, platform_type, status, created_at
 		FROM downloads
 		WHERE created_at < $1
-		  AND status IN ('completed', 'failed')
+		  AND status IN ('completed', 'failed', 'canceled')
 		LIMIT $2
 	`
 
@@ -517,3 +601,252 @@ func (r *downloadRepository) FindOldAndCompleted(ctx context.Context, cutoff tim
 
 	return tasks, nil
 }
+
+func (r *downloadRepository) FindByBatchID(ctx context.Context, batchID uuid.UUID) ([]*model.DownloadTask, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, app_id, platform_id, platform_type, original_url, file_path, thumbnail_url,
+			title, duration, file_size, format, status, error_message, error_code, batch_id, created_at
+		FROM downloads
+		WHERE batch_id = $1
+		ORDER BY created_at ASC
+	`
+
+	var tasks []*model.DownloadTask
+	if err := pgxscan.Select(subCtx, r.db, &tasks, query, batchID); err != nil {
+		return nil, fmt.Errorf("failed to find downloads by batch: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// AcquireStoredObject looks for a live stored object with the given content key and,
+// when one exists, takes a reference on it and records it as a file of file.DownloadID.
+// The object row is locked so a concurrent cleanup cannot purge it in between.
+func (r *downloadRepository) AcquireStoredObject(ctx context.Context, contentKey string, file *model.DownloadFile) (bool, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	acquired := false
+	err := r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		selectQuery := `
+			SELECT object_name, url, format_id, resolution, extension, file_size
+			FROM stored_objects
+			WHERE content_key = $1 AND ref_count > 0
+			ORDER BY created_at DESC
+			LIMIT 1
+			FOR UPDATE
+		`
+		var object model.StoredObject
+		err := tx.QueryRow(subCtx, selectQuery, contentKey).Scan(
+			&object.ObjectName, &object.URL, &object.FormatID, &object.Resolution, &object.Extension, &object.FileSize,
+		)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return nil
+			}
+			return fmt.Errorf("failed to find stored object: %w", err)
+		}
+
+		now := time.Now()
+		insertQuery := `
+			INSERT INTO download_files (download_id, url, format_id, resolution, extension, file_size, object_name, created_at)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8
+			WHERE EXISTS (SELECT 1 FROM downloads WHERE id = $1)
+			RETURNING id
+		`
+		if err := tx.QueryRow(subCtx, insertQuery,
+			file.DownloadID, object.URL, object.FormatID, object.Resolution, object.Extension, object.FileSize, object.ObjectName, now,
+		).Scan(&file.ID); err != nil {
+			if err == pgx.ErrNoRows {
+				return nil
+			}
+			return fmt.Errorf("failed to add reused download file: %w", err)
+		}
+
+		updateQuery := `UPDATE stored_objects SET ref_count = ref_count + 1, last_used_at = $1 WHERE object_name = $2`
+		if _, err := tx.Exec(subCtx, updateQuery, now, object.ObjectName); err != nil {
+			return fmt.Errorf("failed to reference stored object: %w", err)
+		}
+
+		file.URL = object.URL
+		file.FormatID = object.FormatID
+		file.Resolution = object.Resolution
+		file.Extension = object.Extension
+		file.FileSize = object.FileSize
+		file.ObjectName = &object.ObjectName
+		file.CreatedAt = now
+		acquired = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return acquired, nil
+}
+
+// RegisterStoredObject records a freshly uploaded object holding one reference for
+// the download file that points at it.
+func (r *downloadRepository) RegisterStoredObject(ctx context.Context, object *model.StoredObject) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO stored_objects (object_name, content_key, url, format_id, resolution, extension, file_size, ref_count, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 1, $8, $8)
+		ON CONFLICT (object_name) DO UPDATE
+		SET content_key = EXCLUDED.content_key,
+			url = EXCLUDED.url,
+			file_size = EXCLUDED.file_size,
+			ref_count = stored_objects.ref_count + 1,
+			last_used_at = EXCLUDED.last_used_at
+	`
+	now := time.Now()
+	_, err := r.db.Exec(subCtx, query,
+		object.ObjectName,
+		object.ContentKey,
+		object.URL,
+		object.FormatID,
+		object.Resolution,
+		object.Extension,
+		object.FileSize,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register stored object: %w", err)
+	}
+	return nil
+}
+
+func (r *downloadRepository) FindUnreferencedObjects(ctx context.Context, limit int) ([]*model.StoredObject, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT * FROM stored_objects WHERE ref_count = 0 ORDER BY last_used_at ASC LIMIT $1`
+
+	var objects []*model.StoredObject
+	if err := pgxscan.Select(subCtx, r.db, &objects, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to find unreferenced objects: %w", err)
+	}
+	return objects, nil
+}
+
+// DeleteStoredObject drops the object record only while it is still unreferenced and
+// reports whether it did, so the caller knows it may remove the object from storage.
+func (r *downloadRepository) DeleteStoredObject(ctx context.Context, objectName string) (bool, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	ct, err := r.db.Exec(subCtx, `DELETE FROM stored_objects WHERE object_name = $1 AND ref_count = 0`, objectName)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete stored object: %w", err)
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+func (r *downloadRepository) CountStoredObjectsByPrefix(ctx context.Context, prefix string) (int, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM stored_objects WHERE starts_with(object_name, $1)`
+	if err := r.db.QueryRow(subCtx, query, prefix).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count stored objects: %w", err)
+	}
+	return count, nil
+}
+
+// FindEncryptedTasks pages through downloads holding an encrypted blob, ordered by ID
+// so a batch job can resume after the last ID it processed.
+func (r *downloadRepository) FindEncryptedTasks(ctx context.Context, after uuid.UUID, limit int) ([]*model.DownloadTask, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, encrypted_data
+		FROM downloads
+		WHERE encrypted_data IS NOT NULL AND id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(subCtx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find encrypted downloads: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []*model.DownloadTask
+	for rows.Next() {
+		var task model.DownloadTask
+		if err := rows.Scan(&task.ID, &task.EncryptedData); err != nil {
+			return nil, fmt.Errorf("failed to scan encrypted download: %w", err)
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks, rows.Err()
+}
+
+// FindEncryptedFiles pages through download files that are encrypted, either as a
+// blob in the row or as an object in storage.
+func (r *downloadRepository) FindEncryptedFiles(ctx context.Context, after uuid.UUID, limit int) ([]*model.DownloadFile, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, download_id, url, extension, encrypted_data, object_name, encryption, created_at
+		FROM download_files
+		WHERE (encrypted_data IS NOT NULL OR encryption IS NOT NULL) AND id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	`
+	var files []*model.DownloadFile
+	if err := pgxscan.Select(subCtx, r.db, &files, query, after, limit); err != nil {
+		return nil, fmt.Errorf("failed to find encrypted files: %w", err)
+	}
+	return files, nil
+}
+
+func (r *downloadRepository) UpdateTaskEncryptedData(ctx context.Context, updates map[uuid.UUID][]byte) error {
+	return r.updateEncryptedData(ctx, "downloads", updates)
+}
+
+func (r *downloadRepository) UpdateFileEncryptedData(ctx context.Context, updates map[uuid.UUID][]byte) error {
+	return r.updateEncryptedData(ctx, "download_files", updates)
+}
+
+func (r *downloadRepository) updateEncryptedData(ctx context.Context, table string, updates map[uuid.UUID][]byte) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %s SET encrypted_data = $1 WHERE id = $2 AND encrypted_data IS NOT NULL`, table)
+	return r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		for id, data := range updates {
+			batch.Queue(query, data, id)
+		}
+		if err := tx.SendBatch(subCtx, batch).Close(); err != nil {
+			return fmt.Errorf("failed to update encrypted data in %s: %w", table, err)
+		}
+		return nil
+	})
+}
+
+// ReplaceFileObject points a file at a re-written object. It only succeeds while the
+// file still references oldObjectName.
+func (r *downloadRepository) ReplaceFileObject(ctx context.Context, id uuid.UUID, oldObjectName, newObjectName string) (bool, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	ct, err := r.db.Exec(subCtx, `UPDATE download_files SET object_name = $1 WHERE id = $2 AND object_name = $3`, newObjectName, id, oldObjectName)
+	if err != nil {
+		return false, fmt.Errorf("failed to replace file object: %w", err)
+	}
+	return ct.RowsAffected() > 0, nil
+}
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_downloads_batch_id;
+
+ALTER TABLE downloads
+DROP COLUMN IF EXISTS batch_id;
+
+DROP INDEX IF EXISTS idx_download_batches_user_id;
+
+DROP TABLE IF EXISTS download_batches;
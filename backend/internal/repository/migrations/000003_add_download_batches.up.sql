@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS download_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    app_id UUID REFERENCES applications(id) ON DELETE SET NULL,
+    platform_id UUID REFERENCES platforms(id) ON DELETE SET NULL,
+    platform_type TEXT NOT NULL,
+    original_url TEXT NOT NULL,
+    title TEXT,
+    kind VARCHAR(20) NOT NULL DEFAULT 'playlist', -- 'playlist', 'channel'
+    format VARCHAR(20) NOT NULL DEFAULT 'mp4', -- mp4, mp3
+    status VARCHAR(20) NOT NULL, -- 'queued', 'processing', 'completed', 'failed'
+    total_items INT NOT NULL DEFAULT 0,
+    completed_items INT NOT NULL DEFAULT 0,
+    failed_items INT NOT NULL DEFAULT 0,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_download_batches_user_id
+ON download_batches (user_id);
+
+ALTER TABLE downloads
+ADD COLUMN IF NOT EXISTS batch_id UUID REFERENCES download_batches(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_downloads_batch_id
+ON downloads (batch_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_download_files_object_name;
+
+ALTER TABLE download_files
+DROP COLUMN IF EXISTS object_name;
+
+DROP INDEX IF EXISTS idx_stored_objects_unreferenced;
+DROP INDEX IF EXISTS idx_stored_objects_content_key;
+DROP TABLE IF EXISTS stored_objects;
@@ -0,0 +1,25 @@
+-- Objects uploaded to MinIO, shared by every download_files row that points at them.
+CREATE TABLE IF NOT EXISTS stored_objects (
+    object_name TEXT PRIMARY KEY,
+    content_key TEXT NOT NULL, -- canonical video key + variant, e.g. youtube:<id>|720p.mp4
+    url TEXT NOT NULL,
+    format_id VARCHAR(50),
+    resolution VARCHAR(50),
+    extension VARCHAR(10),
+    file_size BIGINT,
+    ref_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stored_objects_content_key
+ON stored_objects (content_key, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_stored_objects_unreferenced
+ON stored_objects (ref_count) WHERE ref_count = 0;
+
+ALTER TABLE download_files
+ADD COLUMN IF NOT EXISTS object_name TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_download_files_object_name
+ON download_files (object_name);
@@ -0,0 +1,2 @@
+ALTER TABLE downloads
+ADD COLUMN IF NOT EXISTS format_selection JSONB;
@@ -0,0 +1,2 @@
+ALTER TABLE download_files
+DROP COLUMN IF EXISTS encryption;
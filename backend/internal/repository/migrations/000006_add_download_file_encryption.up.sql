@@ -0,0 +1,4 @@
+-- Scheme of download files encrypted into object storage, e.g. aead-chunked-v1.
+-- NULL means the file is either plain or a legacy encrypted_data blob.
+ALTER TABLE download_files
+ADD COLUMN IF NOT EXISTS encryption VARCHAR(32);
@@ -0,0 +1,8 @@
+-- Store order / transaction ID of each charge so verified purchases and store
+-- notifications are recorded once.
+ALTER TABLE transactions
+ADD COLUMN IF NOT EXISTS provider_transaction_id VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_provider_transaction_id
+ON transactions (provider, provider_transaction_id)
+WHERE provider_transaction_id IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscriptions_purchase_token;
+
+DROP TABLE IF EXISTS store_notifications;
@@ -0,0 +1,12 @@
+-- Store server notifications already handled, so redelivered webhooks are ignored.
+CREATE TABLE IF NOT EXISTS store_notifications (
+    provider VARCHAR(50) NOT NULL, -- 'google_play', 'app_store'
+    notification_id VARCHAR(255) NOT NULL, -- Pub/Sub message ID or notificationUUID
+    notification_type VARCHAR(100),
+    payload JSONB,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, notification_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_purchase_token
+ON subscriptions (purchase_token);
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_transactions_created_at;
+
+DROP INDEX IF EXISTS idx_downloads_created_at;
+
+DROP INDEX IF EXISTS idx_analytics_daily_dimensions;
+
+DELETE FROM analytics_daily
+WHERE platform_type <> '' OR app_id IS NOT NULL;
+
+ALTER TABLE analytics_daily
+ADD CONSTRAINT analytics_daily_date_key UNIQUE (date);
+
+ALTER TABLE analytics_daily
+DROP COLUMN IF EXISTS new_users,
+DROP COLUMN IF EXISTS app_id,
+DROP COLUMN IF EXISTS platform_type;
@@ -0,0 +1,19 @@
+-- analytics_daily keeps one row per day and dimension. The day total has an
+-- empty platform_type and no app_id; per-platform rows set platform_type, and
+-- per-application rows set app_id.
+ALTER TABLE analytics_daily
+ADD COLUMN IF NOT EXISTS platform_type TEXT NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS app_id UUID REFERENCES applications(id) ON DELETE CASCADE,
+ADD COLUMN IF NOT EXISTS new_users INT DEFAULT 0;
+
+ALTER TABLE analytics_daily
+DROP CONSTRAINT IF EXISTS analytics_daily_date_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_analytics_daily_dimensions
+ON analytics_daily (date, platform_type, (COALESCE(app_id, '00000000-0000-0000-0000-000000000000'::uuid)));
+
+CREATE INDEX IF NOT EXISTS idx_downloads_created_at
+ON downloads (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at
+ON transactions (created_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_downloads_error_code;
+
+ALTER TABLE downloads
+DROP COLUMN IF EXISTS error_code;
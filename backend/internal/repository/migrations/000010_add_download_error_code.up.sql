@@ -0,0 +1,8 @@
+-- Machine-readable failure code (private_video, rate_limited, ...) next to the
+-- free-form error_message of failed downloads.
+ALTER TABLE downloads
+ADD COLUMN IF NOT EXISTS error_code VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_downloads_error_code
+ON downloads (error_code)
+WHERE error_code IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE downloads
+DROP COLUMN IF EXISTS clip;
@@ -0,0 +1,4 @@
+-- Start and end of the requested clip, in seconds, for downloads limited to
+-- part of a video.
+ALTER TABLE downloads
+ADD COLUMN IF NOT EXISTS clip JSONB;
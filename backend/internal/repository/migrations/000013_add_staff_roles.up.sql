@@ -0,0 +1,6 @@
+-- Staff roles with narrower admin panel access than 'admin'. Permission keys
+-- are listed in model.Permissions.
+INSERT INTO roles (name, permissions) VALUES
+('support', '{"dashboard:read": true, "users:read": true, "downloads:read": true, "tasks:read": true, "subscriptions:read": true}'),
+('finance', '{"dashboard:read": true, "analytics:read": true, "subscriptions:read": true}')
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,22 @@
+-- Back-office actions, written by the audit middleware on mutating admin routes.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    actor_email VARCHAR(255),
+    actor_role VARCHAR(50),
+    action VARCHAR(100) NOT NULL, -- e.g. 'platform.update'
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    target_ids TEXT[] NOT NULL DEFAULT '{}',
+    changes JSONB, -- {"field": {"before": ..., "after": ...}}, secrets masked
+    payload JSONB, -- request body, secrets masked
+    status_code INT NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_targets ON audit_events USING GIN (target_ids);
@@ -0,0 +1,7 @@
+DELETE FROM settings WHERE key = 'admin_two_factor_required';
+
+DROP TABLE IF EXISTS two_factor_recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS two_factor_enabled_at,
+DROP COLUMN IF EXISTS two_factor_secret;
@@ -0,0 +1,18 @@
+-- TOTP second factor. The secret is encrypted with the key ring like download
+-- data; recovery codes are stored as SHA-256 hashes and spent once.
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS two_factor_secret BYTEA,
+ADD COLUMN IF NOT EXISTS two_factor_enabled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
+
+INSERT INTO settings (scope, key, value, description, group_name) VALUES
+('default', 'admin_two_factor_required', 'false', 'Require Two-Factor Authentication for Admin Panel Users', 'SYSTEM')
+ON CONFLICT (scope, key) DO NOTHING;
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_users_pending_purge;
+DROP INDEX IF EXISTS idx_users_email_active;
+
+-- Deleted accounts whose email was registered again would break the
+-- constraint, so they go first.
+DELETE FROM users d
+WHERE d.deleted_at IS NOT NULL
+  AND EXISTS (SELECT 1 FROM users u WHERE u.email = d.email AND u.id <> d.id);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS data_purged_at,
+DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,13 @@
+-- Email ownership and self-service account deletion. Accounts that existed
+-- before verification was introduced are taken as verified. A deleted account
+-- keeps its row with deleted_at set until the worker has purged its downloads
+-- and files, which sets data_purged_at; its email can be registered again.
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN IF NOT EXISTS data_purged_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (email) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_pending_purge ON users (deleted_at) WHERE deleted_at IS NOT NULL AND data_purged_at IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE store_notifications
+DROP COLUMN IF EXISTS processed_at;
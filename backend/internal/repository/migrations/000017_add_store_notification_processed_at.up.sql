@@ -0,0 +1,7 @@
+-- A claimed notification is only done once processed_at is set; until then a
+-- redelivery is asked to retry later. Notifications claimed before this column
+-- existed were handled already.
+ALTER TABLE store_notifications
+ADD COLUMN IF NOT EXISTS processed_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE store_notifications SET processed_at = received_at WHERE processed_at IS NULL;
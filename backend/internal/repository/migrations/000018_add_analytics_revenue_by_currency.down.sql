@@ -0,0 +1,2 @@
+ALTER TABLE analytics_daily
+DROP COLUMN IF EXISTS revenue_by_currency;
@@ -0,0 +1,4 @@
+-- Transactions are charged in the store's local currency, so revenue is kept
+-- per currency code. total_revenue only counts the base currency (USD).
+ALTER TABLE analytics_daily
+ADD COLUMN IF NOT EXISTS revenue_by_currency JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+type RefreshTokenRepository interface {
+	BaseRepository
+	Create(ctx context.Context, token *model.RefreshToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	Rotate(ctx context.Context, currentID uuid.UUID, next *model.RefreshToken) (bool, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUser(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	RevokeRole(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error)
+	RevokeDevice(ctx context.Context, userID uuid.UUID, deviceID string) ([]uuid.UUID, error)
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type refreshTokenRepository struct {
+	*baseRepository
+}
+
+func NewRefreshTokenRepository(db *pgxpool.Pool) RefreshTokenRepository {
+	return &refreshTokenRepository{
+		baseRepository: NewBaseRepository(db).(*baseRepository),
+	}
+}
+
+const insertRefreshTokenQuery = `
+	INSERT INTO refresh_tokens (user_id, family_id, token_hash, device_id, user_agent, ip_address, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id
+`
+
+func insertRefreshToken(ctx context.Context, q interface {
+	QueryRow(context.Context, string, ...any) pgx.Row
+}, token *model.RefreshToken) error {
+	now := time.Now()
+	err := q.QueryRow(ctx, insertRefreshTokenQuery,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.DeviceID,
+		token.UserAgent,
+		token.IPAddress,
+		token.ExpiresAt,
+		now,
+	).Scan(&token.ID)
+	if err != nil {
+		return err
+	}
+	token.CreatedAt = now
+	return nil
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	if err := insertRefreshToken(subCtx, r.db, token); err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT * FROM refresh_tokens WHERE token_hash = $1`
+
+	var token model.RefreshToken
+	if err := pgxscan.Get(subCtx, r.db, &token, query, tokenHash); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+	return &token, nil
+}
+
+// Rotate replaces the token currentID with next. It returns false, and stores
+// nothing, when currentID was already revoked or replaced: two requests
+// presenting the same token race here and only one of them wins.
+func (r *refreshTokenRepository) Rotate(ctx context.Context, currentID uuid.UUID, next *model.RefreshToken) (bool, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	err := r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		if err := insertRefreshToken(subCtx, tx, next); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		query := `
+			UPDATE refresh_tokens
+			SET revoked_at = $2, replaced_by = $3, last_used_at = $2
+			WHERE id = $1 AND revoked_at IS NULL
+		`
+		ct, err := tx.Exec(subCtx, query, currentID, now, next.ID)
+		if err != nil {
+			return err
+		}
+		if ct.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return true, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(subCtx, query, familyID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// RevokeUser revokes every active token of a user and returns the families,
+// that is the sessions, it ended.
+func (r *refreshTokenRepository) RevokeUser(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING family_id
+	`
+	var families []uuid.UUID
+	if err := pgxscan.Select(subCtx, r.db, &families, query, userID, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+	return families, nil
+}
+
+// RevokeRole revokes every active token of the users holding a role and
+// returns the families it ended.
+func (r *refreshTokenRepository) RevokeRole(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE user_id IN (SELECT id FROM users WHERE role_id = $1) AND revoked_at IS NULL
+		RETURNING family_id
+	`
+	var families []uuid.UUID
+	if err := pgxscan.Select(subCtx, r.db, &families, query, roleID, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to revoke role refresh tokens: %w", err)
+	}
+	return families, nil
+}
+
+// RevokeDevice revokes the active tokens a user holds on one device and returns
+// the families it ended.
+func (r *refreshTokenRepository) RevokeDevice(ctx context.Context, userID uuid.UUID, deviceID string) ([]uuid.UUID, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $3
+		WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL
+		RETURNING family_id
+	`
+	var families []uuid.UUID
+	if err := pgxscan.Select(subCtx, r.db, &families, query, userID, deviceID, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to revoke device refresh tokens: %w", err)
+	}
+	return families, nil
+}
+
+// DeleteExpired removes tokens that expired before the given time. Revoked
+// tokens are kept until then so that reusing them is still detected.
+func (r *refreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM refresh_tokens WHERE expires_at < $1`
+	ct, err := r.db.Exec(subCtx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	return ct.RowsAffected(), nil
+}
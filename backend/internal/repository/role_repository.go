@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+type RoleRepository interface {
+	BaseRepository
+	FindAll(ctx context.Context) ([]model.Role, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*model.Role, error)
+	FindByName(ctx context.Context, name string) (*model.Role, error)
+	Create(ctx context.Context, role *model.Role) error
+	Update(ctx context.Context, role *model.Role) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	CountUsers(ctx context.Context, id uuid.UUID) (int64, error)
+}
+
+type roleRepository struct {
+	*baseRepository
+}
+
+func NewRoleRepository(db *pgxpool.Pool) RoleRepository {
+	return &roleRepository{
+		baseRepository: NewBaseRepository(db).(*baseRepository),
+	}
+}
+
+func (r *roleRepository) FindAll(ctx context.Context) ([]model.Role, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT id, name, permissions, created_at FROM roles ORDER BY name`
+
+	var roles []model.Role
+	if err := pgxscan.Select(subCtx, r.db, &roles, query); err != nil {
+		return nil, fmt.Errorf("failed to find roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Role, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT id, name, permissions, created_at FROM roles WHERE id = $1`
+
+	var role model.Role
+	if err := pgxscan.Get(subCtx, r.db, &role, query, id); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find role: %w", err)
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) FindByName(ctx context.Context, name string) (*model.Role, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT id, name, permissions, created_at FROM roles WHERE name = $1`
+
+	var role model.Role
+	if err := pgxscan.Get(subCtx, r.db, &role, query, name); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find role: %w", err)
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *model.Role) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO roles (name, permissions, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(subCtx, query, role.Name, role.Permissions, time.Now()).Scan(&role.ID, &role.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+	return nil
+}
+
+func (r *roleRepository) Update(ctx context.Context, role *model.Role) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `UPDATE roles SET name = $1, permissions = $2 WHERE id = $3`
+	ct, err := r.db.Exec(subCtx, query, role.Name, role.Permissions, role.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *roleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	ct, err := r.db.Exec(subCtx, `DELETE FROM roles WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// CountUsers counts the users, deleted ones included, that hold the role.
+func (r *roleRepository) CountUsers(ctx context.Context, id uuid.UUID) (int64, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	var count int64
+	if err := r.db.QueryRow(subCtx, `SELECT COUNT(*) FROM users WHERE role_id = $1`, id).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count role users: %w", err)
+	}
+	return count, nil
+}
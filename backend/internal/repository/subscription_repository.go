@@ -16,6 +16,15 @@ type SubscriptionRepository interface {
 	BaseRepository
 	Upsert(ctx context.Context, sub *model.Subscription) (*model.Subscription, error)
 	FindCurrentByUserAndApp(ctx context.Context, userID uuid.UUID, appID uuid.UUID, now time.Time) (*model.Subscription, error)
+	FindLatestByUser(ctx context.Context, userID uuid.UUID) (*model.Subscription, error)
+	UpdateStatus(ctx context.Context, subID uuid.UUID, from, to string) (*model.Subscription, error)
+	ExpireDue(ctx context.Context, now time.Time, limit int) ([]model.Subscription, error)
+	FindByOriginalTransactionID(ctx context.Context, originalTransactionID string) (*model.Subscription, error)
+	FindByPurchaseToken(ctx context.Context, purchaseToken string) (*model.Subscription, error)
+	AddTransaction(ctx context.Context, tx *model.Transaction) error
+	ClaimNotification(ctx context.Context, n *model.StoreNotification, staleAfter time.Duration) (claimed bool, processed bool, err error)
+	CompleteNotification(ctx context.Context, provider, notificationID string) error
+	ReleaseNotification(ctx context.Context, provider, notificationID string) error
 	FindByID(ctx context.Context, subID uuid.UUID) (*model.Subscription, error)
 	FindAll(ctx context.Context, params model.QueryParamsRequest) ([]model.Subscription, model.Pagination, error)
 	Delete(ctx context.Context, subID uuid.UUID) error
@@ -92,6 +101,13 @@ func (r *subscriptionRepository) Upsert(ctx context.Context, sub *model.Subscrip
 	return &out, nil
 }
 
+// entitledSubscriptionStatuses are the statuses that grant features until
+// end_time. on_hold and paused subscriptions wait for the store instead.
+var entitledSubscriptionStatuses = []string{"trial", "active", "grace", "canceled"}
+
+// FindCurrentByUserAndApp returns the subscription that entitles the user in the
+// application at now, falling back to the one that ran the longest. It returns
+// nil when the user never subscribed.
 func (r *subscriptionRepository) FindCurrentByUserAndApp(ctx context.Context, userID uuid.UUID, appID uuid.UUID, now time.Time) (*model.Subscription, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
@@ -101,21 +117,71 @@ func (r *subscriptionRepository) FindCurrentByUserAndApp(ctx context.Context, us
 		FROM subscriptions
 		WHERE user_id = $1
 		  AND app_id = $2
-		ORDER BY end_time DESC
+		ORDER BY (status = ANY($3) AND end_time > $4) DESC, end_time DESC
 		LIMIT 1
 	`
 	var out model.Subscription
-	err := pgxscan.Get(subCtx, r.db, &out, query, userID, appID)
-	if err != nil {
-		return nil, err
+	if err := pgxscan.Get(subCtx, r.db, &out, query, userID, appID, entitledSubscriptionStatuses, now); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find current subscription: %w", err)
 	}
+	return &out, nil
+}
 
-	if out.EndTime.Before(now) && out.Status == "active" {
-		out.Status = "expired"
+// UpdateStatus moves a subscription from one status to another. It returns nil
+// when the subscription is no longer in status from.
+func (r *subscriptionRepository) UpdateStatus(ctx context.Context, subID uuid.UUID, from, to string) (*model.Subscription, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE subscriptions
+		SET status = $3, updated_at = $4
+		WHERE id = $1 AND status = $2
+		RETURNING *
+	`
+	var out model.Subscription
+	if err := pgxscan.Get(subCtx, r.db, &out, query, subID, from, to, time.Now()); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to update subscription status: %w", err)
 	}
 	return &out, nil
 }
 
+// ExpireDue marks up to limit entitled subscriptions whose end_time is before now
+// as expired and returns them as they were before the update. Rows locked by
+// another sweeper are skipped.
+func (r *subscriptionRepository) ExpireDue(ctx context.Context, now time.Time, limit int) ([]model.Subscription, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		WITH due AS (
+			SELECT *
+			FROM subscriptions
+			WHERE status = ANY($1) AND end_time < $2
+			ORDER BY end_time
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		), expired AS (
+			UPDATE subscriptions s
+			SET status = 'expired', updated_at = $2
+			FROM due
+			WHERE s.id = due.id
+		)
+		SELECT * FROM due
+	`
+	var out []model.Subscription
+	if err := pgxscan.Select(subCtx, r.db, &out, query, entitledSubscriptionStatuses, now, limit); err != nil {
+		return nil, fmt.Errorf("failed to expire subscriptions: %w", err)
+	}
+	return out, nil
+}
+
 func (r *subscriptionRepository) FindAll(ctx context.Context, params model.QueryParamsRequest) ([]model.Subscription, model.Pagination, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
@@ -244,7 +310,192 @@ func (r *subscriptionRepository) FindByID(ctx context.Context, subID uuid.UUID)
 	var out model.Subscription
 	err := pgxscan.Get(subCtx, r.db, &out, query, subID)
 	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to find subscription by id: %w", err)
 	}
 	return &out, nil
 }
+
+// FindLatestByUser returns the entitling subscription of any application that
+// runs the longest, or nil when the user never subscribed.
+func (r *subscriptionRepository) FindLatestByUser(ctx context.Context, userID uuid.UUID) (*model.Subscription, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT *
+		FROM subscriptions
+		WHERE user_id = $1
+		ORDER BY (status = ANY($2) AND end_time > $3) DESC, end_time DESC
+		LIMIT 1
+	`
+	var out model.Subscription
+	if err := pgxscan.Get(subCtx, r.db, &out, query, userID, entitledSubscriptionStatuses, time.Now()); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find latest subscription: %w", err)
+	}
+	return &out, nil
+}
+
+func (r *subscriptionRepository) FindByOriginalTransactionID(ctx context.Context, originalTransactionID string) (*model.Subscription, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT * FROM subscriptions WHERE original_transaction_id = $1
+	`
+	var out model.Subscription
+	if err := pgxscan.Get(subCtx, r.db, &out, query, originalTransactionID); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find subscription by original transaction id: %w", err)
+	}
+	return &out, nil
+}
+
+// AddTransaction records a store charge. A charge already recorded under the same
+// provider transaction ID is updated in place, so verifying a purchase again does
+// not count it twice; its original amount and date are read back into tx.
+func (r *subscriptionRepository) AddTransaction(ctx context.Context, tx *model.Transaction) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO transactions (
+			user_id,
+			app_id,
+			subscription_id,
+			amount,
+			currency,
+			provider,
+			provider_transaction_id,
+			status,
+			provider_response,
+			created_at
+		)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+		ON CONFLICT (provider, provider_transaction_id) WHERE provider_transaction_id IS NOT NULL DO UPDATE SET
+			subscription_id = COALESCE(EXCLUDED.subscription_id, transactions.subscription_id),
+			status = EXCLUDED.status,
+			provider_response = COALESCE(transactions.provider_response, '{}'::jsonb) || COALESCE(EXCLUDED.provider_response, '{}'::jsonb)
+		RETURNING id, amount, created_at
+	`
+	if tx.CreatedAt.IsZero() {
+		tx.CreatedAt = time.Now()
+	}
+	err := r.db.QueryRow(
+		subCtx,
+		query,
+		tx.UserID,
+		tx.AppID,
+		tx.SubscriptionID,
+		tx.Amount,
+		tx.Currency,
+		tx.Provider,
+		tx.ProviderTransactionID,
+		tx.Status,
+		tx.ProviderResponse,
+		tx.CreatedAt,
+	).Scan(&tx.ID, &tx.Amount, &tx.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *subscriptionRepository) FindByPurchaseToken(ctx context.Context, purchaseToken string) (*model.Subscription, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT *
+		FROM subscriptions
+		WHERE purchase_token = $1
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`
+	var out model.Subscription
+	if err := pgxscan.Get(subCtx, r.db, &out, query, purchaseToken); err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find subscription by purchase token: %w", err)
+	}
+	return &out, nil
+}
+
+// ClaimNotification stores a store notification and reports whether it is new.
+// A notification already claimed returns false and must not be processed now;
+// processed tells a finished one from one still being handled. A claim left
+// unfinished for longer than staleAfter, e.g. by a crashed instance, is taken
+// over.
+func (r *subscriptionRepository) ClaimNotification(ctx context.Context, n *model.StoreNotification, staleAfter time.Duration) (bool, bool, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	query := `
+		INSERT INTO store_notifications (provider, notification_id, notification_type, payload, received_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, notification_id) DO UPDATE
+		SET received_at = EXCLUDED.received_at
+		WHERE store_notifications.processed_at IS NULL
+		  AND store_notifications.received_at < $6
+	`
+	tag, err := r.db.Exec(subCtx, query, n.Provider, n.NotificationID, n.NotificationType, n.Payload, now, now.Add(-staleAfter))
+	if err != nil {
+		return false, false, fmt.Errorf("failed to claim store notification: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return true, false, nil
+	}
+
+	var processed bool
+	err = r.db.QueryRow(subCtx, `
+		SELECT processed_at IS NOT NULL
+		FROM store_notifications
+		WHERE provider = $1 AND notification_id = $2
+	`, n.Provider, n.NotificationID).Scan(&processed)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to check store notification: %w", err)
+	}
+	return false, processed, nil
+}
+
+// CompleteNotification marks a claimed notification as processed, so that
+// redeliveries are acknowledged without being handled again.
+func (r *subscriptionRepository) CompleteNotification(ctx context.Context, provider, notificationID string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE store_notifications
+		SET processed_at = $3
+		WHERE provider = $1 AND notification_id = $2
+	`
+	if _, err := r.db.Exec(subCtx, query, provider, notificationID, time.Now()); err != nil {
+		return fmt.Errorf("failed to complete store notification: %w", err)
+	}
+	return nil
+}
+
+// ReleaseNotification forgets a claimed notification whose processing failed, so
+// the store's retry is handled.
+func (r *subscriptionRepository) ReleaseNotification(ctx context.Context, provider, notificationID string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM store_notifications
+		WHERE provider = $1 AND notification_id = $2
+	`
+	if _, err := r.db.Exec(subCtx, query, provider, notificationID); err != nil {
+		return fmt.Errorf("failed to release store notification: %w", err)
+	}
+	return nil
+}
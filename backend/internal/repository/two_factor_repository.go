@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+type TwoFactorRepository interface {
+	BaseRepository
+	// GetSecret returns the encrypted secret of the user, or nil when no second
+	// factor is enrolled.
+	GetSecret(ctx context.Context, userID uuid.UUID) ([]byte, error)
+	// Enable stores the secret and replaces the recovery codes.
+	Enable(ctx context.Context, userID uuid.UUID, secret []byte, codeHashes []string) error
+	Disable(ctx context.Context, userID uuid.UUID) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode spends an unused code and reports whether there was one.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+	// FindSecrets pages through enrolled secrets by user id, for re-encryption.
+	FindSecrets(ctx context.Context, after uuid.UUID, limit int) ([]model.TwoFactorSecret, error)
+	UpdateSecrets(ctx context.Context, updates map[uuid.UUID][]byte) error
+}
+
+type twoFactorRepository struct {
+	*baseRepository
+}
+
+func NewTwoFactorRepository(db *pgxpool.Pool) TwoFactorRepository {
+	return &twoFactorRepository{
+		baseRepository: NewBaseRepository(db).(*baseRepository),
+	}
+}
+
+func (r *twoFactorRepository) GetSecret(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT two_factor_secret FROM users WHERE id = $1 AND two_factor_enabled_at IS NOT NULL`
+
+	var secret []byte
+	if err := r.db.QueryRow(subCtx, query, userID).Scan(&secret); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find two-factor secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (r *twoFactorRepository) Enable(ctx context.Context, userID uuid.UUID, secret []byte, codeHashes []string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	err := r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		query := `UPDATE users SET two_factor_secret = $2, two_factor_enabled_at = $3, updated_at = $3 WHERE id = $1`
+		ct, err := tx.Exec(subCtx, query, userID, secret, time.Now())
+		if err != nil {
+			return err
+		}
+		if ct.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return replaceRecoveryCodes(subCtx, tx, userID, codeHashes)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) Disable(ctx context.Context, userID uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	err := r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		query := `UPDATE users SET two_factor_secret = NULL, two_factor_enabled_at = NULL, updated_at = $2 WHERE id = $1`
+		if _, err := tx.Exec(subCtx, query, userID, time.Now()); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(subCtx, tx, userID, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	err := r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		return replaceRecoveryCodes(subCtx, tx, userID, codeHashes)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if len(codeHashes) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, hash := range codeHashes {
+		batch.Queue(`INSERT INTO two_factor_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+	}
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE two_factor_recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	ct, err := r.db.Exec(subCtx, query, userID, codeHash, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+func (r *twoFactorRepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `SELECT COUNT(*) FROM two_factor_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	if err := r.db.QueryRow(subCtx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+func (r *twoFactorRepository) FindSecrets(ctx context.Context, after uuid.UUID, limit int) ([]model.TwoFactorSecret, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, two_factor_secret
+		FROM users
+		WHERE two_factor_secret IS NOT NULL AND id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(subCtx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find two-factor secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []model.TwoFactorSecret
+	for rows.Next() {
+		var s model.TwoFactorSecret
+		if err := rows.Scan(&s.UserID, &s.Secret); err != nil {
+			return nil, fmt.Errorf("failed to scan two-factor secret: %w", err)
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, rows.Err()
+}
+
+func (r *twoFactorRepository) UpdateSecrets(ctx context.Context, updates map[uuid.UUID][]byte) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET two_factor_secret = $1 WHERE id = $2 AND two_factor_secret IS NOT NULL`
+	return r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		for id, secret := range updates {
+			batch.Queue(query, secret, id)
+		}
+		if err := tx.SendBatch(subCtx, batch).Close(); err != nil {
+			return fmt.Errorf("failed to update two-factor secrets: %w", err)
+		}
+		return nil
+	})
+}
@@ -22,7 +22,18 @@ type UserRepository interface {
 	UpdateAvatar(ctx context.Context, userID uuid.UUID, avatarURL string) error
 	UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
 	UpdateProfile(ctx context.Context, userID uuid.UUID, req model.UpdateProfileRequest) error
+	UpdateRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error
+	// MarkEmailVerified verifies the email of an account and reports whether it
+	// was still unverified.
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID) (bool, error)
 	FindAll(ctx context.Context, params model.QueryParamsRequest) ([]model.User, model.Pagination, error)
+	// SoftDelete closes an account: it is deactivated, stripped of its
+	// credentials and hidden from sign-in and listings. Its data stays until
+	// the worker purges it.
+	SoftDelete(ctx context.Context, userID uuid.UUID) error
+	// FindPendingPurge returns deleted accounts whose data was not purged yet.
+	FindPendingPurge(ctx context.Context, limit int) ([]model.User, error)
+	MarkDataPurged(ctx context.Context, userID uuid.UUID) error
 	Delete(ctx context.Context, userID uuid.UUID) error
 	BulkDelete(ctx context.Context, userIDs []uuid.UUID) error
 }
@@ -43,11 +54,11 @@ func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.
 
 	query := `
 		SELECT 
-			u.id, u.email, u.password_hash, u.full_name, u.avatar_url, u.role_id, u.is_active, u.last_login_at, u.created_at, u.updated_at,
+			u.id, u.email, u.password_hash, u.full_name, u.avatar_url, u.role_id, u.is_active, u.last_login_at, u.two_factor_enabled_at, u.email_verified_at, u.created_at, u.updated_at,
 			r.id, r.name, r.permissions
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.id
-		WHERE u.email = $1
+		WHERE u.email = $1 AND u.deleted_at IS NULL
 	`
 
 	var user model.User
@@ -55,7 +66,7 @@ func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.
 
 	err := r.db.QueryRow(subCtx, query, email).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.FullName, &user.AvatarURL,
-		&user.RoleID, &user.IsActive, &user.LastLoginAt, &user.CreatedAt, &user.UpdatedAt,
+		&user.RoleID, &user.IsActive, &user.LastLoginAt, &user.TwoFactorEnabledAt, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
 		&user.Role.ID, &user.Role.Name, &user.Role.Permissions,
 	)
 
@@ -75,7 +86,7 @@ func (r *userRepository) FindByID(ctx context.Context, userID uuid.UUID) (*model
 
 	query := `
 		SELECT 
-			u.id, u.email, u.password_hash, u.full_name, u.avatar_url, u.role_id, u.is_active, u.last_login_at, u.created_at, u.updated_at,
+			u.id, u.email, u.password_hash, u.full_name, u.avatar_url, u.role_id, u.is_active, u.last_login_at, u.two_factor_enabled_at, u.email_verified_at, u.created_at, u.updated_at, u.deleted_at,
 			r.id, r.name, r.permissions
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.id
@@ -87,7 +98,7 @@ func (r *userRepository) FindByID(ctx context.Context, userID uuid.UUID) (*model
 
 	err := r.db.QueryRow(subCtx, query, userID).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.FullName, &user.AvatarURL,
-		&user.RoleID, &user.IsActive, &user.LastLoginAt, &user.CreatedAt, &user.UpdatedAt,
+		&user.RoleID, &user.IsActive, &user.LastLoginAt, &user.TwoFactorEnabledAt, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt,
 		&user.Role.ID, &user.Role.Name, &user.Role.Permissions,
 	)
 
@@ -106,8 +117,8 @@ func (r *userRepository) Create(ctx context.Context, user *model.User, passwordH
 	defer cancel()
 
 	query := `
-		INSERT INTO users (email, full_name, password_hash, avatar_url, role_id, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, COALESCE($5, (SELECT id FROM roles WHERE name = 'customer')), $6, $7, $8)
+		INSERT INTO users (email, full_name, password_hash, avatar_url, role_id, is_active, created_at, updated_at, email_verified_at)
+		VALUES ($1, $2, $3, $4, COALESCE($5, (SELECT id FROM roles WHERE name = 'customer')), $6, $7, $8, $9)
 		RETURNING id, created_at, updated_at
 	`
 	now := time.Now()
@@ -126,6 +137,7 @@ func (r *userRepository) Create(ctx context.Context, user *model.User, passwordH
 		true,        // is_active
 		now,
 		now,
+		user.EmailVerifiedAt,
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 
 	return err
@@ -162,22 +174,59 @@ func (r *userRepository) UpdateProfile(ctx context.Context, userID uuid.UUID, re
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
 
-	query := `UPDATE users SET full_name = $1, email = $2, updated_at = $3 WHERE id = $4`
+	// A new address has to be verified again.
+	query := `
+		UPDATE users
+		SET full_name = $1, email = $2, updated_at = $3,
+			email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+		WHERE id = $4
+	`
 	_, err := r.db.Exec(subCtx, query, req.FullName, req.Email, time.Now(), userID)
 	return err
 }
 
+func (r *userRepository) UpdateRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET role_id = $1, updated_at = $2 WHERE id = $3`
+	ct, err := r.db.Exec(subCtx, query, roleID, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE users SET email_verified_at = $2, updated_at = $2
+		WHERE id = $1 AND email_verified_at IS NULL AND deleted_at IS NULL
+	`
+	ct, err := r.db.Exec(subCtx, query, userID, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to verify email: %w", err)
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
 func (r *userRepository) FindAll(ctx context.Context, params model.QueryParamsRequest) ([]model.User, model.Pagination, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
 
 	qb := NewQueryBuilder(`
 		SELECT 
-			u.id, u.email, u.full_name, u.avatar_url, u.role_id, u.is_active, u.last_login_at, u.created_at, u.updated_at,
+			u.id, u.email, u.full_name, u.avatar_url, u.role_id, u.is_active, u.last_login_at, u.two_factor_enabled_at, u.email_verified_at, u.created_at, u.updated_at,
 			r.id, r.name, r.permissions
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.id
 	`)
+	qb.Where("u.deleted_at IS NULL")
 
 	if params.Search != "" {
 		qb.Where("(u.email ILIKE $? OR u.full_name ILIKE $?)",
@@ -209,7 +258,7 @@ func (r *userRepository) FindAll(ctx context.Context, params model.QueryParamsRe
 
 	countQuery := `SELECT COUNT(*) FROM users`
 	args := []interface{}{}
-	whereClauses := []string{}
+	whereClauses := []string{"deleted_at IS NULL"}
 	argIdx := 1
 
 	if params.Search != "" {
@@ -223,9 +272,7 @@ func (r *userRepository) FindAll(ctx context.Context, params model.QueryParamsRe
 		args = append(args, params.DateFrom, params.DateTo)
 	}
 
-	if len(whereClauses) > 0 {
-		countQuery += " WHERE " + strings.Join(whereClauses, " AND ")
-	}
+	countQuery += " WHERE " + strings.Join(whereClauses, " AND ")
 
 	var totalItems int64
 	err := r.db.QueryRow(subCtx, countQuery, args...).Scan(&totalItems)
@@ -249,7 +296,7 @@ func (r *userRepository) FindAll(ctx context.Context, params model.QueryParamsRe
 		var role model.Role
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.FullName, &user.AvatarURL, &user.RoleID, &user.IsActive,
-			&user.LastLoginAt, &user.CreatedAt, &user.UpdatedAt,
+			&user.LastLoginAt, &user.TwoFactorEnabledAt, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
 			&role.ID, &role.Name, &role.Permissions,
 		)
 		if err != nil {
@@ -278,6 +325,71 @@ func (r *userRepository) FindAll(ctx context.Context, params model.QueryParamsRe
 	}, nil
 }
 
+func (r *userRepository) SoftDelete(ctx context.Context, userID uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	err := r.WithTransaction(subCtx, func(tx pgx.Tx) error {
+		query := `
+			UPDATE users
+			SET deleted_at = $2, updated_at = $2, is_active = false, password_hash = NULL,
+				two_factor_secret = NULL, two_factor_enabled_at = NULL
+			WHERE id = $1 AND deleted_at IS NULL
+		`
+		ct, err := tx.Exec(subCtx, query, userID, time.Now())
+		if err != nil {
+			return err
+		}
+		if ct.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return replaceRecoveryCodes(subCtx, tx, userID, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) FindPendingPurge(ctx context.Context, limit int) ([]model.User, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, email, avatar_url, deleted_at
+		FROM users
+		WHERE deleted_at IS NOT NULL AND data_purged_at IS NULL
+		ORDER BY deleted_at ASC
+		LIMIT $1
+	`
+	rows, err := r.db.Query(subCtx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deleted accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.AvatarURL, &user.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted account: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *userRepository) MarkDataPurged(ctx context.Context, userID uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET data_purged_at = $2, avatar_url = NULL WHERE id = $1`
+	if _, err := r.db.Exec(subCtx, query, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark account data purged: %w", err)
+	}
+	return nil
+}
+
 func (r *userRepository) Delete(ctx context.Context, userID uuid.UUID) error {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/config"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+)
+
+var ErrInvalidAnalyticsQuery = errors.New("invalid analytics query")
+
+// maxAnalyticsBuckets bounds the number of time buckets one series may span.
+const maxAnalyticsBuckets = 1000
+
+var analyticsIntervals = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+type AnalyticsService interface {
+	DownloadSeries(ctx context.Context, q model.AnalyticsQuery) (*model.AnalyticsSeries, error)
+}
+
+type analyticsService struct {
+	repo  repository.AnalyticRepository
+	geoIP infrastructure.GeoIP
+}
+
+// LoadGeoIP opens the configured IP-to-country database. Without one, or when it
+// fails to load, every country is reported as unknown.
+func LoadGeoIP(cfg *config.Config) infrastructure.GeoIP {
+	if cfg.GeoIPDatabase == "" {
+		return infrastructure.NoGeoIP{}
+	}
+	geoIP, err := infrastructure.NewGeoIPFromCSV(cfg.GeoIPDatabase)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load the GeoIP database, countries will be unknown")
+		return infrastructure.NoGeoIP{}
+	}
+	return geoIP
+}
+
+func NewAnalyticsService(repo repository.AnalyticRepository, geoIP infrastructure.GeoIP) AnalyticsService {
+	if geoIP == nil {
+		geoIP = infrastructure.NoGeoIP{}
+	}
+	return &analyticsService{
+		repo:  repo,
+		geoIP: geoIP,
+	}
+}
+
+// DownloadSeries returns download counts per time bucket and group, with the
+// failure rate of each point. Defaults are the last 7 days by day, ungrouped.
+func (s *analyticsService) DownloadSeries(ctx context.Context, q model.AnalyticsQuery) (*model.AnalyticsSeries, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 60*time.Second)
+	defer cancel()
+
+	if err := normalizeAnalyticsQuery(&q); err != nil {
+		return nil, err
+	}
+
+	points, err := s.repo.DownloadSeries(subCtx, q)
+	if err != nil {
+		return nil, err
+	}
+	if q.GroupBy == model.AnalyticsGroupCountry {
+		points = s.groupByCountry(points)
+	}
+	for i := range points {
+		if points[i].Total > 0 {
+			points[i].FailureRate = float64(points[i].Failed) / float64(points[i].Total)
+		}
+	}
+	if points == nil {
+		points = []model.AnalyticsPoint{}
+	}
+
+	return &model.AnalyticsSeries{Query: q, Points: points}, nil
+}
+
+func normalizeAnalyticsQuery(q *model.AnalyticsQuery) error {
+	if q.To.IsZero() {
+		q.To = time.Now().UTC()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.AddDate(0, 0, -7)
+	}
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("%w: date_from must be before date_to", ErrInvalidAnalyticsQuery)
+	}
+
+	if q.Interval == "" {
+		q.Interval = "day"
+	}
+	step, ok := analyticsIntervals[q.Interval]
+	if !ok {
+		return fmt.Errorf("%w: unknown interval %q", ErrInvalidAnalyticsQuery, q.Interval)
+	}
+	if step > 0 && q.To.Sub(q.From)/step > maxAnalyticsBuckets {
+		return fmt.Errorf("%w: range spans more than %d %s buckets", ErrInvalidAnalyticsQuery, maxAnalyticsBuckets, q.Interval)
+	}
+
+	switch q.GroupBy {
+	case "":
+		q.GroupBy = model.AnalyticsGroupNone
+	case model.AnalyticsGroupNone, model.AnalyticsGroupPlatform, model.AnalyticsGroupApplication,
+		model.AnalyticsGroupStatus, model.AnalyticsGroupErrorCategory, model.AnalyticsGroupCountry:
+	default:
+		return fmt.Errorf("%w: unknown group_by %q", ErrInvalidAnalyticsQuery, q.GroupBy)
+	}
+	return nil
+}
+
+// groupByCountry folds the per-address points of a country query into one point
+// per country and bucket, largest first within each bucket.
+func (s *analyticsService) groupByCountry(points []model.AnalyticsPoint) []model.AnalyticsPoint {
+	type key struct {
+		bucket  time.Time
+		country string
+	}
+	byKey := make(map[key]*model.AnalyticsPoint)
+	var out []model.AnalyticsPoint
+	var keys []key
+	for _, p := range points {
+		k := key{bucket: p.Bucket, country: s.geoIP.Country(p.Group)}
+		agg, ok := byKey[k]
+		if !ok {
+			keys = append(keys, k)
+			agg = &model.AnalyticsPoint{Bucket: p.Bucket, Group: k.country}
+			byKey[k] = agg
+		}
+		agg.Total += p.Total
+		agg.Completed += p.Completed
+		agg.Failed += p.Failed
+	}
+	for _, k := range keys {
+		out = append(out, *byKey[k])
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].Bucket.Equal(out[j].Bucket) {
+			return out[i].Bucket.Before(out[j].Bucket)
+		}
+		return out[i].Total > out[j].Total
+	})
+	return out
+}
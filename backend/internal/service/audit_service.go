@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+	"github.com/user/video-downloader-backend/pkg/utils"
+)
+
+type AuditService interface {
+	// Record stores event with the fields that differ between before and
+	// after, either of which may be nil. Secrets in the changes and the payload
+	// are masked before anything is written.
+	Record(ctx context.Context, event *model.AuditEvent, before, after any) error
+	Search(ctx context.Context, query model.AuditEventQuery) ([]model.AuditEvent, model.Pagination, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) Record(ctx context.Context, event *model.AuditEvent, before, after any) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		return err
+	}
+	event.Changes = changes
+
+	// Requests that carry a secret as a whole, such as a cookies file, a
+	// second factor code or a password change, are recorded without their
+	// payload.
+	if resource, verb, _ := strings.Cut(event.Action, "."); slices.Contains(secretActionResources, resource) || strings.Contains(verb, "password") {
+		event.Payload = nil
+	} else {
+		payload, err := jsonValue(event.Payload)
+		if err != nil {
+			return err
+		}
+		event.Payload = maskAuditValue(payload)
+	}
+
+	return s.repo.Create(subCtx, event)
+}
+
+func (s *auditService) Search(ctx context.Context, query model.AuditEventQuery) ([]model.AuditEvent, model.Pagination, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		query.Limit = 20
+	}
+	return s.repo.Search(subCtx, query)
+}
+
+// auditChanges diffs the JSON forms of before and after field by field. Values
+// that are not objects are compared as a whole under "value".
+func auditChanges(before, after any) (map[string]any, error) {
+	b, err := jsonValue(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonValue(after)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil && a == nil {
+		return nil, nil
+	}
+
+	bm, bok := b.(map[string]any)
+	am, aok := a.(map[string]any)
+	if (!bok && b != nil) || (!aok && a != nil) {
+		if reflect.DeepEqual(b, a) {
+			return nil, nil
+		}
+		return map[string]any{"value": auditChange("value", b, a)}, nil
+	}
+
+	changes := make(map[string]any)
+	for field, bv := range bm {
+		if av, ok := am[field]; !ok || !reflect.DeepEqual(bv, av) {
+			changes[field] = auditChange(field, bv, am[field])
+		}
+	}
+	for field, av := range am {
+		if _, ok := bm[field]; !ok {
+			changes[field] = auditChange(field, nil, av)
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, nil
+}
+
+func auditChange(field string, before, after any) map[string]any {
+	if isSecretField(field) {
+		return map[string]any{"before": maskSecretValue(field, before), "after": maskSecretValue(field, after)}
+	}
+	return map[string]any{"before": maskAuditValue(before), "after": maskAuditValue(after)}
+}
+
+// jsonValue turns v into the maps, slices and scalars encoding/json decodes
+// to, so structs and raw request bodies are compared and masked alike.
+func jsonValue(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var raw []byte
+	switch t := v.(type) {
+	case json.RawMessage:
+		raw = t
+	case []byte:
+		raw = t
+	default:
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("failed to encode audit value: %w", err)
+		}
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode audit value: %w", err)
+	}
+	return out, nil
+}
+
+// maxAuditString bounds the strings kept in an event; uploaded content such
+// as a cookies file is summarized by its size.
+const maxAuditString = 1024
+
+var secretActionResources = []string{"cookies", "two_factor"}
+
+var secretFieldMarkers = []string{"password", "secret", "token", "api_key", "apikey", "private_key", "credential", "cookie"}
+
+// identifierFieldMarkers name secrets that double as identifiers, such as the
+// API keys admins tell apart by their ends. Only these keep a masked hint; the
+// rest, passwords above all, are redacted whole.
+var identifierFieldMarkers = []string{"api_key", "apikey"}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, marker := range secretFieldMarkers {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskAuditValue masks the values of secret looking fields anywhere in v. Key
+// and value pairs, as settings are sent, are masked by their key.
+func maskAuditValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		pairKey, _ := t["key"].(string)
+		secretPair := isSecretField(pairKey)
+		out := make(map[string]any, len(t))
+		for field, value := range t {
+			if isSecretField(field) {
+				out[field] = maskSecretValue(field, value)
+			} else if secretPair && field == "value" {
+				out[field] = maskSecretValue(pairKey, value)
+			} else {
+				out[field] = maskAuditValue(value)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, value := range t {
+			out[i] = maskAuditValue(value)
+		}
+		return out
+	case string:
+		if len(t) > maxAuditString {
+			return fmt.Sprintf("<%d bytes>", len(t))
+		}
+		return t
+	}
+	return v
+}
+
+func maskSecretValue(field string, v any) any {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if t == "" {
+			return ""
+		}
+		field = strings.ToLower(field)
+		for _, marker := range identifierFieldMarkers {
+			if strings.Contains(field, marker) {
+				return utils.MaskSecret(t)
+			}
+		}
+	}
+	return "***"
+}
@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
 	"github.com/user/video-downloader-backend/internal/delivery/helpers"
 	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
 	"github.com/user/video-downloader-backend/internal/model"
@@ -15,47 +19,134 @@ import (
 	"google.golang.org/api/idtoken"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token that was already
+	// exchanged is presented again. The whole session is revoked, since either
+	// the client or whoever stole the token holds a newer one.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	// ErrTwoFactorRequired is wrapped by TwoFactorChallengeError.
+	ErrTwoFactorRequired         = errors.New("two-factor authentication required")
+	ErrInvalidTwoFactorChallenge = errors.New("invalid or expired two-factor challenge")
+
+	ErrEmailNotVerified            = errors.New("email address is not verified")
+	ErrEmailAlreadyVerified        = errors.New("email address is already verified")
+	ErrInvalidVerificationToken    = errors.New("invalid or expired verification link")
+	ErrInvalidVerificationCode     = errors.New("invalid verification code")
+	ErrVerificationExpired         = errors.New("verification code expired, request a new one")
+	ErrTooManyVerificationAttempts = errors.New("too many invalid verification codes, request a new one")
+	ErrVerificationResendTooSoon   = errors.New("a verification email was sent recently, try again later")
+	ErrIncorrectPassword           = errors.New("incorrect password")
+	ErrReauthenticationRequired    = errors.New("sign in with Google again to confirm")
+)
+
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// googleReauthMaxAge is how recent a Google ID token must be to confirm a
+// sensitive action on an account without a password.
+const googleReauthMaxAge = 5 * time.Minute
+
+const (
+	// The link and the code of a verification email stay valid together; a
+	// new email replaces both.
+	emailVerificationTTL         = 24 * time.Hour
+	emailVerificationCodeDigits  = 6
+	emailVerificationMaxFailures = 5
+	emailVerificationResendDelay = time.Minute
+)
+
+// TwoFactorChallengeError is returned by sign-in in place of tokens when the
+// password was right but the account needs a second factor.
+type TwoFactorChallengeError struct {
+	Challenge *model.TwoFactorChallenge
+}
+
+func (e *TwoFactorChallengeError) Error() string {
+	return ErrTwoFactorRequired.Error()
+}
+
+func (e *TwoFactorChallengeError) Unwrap() error {
+	return ErrTwoFactorRequired
+}
+
 type AuthService interface {
-	VerifyGoogleToken(ctx context.Context, idToken string) (*model.User, string, error)
-	LoginEmail(ctx context.Context, email, password string) (*model.User, string, error)
-	RegisterEmail(ctx context.Context, fullName, email, password string) (*model.User, string, error)
-	Logout(ctx context.Context, userID uuid.UUID) error
+	VerifyGoogleToken(ctx context.Context, idToken string, device model.DeviceInfo) (*model.User, *model.AuthTokens, error)
+	LoginEmail(ctx context.Context, email, password string, device model.DeviceInfo) (*model.User, *model.AuthTokens, error)
+	RegisterEmail(ctx context.Context, fullName, email, password string, device model.DeviceInfo) (*model.User, *model.AuthTokens, error)
+	// Refresh exchanges a refresh token for a new token pair. The presented
+	// refresh token is spent.
+	Refresh(ctx context.Context, refreshToken string, device model.DeviceInfo) (*model.User, *model.AuthTokens, error)
+	// Logout ends the session of the access token with the given jti.
+	Logout(ctx context.Context, sessionID uuid.UUID, jti string, expiresAt time.Time) error
+	// LogoutAll ends every session of the user.
+	LogoutAll(ctx context.Context, userID uuid.UUID) error
 	ForgotPassword(ctx context.Context, email string) error
 	ResetPassword(ctx context.Context, token, newPassword string) error
+	// SetupTwoFactorChallenge starts the enrollment a challenge asks for.
+	SetupTwoFactorChallenge(ctx context.Context, challengeToken string) (*model.TwoFactorSetup, error)
+	// VerifyTwoFactorChallenge completes a sign-in with a second factor code.
+	// Recovery codes are returned when the code confirmed a new enrollment.
+	VerifyTwoFactorChallenge(ctx context.Context, challengeToken, code string, device model.DeviceInfo) (*model.User, *model.AuthTokens, []string, error)
+	// VerifyEmail confirms an email address with the token of the emailed link.
+	VerifyEmail(ctx context.Context, token string) error
+	// VerifyEmailCode confirms the email address of a signed-in user with the
+	// emailed code.
+	VerifyEmailCode(ctx context.Context, userID uuid.UUID, code string) error
+	// ResendVerification sends a new verification email, which invalidates the
+	// previous one.
+	ResendVerification(ctx context.Context, userID uuid.UUID) error
+	// DeleteAccount closes the account of the user and ends all its sessions.
+	// Its downloads and files are purged by the worker afterwards.
+	DeleteAccount(ctx context.Context, userID uuid.UUID, req model.DeleteAccountRequest) error
 }
 
 type authService struct {
-	userRepo     repository.UserRepository
-	mailHelper   helpers.MailHelper
-	tokenService TokenService
-	redisClient  *redis.Client
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	mailHelper       helpers.MailHelper
+	tokenService     TokenService
+	twoFactorService TwoFactorService
+	redisClient      *redis.Client
 }
 
-func NewAuthService(userRepo repository.UserRepository, mailHelper helpers.MailHelper, tokenService TokenService, redisClient *redis.Client) AuthService {
+func NewAuthService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, mailHelper helpers.MailHelper, tokenService TokenService, twoFactorService TwoFactorService, redisClient *redis.Client) AuthService {
 	return &authService{
-		userRepo:     userRepo,
-		mailHelper:   mailHelper,
-		tokenService: tokenService,
-		redisClient:  redisClient,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		mailHelper:       mailHelper,
+		tokenService:     tokenService,
+		twoFactorService: twoFactorService,
+		redisClient:      redisClient,
 	}
 }
 
-func (s *authService) VerifyGoogleToken(ctx context.Context, idToken string) (*model.User, string, error) {
+func (s *authService) VerifyGoogleToken(ctx context.Context, idToken string, device model.DeviceInfo) (*model.User, *model.AuthTokens, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
 
 	payload, err := idtoken.Validate(subCtx, idToken, "")
 	if err != nil {
-		return nil, "", fmt.Errorf("invalid google token: %w", err)
+		return nil, nil, fmt.Errorf("invalid google token: %w", err)
 	}
 
 	email := payload.Claims["email"].(string)
 	name := payload.Claims["name"].(string)
 	picture := payload.Claims["picture"].(string)
+	// Google has confirmed the address itself for most accounts.
+	emailVerified, _ := payload.Claims["email_verified"].(bool)
 
 	user, err := s.userRepo.FindByEmail(subCtx, email)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
+	}
+
+	if user != nil && user.EmailVerifiedAt == nil && emailVerified {
+		if _, err := s.userRepo.MarkEmailVerified(subCtx, user.ID); err != nil {
+			return nil, nil, err
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		s.clearEmailVerification(subCtx, user.ID)
 	}
 
 	if user == nil {
@@ -68,76 +159,72 @@ func (s *authService) VerifyGoogleToken(ctx context.Context, idToken string) (*m
 			RoleID:    nil,
 			IsActive:  true,
 		}
+		if emailVerified {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
 		if err := s.userRepo.Create(subCtx, user, ""); err != nil {
-			return nil, "", err
+			return nil, nil, err
 		}
 
 		fullUser, err := s.userRepo.FindByID(subCtx, user.ID)
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to fetch user details after creation: %w", err)
+			return nil, nil, fmt.Errorf("failed to fetch user details after creation: %w", err)
 		}
 		user = fullUser
 	}
 
-	_ = s.userRepo.UpdateLastLogin(subCtx, user.ID)
-
-	accessToken, err := s.tokenService.GenerateAccessToken(user)
+	tokens, err := s.signIn(subCtx, user, device)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, accessToken, nil
+	return user, tokens, nil
 }
 
-func (s *authService) Logout(ctx context.Context, userID uuid.UUID) error {
-	return nil
-}
-
-func (s *authService) LoginEmail(ctx context.Context, email, password string) (*model.User, string, error) {
+func (s *authService) LoginEmail(ctx context.Context, email, password string, device model.DeviceInfo) (*model.User, *model.AuthTokens, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
 
 	user, err := s.userRepo.FindByEmail(subCtx, email)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 	if user == nil {
-		return nil, "", fmt.Errorf("invalid credentials")
+		return nil, nil, fmt.Errorf("invalid credentials")
 	}
 
 	if user.PasswordHash == nil {
-		return nil, "", fmt.Errorf("invalid credentials")
+		return nil, nil, fmt.Errorf("invalid credentials")
 	}
 
 	if !utils.CheckPasswordHash(password, *user.PasswordHash) {
-		return nil, "", fmt.Errorf("invalid credentials")
+		return nil, nil, fmt.Errorf("invalid credentials")
 	}
 
-	_ = s.userRepo.UpdateLastLogin(subCtx, user.ID)
-
-	accessToken, err := s.tokenService.GenerateAccessToken(user)
+	tokens, err := s.signIn(subCtx, user, device)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, accessToken, nil
+	return user, tokens, nil
 }
 
-func (s *authService) RegisterEmail(ctx context.Context, fullName, email, password string) (*model.User, string, error) {
+func (s *authService) RegisterEmail(ctx context.Context, fullName, email, password string, device model.DeviceInfo) (*model.User, *model.AuthTokens, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
 
 	existing, err := s.userRepo.FindByEmail(subCtx, email)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 	if existing != nil {
-		return nil, "", fmt.Errorf("email already registered")
+		return nil, nil, fmt.Errorf("email already registered")
 	}
 
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	user := &model.User{
@@ -147,23 +234,517 @@ func (s *authService) RegisterEmail(ctx context.Context, fullName, email, passwo
 		IsActive: true,
 	}
 	if err := s.userRepo.Create(subCtx, user, hashedPassword); err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	fullUser, err := s.userRepo.FindByID(subCtx, user.ID)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to fetch user details after creation: %w", err)
+		return nil, nil, fmt.Errorf("failed to fetch user details after creation: %w", err)
 	}
 	if fullUser != nil {
 		user = fullUser
 	}
 
-	accessToken, err := s.tokenService.GenerateAccessToken(user)
+	// The account works unverified, with limits, so a mail outage must not
+	// fail the sign-up; the user can ask for the email again.
+	s.redisClient.Set(subCtx, emailVerificationResendKey(user.ID), 1, emailVerificationResendDelay)
+	if err := s.sendEmailVerification(subCtx, user); err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to send verification email")
+	}
+
+	tokens, err := s.startSession(subCtx, user, device)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// signIn opens a session for a user whose credentials were verified, unless
+// the account needs a second factor first. Then a TwoFactorChallengeError is
+// returned instead.
+func (s *authService) signIn(ctx context.Context, user *model.User, device model.DeviceInfo) (*model.AuthTokens, error) {
+	required, err := s.twoFactorService.Required(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabledAt != nil || required {
+		state := model.TwoFactorChallengeState{
+			UserID:             user.ID,
+			EnrollmentRequired: user.TwoFactorEnabledAt == nil,
+		}
+		token, err := utils.GenerateRandomString(48)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(state)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.redisClient.Set(ctx, twoFactorChallengeKey(token), data, twoFactorChallengeTTL).Err(); err != nil {
+			return nil, err
+		}
+		return nil, &TwoFactorChallengeError{Challenge: &model.TwoFactorChallenge{
+			ChallengeToken:     token,
+			EnrollmentRequired: state.EnrollmentRequired,
+			ExpiresIn:          int(twoFactorChallengeTTL.Seconds()),
+		}}
+	}
+
+	_ = s.userRepo.UpdateLastLogin(ctx, user.ID)
+	return s.startSession(ctx, user, device)
+}
+
+func (s *authService) SetupTwoFactorChallenge(ctx context.Context, challengeToken string) (*model.TwoFactorSetup, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	state, err := s.twoFactorChallenge(subCtx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !state.EnrollmentRequired {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	return s.twoFactorService.Setup(subCtx, state.UserID)
+}
+
+func (s *authService) VerifyTwoFactorChallenge(ctx context.Context, challengeToken, code string, device model.DeviceInfo) (*model.User, *model.AuthTokens, []string, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	state, err := s.twoFactorChallenge(subCtx, challengeToken)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	user, err := s.userRepo.FindByID(subCtx, state.UserID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if user == nil || !user.IsActive {
+		s.redisClient.Del(subCtx, twoFactorChallengeKey(challengeToken))
+		return nil, nil, nil, ErrInvalidTwoFactorChallenge
+	}
+
+	var recoveryCodes []string
+	if state.EnrollmentRequired {
+		recoveryCodes, err = s.twoFactorService.Enable(subCtx, user.ID, code)
+	} else {
+		err = s.twoFactorService.Verify(subCtx, user.ID, code)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// A challenge completes one sign-in only.
+	deleted, err := s.redisClient.Del(subCtx, twoFactorChallengeKey(challengeToken)).Result()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if deleted == 0 {
+		return nil, nil, nil, ErrInvalidTwoFactorChallenge
+	}
+
+	_ = s.userRepo.UpdateLastLogin(subCtx, user.ID)
+	tokens, err := s.startSession(subCtx, user, device)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return user, tokens, recoveryCodes, nil
+}
+
+func (s *authService) twoFactorChallenge(ctx context.Context, challengeToken string) (*model.TwoFactorChallengeState, error) {
+	data, err := s.redisClient.Get(ctx, twoFactorChallengeKey(challengeToken)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state model.TwoFactorChallengeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid two-factor challenge: %w", err)
+	}
+	return &state, nil
+}
+
+func twoFactorChallengeKey(token string) string {
+	return fmt.Sprintf("auth:2fa_challenge:%s", token)
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, token string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	userIDStr, err := s.redisClient.Get(subCtx, emailVerificationTokenKey(token)).Result()
+	if err == redis.Nil {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid user id in verification token: %w", err)
+	}
+
+	return s.markEmailVerified(subCtx, userID)
+}
+
+func (s *authService) VerifyEmailCode(ctx context.Context, userID uuid.UUID, code string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	state, err := s.emailVerification(subCtx, userID)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		user, err := s.userRepo.FindByID(subCtx, userID)
+		if err != nil {
+			return err
+		}
+		if user != nil && user.EmailVerifiedAt != nil {
+			return ErrEmailAlreadyVerified
+		}
+		return ErrVerificationExpired
+	}
+
+	if subtle.ConstantTimeCompare([]byte(state.Code), []byte(code)) != 1 {
+		key := emailVerificationFailuresKey(userID)
+		failures, err := s.redisClient.Incr(subCtx, key).Result()
+		if err != nil {
+			return err
+		}
+		if failures == 1 {
+			s.redisClient.Expire(subCtx, key, emailVerificationTTL)
+		}
+		// Six digits do not survive unlimited guesses; the code is spent.
+		if failures >= emailVerificationMaxFailures {
+			s.clearEmailVerification(subCtx, userID)
+			return ErrTooManyVerificationAttempts
+		}
+		return ErrInvalidVerificationCode
+	}
+
+	return s.markEmailVerified(subCtx, userID)
+}
+
+func (s *authService) ResendVerification(ctx context.Context, userID uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	user, err := s.userRepo.FindByID(subCtx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.DeletedAt != nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	sent, err := s.redisClient.SetNX(subCtx, emailVerificationResendKey(userID), 1, emailVerificationResendDelay).Result()
+	if err != nil {
+		return err
+	}
+	if !sent {
+		return ErrVerificationResendTooSoon
+	}
+	return s.sendEmailVerification(subCtx, user)
+}
+
+// sendEmailVerification emails a new link and code to the user, replacing
+// the ones sent before.
+func (s *authService) sendEmailVerification(ctx context.Context, user *model.User) error {
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+	code, err := utils.GenerateNumericCode(emailVerificationCodeDigits)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(model.EmailVerificationState{Token: token, Code: code})
+	if err != nil {
+		return err
+	}
+
+	s.clearEmailVerification(ctx, user.ID)
+	if err := s.redisClient.Set(ctx, emailVerificationTokenKey(token), user.ID.String(), emailVerificationTTL).Err(); err != nil {
+		return err
+	}
+	if err := s.redisClient.Set(ctx, emailVerificationKey(user.ID), data, emailVerificationTTL).Err(); err != nil {
+		return err
+	}
+
+	return s.mailHelper.SendVerificationEmail(ctx, user.Email, token, code, emailVerificationTTL)
+}
+
+func (s *authService) markEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	verified, err := s.userRepo.MarkEmailVerified(ctx, userID)
+	if err != nil {
+		return err
+	}
+	s.clearEmailVerification(ctx, userID)
+	if !verified {
+		return ErrEmailAlreadyVerified
+	}
+	return nil
+}
+
+func (s *authService) emailVerification(ctx context.Context, userID uuid.UUID) (*model.EmailVerificationState, error) {
+	data, err := s.redisClient.Get(ctx, emailVerificationKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state model.EmailVerificationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid email verification: %w", err)
+	}
+	return &state, nil
+}
+
+// clearEmailVerification invalidates the link and code last sent to the user.
+// Leftovers expire on their own, so errors are ignored.
+func (s *authService) clearEmailVerification(ctx context.Context, userID uuid.UUID) {
+	keys := []string{emailVerificationKey(userID), emailVerificationFailuresKey(userID)}
+	if state, err := s.emailVerification(ctx, userID); err == nil && state != nil {
+		keys = append(keys, emailVerificationTokenKey(state.Token))
+	}
+	s.redisClient.Del(ctx, keys...)
+}
+
+func emailVerificationKey(userID uuid.UUID) string {
+	return fmt.Sprintf("auth:email_verification:%s", userID)
+}
+
+func emailVerificationTokenKey(token string) string {
+	return fmt.Sprintf("auth:email_verification_token:%s", token)
+}
+
+func emailVerificationFailuresKey(userID uuid.UUID) string {
+	return fmt.Sprintf("auth:email_verification_failures:%s", userID)
+}
+
+func emailVerificationResendKey(userID uuid.UUID) string {
+	return fmt.Sprintf("auth:email_verification_resend:%s", userID)
+}
+
+func (s *authService) DeleteAccount(ctx context.Context, userID uuid.UUID, req model.DeleteAccountRequest) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	user, err := s.userRepo.FindByID(subCtx, userID)
 	if err != nil {
-		return nil, "", err
+		return err
+	}
+	if user == nil || user.DeletedAt != nil {
+		return ErrUserNotFound
+	}
+	// A stolen access token alone must not be enough to wipe an account.
+	if user.PasswordHash != nil {
+		if !utils.CheckPasswordHash(req.Password, *user.PasswordHash) {
+			return ErrIncorrectPassword
+		}
+	} else if err := s.confirmGoogleReauth(subCtx, user, req.Credential); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SoftDelete(subCtx, userID); err != nil {
+		return err
 	}
+	s.clearEmailVerification(subCtx, userID)
 
-	return user, accessToken, nil
+	if err := s.LogoutAll(subCtx, userID); err != nil {
+		log.Error().Err(err).Str("user_id", userID.String()).Msg("Failed to end sessions of deleted account")
+	}
+	log.Info().Str("user_id", userID.String()).Msg("Account deleted by its owner")
+	return nil
+}
+
+// confirmGoogleReauth accepts a Google ID token for the user's own address
+// that was issued moments ago, proving the owner just signed in again.
+func (s *authService) confirmGoogleReauth(ctx context.Context, user *model.User, credential string) error {
+	if credential == "" {
+		return ErrReauthenticationRequired
+	}
+	payload, err := idtoken.Validate(ctx, credential, "")
+	if err != nil {
+		return ErrReauthenticationRequired
+	}
+	email, _ := payload.Claims["email"].(string)
+	if email == "" || email != user.Email {
+		return ErrReauthenticationRequired
+	}
+	if time.Since(time.Unix(payload.IssuedAt, 0)) > googleReauthMaxAge {
+		return ErrReauthenticationRequired
+	}
+	return nil
+}
+
+// startSession opens a new session for the user. Signing in again from a
+// device ends the sessions it had before.
+func (s *authService) startSession(ctx context.Context, user *model.User, device model.DeviceInfo) (*model.AuthTokens, error) {
+	if device.DeviceID != "" {
+		families, err := s.refreshTokenRepo.RevokeDevice(ctx, user.ID, device.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.tokenService.RevokeSessions(ctx, families...); err != nil {
+			log.Warn().Err(err).Str("user_id", user.ID.String()).Msg("Failed to revoke previous device sessions")
+		}
+	}
+
+	refreshToken, tokenHash, err := s.tokenService.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	stored := newRefreshToken(user.ID, uuid.New(), tokenHash, device, s.tokenService.RefreshTokenTTL())
+	if err := s.refreshTokenRepo.Create(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, stored.FamilyID, refreshToken)
+}
+
+func (s *authService) issueTokens(user *model.User, sessionID uuid.UUID, refreshToken string) (*model.AuthTokens, error) {
+	accessToken, err := s.tokenService.GenerateAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.tokenService.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+func newRefreshToken(userID, familyID uuid.UUID, tokenHash string, device model.DeviceInfo, ttl time.Duration) *model.RefreshToken {
+	token := &model.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if device.DeviceID != "" {
+		token.DeviceID = &device.DeviceID
+	}
+	if device.UserAgent != "" {
+		token.UserAgent = &device.UserAgent
+	}
+	if device.IPAddress != "" {
+		token.IPAddress = &device.IPAddress
+	}
+	return token
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string, device model.DeviceInfo) (*model.User, *model.AuthTokens, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	current, err := s.refreshTokenRepo.FindByHash(subCtx, s.tokenService.HashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	if current == nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if current.RevokedAt != nil {
+		if current.ReplacedBy == nil {
+			// Ended by a logout, not by a rotation.
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		log.Warn().Str("user_id", current.UserID.String()).Str("session_id", current.FamilyID.String()).Msg("Refresh token reuse detected, revoking session")
+		s.endSession(subCtx, current.FamilyID)
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.FindByID(subCtx, current.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || !user.IsActive {
+		s.endSession(subCtx, current.FamilyID)
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	// The device id is fixed at sign-in; only the network details move.
+	device.DeviceID = ""
+	if current.DeviceID != nil {
+		device.DeviceID = *current.DeviceID
+	}
+
+	nextToken, tokenHash, err := s.tokenService.GenerateRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	next := newRefreshToken(user.ID, current.FamilyID, tokenHash, device, s.tokenService.RefreshTokenTTL())
+	rotated, err := s.refreshTokenRepo.Rotate(subCtx, current.ID, next)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rotated {
+		log.Warn().Str("user_id", current.UserID.String()).Str("session_id", current.FamilyID.String()).Msg("Concurrent refresh token reuse detected, revoking session")
+		s.endSession(subCtx, current.FamilyID)
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	tokens, err := s.issueTokens(user, current.FamilyID, nextToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// endSession revokes a refresh token family and the access tokens issued from
+// it. It is used on paths that already fail, so errors are only logged.
+func (s *authService) endSession(ctx context.Context, familyID uuid.UUID) {
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, familyID); err != nil {
+		log.Error().Err(err).Str("session_id", familyID.String()).Msg("Failed to revoke refresh token family")
+	}
+	if err := s.tokenService.RevokeSessions(ctx, familyID); err != nil {
+		log.Error().Err(err).Str("session_id", familyID.String()).Msg("Failed to revoke session")
+	}
+}
+
+func (s *authService) Logout(ctx context.Context, sessionID uuid.UUID, jti string, expiresAt time.Time) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	if err := s.tokenService.RevokeToken(subCtx, jti, expiresAt); err != nil {
+		return err
+	}
+	// Tokens issued before sessions existed carry no session id.
+	if sessionID == uuid.Nil {
+		return nil
+	}
+	if err := s.refreshTokenRepo.RevokeFamily(subCtx, sessionID); err != nil {
+		return err
+	}
+	return s.tokenService.RevokeSessions(subCtx, sessionID)
+}
+
+func (s *authService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	families, err := s.refreshTokenRepo.RevokeUser(subCtx, userID)
+	if err != nil {
+		return err
+	}
+	return s.tokenService.RevokeSessions(subCtx, families...)
 }
 
 func (s *authService) ForgotPassword(ctx context.Context, email string) error {
@@ -232,5 +813,10 @@ func (s *authService) ResetPassword(ctx context.Context, token, newPassword stri
 
 	s.redisClient.Del(subCtx, key)
 
+	// Whoever knew the old password must not stay signed in.
+	if err := s.LogoutAll(subCtx, userID); err != nil {
+		log.Error().Err(err).Str("user_id", userID.String()).Msg("Failed to end sessions after password reset")
+	}
+
 	return nil
 }
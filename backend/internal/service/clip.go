@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+var ErrInvalidClip = errors.New("invalid clip range")
+
+// minClipSeconds keeps clips long enough to hold at least one keyframe.
+const minClipSeconds = 1
+
+// resolveClip turns the start and end of a request into a clip of the video. It
+// returns nil when the request asks for the whole video. A missing start means
+// the beginning and a missing end the end of the video, which then has to have
+// a known length.
+func resolveClip(req model.DownloadRequest, info *infrastructure.VideoInfo) (*model.ClipRange, error) {
+	hasStart := req.Start != nil && strings.TrimSpace(*req.Start) != ""
+	hasEnd := req.End != nil && strings.TrimSpace(*req.End) != ""
+	if !hasStart && !hasEnd {
+		return nil, nil
+	}
+
+	var duration float64
+	if info != nil && info.Duration != nil {
+		duration = *info.Duration
+	}
+
+	clip := &model.ClipRange{End: duration}
+	if hasStart {
+		start, err := parseTimestamp(*req.Start)
+		if err != nil {
+			return nil, fmt.Errorf("%w: start: %w", ErrInvalidClip, err)
+		}
+		clip.Start = start
+	}
+	if hasEnd {
+		end, err := parseTimestamp(*req.End)
+		if err != nil {
+			return nil, fmt.Errorf("%w: end: %w", ErrInvalidClip, err)
+		}
+		clip.End = end
+	} else if duration <= 0 {
+		return nil, fmt.Errorf("%w: end is required when the video length is unknown", ErrInvalidClip)
+	}
+
+	if clip.Length() < minClipSeconds {
+		return nil, fmt.Errorf("%w: end must be at least %d second after start", ErrInvalidClip, minClipSeconds)
+	}
+	if duration > 0 && clip.End > duration {
+		return nil, fmt.Errorf("%w: end is past the end of the video (%s)", ErrInvalidClip, formatTimestamp(duration))
+	}
+	if duration > 0 && clip.Start == 0 && clip.End == duration {
+		// The whole video; no need to cut it.
+		return nil, nil
+	}
+	return clip, nil
+}
+
+// parseTimestamp reads seconds ("90", "90.5") or a timestamp with minutes and
+// optionally hours ("1:30", "01:02:30.5").
+func parseTimestamp(s string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("%q is not a timestamp", s)
+	}
+
+	var total float64
+	for i, part := range parts {
+		// Only the seconds may have a fraction.
+		if !isDecimal(part, i == len(parts)-1) {
+			return 0, fmt.Errorf("%q is not a timestamp", s)
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || (i > 0 && v >= 60) {
+			return 0, fmt.Errorf("%q is not a timestamp", s)
+		}
+		total = total*60 + v
+	}
+	return total, nil
+}
+
+func isDecimal(s string, fraction bool) bool {
+	digits, dots := 0, 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.' && fraction:
+			dots++
+		default:
+			return false
+		}
+	}
+	return digits > 0 && dots <= 1
+}
+
+func formatTimestamp(seconds float64) string {
+	total := int(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", total/3600, total%3600/60, total%60)
+}
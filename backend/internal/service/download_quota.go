@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+func (s *downloadService) quotaError(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits, reason, message string) error {
+	status, _ := s.quotaSvc.Status(ctx, subject, limits)
+	return &QuotaError{Reason: reason, Message: message, Quota: status}
+}
+
+// quotaLimits resolves the caller's plan and rejects premium platforms it does
+// not include.
+func (s *downloadService) quotaLimits(ctx context.Context, subject QuotaSubject, platform *model.Platform) (*model.QuotaLimits, error) {
+	limits, err := s.quotaSvc.Limits(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	if platform.IsPremium && !limits.PremiumPlatforms {
+		return nil, s.quotaError(ctx, subject, limits, QuotaReasonPremiumPlatform,
+			fmt.Sprintf("%s downloads require a premium subscription", platform.Name))
+	}
+	return limits, nil
+}
+
+func (s *downloadService) checkQuotaDuration(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits, info *infrastructure.VideoInfo, clip *model.ClipRange) error {
+	if limits.MaxDuration <= 0 {
+		return nil
+	}
+	// A clip only counts with its own length.
+	if clip != nil {
+		if clip.Length() > float64(limits.MaxDuration) {
+			return s.quotaError(ctx, subject, limits, QuotaReasonMaxDuration,
+				fmt.Sprintf("clips longer than %d minutes are not included in your plan", limits.MaxDuration/60))
+		}
+		return nil
+	}
+	if info == nil || info.Duration == nil {
+		return nil
+	}
+	if int(*info.Duration) > limits.MaxDuration {
+		return s.quotaError(ctx, subject, limits, QuotaReasonMaxDuration,
+			fmt.Sprintf("videos longer than %d minutes are not included in your plan", limits.MaxDuration/60))
+	}
+	return nil
+}
+
+// capQuotaResolution rejects explicit requests above the plan's maximum height and
+// otherwise narrows the download to it. The cap is only applied when the source
+// reports a taller format, so sources without height information keep working.
+func (s *downloadService) capQuotaResolution(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits, req *model.DownloadRequest, formats []infrastructure.FormatInfo) error {
+	maxHeight := limits.MaxHeight
+	if maxHeight <= 0 {
+		return nil
+	}
+	exceeded := func() error {
+		return s.quotaError(ctx, subject, limits, QuotaReasonMaxResolution,
+			fmt.Sprintf("resolutions above %dp are not included in your plan", maxHeight))
+	}
+
+	if req.MaxHeight != nil && *req.MaxHeight > maxHeight {
+		return exceeded()
+	}
+	if req.FormatID != nil && strings.TrimSpace(*req.FormatID) != "" {
+		id := strings.TrimSpace(*req.FormatID)
+		for _, f := range formats {
+			if f.FormatID == id && f.Height != nil && *f.Height > maxHeight {
+				return exceeded()
+			}
+		}
+		return nil
+	}
+	if req.MaxHeight != nil {
+		return nil
+	}
+
+	for _, f := range formats {
+		if hasVideoStream(f) && f.Height != nil && *f.Height > maxHeight {
+			req.MaxHeight = &maxHeight
+			break
+		}
+	}
+	return nil
+}
+
+// quotaBatchSize caps how many playlist entries are expanded to what is left of the
+// caller's daily quota.
+func (s *downloadService) quotaBatchSize(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits, maxItems int) (int, error) {
+	if limits.DailyDownloads <= 0 {
+		return maxItems, nil
+	}
+	status, err := s.quotaSvc.Status(ctx, subject, limits)
+	if err != nil {
+		return 0, err
+	}
+	if status.Remaining <= 0 {
+		return 0, &QuotaError{
+			Reason:  QuotaReasonDailyLimit,
+			Message: fmt.Sprintf("daily download limit of %d reached", limits.DailyDownloads),
+			Quota:   status,
+		}
+	}
+	if status.Remaining < maxItems {
+		return status.Remaining, nil
+	}
+	return maxItems, nil
+}
+
+// quotaBatchEntries drops playlist entries longer than the plan allows. Entries of
+// unknown length are kept; the batch only fails when nothing is left.
+func (s *downloadService) quotaBatchEntries(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits, entries []infrastructure.PlaylistEntry) ([]infrastructure.PlaylistEntry, error) {
+	if limits.MaxDuration <= 0 || len(entries) == 0 {
+		return entries, nil
+	}
+	kept := make([]infrastructure.PlaylistEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Duration != nil && int(*entry.Duration) > limits.MaxDuration {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	if len(kept) == 0 {
+		return nil, s.quotaError(ctx, subject, limits, QuotaReasonMaxDuration,
+			fmt.Sprintf("every entry is longer than the %d minutes your plan allows", limits.MaxDuration/60))
+	}
+	return kept, nil
+}
@@ -26,32 +26,53 @@ type DownloadService interface {
 	BulkDelete(ctx context.Context, ids []uuid.UUID) error
 	GetTaskCookies(ctx context.Context, taskID uuid.UUID) (map[string]string, error)
 	ProcessDownloadMp3(ctx context.Context, req model.DownloadRequest, userID *uuid.UUID, ip string) (*model.DownloadTask, error)
+	ProcessBatch(ctx context.Context, req model.BatchDownloadRequest, userID *uuid.UUID, ip string) (*model.DownloadBatch, error)
+	FindBatchByID(ctx context.Context, id uuid.UUID) (*model.DownloadBatch, error)
+	FindBatchForOwner(ctx context.Context, id uuid.UUID, userID *uuid.UUID, ip string) (*model.DownloadBatch, error)
+	Cancel(ctx context.Context, id uuid.UUID, userID *uuid.UUID, ip string) (*model.DownloadTask, error)
 }
 
+var (
+	ErrDownloadNotFound      = errors.New("download not found")
+	ErrDownloadNotOwned      = errors.New("download belongs to another user")
+	ErrDownloadNotCancelable = errors.New("download has already finished")
+)
+
+const defaultBatchMaxItems = 50
+
 type downloadService struct {
 	repo         repository.DownloadRepository
+	batchRepo    repository.DownloadBatchRepository
 	appRepo      repository.ApplicationRepository
 	platformRepo repository.PlatformRepository
 	downloader   infrastructure.DownloaderClient
 	taskClient   infrastructure.TaskClient
 	redisClient  *redis.Client
+	centrifugo   infrastructure.CentrifugoClient
+	quotaSvc     QuotaService
 }
 
 func NewDownloadService(
 	repo repository.DownloadRepository,
+	batchRepo repository.DownloadBatchRepository,
 	appRepo repository.ApplicationRepository,
 	platformRepo repository.PlatformRepository,
 	downloader infrastructure.DownloaderClient,
 	taskClient infrastructure.TaskClient,
 	redisClient *redis.Client,
+	centrifugo infrastructure.CentrifugoClient,
+	quotaSvc QuotaService,
 ) DownloadService {
 	return &downloadService{
 		repo:         repo,
+		batchRepo:    batchRepo,
 		appRepo:      appRepo,
 		platformRepo: platformRepo,
 		downloader:   downloader,
 		taskClient:   taskClient,
 		redisClient:  redisClient,
+		centrifugo:   centrifugo,
+		quotaSvc:     quotaSvc,
 	}
 }
 
@@ -74,10 +95,10 @@ func (s *downloadService) ProcessDownload(ctx context.Context, req model.Downloa
 			return nil, err
 		}
 		if appPtr == nil {
-			return nil, errors.New("application not found")
+			return nil, infrastructure.NewDownloadError(infrastructure.ErrCodeAppUnavailable, "application not found", nil)
 		}
 		if !appPtr.IsActive {
-			return nil, errors.New("application is not active")
+			return nil, infrastructure.NewDownloadError(infrastructure.ErrCodeAppUnavailable, "application is not active", nil)
 		}
 		appID = &appPtr.ID
 	}
@@ -88,20 +109,20 @@ func (s *downloadService) ProcessDownload(ctx context.Context, req model.Downloa
 	}
 
 	if !platform.IsActive {
-		return nil, errors.New("platform is not active")
+		return nil, infrastructure.NewDownloadError(infrastructure.ErrCodePlatformDisabled, "platform is not active", nil)
+	}
+
+	subject := QuotaSubject{UserID: userID, AppID: appID, IP: ip}
+	limits, err := s.quotaLimits(subCtx, subject, platform)
+	if err != nil {
+		return nil, err
 	}
 
 	normalizedType := strings.ToLower(platform.Type)
 	isYouTube := normalizedType == "youtube" || strings.Contains(strings.ToLower(req.Type), "youtube") ||
 		strings.Contains(strings.ToLower(req.URL), "youtube.com") || strings.Contains(strings.ToLower(req.URL), "youtu.be")
 
-	if typeAware, ok := s.downloader.(interface {
-		GetVideoInfoWithType(ctx context.Context, url string, downloadType string) (*infrastructure.VideoInfo, error)
-	}); ok {
-		info, err = typeAware.GetVideoInfoWithType(subCtx, req.URL, platform.Type)
-	} else {
-		info, err = s.downloader.GetVideoInfo(subCtx, req.URL)
-	}
+	info, err = s.fetchVideoInfo(subCtx, req.URL, platform)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			log.Error().
@@ -116,6 +137,25 @@ func (s *downloadService) ProcessDownload(ctx context.Context, req model.Downloa
 		info = &infrastructure.VideoInfo{Extractor: "youtube"}
 	}
 
+	var sourceFormats []infrastructure.FormatInfo
+	if info != nil {
+		sourceFormats = info.Formats
+	}
+	clip, err := resolveClip(req, info)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkQuotaDuration(subCtx, subject, limits, info, clip); err != nil {
+		return nil, err
+	}
+	if err := s.capQuotaResolution(subCtx, subject, limits, &req, sourceFormats); err != nil {
+		return nil, err
+	}
+	selection, err := resolveFormatSelection(req, sourceFormats)
+	if err != nil {
+		return nil, err
+	}
+
 	if info != nil && len(info.Formats) > 0 {
 		// Filter formats logic
 		var formatsToProcess []infrastructure.FormatInfo
@@ -185,9 +225,13 @@ func (s *downloadService) ProcessDownload(ctx context.Context, req model.Downloa
 				Tbr:      f.Tbr,
 			})
 		}
+		formats = filterSelectedFormats(formats, selection)
 	}
 
 	format := "mp4"
+	if selection != nil {
+		format = selection.Container
+	}
 	title := ""
 	thumbnailURL := ""
 	filePath := ""
@@ -222,11 +266,20 @@ func (s *downloadService) ProcessDownload(ctx context.Context, req model.Downloa
 		Duration:     duration,
 		FileSize:     fileSize,
 		Formats:      formats,
+		Selection:    selection,
+		Clip:         clip,
 		IPAddress:    &ip,
 		CreatedAt:    time.Now(),
 	}
 
+	quota, err := s.quotaSvc.Reserve(subCtx, subject, limits, 1)
+	if err != nil {
+		return nil, err
+	}
+	task.Quota = quota
+
 	if err := s.repo.Create(subCtx, task); err != nil {
+		s.quotaSvc.Release(subCtx, subject, 1)
 		return nil, err
 	}
 
@@ -236,11 +289,12 @@ func (s *downloadService) ProcessDownload(ctx context.Context, req model.Downloa
 			Str("url", task.OriginalURL).
 			Msg("Enqueuing video download task")
 
-		if err := s.taskClient.EnqueueVideoDownload(task); err != nil {
+		if err := s.taskClient.EnqueueVideoDownload(task, limits.Premium()); err != nil {
 			log.Error().
 				Err(err).
 				Str("task_id", task.ID.String()).
 				Msg("Failed to enqueue video download task")
+			s.quotaSvc.Release(subCtx, subject, 1)
 			return nil, err
 		}
 
@@ -252,6 +306,29 @@ func (s *downloadService) ProcessDownload(ctx context.Context, req model.Downloa
 	return task, nil
 }
 
+// fetchVideoInfo resolves metadata through the strategy chain, honouring the
+// platform's routing config when the downloader supports it.
+func (s *downloadService) fetchVideoInfo(ctx context.Context, url string, platform *model.Platform) (*infrastructure.VideoInfo, error) {
+	routing, err := infrastructure.ParseStrategyRouting(platform.Config)
+	if err != nil {
+		log.Warn().Err(err).Str("platform", platform.Type).Msg("Invalid downloader routing config; using default strategy order")
+	}
+
+	if routingAware, ok := s.downloader.(interface {
+		GetVideoInfoWithRouting(ctx context.Context, url string, downloadType string, routing *infrastructure.StrategyRouting) (*infrastructure.VideoInfo, error)
+	}); ok {
+		return routingAware.GetVideoInfoWithRouting(ctx, url, platform.Type, routing)
+	}
+
+	if typeAware, ok := s.downloader.(interface {
+		GetVideoInfoWithType(ctx context.Context, url string, downloadType string) (*infrastructure.VideoInfo, error)
+	}); ok {
+		return typeAware.GetVideoInfoWithType(ctx, url, platform.Type)
+	}
+
+	return s.downloader.GetVideoInfo(ctx, url)
+}
+
 func (s *downloadService) GetUserHistory(ctx context.Context, userID uuid.UUID, page, limit int) ([]*model.DownloadTask, error) {
 	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
 	defer cancel()
@@ -352,10 +429,10 @@ func (s *downloadService) ProcessDownloadMp3(ctx context.Context, req model.Down
 			return nil, err
 		}
 		if appPtr == nil {
-			return nil, errors.New("application not found")
+			return nil, infrastructure.NewDownloadError(infrastructure.ErrCodeAppUnavailable, "application not found", nil)
 		}
 		if !appPtr.IsActive {
-			return nil, errors.New("application is not active")
+			return nil, infrastructure.NewDownloadError(infrastructure.ErrCodeAppUnavailable, "application is not active", nil)
 		}
 		appID = &appPtr.ID
 	}
@@ -366,20 +443,20 @@ func (s *downloadService) ProcessDownloadMp3(ctx context.Context, req model.Down
 	}
 
 	if !platform.IsActive {
-		return nil, errors.New("platform is not active")
+		return nil, infrastructure.NewDownloadError(infrastructure.ErrCodePlatformDisabled, "platform is not active", nil)
+	}
+
+	subject := QuotaSubject{UserID: userID, AppID: appID, IP: ip}
+	limits, err := s.quotaLimits(subCtx, subject, platform)
+	if err != nil {
+		return nil, err
 	}
 
 	normalizedType := strings.ToLower(platform.Type)
 	isYouTube := normalizedType == "youtube" || strings.Contains(strings.ToLower(req.Type), "youtube") ||
 		strings.Contains(strings.ToLower(req.URL), "youtube.com") || strings.Contains(strings.ToLower(req.URL), "youtu.be")
 
-	if typeAware, ok := s.downloader.(interface {
-		GetVideoInfoWithType(ctx context.Context, url string, downloadType string) (*infrastructure.VideoInfo, error)
-	}); ok {
-		info, err = typeAware.GetVideoInfoWithType(subCtx, req.URL, platform.Type)
-	} else {
-		info, err = s.downloader.GetVideoInfo(subCtx, req.URL)
-	}
+	info, err = s.fetchVideoInfo(subCtx, req.URL, platform)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			log.Error().
@@ -394,6 +471,14 @@ func (s *downloadService) ProcessDownloadMp3(ctx context.Context, req model.Down
 		info = &infrastructure.VideoInfo{Extractor: "youtube"}
 	}
 
+	clip, err := resolveClip(req, info)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkQuotaDuration(subCtx, subject, limits, info, clip); err != nil {
+		return nil, err
+	}
+
 	if info != nil && len(info.Formats) > 0 {
 		// Filter formats logic
 		var formatsToProcess []infrastructure.FormatInfo
@@ -500,11 +585,19 @@ func (s *downloadService) ProcessDownloadMp3(ctx context.Context, req model.Down
 		Duration:     duration,
 		FileSize:     fileSize,
 		Formats:      nil,
+		Clip:         clip,
 		IPAddress:    &ip,
 		CreatedAt:    time.Now(),
 	}
 
+	quota, err := s.quotaSvc.Reserve(subCtx, subject, limits, 1)
+	if err != nil {
+		return nil, err
+	}
+	task.Quota = quota
+
 	if err := s.repo.Create(subCtx, task); err != nil {
+		s.quotaSvc.Release(subCtx, subject, 1)
 		return nil, err
 	}
 
@@ -514,11 +607,12 @@ func (s *downloadService) ProcessDownloadMp3(ctx context.Context, req model.Down
 			Str("url", task.OriginalURL).
 			Msg("Enqueuing mp3 download task")
 
-		if err := s.taskClient.EnqueueMp3Download(task); err != nil {
+		if err := s.taskClient.EnqueueMp3Download(task, limits.Premium()); err != nil {
 			log.Error().
 				Err(err).
 				Str("task_id", task.ID.String()).
 				Msg("Failed to enqueue mp3 download task")
+			s.quotaSvc.Release(subCtx, subject, 1)
 			return nil, err
 		}
 
@@ -529,3 +623,338 @@ func (s *downloadService) ProcessDownloadMp3(ctx context.Context, req model.Down
 
 	return task, nil
 }
+
+func (s *downloadService) ProcessBatch(ctx context.Context, req model.BatchDownloadRequest, userID *uuid.UUID, ip string) (*model.DownloadBatch, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 90*time.Second)
+	defer cancel()
+
+	var appID *uuid.UUID
+	if req.AppID != nil && *req.AppID != "" {
+		id, err := uuid.Parse(*req.AppID)
+		if err != nil {
+			return nil, err
+		}
+		appPtr, err := s.appRepo.FindByID(subCtx, id)
+		if err != nil {
+			return nil, err
+		}
+		if appPtr == nil {
+			return nil, infrastructure.NewDownloadError(infrastructure.ErrCodeAppUnavailable, "application not found", nil)
+		}
+		if !appPtr.IsActive {
+			return nil, infrastructure.NewDownloadError(infrastructure.ErrCodeAppUnavailable, "application is not active", nil)
+		}
+		appID = &appPtr.ID
+	}
+
+	platform, err := s.platformRepo.FindByType(subCtx, req.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if !platform.IsActive {
+		return nil, infrastructure.NewDownloadError(infrastructure.ErrCodePlatformDisabled, "platform is not active", nil)
+	}
+
+	subject := QuotaSubject{UserID: userID, AppID: appID, IP: ip}
+	limits, err := s.quotaLimits(subCtx, subject, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	playlistAware, ok := s.downloader.(interface {
+		GetPlaylistEntries(ctx context.Context, url string, limit int) (*infrastructure.PlaylistInfo, error)
+	})
+	if !ok {
+		return nil, infrastructure.NewDownloadError(infrastructure.ErrCodeUnsupportedURL, "downloader does not support playlist expansion", nil)
+	}
+
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = "mp4"
+		if strings.HasSuffix(strings.ToLower(platform.Type), "-to-mp3") {
+			format = "mp3"
+		}
+	}
+
+	maxItems := req.MaxItems
+	if maxItems <= 0 {
+		maxItems = defaultBatchMaxItems
+	}
+	maxItems, err = s.quotaBatchSize(subCtx, subject, limits, maxItems)
+	if err != nil {
+		return nil, err
+	}
+
+	playlist, err := playlistAware.GetPlaylistEntries(subCtx, req.URL, maxItems)
+	if err != nil {
+		log.Error().Err(err).Str("url", req.URL).Msg("Failed to enumerate playlist entries")
+		return nil, err
+	}
+
+	entries, err := s.quotaBatchEntries(subCtx, subject, limits, playlist.Entries)
+	if err != nil {
+		return nil, err
+	}
+	quota, err := s.quotaSvc.Reserve(subCtx, subject, limits, len(entries))
+	if err != nil {
+		return nil, err
+	}
+	released := 0
+
+	platformID := platform.ID
+	var title *string
+	if playlist.Title != "" {
+		t := playlist.Title
+		title = &t
+	}
+
+	batch := &model.DownloadBatch{
+		UserID:       userID,
+		AppID:        appID,
+		PlatformID:   &platformID,
+		PlatformType: platform.Type,
+		OriginalURL:  req.URL,
+		Title:        title,
+		Kind:         playlist.Kind,
+		Format:       format,
+		Status:       "queued",
+		TotalItems:   len(entries),
+		IPAddress:    &ip,
+		Quota:        quota,
+	}
+
+	if err := s.batchRepo.Create(subCtx, batch); err != nil {
+		s.quotaSvc.Release(subCtx, subject, len(entries))
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		entryTitle := entry.Title
+		entryFormat := format
+		var duration *int
+		if entry.Duration != nil && *entry.Duration > 0 {
+			d := int(*entry.Duration)
+			duration = &d
+		}
+
+		task := &model.DownloadTask{
+			UserID:       userID,
+			AppID:        appID,
+			OriginalURL:  entry.URL,
+			PlatformID:   platform.ID,
+			PlatformType: platform.Type,
+			Status:       "queued",
+			Title:        &entryTitle,
+			Format:       &entryFormat,
+			Duration:     duration,
+			IPAddress:    &ip,
+			BatchID:      &batch.ID,
+			CreatedAt:    time.Now(),
+		}
+
+		if err := s.repo.Create(subCtx, task); err != nil {
+			log.Error().Err(err).Str("batch_id", batch.ID.String()).Str("url", entry.URL).Msg("Failed to create batch child task")
+			released++
+			continue
+		}
+
+		if s.taskClient != nil {
+			var enqueueErr error
+			if format == "mp3" {
+				enqueueErr = s.taskClient.EnqueueMp3Download(task, limits.Premium())
+			} else {
+				enqueueErr = s.taskClient.EnqueueVideoDownload(task, limits.Premium())
+			}
+			if enqueueErr != nil {
+				log.Error().
+					Err(enqueueErr).
+					Str("batch_id", batch.ID.String()).
+					Str("task_id", task.ID.String()).
+					Msg("Failed to enqueue batch child task")
+
+				errMsg := enqueueErr.Error()
+				errCode := infrastructure.ErrCodeInternal
+				task.Status = "failed"
+				task.ErrorMessage = &errMsg
+				task.ErrorCode = &errCode
+				if err := s.repo.Update(subCtx, task); err != nil {
+					log.Error().Err(err).Str("task_id", task.ID.String()).Msg("Failed to mark batch child task as failed")
+				}
+				released++
+			}
+		}
+
+		batch.Tasks = append(batch.Tasks, task)
+	}
+
+	if released > 0 {
+		s.quotaSvc.Release(subCtx, subject, released)
+		if batch.Quota != nil {
+			batch.Quota, _ = s.quotaSvc.Status(subCtx, subject, limits)
+		}
+	}
+
+	// Child tasks that could not be created never count towards completion.
+	if len(batch.Tasks) != batch.TotalItems {
+		batch.TotalItems = len(batch.Tasks)
+		if batch.TotalItems == 0 {
+			batch.Status = "failed"
+		}
+		if err := s.batchRepo.UpdateStatus(subCtx, batch.ID, batch.Status, batch.TotalItems); err != nil {
+			return nil, err
+		}
+	}
+
+	// Children that failed to enqueue are already final, so the batch may be
+	// partly (or entirely) done before any worker picks it up.
+	if released > 0 && len(batch.Tasks) > 0 {
+		refreshed, err := s.batchRepo.RefreshProgress(subCtx, batch.ID)
+		if err != nil {
+			log.Warn().Err(err).Str("batch_id", batch.ID.String()).Msg("Failed to refresh batch progress after enqueue failures")
+		} else if refreshed != nil {
+			batch.Status = refreshed.Status
+			batch.CompletedItems = refreshed.CompletedItems
+			batch.FailedItems = refreshed.FailedItems
+			batch.UpdatedAt = refreshed.UpdatedAt
+		}
+	}
+
+	log.Info().
+		Str("batch_id", batch.ID.String()).
+		Str("url", req.URL).
+		Int("items", batch.TotalItems).
+		Msg("Successfully enqueued batch download")
+
+	return batch, nil
+}
+
+func (s *downloadService) FindBatchByID(ctx context.Context, id uuid.UUID) (*model.DownloadBatch, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	batch, err := s.batchRepo.FindByID(subCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, errors.New("download batch not found")
+	}
+
+	tasks, err := s.repo.FindByBatchID(subCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	batch.Tasks = tasks
+
+	return batch, nil
+}
+
+// FindBatchForOwner returns a batch only to whoever started it, using the same
+// rule as Cancel.
+func (s *downloadService) FindBatchForOwner(ctx context.Context, id uuid.UUID, userID *uuid.UUID, ip string) (*model.DownloadBatch, error) {
+	batch, err := s.FindBatchByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ownedBy(batch.UserID, batch.IPAddress, userID, ip) {
+		return nil, ErrDownloadNotOwned
+	}
+	return batch, nil
+}
+
+// ownedBy matches the requester against a download's user, falling back to the
+// requesting IP when the download was made anonymously.
+func ownedBy(ownerID *uuid.UUID, ownerIP *string, userID *uuid.UUID, ip string) bool {
+	if ownerID != nil {
+		return userID != nil && *userID == *ownerID
+	}
+	return ownerIP != nil && *ownerIP == ip
+}
+
+// Cancel stops a download on behalf of its owner: the user it belongs to or, for
+// downloads made without an account, the address that requested it. A running
+// task is signaled and the worker cleans up and reports the cancellation; for a
+// waiting one that happens here.
+func (s *downloadService) Cancel(ctx context.Context, id uuid.UUID, userID *uuid.UUID, ip string) (*model.DownloadTask, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	task, err := s.repo.FindByID(subCtx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrDownloadNotFound) {
+			return nil, ErrDownloadNotFound
+		}
+		return nil, err
+	}
+	if !ownedBy(task.UserID, task.IPAddress, userID, ip) {
+		return nil, ErrDownloadNotOwned
+	}
+	if task.Status == "canceled" {
+		return task, nil
+	}
+
+	message := "canceled by the user"
+	canceled, err := s.repo.MarkCanceled(subCtx, id, message)
+	if err != nil {
+		return nil, err
+	}
+	if !canceled {
+		return nil, ErrDownloadNotCancelable
+	}
+	code := infrastructure.ErrCodeCanceled
+	task.Status = "canceled"
+	task.ErrorMessage = &message
+	task.ErrorCode = &code
+
+	running := false
+	if s.taskClient != nil {
+		running, err = s.taskClient.CancelDownload(id)
+		if err != nil {
+			// The worker checks the status before it starts, so a task left in
+			// the queue ends without running.
+			log.Warn().Err(err).Str("task_id", id.String()).Msg("Failed to cancel queued download task")
+		}
+	}
+	if running {
+		return task, nil
+	}
+
+	if task.BatchID != nil {
+		if _, err := s.batchRepo.RefreshProgress(subCtx, *task.BatchID); err != nil {
+			log.Warn().Err(err).Str("batch_id", task.BatchID.String()).Msg("Failed to refresh batch progress after cancel")
+		}
+	}
+	s.publishEvent(subCtx, &model.DownloadEvent{
+		Type:      "download.canceled",
+		TaskID:    task.ID,
+		BatchID:   task.BatchID,
+		UserID:    task.UserID,
+		Status:    "canceled",
+		Message:   message,
+		ErrorCode: code,
+		CreatedAt: time.Now(),
+	})
+	return task, nil
+}
+
+// publishEvent sends a download event to the API instances, which relay it to
+// websocket clients, and to the task's Centrifugo channel.
+func (s *downloadService) publishEvent(ctx context.Context, event *model.DownloadEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode download event")
+		return
+	}
+	if s.redisClient != nil {
+		if err := s.redisClient.Publish(ctx, infrastructure.DownloadEventChannel, data).Err(); err != nil {
+			log.Error().Err(err).Str("task_id", event.TaskID.String()).Msg("Failed to publish download event to Redis")
+		}
+	}
+	if s.centrifugo != nil {
+		channel := "download:progress:" + event.TaskID.String()
+		if err := s.centrifugo.Publish(ctx, channel, event); err != nil {
+			log.Error().Err(err).Str("channel", channel).Msg("Failed to publish download event to Centrifugo")
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+var ErrInvalidFormatSelection = errors.New("invalid format selection")
+
+var codecPrefixes = map[string][]string{
+	"h264": {"avc1", "avc", "h264"},
+	"hevc": {"hvc1", "hev1", "hevc", "h265"},
+	"vp9":  {"vp9", "vp09"},
+	"av1":  {"av01", "av1"},
+}
+
+func matchesCodec(vcodec string, codec string) bool {
+	vcodec = strings.ToLower(strings.TrimSpace(vcodec))
+	for _, prefix := range codecPrefixes[codec] {
+		if strings.HasPrefix(vcodec, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVideoStream(f infrastructure.FormatInfo) bool {
+	return f.Vcodec != "none"
+}
+
+// fitsContainer reports whether f can be delivered as container. Video-only formats
+// are merged with an audio track into the container; the others keep their own.
+func fitsContainer(f infrastructure.FormatInfo, container string) bool {
+	return f.Acodec == "none" || strings.EqualFold(f.Ext, container)
+}
+
+// resolveFormatSelection validates the requested format options against the formats
+// reported by the metadata step. It returns nil when the request leaves the choice to
+// the worker. When the source did not report any formats the options are kept as a
+// preference, except for an explicit format ID which cannot be checked. A format whose
+// codec or extension was not reported does not match a requested codec or container.
+func resolveFormatSelection(req model.DownloadRequest, formats []infrastructure.FormatInfo) (*model.FormatSelection, error) {
+	if !req.HasFormatSelection() {
+		return nil, nil
+	}
+
+	sel := &model.FormatSelection{Container: "mp4"}
+	checkContainer := req.Container != nil && *req.Container != ""
+	if checkContainer {
+		sel.Container = *req.Container
+	}
+	if req.Codec != nil {
+		sel.Codec = *req.Codec
+	}
+	if req.MaxHeight != nil {
+		sel.MaxHeight = *req.MaxHeight
+	}
+
+	checkCodec := sel.Codec != ""
+
+	if req.FormatID != nil && strings.TrimSpace(*req.FormatID) != "" {
+		id := strings.TrimSpace(*req.FormatID)
+		for _, f := range formats {
+			if f.FormatID != id {
+				continue
+			}
+			if !hasVideoStream(f) {
+				return nil, fmt.Errorf("%w: format %q has no video stream", ErrInvalidFormatSelection, id)
+			}
+			if checkCodec && !matchesCodec(f.Vcodec, sel.Codec) {
+				return nil, fmt.Errorf("%w: format %q is not encoded with %s", ErrInvalidFormatSelection, id, sel.Codec)
+			}
+			if sel.MaxHeight > 0 && f.Height != nil && *f.Height > sel.MaxHeight {
+				return nil, fmt.Errorf("%w: format %q exceeds max height %d", ErrInvalidFormatSelection, id, sel.MaxHeight)
+			}
+
+			sel.MergeAudio = f.Acodec == "none"
+			if !sel.MergeAudio {
+				// Served as is, so the file keeps the format's own extension.
+				if checkContainer && !fitsContainer(f, sel.Container) {
+					return nil, fmt.Errorf("%w: format %q is not available as %s", ErrInvalidFormatSelection, id, sel.Container)
+				}
+				if f.Ext != "" {
+					sel.Container = f.Ext
+				}
+			}
+
+			sel.FormatID = id
+			sel.MaxHeight = 0
+			if f.Height != nil {
+				sel.Height = *f.Height
+			}
+			return sel, nil
+		}
+		return nil, fmt.Errorf("%w: format %q is not available", ErrInvalidFormatSelection, id)
+	}
+
+	if len(formats) == 0 {
+		return sel, nil
+	}
+
+	found := false
+	for _, f := range formats {
+		if !hasVideoStream(f) {
+			continue
+		}
+		if checkCodec && !matchesCodec(f.Vcodec, sel.Codec) {
+			continue
+		}
+		if checkContainer && !fitsContainer(f, sel.Container) {
+			continue
+		}
+		if f.Height == nil || *f.Height == 0 {
+			if sel.MaxHeight == 0 {
+				found = true
+			}
+			continue
+		}
+		if sel.MaxHeight > 0 && *f.Height > sel.MaxHeight {
+			continue
+		}
+		found = true
+		if *f.Height > sel.Height {
+			sel.Height = *f.Height
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no format matches the requested height, codec or container", ErrInvalidFormatSelection)
+	}
+
+	return sel, nil
+}
+
+// filterSelectedFormats narrows the formats returned to the client to the ones the
+// selection allows, falling back to the full list when nothing matches.
+func filterSelectedFormats(formats []model.DownloadFormat, sel *model.FormatSelection) []model.DownloadFormat {
+	if sel == nil || len(formats) == 0 {
+		return formats
+	}
+
+	filtered := make([]model.DownloadFormat, 0, len(formats))
+	for _, f := range formats {
+		if sel.FormatID != "" {
+			if f.FormatID == sel.FormatID {
+				filtered = append(filtered, f)
+			}
+			continue
+		}
+		if sel.MaxHeight > 0 && f.Height != nil && *f.Height > sel.MaxHeight {
+			continue
+		}
+		if sel.Codec != "" && f.Vcodec != "none" && !matchesCodec(f.Vcodec, sel.Codec) {
+			continue
+		}
+		filtered = append(filtered, f)
+	}
+
+	if len(filtered) == 0 {
+		return formats
+	}
+	return filtered
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/config"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+)
+
+var ErrQuotaExceeded = errors.New("download quota exceeded")
+
+const (
+	QuotaReasonDailyLimit      = "daily_limit"
+	QuotaReasonPremiumPlatform = "premium_platform"
+	QuotaReasonMaxDuration     = "max_duration"
+	QuotaReasonMaxResolution   = "max_resolution"
+)
+
+// QuotaError reports which limit a download request hit, together with the
+// caller's quota so it can still be returned in the response headers.
+type QuotaError struct {
+	Reason  string
+	Message string
+	Quota   *model.QuotaStatus
+}
+
+func (e *QuotaError) Error() string {
+	return e.Message
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaSubject identifies whose daily quota a download counts against. Downloads
+// are counted per user within an application; anonymous callers by IP.
+type QuotaSubject struct {
+	UserID *uuid.UUID
+	AppID  *uuid.UUID
+	IP     string
+}
+
+func (s QuotaSubject) key(day time.Time) string {
+	scope := "web"
+	if s.AppID != nil {
+		scope = "app:" + s.AppID.String()
+	}
+	who := "ip:" + s.IP
+	if s.UserID != nil {
+		who = "user:" + s.UserID.String()
+	}
+	return fmt.Sprintf("quota:%s:%s:%s", scope, who, day.Format("20060102"))
+}
+
+type QuotaService interface {
+	Limits(ctx context.Context, subject QuotaSubject) (*model.QuotaLimits, error)
+	Status(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits) (*model.QuotaStatus, error)
+	Reserve(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits, n int) (*model.QuotaStatus, error)
+	Release(ctx context.Context, subject QuotaSubject, n int)
+}
+
+type quotaService struct {
+	subscriptionRepo repository.SubscriptionRepository
+	appRepo          repository.ApplicationRepository
+	redisClient      *redis.Client
+	free             model.QuotaLimits
+}
+
+func NewQuotaService(subscriptionRepo repository.SubscriptionRepository, appRepo repository.ApplicationRepository, redisClient *redis.Client, cfg *config.Config) QuotaService {
+	return &quotaService{
+		subscriptionRepo: subscriptionRepo,
+		appRepo:          appRepo,
+		redisClient:      redisClient,
+		free: model.QuotaLimits{
+			Plan:           model.QuotaPlanFree,
+			DailyDownloads: cfg.QuotaFreeDailyDownloads,
+			MaxHeight:      cfg.QuotaFreeMaxHeight,
+			MaxDuration:    cfg.QuotaFreeMaxDuration,
+		},
+	}
+}
+
+func (s *quotaService) Limits(ctx context.Context, subject QuotaSubject) (*model.QuotaLimits, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	free := s.free
+	if subject.UserID == nil {
+		return &free, nil
+	}
+
+	var sub *model.Subscription
+	var err error
+	if subject.AppID != nil {
+		sub, err = s.subscriptionRepo.FindCurrentByUserAndApp(subCtx, *subject.UserID, *subject.AppID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		sub, err = s.subscriptionRepo.FindLatestByUser(subCtx, *subject.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !subscriptionEntitled(sub, time.Now()) {
+		return &free, nil
+	}
+
+	// A subscriber gets everything the product does not explicitly restrict.
+	limits := &model.QuotaLimits{Plan: sub.ProductID, PremiumPlatforms: true}
+	if sub.AppID == nil {
+		return limits, nil
+	}
+	product, err := s.appRepo.FindProduct(subCtx, *sub.AppID, sub.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if product != nil && product.Features != nil {
+		applyQuotaFeatures(limits, *product.Features)
+	}
+	return limits, nil
+}
+
+func applyQuotaFeatures(limits *model.QuotaLimits, features map[string]any) {
+	if v, ok := featureInt(features["daily_downloads"]); ok {
+		limits.DailyDownloads = max(v, 0)
+	}
+	if v, ok := featureResolution(features["max_resolution"]); ok {
+		limits.MaxHeight = v
+	}
+	if v, ok := featureInt(features["max_duration"]); ok {
+		limits.MaxDuration = max(v, 0)
+	}
+	if v, ok := features["premium_platforms"].(bool); ok {
+		limits.PremiumPlatforms = v
+	}
+}
+
+func featureInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(n))
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// featureResolution accepts a height (1080) or a label ("1080p", "4k").
+func featureResolution(v any) (int, bool) {
+	if s, ok := v.(string); ok {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case "", "unlimited", "original":
+			return 0, true
+		case "4k":
+			return 2160, true
+		case "8k":
+			return 4320, true
+		}
+		v = strings.TrimSuffix(s, "p")
+	}
+	n, ok := featureInt(v)
+	if !ok || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+func nextQuotaReset(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+func quotaStatus(limits *model.QuotaLimits, used int, now time.Time) *model.QuotaStatus {
+	remaining := -1
+	if limits.DailyDownloads > 0 {
+		remaining = max(limits.DailyDownloads-used, 0)
+	}
+	return &model.QuotaStatus{
+		QuotaLimits: *limits,
+		Used:        used,
+		Remaining:   remaining,
+		ResetAt:     nextQuotaReset(now),
+	}
+}
+
+func (s *quotaService) Status(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits) (*model.QuotaStatus, error) {
+	now := time.Now()
+	if s.redisClient == nil {
+		return quotaStatus(limits, 0, now), nil
+	}
+
+	used, err := s.redisClient.Get(ctx, subject.key(now.UTC())).Int()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read download quota: %w", err)
+	}
+	return quotaStatus(limits, used, now), nil
+}
+
+var reserveQuotaLua = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local n = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+if limit > 0 and current + n > limit then
+	return {0, current}
+end
+current = redis.call("INCRBY", KEYS[1], n)
+if redis.call("TTL", KEYS[1]) < 0 then
+	redis.call("EXPIRE", KEYS[1], ARGV[3])
+end
+return {1, current}
+`)
+
+// Reserve counts n downloads against today's quota, or fails with a QuotaError
+// without counting anything when they do not fit.
+func (s *quotaService) Reserve(ctx context.Context, subject QuotaSubject, limits *model.QuotaLimits, n int) (*model.QuotaStatus, error) {
+	now := time.Now()
+	if s.redisClient == nil {
+		return quotaStatus(limits, 0, now), nil
+	}
+
+	ttl := int64(math.Ceil(time.Until(nextQuotaReset(now)).Seconds())) + 60
+	res, err := reserveQuotaLua.Run(ctx, s.redisClient, []string{subject.key(now.UTC())}, n, limits.DailyDownloads, ttl).Int64Slice()
+	if err != nil || len(res) < 2 {
+		// Fail open: quota bookkeeping must not take downloads offline.
+		log.Warn().Err(err).Msg("Failed to reserve download quota")
+		return quotaStatus(limits, 0, now), nil
+	}
+
+	status := quotaStatus(limits, int(res[1]), now)
+	if res[0] == 0 {
+		return nil, &QuotaError{
+			Reason:  QuotaReasonDailyLimit,
+			Message: fmt.Sprintf("daily download limit of %d reached", limits.DailyDownloads),
+			Quota:   status,
+		}
+	}
+	return status, nil
+}
+
+// Release gives back downloads reserved for requests that failed before enqueueing.
+func (s *quotaService) Release(ctx context.Context, subject QuotaSubject, n int) {
+	if s.redisClient == nil || n <= 0 {
+		return
+	}
+	if err := s.redisClient.DecrBy(ctx, subject.key(time.Now().UTC()), int64(n)).Err(); err != nil {
+		log.Warn().Err(err).Msg("Failed to release download quota")
+	}
+}
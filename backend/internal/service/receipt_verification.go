@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/config"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+)
+
+// ReceiptVerifiers holds the verifier of each store, keyed by provider
+// (infrastructure.ReceiptProviderGooglePlay, infrastructure.ReceiptProviderAppStore).
+type ReceiptVerifiers map[string]infrastructure.ReceiptVerifier
+
+// NewReceiptVerifiers builds a verifier for every store with credentials. A store
+// that is not configured has no verifier, and its purchases are saved unverified.
+func NewReceiptVerifiers(ctx context.Context, cfg *config.Config) ReceiptVerifiers {
+	verifiers := make(ReceiptVerifiers)
+
+	if strings.EqualFold(cfg.ReceiptVerifier, "fake") {
+		if cfg.AppEnv != "production" {
+			fake := infrastructure.NewFakeReceiptVerifier()
+			fake.AcceptUnknown = true
+			log.Warn().Msg("Using the fake receipt verifier: every purchase is accepted")
+			verifiers[infrastructure.ReceiptProviderGooglePlay] = fake
+			verifiers[infrastructure.ReceiptProviderAppStore] = fake
+			return verifiers
+		}
+		log.Error().Msg("The fake receipt verifier cannot be used in production; using the store verifiers")
+	}
+
+	if cfg.GooglePlayCredentialsFile != "" {
+		v, err := infrastructure.NewGooglePlayVerifier(ctx, cfg.GooglePlayCredentialsFile)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to set up Google Play receipt verification")
+		} else {
+			verifiers[infrastructure.ReceiptProviderGooglePlay] = v
+		}
+	}
+
+	if cfg.AppStoreIssuerID != "" && cfg.AppStorePrivateKeyFile != "" {
+		storeCfg := infrastructure.AppStoreVerifierConfig{
+			IssuerID:       cfg.AppStoreIssuerID,
+			KeyID:          cfg.AppStoreKeyID,
+			PrivateKeyFile: cfg.AppStorePrivateKeyFile,
+			Sandbox:        cfg.AppStoreSandbox,
+		}
+		if cfg.AppStoreRootCertFile != "" {
+			root, err := infrastructure.LoadAppStoreRootCert(cfg.AppStoreRootCertFile)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to load the App Store root certificate")
+			}
+			storeCfg.RootCert = root
+		}
+		v, err := infrastructure.NewAppStoreVerifier(storeCfg)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to set up App Store receipt verification")
+		} else {
+			verifiers[infrastructure.ReceiptProviderAppStore] = v
+		}
+	}
+
+	return verifiers
+}
+
+// receiptProvider maps the platform reported with a purchase to its store.
+func receiptProvider(platform string) string {
+	switch strings.ToLower(strings.TrimSpace(platform)) {
+	case "ios", "app_store", "appstore", "apple":
+		return infrastructure.ReceiptProviderAppStore
+	default:
+		return infrastructure.ReceiptProviderGooglePlay
+	}
+}
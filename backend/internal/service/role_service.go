@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleNameTaken     = errors.New("role name already exists")
+	ErrRoleInUse         = errors.New("role is assigned to users")
+	ErrProtectedRole     = errors.New("built-in role cannot be changed this way")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrUserNotFound      = errors.New("user not found")
+	// ErrOwnRole keeps admins from demoting themselves out of the panel.
+	ErrOwnRole = errors.New("cannot change your own role")
+	// ErrPermissionNotHeld is returned when an admin grants, directly or by
+	// assigning a role, a permission they do not hold themselves.
+	ErrPermissionNotHeld = errors.New("cannot grant a permission you do not hold")
+)
+
+// Built-in roles. New users get the customer role; the admin role always holds
+// every permission so there is one role no edit can lock out.
+const (
+	RoleAdmin    = "admin"
+	RoleCustomer = "customer"
+)
+
+type RoleService interface {
+	FindAll(ctx context.Context) ([]model.Role, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*model.Role, error)
+	// Create and Update only grant permissions held by the acting admin, whose
+	// permissions are passed as actor.
+	Create(ctx context.Context, actor map[string]bool, name string, permissions []string) (*model.Role, error)
+	// Update also ends the sessions of every user holding the role.
+	Update(ctx context.Context, actor map[string]bool, id uuid.UUID, name string, permissions []string) (*model.Role, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// AssignRole gives a user a role. Permissions are baked into access tokens,
+	// so the user's sessions are ended and the new ones carry the new role.
+	AssignRole(ctx context.Context, actorID uuid.UUID, actor map[string]bool, userID, roleID uuid.UUID) (*model.User, error)
+}
+
+type roleService struct {
+	repo             repository.RoleRepository
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	tokenService     TokenService
+}
+
+func NewRoleService(repo repository.RoleRepository, userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, tokenService TokenService) RoleService {
+	return &roleService{repo: repo, userRepo: userRepo, refreshTokenRepo: refreshTokenRepo, tokenService: tokenService}
+}
+
+func (s *roleService) FindAll(ctx context.Context) ([]model.Role, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	return s.repo.FindAll(subCtx)
+}
+
+func (s *roleService) FindByID(ctx context.Context, id uuid.UUID) (*model.Role, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	role, err := s.repo.FindByID(subCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (s *roleService) Create(ctx context.Context, actor map[string]bool, name string, permissions []string) (*model.Role, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	granted, err := permissionSet(permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureGrantable(actor, granted); err != nil {
+		return nil, err
+	}
+	name = normalizeRoleName(name)
+	if err := s.ensureNameFree(subCtx, name, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	role := &model.Role{Name: name, Permissions: granted}
+	if err := s.repo.Create(subCtx, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (s *roleService) Update(ctx context.Context, actor map[string]bool, id uuid.UUID, name string, permissions []string) (*model.Role, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	role, err := s.repo.FindByID(subCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+
+	granted, err := permissionSet(permissions)
+	if err != nil {
+		return nil, err
+	}
+	// Both sides count: dropping a permission the actor lacks would be as much
+	// a change to it as granting one.
+	if err := ensureGrantable(actor, granted); err != nil {
+		return nil, err
+	}
+	if err := ensureGrantable(actor, role.Permissions); err != nil {
+		return nil, err
+	}
+	name = normalizeRoleName(name)
+	if isBuiltinRole(role.Name) && name != role.Name {
+		return nil, fmt.Errorf("%w: %s cannot be renamed", ErrProtectedRole, role.Name)
+	}
+	if role.Name == RoleAdmin && !granted[model.PermissionAll] {
+		return nil, fmt.Errorf("%w: %s must keep the %q permission", ErrProtectedRole, RoleAdmin, model.PermissionAll)
+	}
+	if err := s.ensureNameFree(subCtx, name, role.ID); err != nil {
+		return nil, err
+	}
+
+	role.Name = name
+	role.Permissions = granted
+	if err := s.repo.Update(subCtx, role); err != nil {
+		return nil, err
+	}
+
+	families, err := s.refreshTokenRepo.RevokeRole(subCtx, role.ID)
+	if err != nil {
+		return nil, fmt.Errorf("role updated but its sessions were not ended: %w", err)
+	}
+	if err := s.tokenService.RevokeSessions(subCtx, families...); err != nil {
+		return nil, fmt.Errorf("role updated but its sessions were not ended: %w", err)
+	}
+	return role, nil
+}
+
+func (s *roleService) Delete(ctx context.Context, id uuid.UUID) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	role, err := s.repo.FindByID(subCtx, id)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return ErrRoleNotFound
+	}
+	if isBuiltinRole(role.Name) {
+		return fmt.Errorf("%w: %s cannot be deleted", ErrProtectedRole, role.Name)
+	}
+
+	// users.role_id would be set to NULL, leaving the users without any role.
+	count, err := s.repo.CountUsers(subCtx, id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d users", ErrRoleInUse, count)
+	}
+
+	return s.repo.Delete(subCtx, id)
+}
+
+func (s *roleService) AssignRole(ctx context.Context, actorID uuid.UUID, actor map[string]bool, userID, roleID uuid.UUID) (*model.User, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	if actorID == userID {
+		return nil, ErrOwnRole
+	}
+
+	role, err := s.repo.FindByID(subCtx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	if err := ensureGrantable(actor, role.Permissions); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(subCtx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	// Taking a role away is as sensitive as handing it out.
+	if user.Role != nil {
+		if err := ensureGrantable(actor, user.Role.Permissions); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.UpdateRole(subCtx, userID, roleID); err != nil {
+		return nil, err
+	}
+
+	families, err := s.refreshTokenRepo.RevokeUser(subCtx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("role assigned but the user's sessions were not ended: %w", err)
+	}
+	if err := s.tokenService.RevokeSessions(subCtx, families...); err != nil {
+		return nil, fmt.Errorf("role assigned but the user's sessions were not ended: %w", err)
+	}
+	user.RoleID = &role.ID
+	user.Role = role
+	return user, nil
+}
+
+func (s *roleService) ensureNameFree(ctx context.Context, name string, id uuid.UUID) error {
+	existing, err := s.repo.FindByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return ErrRoleNameTaken
+	}
+	return nil
+}
+
+func permissionSet(permissions []string) (map[string]bool, error) {
+	granted := make(map[string]bool, len(permissions))
+	for _, key := range permissions {
+		key = strings.TrimSpace(key)
+		if !model.IsKnownPermission(key) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, key)
+		}
+		granted[key] = true
+	}
+	return granted, nil
+}
+
+// ensureGrantable checks that actor holds every permission in granted. A
+// wildcard or "all" can only be granted by someone holding it as such.
+func ensureGrantable(actor, granted map[string]bool) error {
+	for key, ok := range granted {
+		if !ok || actor[model.PermissionAll] {
+			continue
+		}
+		held := actor[key]
+		if key != model.PermissionAll && !strings.HasSuffix(key, ":*") {
+			held = model.HasPermission(actor, key)
+		}
+		if !held {
+			return fmt.Errorf("%w: %s", ErrPermissionNotHeld, key)
+		}
+	}
+	return nil
+}
+
+func normalizeRoleName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func isBuiltinRole(name string) bool {
+	return name == RoleAdmin || name == RoleCustomer
+}
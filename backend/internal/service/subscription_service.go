@@ -2,13 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/config"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
 	"github.com/user/video-downloader-backend/internal/model"
 	"github.com/user/video-downloader-backend/internal/repository"
 )
 
+var ErrPurchaseRejected = errors.New("purchase could not be verified")
+
+// SubscriptionStatusUnverified marks a purchase the store could not be asked
+// about yet. It grants nothing until a later verification succeeds.
+const SubscriptionStatusUnverified = "unverified"
+
 type SubscriptionService interface {
 	Upsert(ctx context.Context, sub *model.Subscription) (*model.Subscription, error)
 	FindCurrentByUserAndApp(ctx context.Context, userID uuid.UUID, appID uuid.UUID, now time.Time) (*model.Subscription, error)
@@ -16,22 +28,226 @@ type SubscriptionService interface {
 	FindAll(ctx context.Context, params model.QueryParamsRequest) ([]model.Subscription, model.Pagination, error)
 	Delete(ctx context.Context, subID uuid.UUID) error
 	BulkDelete(ctx context.Context, subIDs []uuid.UUID) error
+	ProcessGooglePlayNotification(ctx context.Context, bearerToken, queryToken string, body []byte) error
+	ProcessAppStoreNotification(ctx context.Context, body []byte) error
+	Transition(ctx context.Context, subID uuid.UUID, to string) (*model.Subscription, error)
+	ExpireDue(ctx context.Context, now time.Time) (int, error)
 }
 
 type subscriptionService struct {
-	repo repository.SubscriptionRepository
+	repo         repository.SubscriptionRepository
+	appRepo      repository.ApplicationRepository
+	analyticRepo repository.AnalyticRepository
+	verifiers    ReceiptVerifiers
+	webhook      storeWebhookConfig
+	centrifugo   infrastructure.CentrifugoClient
 }
 
-func NewSubscriptionService(repo repository.SubscriptionRepository) SubscriptionService {
+func NewSubscriptionService(repo repository.SubscriptionRepository, appRepo repository.ApplicationRepository, analyticRepo repository.AnalyticRepository, verifiers ReceiptVerifiers, centrifugo infrastructure.CentrifugoClient, cfg *config.Config) SubscriptionService {
 	return &subscriptionService{
-		repo: repo,
+		repo:         repo,
+		appRepo:      appRepo,
+		analyticRepo: analyticRepo,
+		verifiers:    verifiers,
+		webhook:      newStoreWebhookConfig(cfg),
+		centrifugo:   centrifugo,
 	}
 }
 
+// Upsert saves a purchase reported by the app as the store sees it. Status, times
+// and product come from the store; a purchase the store rejects is not saved and
+// fails with ErrPurchaseRejected. When the store cannot be asked, the purchase is
+// kept as unverified, or left as previously verified.
 func (s *subscriptionService) Upsert(ctx context.Context, sub *model.Subscription) (*model.Subscription, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	provider := receiptProvider(sub.Platform)
+	verifier := s.verifiers[provider]
+
+	var app *model.Application
+	if sub.AppID != nil {
+		var err error
+		app, err = s.appRepo.FindByID(subCtx, *sub.AppID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if verifier == nil || app == nil {
+		log.Warn().Str("provider", provider).Str("product_id", sub.ProductID).Msg("No receipt verifier for purchase, saving it unverified")
+		return s.saveUnverified(subCtx, sub)
+	}
+
+	result, err := verifier.Verify(subCtx, infrastructure.ReceiptRequest{
+		PackageName:   app.PackageName,
+		ProductID:     sub.ProductID,
+		PurchaseToken: sub.PurchaseToken,
+		TransactionID: sub.OriginalTransactionID,
+	})
+	if err != nil {
+		if errors.Is(err, infrastructure.ErrReceiptInvalid) {
+			s.recordRejected(subCtx, sub, provider, err)
+			return nil, fmt.Errorf("%w: %v", ErrPurchaseRejected, err)
+		}
+		log.Warn().Err(err).Str("provider", provider).Str("product_id", sub.ProductID).Msg("Receipt verification unavailable, saving purchase unverified")
+		return s.saveUnverified(subCtx, sub)
+	}
+
+	return s.saveVerified(subCtx, sub, result)
+}
+
+// claimable returns the stored subscription for originalTransactionID and fails
+// when it belongs to another user, so a leaked token cannot be moved to a new
+// account.
+func (s *subscriptionService) claimable(ctx context.Context, sub *model.Subscription, originalTransactionID string) (*model.Subscription, error) {
+	existing, err := s.repo.FindByOriginalTransactionID(ctx, originalTransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.UserID != nil && (sub.UserID == nil || *existing.UserID != *sub.UserID) {
+		return nil, fmt.Errorf("%w: purchase belongs to another account", ErrPurchaseRejected)
+	}
+	return existing, nil
+}
+
+func (s *subscriptionService) saveUnverified(ctx context.Context, sub *model.Subscription) (*model.Subscription, error) {
+	existing, err := s.claimable(ctx, sub, sub.OriginalTransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Status != SubscriptionStatusUnverified {
+		return existing, nil
+	}
+
+	sub.Status = SubscriptionStatusUnverified
 	return s.repo.Upsert(ctx, sub)
 }
 
+func (s *subscriptionService) saveVerified(ctx context.Context, sub *model.Subscription, result *infrastructure.ReceiptVerification) (*model.Subscription, error) {
+	existing, err := s.claimable(ctx, sub, result.OriginalTransactionID)
+	if err != nil {
+		return nil, err
+	}
+	previous := ""
+	if existing != nil {
+		previous = existing.Status
+	}
+	return s.applyVerification(ctx, sub, previous, result)
+}
+
+// applyVerification saves sub as the store reported it and records the charge.
+// previous is the stored status of the subscription, empty for a new one; a
+// store status it cannot move to is logged and ignored.
+func (s *subscriptionService) applyVerification(ctx context.Context, sub *model.Subscription, previous string, result *infrastructure.ReceiptVerification) (*model.Subscription, error) {
+	status := result.Status
+	if result.Refunded {
+		status = SubscriptionStatusRefunded
+	}
+	if err := ValidateSubscriptionTransition(previous, status); err != nil {
+		log.Warn().Err(err).
+			Str("original_transaction_id", result.OriginalTransactionID).
+			Msg("Ignoring store status the subscription cannot move to")
+		status = previous
+	}
+
+	sub.OriginalTransactionID = result.OriginalTransactionID
+	sub.ProductID = result.ProductID
+	sub.Status = status
+	sub.AutoRenew = result.AutoRenew
+	if !result.StartTime.IsZero() {
+		sub.StartTime = result.StartTime
+	}
+	if !result.EndTime.IsZero() {
+		sub.EndTime = result.EndTime
+	}
+
+	out, err := s.repo.Upsert(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+	if out.Status != previous {
+		s.publishStatusChange(ctx, out, previous)
+	}
+
+	amount, currency := result.Amount, result.Currency
+	if amount == 0 && out.AppID != nil {
+		product, err := s.appRepo.FindProduct(ctx, *out.AppID, out.ProductID)
+		if err != nil {
+			log.Warn().Err(err).Str("product_id", out.ProductID).Msg("Failed to look up product price")
+		} else if product != nil && product.Price != nil {
+			amount = *product.Price
+			if product.Currency != nil {
+				currency = *product.Currency
+			}
+		}
+	}
+	if currency == "" {
+		currency = "USD"
+	}
+
+	txStatus := "success"
+	switch {
+	case result.Refunded:
+		txStatus = "refunded"
+	case result.Status == SubscriptionStatusPending:
+		txStatus = "pending"
+	case result.Test:
+		// Sandbox and test purchases are kept apart so they never count as revenue.
+		txStatus = "test"
+	}
+
+	tx := &model.Transaction{
+		UserID:           out.UserID,
+		AppID:            out.AppID,
+		SubscriptionID:   &out.ID,
+		Amount:           amount,
+		Currency:         currency,
+		Provider:         result.Provider,
+		Status:           txStatus,
+		ProviderResponse: result.Raw,
+	}
+	if result.ProviderTransactionID != "" {
+		tx.ProviderTransactionID = &result.ProviderTransactionID
+	}
+	if err := s.recordTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// recordTransaction adds or updates a charge and refreshes the revenue of the day
+// it was first recorded on.
+func (s *subscriptionService) recordTransaction(ctx context.Context, tx *model.Transaction) error {
+	if err := s.repo.AddTransaction(ctx, tx); err != nil {
+		return err
+	}
+	if err := s.analyticRepo.RefreshRevenue(ctx, tx.CreatedAt); err != nil {
+		log.Warn().Err(err).Time("day", tx.CreatedAt).Msg("Failed to refresh daily revenue")
+	}
+	return nil
+}
+
+// recordRejected keeps a trace of purchases the store refused, for support and
+// fraud review.
+func (s *subscriptionService) recordRejected(ctx context.Context, sub *model.Subscription, provider string, reason error) {
+	tx := &model.Transaction{
+		UserID:   sub.UserID,
+		AppID:    sub.AppID,
+		Currency: "USD",
+		Provider: provider,
+		Status:   "rejected",
+		ProviderResponse: map[string]any{
+			"error":                   reason.Error(),
+			"product_id":              sub.ProductID,
+			"original_transaction_id": sub.OriginalTransactionID,
+		},
+	}
+	if err := s.repo.AddTransaction(ctx, tx); err != nil {
+		log.Warn().Err(err).Str("product_id", sub.ProductID).Msg("Failed to record rejected purchase")
+	}
+}
+
 func (s *subscriptionService) FindCurrentByUserAndApp(ctx context.Context, userID uuid.UUID, appID uuid.UUID, now time.Time) (*model.Subscription, error) {
 	return s.repo.FindCurrentByUserAndApp(ctx, userID, appID, now)
 }
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/user/video-downloader-backend/internal/config"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+)
+
+// memorySubscriptionRepo keeps subscriptions by original transaction ID. Only
+// the methods Upsert reaches are implemented.
+type memorySubscriptionRepo struct {
+	repository.SubscriptionRepository
+	subs         map[string]*model.Subscription
+	transactions []model.Transaction
+	upserts      int
+}
+
+func (r *memorySubscriptionRepo) FindByOriginalTransactionID(ctx context.Context, originalTransactionID string) (*model.Subscription, error) {
+	if sub, ok := r.subs[originalTransactionID]; ok {
+		copied := *sub
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *memorySubscriptionRepo) Upsert(ctx context.Context, sub *model.Subscription) (*model.Subscription, error) {
+	r.upserts++
+	saved := *sub
+	if existing, ok := r.subs[sub.OriginalTransactionID]; ok {
+		saved.ID = existing.ID
+	} else {
+		saved.ID = uuid.New()
+	}
+	r.subs[sub.OriginalTransactionID] = &saved
+	out := saved
+	return &out, nil
+}
+
+func (r *memorySubscriptionRepo) AddTransaction(ctx context.Context, tx *model.Transaction) error {
+	tx.ID = uuid.New()
+	tx.CreatedAt = time.Now()
+	r.transactions = append(r.transactions, *tx)
+	return nil
+}
+
+type memoryApplicationRepo struct {
+	repository.ApplicationRepository
+	app *model.Application
+}
+
+func (r *memoryApplicationRepo) FindByID(ctx context.Context, id uuid.UUID) (*model.Application, error) {
+	return r.app, nil
+}
+
+func (r *memoryApplicationRepo) FindProduct(ctx context.Context, appID uuid.UUID, productID string) (*model.InAppProduct, error) {
+	return nil, nil
+}
+
+type memoryAnalyticRepo struct {
+	repository.AnalyticRepository
+	refreshed int
+}
+
+func (r *memoryAnalyticRepo) RefreshRevenue(ctx context.Context, day time.Time) error {
+	r.refreshed++
+	return nil
+}
+
+func TestSubscriptionServiceUpsert(t *testing.T) {
+	appID := uuid.New()
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	verified := infrastructure.ReceiptVerification{
+		Provider:              infrastructure.ReceiptProviderGooglePlay,
+		OriginalTransactionID: "GPA.1234",
+		ProviderTransactionID: "GPA.1234..0",
+		ProductID:             "premium_monthly",
+		StartTime:             start,
+		EndTime:               start.AddDate(0, 1, 0),
+		Status:                SubscriptionStatusActive,
+		AutoRenew:             true,
+		Amount:                4.99,
+		Currency:              "USD",
+	}
+
+	tests := []struct {
+		name string
+		// existing is stored under verified.OriginalTransactionID before the call.
+		existing  *model.Subscription
+		verifyErr error
+		// unknownToken sends a token the fake verifier does not know.
+		unknownToken bool
+
+		wantErr          error
+		wantStatus       string
+		wantUpserts      int
+		wantTransactions []string
+		wantRefreshes    int
+	}{
+		{
+			name:             "verified",
+			wantStatus:       SubscriptionStatusActive,
+			wantUpserts:      1,
+			wantTransactions: []string{"success"},
+			wantRefreshes:    1,
+		},
+		{
+			name:             "rejected",
+			unknownToken:     true,
+			wantErr:          ErrPurchaseRejected,
+			wantTransactions: []string{"rejected"},
+		},
+		{
+			name:        "unverified when the store is unreachable",
+			verifyErr:   errors.New("store unreachable"),
+			wantStatus:  SubscriptionStatusUnverified,
+			wantUpserts: 1,
+		},
+		{
+			name: "unverified keeps an earlier verification",
+			existing: &model.Subscription{
+				ID: uuid.New(), UserID: &userID, AppID: &appID,
+				OriginalTransactionID: verified.OriginalTransactionID, Status: SubscriptionStatusActive,
+			},
+			verifyErr:  errors.New("store unreachable"),
+			wantStatus: SubscriptionStatusActive,
+		},
+		{
+			name: "purchase claimed by another account",
+			existing: &model.Subscription{
+				ID: uuid.New(), UserID: &otherUserID, AppID: &appID,
+				OriginalTransactionID: verified.OriginalTransactionID, Status: SubscriptionStatusActive,
+			},
+			wantErr: ErrPurchaseRejected,
+		},
+		{
+			name: "unverified purchase claimed by another account",
+			existing: &model.Subscription{
+				ID: uuid.New(), UserID: &otherUserID, AppID: &appID,
+				OriginalTransactionID: verified.OriginalTransactionID, Status: SubscriptionStatusUnverified,
+			},
+			verifyErr: errors.New("store unreachable"),
+			wantErr:   ErrPurchaseRejected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subRepo := &memorySubscriptionRepo{subs: make(map[string]*model.Subscription)}
+			if tt.existing != nil {
+				subRepo.subs[tt.existing.OriginalTransactionID] = tt.existing
+			}
+			appRepo := &memoryApplicationRepo{app: &model.Application{ID: appID, PackageName: "com.example.vds"}}
+			analyticRepo := &memoryAnalyticRepo{}
+
+			fake := infrastructure.NewFakeReceiptVerifier()
+			fake.Add("purchase-token", verified)
+			fake.Err = tt.verifyErr
+			verifiers := ReceiptVerifiers{infrastructure.ReceiptProviderGooglePlay: fake}
+			svc := NewSubscriptionService(subRepo, appRepo, analyticRepo, verifiers, nil, &config.Config{})
+
+			token := "purchase-token"
+			if tt.unknownToken {
+				token = "forged-token"
+			}
+			out, err := svc.Upsert(context.Background(), &model.Subscription{
+				UserID:                &userID,
+				AppID:                 &appID,
+				Platform:              "android",
+				ProductID:             "premium_monthly",
+				PurchaseToken:         token,
+				OriginalTransactionID: verified.OriginalTransactionID,
+			})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Upsert error = %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Upsert: %v", err)
+				}
+				if out.Status != tt.wantStatus {
+					t.Errorf("status = %q, want %q", out.Status, tt.wantStatus)
+				}
+			}
+			if subRepo.upserts != tt.wantUpserts {
+				t.Errorf("saved %d times, want %d", subRepo.upserts, tt.wantUpserts)
+			}
+			if len(subRepo.transactions) != len(tt.wantTransactions) {
+				t.Fatalf("recorded %d transactions, want %v", len(subRepo.transactions), tt.wantTransactions)
+			}
+			for i, tx := range subRepo.transactions {
+				if tx.Status != tt.wantTransactions[i] {
+					t.Errorf("transaction %d status = %q, want %q", i, tx.Status, tt.wantTransactions[i])
+				}
+			}
+			if analyticRepo.refreshed != tt.wantRefreshes {
+				t.Errorf("revenue refreshed %d times, want %d", analyticRepo.refreshed, tt.wantRefreshes)
+			}
+		})
+	}
+}
+
+func TestSubscriptionServiceUpsertSavesStoreView(t *testing.T) {
+	appID := uuid.New()
+	userID := uuid.New()
+	end := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	subRepo := &memorySubscriptionRepo{subs: make(map[string]*model.Subscription)}
+	fake := infrastructure.NewFakeReceiptVerifier()
+	fake.Add("purchase-token", infrastructure.ReceiptVerification{
+		Provider:              infrastructure.ReceiptProviderGooglePlay,
+		OriginalTransactionID: "GPA.5678",
+		ProductID:             "premium_monthly",
+		EndTime:               end,
+		Status:                SubscriptionStatusActive,
+		Amount:                150000,
+		Currency:              "IDR",
+	})
+	svc := NewSubscriptionService(subRepo,
+		&memoryApplicationRepo{app: &model.Application{ID: appID, PackageName: "com.example.vds"}},
+		&memoryAnalyticRepo{},
+		ReceiptVerifiers{infrastructure.ReceiptProviderGooglePlay: fake},
+		nil, &config.Config{})
+
+	// The transaction ID and times the app reports are replaced by the store's.
+	out, err := svc.Upsert(context.Background(), &model.Subscription{
+		UserID:                &userID,
+		AppID:                 &appID,
+		Platform:              "android",
+		ProductID:             "premium_monthly",
+		PurchaseToken:         "purchase-token",
+		OriginalTransactionID: "made-up",
+		EndTime:               end.AddDate(1, 0, 0),
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if out.OriginalTransactionID != "GPA.5678" || !out.EndTime.Equal(end) {
+		t.Errorf("saved %s ending %s, want the store's GPA.5678 ending %s", out.OriginalTransactionID, out.EndTime, end)
+	}
+	if len(subRepo.transactions) != 1 {
+		t.Fatalf("recorded %d transactions, want 1", len(subRepo.transactions))
+	}
+	if tx := subRepo.transactions[0]; tx.Amount != 150000 || tx.Currency != "IDR" {
+		t.Errorf("transaction = %v %s, want 150000 IDR", tx.Amount, tx.Currency)
+	}
+}
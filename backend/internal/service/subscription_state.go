@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+)
+
+const (
+	SubscriptionStatusTrial    = "trial"
+	SubscriptionStatusActive   = "active"
+	SubscriptionStatusGrace    = "grace"
+	SubscriptionStatusOnHold   = "on_hold"
+	SubscriptionStatusPaused   = "paused"
+	SubscriptionStatusCanceled = "canceled"
+	SubscriptionStatusExpired  = "expired"
+	SubscriptionStatusRefunded = "refunded"
+	// SubscriptionStatusPending is a purchase whose payment has not cleared yet.
+	SubscriptionStatusPending = "pending"
+)
+
+var (
+	ErrInvalidSubscriptionTransition = errors.New("invalid subscription status transition")
+	ErrSubscriptionNotFound          = errors.New("subscription not found")
+)
+
+// expirySweepBatch is how many subscriptions one ExpireDue round updates.
+const expirySweepBatch = 500
+
+// subscriptionTransitions lists the statuses each status may move to. Staying in
+// the same status is always allowed, since renewals only move EndTime. Unverified
+// and pending purchases, and new subscriptions, may start in any status.
+//
+// Expired is also set by the sweeper when EndTime passes, so the store may still
+// report a renewal, a grace period or a billing hold after it. A refunded
+// subscription only comes back through a new paid purchase.
+var subscriptionTransitions = map[string][]string{
+	SubscriptionStatusTrial:    {SubscriptionStatusActive, SubscriptionStatusGrace, SubscriptionStatusOnHold, SubscriptionStatusCanceled, SubscriptionStatusExpired, SubscriptionStatusRefunded},
+	SubscriptionStatusActive:   {SubscriptionStatusGrace, SubscriptionStatusOnHold, SubscriptionStatusPaused, SubscriptionStatusCanceled, SubscriptionStatusExpired, SubscriptionStatusRefunded},
+	SubscriptionStatusGrace:    {SubscriptionStatusActive, SubscriptionStatusOnHold, SubscriptionStatusCanceled, SubscriptionStatusExpired, SubscriptionStatusRefunded},
+	SubscriptionStatusOnHold:   {SubscriptionStatusActive, SubscriptionStatusCanceled, SubscriptionStatusExpired, SubscriptionStatusRefunded},
+	SubscriptionStatusPaused:   {SubscriptionStatusActive, SubscriptionStatusCanceled, SubscriptionStatusExpired, SubscriptionStatusRefunded},
+	SubscriptionStatusCanceled: {SubscriptionStatusActive, SubscriptionStatusGrace, SubscriptionStatusExpired, SubscriptionStatusRefunded},
+	SubscriptionStatusExpired:  {SubscriptionStatusActive, SubscriptionStatusGrace, SubscriptionStatusOnHold, SubscriptionStatusPaused, SubscriptionStatusCanceled, SubscriptionStatusRefunded},
+	SubscriptionStatusRefunded: {SubscriptionStatusActive},
+}
+
+func isSubscriptionStatus(status string) bool {
+	if status == SubscriptionStatusUnverified || status == SubscriptionStatusPending {
+		return true
+	}
+	_, ok := subscriptionTransitions[status]
+	return ok
+}
+
+// ValidateSubscriptionTransition reports whether a subscription in status from
+// may move to status to.
+func ValidateSubscriptionTransition(from, to string) error {
+	if !isSubscriptionStatus(to) {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidSubscriptionTransition, to)
+	}
+	if from == to {
+		return nil
+	}
+	allowed, known := subscriptionTransitions[from]
+	if !known {
+		// New, unverified, pending or legacy statuses.
+		return nil
+	}
+	for _, s := range allowed {
+		if s == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s to %s", ErrInvalidSubscriptionTransition, from, to)
+}
+
+// subscriptionEntitled reports whether a subscription still grants its features.
+// Canceled subscriptions only stop renewing and, like trials and billing grace
+// periods, stay usable until they end.
+func subscriptionEntitled(sub *model.Subscription, now time.Time) bool {
+	if sub == nil || !sub.EndTime.After(now) {
+		return false
+	}
+	switch sub.Status {
+	case SubscriptionStatusTrial, SubscriptionStatusActive, SubscriptionStatusGrace, SubscriptionStatusCanceled:
+		return true
+	}
+	return false
+}
+
+// Transition moves a subscription to another status by hand, e.g. from the
+// admin panel, and fails with ErrInvalidSubscriptionTransition when the state
+// machine does not allow it.
+func (s *subscriptionService) Transition(ctx context.Context, subID uuid.UUID, to string) (*model.Subscription, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	sub, err := s.repo.FindByID(subCtx, subID)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err := ValidateSubscriptionTransition(sub.Status, to); err != nil {
+		return nil, err
+	}
+	if sub.Status == to {
+		return sub, nil
+	}
+
+	out, err := s.repo.UpdateStatus(subCtx, subID, sub.Status, to)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, fmt.Errorf("%w: subscription changed status concurrently", ErrInvalidSubscriptionTransition)
+	}
+	s.publishStatusChange(subCtx, out, sub.Status)
+	return out, nil
+}
+
+// ExpireDue expires every trial, active, grace or canceled subscription whose
+// EndTime is before now and tells each user. It returns how many were expired.
+func (s *subscriptionService) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	total := 0
+	for {
+		subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+		due, err := s.repo.ExpireDue(subCtx, now, expirySweepBatch)
+		cancel()
+		if err != nil {
+			return total, err
+		}
+
+		for i := range due {
+			previous := due[i].Status
+			due[i].Status = SubscriptionStatusExpired
+			due[i].UpdatedAt = now
+			s.publishStatusChange(ctx, &due[i], previous)
+		}
+		total += len(due)
+		if len(due) < expirySweepBatch {
+			return total, nil
+		}
+	}
+}
+
+// publishStatusChange sends a SubscriptionEvent to the user's channel. Only the
+// user the channel is limited to can subscribe to it.
+func (s *subscriptionService) publishStatusChange(ctx context.Context, sub *model.Subscription, previous string) {
+	if s.centrifugo == nil || sub.UserID == nil {
+		return
+	}
+
+	now := time.Now()
+	event := &model.SubscriptionEvent{
+		Type:           "subscription." + sub.Status,
+		SubscriptionID: sub.ID,
+		UserID:         *sub.UserID,
+		AppID:          sub.AppID,
+		ProductID:      sub.ProductID,
+		Status:         sub.Status,
+		PreviousStatus: previous,
+		EndTime:        sub.EndTime,
+		Entitled:       subscriptionEntitled(sub, now),
+		CreatedAt:      now,
+	}
+	channel := fmt.Sprintf("subscription:events#%s", sub.UserID.String())
+	if err := s.centrifugo.Publish(ctx, channel, event); err != nil {
+		log.Error().Err(err).Str("channel", channel).Msg("Failed to publish subscription event to Centrifugo")
+	}
+}
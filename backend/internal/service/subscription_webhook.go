@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/config"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+	"google.golang.org/api/idtoken"
+)
+
+var (
+	ErrWebhookUnauthorized = errors.New("store notification could not be authenticated")
+	ErrWebhookMalformed    = errors.New("malformed store notification")
+	// ErrWebhookInProgress is returned for a redelivery that arrives while the
+	// first delivery is still being handled, so the store tries again later.
+	ErrWebhookInProgress = errors.New("store notification is still being processed")
+)
+
+// Google Play subscription notification types that need more than a refresh
+// of the purchase state.
+const googlePlaySubscriptionRevoked = 12
+
+// storeNotificationLease is how long a claimed notification may stay unfinished
+// before a redelivery takes it over. It outlasts the 30s processing timeout.
+const storeNotificationLease = 2 * time.Minute
+
+type storeWebhookConfig struct {
+	pubSubAudience       string
+	pubSubServiceAccount string
+	googlePlayToken      string
+	appStoreRoot         *x509.Certificate
+}
+
+func newStoreWebhookConfig(cfg *config.Config) storeWebhookConfig {
+	out := storeWebhookConfig{
+		pubSubAudience:       cfg.GooglePlayPubSubAudience,
+		pubSubServiceAccount: cfg.GooglePlayPubSubServiceAccount,
+		googlePlayToken:      cfg.GooglePlayWebhookToken,
+	}
+	if cfg.AppStoreRootCertFile != "" {
+		root, err := infrastructure.LoadAppStoreRootCert(cfg.AppStoreRootCertFile)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load the App Store root certificate, App Store notifications will be refused")
+		}
+		out.appStoreRoot = root
+	}
+	return out
+}
+
+// authenticatePubSub accepts a push signed by Google for the configured audience
+// and service account, or, without an audience, the shared webhook token.
+func (s *subscriptionService) authenticatePubSub(ctx context.Context, bearerToken, queryToken string) error {
+	cfg := s.webhook
+	if cfg.pubSubAudience != "" {
+		payload, err := idtoken.Validate(ctx, bearerToken, cfg.pubSubAudience)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrWebhookUnauthorized, err)
+		}
+		if cfg.pubSubServiceAccount != "" {
+			email, _ := payload.Claims["email"].(string)
+			verified, _ := payload.Claims["email_verified"].(bool)
+			if !verified || !strings.EqualFold(email, cfg.pubSubServiceAccount) {
+				return fmt.Errorf("%w: unexpected push service account %q", ErrWebhookUnauthorized, email)
+			}
+		}
+		return nil
+	}
+	if cfg.googlePlayToken != "" && subtle.ConstantTimeCompare([]byte(queryToken), []byte(cfg.googlePlayToken)) == 1 {
+		return nil
+	}
+	return ErrWebhookUnauthorized
+}
+
+type pubSubPush struct {
+	Message struct {
+		Data      string `json:"data"`
+		MessageID string `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+type googlePlayNotification struct {
+	Version                  string `json:"version"`
+	PackageName              string `json:"packageName"`
+	EventTimeMillis          string `json:"eventTimeMillis"`
+	SubscriptionNotification *struct {
+		NotificationType int    `json:"notificationType"`
+		PurchaseToken    string `json:"purchaseToken"`
+		SubscriptionID   string `json:"subscriptionId"`
+	} `json:"subscriptionNotification"`
+	VoidedPurchaseNotification *struct {
+		PurchaseToken string `json:"purchaseToken"`
+		OrderID       string `json:"orderId"`
+		ProductType   int    `json:"productType"`
+		RefundType    int    `json:"refundType"`
+	} `json:"voidedPurchaseNotification"`
+	TestNotification *struct {
+		Version string `json:"version"`
+	} `json:"testNotification"`
+}
+
+func (n *googlePlayNotification) kind() string {
+	switch {
+	case n.SubscriptionNotification != nil:
+		return fmt.Sprintf("subscription:%d", n.SubscriptionNotification.NotificationType)
+	case n.VoidedPurchaseNotification != nil:
+		return "voided"
+	case n.TestNotification != nil:
+		return "test"
+	}
+	return "other"
+}
+
+// ProcessGooglePlayNotification handles a Real-time developer notification
+// pushed by Pub/Sub. The notification only names the purchase, so its current
+// state is fetched from the Play Developer API. An error asks Pub/Sub to retry.
+func (s *subscriptionService) ProcessGooglePlayNotification(ctx context.Context, bearerToken, queryToken string, body []byte) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := s.authenticatePubSub(subCtx, bearerToken, queryToken); err != nil {
+		return err
+	}
+
+	var push pubSubPush
+	if err := json.Unmarshal(body, &push); err != nil || push.Message.MessageID == "" {
+		return ErrWebhookMalformed
+	}
+	data, err := base64.StdEncoding.DecodeString(push.Message.Data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookMalformed, err)
+	}
+	var n googlePlayNotification
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookMalformed, err)
+	}
+
+	var payload map[string]any
+	_ = json.Unmarshal(data, &payload)
+
+	return s.handleNotification(subCtx, &model.StoreNotification{
+		Provider:         infrastructure.ReceiptProviderGooglePlay,
+		NotificationID:   push.Message.MessageID,
+		NotificationType: n.kind(),
+		Payload:          payload,
+	}, func() error {
+		switch {
+		case n.SubscriptionNotification != nil:
+			return s.applyGooglePlaySubscription(subCtx, &n, payload)
+		case n.VoidedPurchaseNotification != nil:
+			return s.applyGooglePlayVoided(subCtx, &n, payload)
+		}
+		return nil
+	})
+}
+
+// handleNotification runs apply once per notification. A failed run is released
+// so that the store's redelivery is processed again.
+func (s *subscriptionService) handleNotification(ctx context.Context, n *model.StoreNotification, apply func() error) error {
+	claimed, processed, err := s.repo.ClaimNotification(ctx, n, storeNotificationLease)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		if !processed {
+			return ErrWebhookInProgress
+		}
+		log.Info().Str("provider", n.Provider).Str("notification_id", n.NotificationID).Msg("Store notification already processed")
+		return nil
+	}
+
+	if err := apply(); err != nil {
+		if releaseErr := s.repo.ReleaseNotification(ctx, n.Provider, n.NotificationID); releaseErr != nil {
+			log.Error().Err(releaseErr).Str("notification_id", n.NotificationID).Msg("Failed to release store notification")
+		}
+		return err
+	}
+	if err := s.repo.CompleteNotification(ctx, n.Provider, n.NotificationID); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("provider", n.Provider).
+		Str("notification_id", n.NotificationID).
+		Str("type", n.NotificationType).
+		Msg("Store notification processed")
+	return nil
+}
+
+func (s *subscriptionService) applyGooglePlaySubscription(ctx context.Context, n *googlePlayNotification, payload map[string]any) error {
+	app, err := s.appRepo.FindByPackageName(ctx, n.PackageName)
+	if err != nil {
+		return err
+	}
+	if app == nil {
+		log.Warn().Str("package_name", n.PackageName).Msg("Play notification for an unknown application")
+		return nil
+	}
+	verifier := s.verifiers[infrastructure.ReceiptProviderGooglePlay]
+	if verifier == nil {
+		return errors.New("google play receipt verification is not configured")
+	}
+
+	sn := n.SubscriptionNotification
+	result, err := verifier.Verify(ctx, infrastructure.ReceiptRequest{
+		PackageName:   n.PackageName,
+		ProductID:     sn.SubscriptionID,
+		PurchaseToken: sn.PurchaseToken,
+	})
+	if err != nil {
+		if errors.Is(err, infrastructure.ErrReceiptInvalid) {
+			log.Warn().Err(err).Str("product_id", sn.SubscriptionID).Msg("Play notification for a purchase that does not verify")
+			return nil
+		}
+		return err
+	}
+	if sn.NotificationType == googlePlaySubscriptionRevoked {
+		result.Status = SubscriptionStatusExpired
+		result.Refunded = true
+		result.EndTime = time.Now()
+	}
+	if result.Raw == nil {
+		result.Raw = make(map[string]any)
+	}
+	result.Raw["notification"] = payload
+
+	sub, err := s.notifiedSubscription(ctx, result.OriginalTransactionID, sn.PurchaseToken)
+	if err != nil {
+		return err
+	}
+	previous := ""
+	if sub == nil {
+		sub = &model.Subscription{AppID: &app.ID, Platform: "android"}
+	} else {
+		previous = sub.Status
+	}
+	sub.PurchaseToken = sn.PurchaseToken
+
+	_, err = s.applyVerification(ctx, sub, previous, result)
+	return err
+}
+
+func (s *subscriptionService) applyGooglePlayVoided(ctx context.Context, n *googlePlayNotification, payload map[string]any) error {
+	vn := n.VoidedPurchaseNotification
+	sub, err := s.repo.FindByPurchaseToken(ctx, vn.PurchaseToken)
+	if err != nil {
+		return err
+	}
+
+	tx := &model.Transaction{
+		Currency:         "USD",
+		Provider:         infrastructure.ReceiptProviderGooglePlay,
+		Status:           "refunded",
+		ProviderResponse: map[string]any{"voided_notification": payload},
+	}
+	if vn.OrderID != "" {
+		tx.ProviderTransactionID = &vn.OrderID
+	}
+	if sub != nil {
+		tx.UserID, tx.AppID, tx.SubscriptionID = sub.UserID, sub.AppID, &sub.ID
+	}
+	if err := s.recordTransaction(ctx, tx); err != nil {
+		return err
+	}
+
+	// productType 1 is a subscription; a voided subscription ends right away.
+	if sub != nil && vn.ProductType == 1 {
+		previous := sub.Status
+		if err := ValidateSubscriptionTransition(previous, SubscriptionStatusRefunded); err != nil {
+			return err
+		}
+		sub.Status = SubscriptionStatusRefunded
+		sub.AutoRenew = false
+		if sub.EndTime.After(time.Now()) {
+			sub.EndTime = time.Now()
+		}
+		out, err := s.repo.Upsert(ctx, sub)
+		if err != nil {
+			return err
+		}
+		if previous != out.Status {
+			s.publishStatusChange(ctx, out, previous)
+		}
+	}
+	return nil
+}
+
+type appStoreNotification struct {
+	NotificationType string `json:"notificationType"`
+	Subtype          string `json:"subtype"`
+	NotificationUUID string `json:"notificationUUID"`
+	Data             struct {
+		BundleID              string `json:"bundleId"`
+		Environment           string `json:"environment"`
+		SignedTransactionInfo string `json:"signedTransactionInfo"`
+		SignedRenewalInfo     string `json:"signedRenewalInfo"`
+		Status                int    `json:"status"`
+	} `json:"data"`
+	SignedDate int64 `json:"signedDate"`
+}
+
+// ProcessAppStoreNotification handles an App Store Server Notification v2. The
+// signed payload and the transaction it carries are checked against Apple's root
+// certificate before anything is applied.
+func (s *subscriptionService) ProcessAppStoreNotification(ctx context.Context, body []byte) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 30*time.Second)
+	defer cancel()
+
+	var req struct {
+		SignedPayload string `json:"signedPayload"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.SignedPayload == "" {
+		return ErrWebhookMalformed
+	}
+
+	root := s.webhook.appStoreRoot
+	var n appStoreNotification
+	if err := infrastructure.VerifyAppStoreJWS(req.SignedPayload, root, &n); err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookUnauthorized, err)
+	}
+	if n.NotificationUUID == "" {
+		return ErrWebhookMalformed
+	}
+
+	var tx infrastructure.AppStoreTransaction
+	var renewal *infrastructure.AppStoreRenewalInfo
+	if n.Data.SignedTransactionInfo != "" {
+		if err := infrastructure.VerifyAppStoreJWS(n.Data.SignedTransactionInfo, root, &tx); err != nil {
+			return fmt.Errorf("%w: %v", ErrWebhookUnauthorized, err)
+		}
+	}
+	if n.Data.SignedRenewalInfo != "" {
+		renewal = &infrastructure.AppStoreRenewalInfo{}
+		if err := infrastructure.VerifyAppStoreJWS(n.Data.SignedRenewalInfo, root, renewal); err != nil {
+			return fmt.Errorf("%w: %v", ErrWebhookUnauthorized, err)
+		}
+	}
+
+	notificationType := n.NotificationType
+	if n.Subtype != "" {
+		notificationType += ":" + n.Subtype
+	}
+	payload := infrastructure.JSONObject(map[string]any{
+		"notification_type": n.NotificationType,
+		"subtype":           n.Subtype,
+		"environment":       n.Data.Environment,
+		"status":            n.Data.Status,
+		"signed_date":       n.SignedDate,
+		"transaction":       tx,
+		"renewal_info":      renewal,
+	})
+
+	return s.handleNotification(subCtx, &model.StoreNotification{
+		Provider:         infrastructure.ReceiptProviderAppStore,
+		NotificationID:   n.NotificationUUID,
+		NotificationType: notificationType,
+		Payload:          payload,
+	}, func() error {
+		if n.NotificationType == "TEST" || tx.OriginalTransactionID == "" {
+			return nil
+		}
+		return s.applyAppStoreTransaction(subCtx, &n, &tx, renewal, payload)
+	})
+}
+
+func (s *subscriptionService) applyAppStoreTransaction(ctx context.Context, n *appStoreNotification, tx *infrastructure.AppStoreTransaction, renewal *infrastructure.AppStoreRenewalInfo, payload map[string]any) error {
+	app, err := s.appRepo.FindByPackageName(ctx, n.Data.BundleID)
+	if err != nil {
+		return err
+	}
+	if app == nil {
+		log.Warn().Str("bundle_id", n.Data.BundleID).Msg("App Store notification for an unknown application")
+		return nil
+	}
+
+	status := n.Data.Status
+	if status == 0 {
+		// Notifications without a status, e.g. REFUND on older payloads.
+		status = 2
+		if tx.ExpiresDate > time.Now().UnixMilli() {
+			status = 1
+		}
+	}
+	result := infrastructure.AppStoreVerification(tx, renewal, status)
+	if n.NotificationType == "REFUND" || n.NotificationType == "REVOKE" {
+		result.Status = SubscriptionStatusExpired
+		result.Refunded = true
+	}
+	result.Raw = map[string]any{"notification": payload}
+
+	sub, err := s.notifiedSubscription(ctx, tx.OriginalTransactionID, "")
+	if err != nil {
+		return err
+	}
+	previous := ""
+	if sub == nil {
+		sub = &model.Subscription{AppID: &app.ID, Platform: "ios", PurchaseToken: tx.OriginalTransactionID}
+	} else {
+		previous = sub.Status
+	}
+
+	_, err = s.applyVerification(ctx, sub, previous, result)
+	return err
+}
+
+// notifiedSubscription finds the subscription a notification is about. Purchases
+// the app never reported are created without a user and are claimed by the first
+// account that reports them.
+func (s *subscriptionService) notifiedSubscription(ctx context.Context, originalTransactionID, purchaseToken string) (*model.Subscription, error) {
+	if originalTransactionID != "" {
+		sub, err := s.repo.FindByOriginalTransactionID(ctx, originalTransactionID)
+		if err != nil || sub != nil {
+			return sub, err
+		}
+	}
+	if purchaseToken != "" {
+		return s.repo.FindByPurchaseToken(ctx, purchaseToken)
+	}
+	return nil, nil
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+	"github.com/user/video-downloader-backend/internal/infrastructure"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+)
+
+var (
+	ErrQueueTaskNotFound     = errors.New("task not found")
+	ErrInvalidQueueTaskState = errors.New("task is not in a state that allows this")
+)
+
+var taskQueues = []string{infrastructure.QueueCritical, infrastructure.QueueDefault, infrastructure.QueueLow}
+
+// TaskQueueService lets admins look into the background task queues and act on
+// single tasks without going through Redis by hand.
+type TaskQueueService interface {
+	Queues(ctx context.Context) ([]model.TaskQueue, error)
+	ListTasks(ctx context.Context, queue, state string, page, limit int) ([]model.QueueTask, model.Pagination, error)
+	GetTask(ctx context.Context, queue, id string) (*model.QueueTask, error)
+	RetryTask(ctx context.Context, queue, id string) error
+	CancelTask(ctx context.Context, queue, id string) error
+	ArchiveTask(ctx context.Context, queue, id string) error
+}
+
+type taskQueueService struct {
+	inspector    *asynq.Inspector
+	downloadRepo repository.DownloadRepository
+}
+
+func NewTaskQueueService(inspector *asynq.Inspector, downloadRepo repository.DownloadRepository) TaskQueueService {
+	return &taskQueueService{
+		inspector:    inspector,
+		downloadRepo: downloadRepo,
+	}
+}
+
+func (s *taskQueueService) Queues(ctx context.Context) ([]model.TaskQueue, error) {
+	queues := make([]model.TaskQueue, 0, len(taskQueues))
+	for _, name := range taskQueues {
+		info, err := s.inspector.GetQueueInfo(name)
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			// Nothing has been enqueued on it yet.
+			queues = append(queues, model.TaskQueue{Queue: name})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get queue %s: %w", name, err)
+		}
+		queues = append(queues, model.TaskQueue{
+			Queue:     info.Queue,
+			Size:      info.Size,
+			Pending:   info.Pending,
+			Active:    info.Active,
+			Scheduled: info.Scheduled,
+			Retry:     info.Retry,
+			Archived:  info.Archived,
+			Completed: info.Completed,
+			Processed: info.Processed,
+			Failed:    info.Failed,
+			Paused:    info.Paused,
+			LatencyMs: info.Latency.Milliseconds(),
+		})
+	}
+	return queues, nil
+}
+
+func (s *taskQueueService) ListTasks(ctx context.Context, queue, state string, page, limit int) ([]model.QueueTask, model.Pagination, error) {
+	if !slices.Contains(taskQueues, queue) {
+		return nil, model.Pagination{}, ErrQueueTaskNotFound
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	if state == "" {
+		state = model.TaskStatePending
+	}
+
+	info, err := s.inspector.GetQueueInfo(queue)
+	if errors.Is(err, asynq.ErrQueueNotFound) {
+		return []model.QueueTask{}, model.Pagination{CurrentPage: page, Limit: limit}, nil
+	}
+	if err != nil {
+		return nil, model.Pagination{}, fmt.Errorf("failed to get queue %s: %w", queue, err)
+	}
+
+	opts := []asynq.ListOption{asynq.Page(page), asynq.PageSize(limit)}
+	var infos []*asynq.TaskInfo
+	var total int
+	switch state {
+	case model.TaskStatePending:
+		infos, err = s.inspector.ListPendingTasks(queue, opts...)
+		total = info.Pending
+	case model.TaskStateActive:
+		infos, err = s.inspector.ListActiveTasks(queue, opts...)
+		total = info.Active
+	case model.TaskStateScheduled:
+		infos, err = s.inspector.ListScheduledTasks(queue, opts...)
+		total = info.Scheduled
+	case model.TaskStateRetry:
+		infos, err = s.inspector.ListRetryTasks(queue, opts...)
+		total = info.Retry
+	case model.TaskStateArchived:
+		infos, err = s.inspector.ListArchivedTasks(queue, opts...)
+		total = info.Archived
+	case model.TaskStateCompleted:
+		infos, err = s.inspector.ListCompletedTasks(queue, opts...)
+		total = info.Completed
+	default:
+		return nil, model.Pagination{}, fmt.Errorf("%w: unknown state %q", ErrInvalidQueueTaskState, state)
+	}
+	if err != nil {
+		return nil, model.Pagination{}, fmt.Errorf("failed to list %s tasks: %w", state, err)
+	}
+
+	tasks := make([]model.QueueTask, 0, len(infos))
+	for _, ti := range infos {
+		tasks = append(tasks, toQueueTask(ti))
+	}
+
+	totalPages := (total + limit - 1) / limit
+	pagination := model.Pagination{
+		CurrentPage: page,
+		Limit:       limit,
+		TotalItems:  int64(total),
+		TotalPages:  totalPages,
+		HasNext:     page < totalPages,
+		HasPrev:     page > 1,
+	}
+	return tasks, pagination, nil
+}
+
+func (s *taskQueueService) GetTask(ctx context.Context, queue, id string) (*model.QueueTask, error) {
+	info, err := s.taskInfo(queue, id)
+	if err != nil {
+		return nil, err
+	}
+	task := toQueueTask(info)
+	return &task, nil
+}
+
+// RetryTask runs a scheduled, retrying or archived task now.
+func (s *taskQueueService) RetryTask(ctx context.Context, queue, id string) error {
+	info, err := s.taskInfo(queue, id)
+	if err != nil {
+		return err
+	}
+	switch info.State {
+	case asynq.TaskStateScheduled, asynq.TaskStateRetry, asynq.TaskStateArchived:
+	default:
+		return fmt.Errorf("%w: cannot retry a %s task", ErrInvalidQueueTaskState, info.State)
+	}
+	if err := s.inspector.RunTask(queue, id); err != nil {
+		return fmt.Errorf("failed to run task: %w", err)
+	}
+	return nil
+}
+
+// CancelTask stops an active task, or removes one that has not started yet. A
+// removed download is marked canceled, since no worker will ever report on it.
+func (s *taskQueueService) CancelTask(ctx context.Context, queue, id string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	info, err := s.taskInfo(queue, id)
+	if err != nil {
+		return err
+	}
+	switch info.State {
+	case asynq.TaskStateActive:
+		// The worker sees its context canceled and records the download itself.
+		if err := s.inspector.CancelProcessing(id); err != nil {
+			return fmt.Errorf("failed to cancel task: %w", err)
+		}
+		return nil
+	case asynq.TaskStatePending, asynq.TaskStateScheduled, asynq.TaskStateRetry:
+		if err := s.inspector.DeleteTask(queue, id); err != nil {
+			return fmt.Errorf("failed to delete task: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: cannot cancel a %s task", ErrInvalidQueueTaskState, info.State)
+	}
+
+	if info.Type != infrastructure.TypeVideoDownload && info.Type != infrastructure.TypeMp3Download {
+		return nil
+	}
+	var payload model.DownloadTask
+	if err := json.Unmarshal(info.Payload, &payload); err != nil {
+		log.Warn().Err(err).Str("task_id", id).Msg("Canceled download task has an unreadable payload")
+		return nil
+	}
+	if _, err := s.downloadRepo.MarkCanceled(subCtx, payload.ID, "canceled by an administrator"); err != nil {
+		return fmt.Errorf("failed to mark canceled download: %w", err)
+	}
+	return nil
+}
+
+// ArchiveTask parks a task that has not started in the archive, from where it
+// can be rerun later.
+func (s *taskQueueService) ArchiveTask(ctx context.Context, queue, id string) error {
+	info, err := s.taskInfo(queue, id)
+	if err != nil {
+		return err
+	}
+	switch info.State {
+	case asynq.TaskStatePending, asynq.TaskStateScheduled, asynq.TaskStateRetry:
+	default:
+		return fmt.Errorf("%w: cannot archive a %s task", ErrInvalidQueueTaskState, info.State)
+	}
+	if err := s.inspector.ArchiveTask(queue, id); err != nil {
+		return fmt.Errorf("failed to archive task: %w", err)
+	}
+	return nil
+}
+
+func (s *taskQueueService) taskInfo(queue, id string) (*asynq.TaskInfo, error) {
+	if !slices.Contains(taskQueues, queue) {
+		return nil, ErrQueueTaskNotFound
+	}
+	info, err := s.inspector.GetTaskInfo(queue, id)
+	if errors.Is(err, asynq.ErrQueueNotFound) || errors.Is(err, asynq.ErrTaskNotFound) {
+		return nil, ErrQueueTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	return info, nil
+}
+
+func toQueueTask(info *asynq.TaskInfo) model.QueueTask {
+	task := model.QueueTask{
+		ID:             info.ID,
+		Queue:          info.Queue,
+		Type:           info.Type,
+		State:          info.State.String(),
+		MaxRetry:       info.MaxRetry,
+		Retried:        info.Retried,
+		LastError:      info.LastErr,
+		TimeoutSeconds: int64(info.Timeout.Seconds()),
+	}
+	if json.Valid(info.Payload) {
+		task.Payload = info.Payload
+	}
+	if !info.LastFailedAt.IsZero() {
+		task.LastFailedAt = &info.LastFailedAt
+	}
+	if !info.NextProcessAt.IsZero() {
+		task.NextProcessAt = &info.NextProcessAt
+	}
+	if !info.CompletedAt.IsZero() {
+		task.CompletedAt = &info.CompletedAt
+	}
+	return task
+}
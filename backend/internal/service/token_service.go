@@ -1,36 +1,71 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"github.com/user/video-downloader-backend/internal/config"
 	"github.com/user/video-downloader-backend/internal/model"
 )
 
 type TokenService interface {
-	GenerateAccessToken(user *model.User) (string, error)
+	// GenerateAccessToken signs a short lived token for the session sessionID,
+	// which is the family of the refresh token it was issued with.
+	GenerateAccessToken(user *model.User, sessionID uuid.UUID) (string, error)
+	// GenerateRefreshToken returns an opaque refresh token and the hash it is
+	// stored under.
+	GenerateRefreshToken() (token string, tokenHash string, err error)
+	HashRefreshToken(token string) string
+	AccessTokenTTL() time.Duration
+	RefreshTokenTTL() time.Duration
 	ValidateToken(tokenString string) (*jwt.Token, error)
+	// IsRevoked reports whether the access token with these claims was revoked,
+	// on its own or with its session.
+	IsRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error)
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeSessions(ctx context.Context, sessionIDs ...uuid.UUID) error
 	GenerateCentrifugoToken(userID string) (string, error)
 }
 
 type tokenService struct {
-	cfg *config.Config
+	cfg         *config.Config
+	redisClient *redis.Client
 }
 
-func NewTokenService(cfg *config.Config) TokenService {
-	return &tokenService{cfg: cfg}
+func NewTokenService(cfg *config.Config, redisClient *redis.Client) TokenService {
+	return &tokenService{cfg: cfg, redisClient: redisClient}
 }
 
-func (s *tokenService) GenerateAccessToken(user *model.User) (string, error) {
-	expiryHour, _ := strconv.Atoi(s.cfg.JWTExpiryHour)
-	expiryTime := time.Now().Add(time.Duration(expiryHour) * time.Hour)
+func (s *tokenService) AccessTokenTTL() time.Duration {
+	if s.cfg.JWTAccessTTLMinutes <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(s.cfg.JWTAccessTTLMinutes) * time.Minute
+}
+
+func (s *tokenService) RefreshTokenTTL() time.Duration {
+	if s.cfg.JWTRefreshTTLDays <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(s.cfg.JWTRefreshTTLDays) * 24 * time.Hour
+}
+
+func (s *tokenService) GenerateAccessToken(user *model.User, sessionID uuid.UUID) (string, error) {
+	expiryTime := time.Now().Add(s.AccessTokenTTL())
 
 	var roleName string
+	var permissions []string
 	if user.Role != nil {
 		roleName = user.Role.Name
+		permissions = user.Role.Granted()
 	}
 
 	claims := jwt.MapClaims{
@@ -38,8 +73,13 @@ func (s *tokenService) GenerateAccessToken(user *model.User) (string, error) {
 		"email":     user.Email,
 		"role":      user.RoleID,
 		"role_name": roleName,
-		"exp":       expiryTime.Unix(),
-		"iat":       time.Now().Unix(),
+		// The role's permissions as of signing; role changes end the sessions holding them.
+		"perms":          permissions,
+		"email_verified": user.EmailVerifiedAt != nil,
+		"sid":            sessionID.String(),
+		"jti":            uuid.NewString(),
+		"exp":            expiryTime.Unix(),
+		"iat":            time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -55,23 +95,20 @@ func (s *tokenService) GenerateAccessToken(user *model.User) (string, error) {
 	return signedToken, nil
 }
 
-func (s *tokenService) GenerateRefreshToken(user *model.User) (string, error) {
-	expiryHour, _ := strconv.Atoi(s.cfg.JWTExpiryHour)
-	expiryTime := time.Now().Add(time.Duration(expiryHour) * time.Hour)
-
-	claims := jwt.MapClaims{
-		"sub": user.ID.String(),
-		"exp": expiryTime.Unix(),
-		"iat": time.Now().Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(s.cfg.JWTSecret))
-	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+func (s *tokenService) GenerateRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
 	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, s.HashRefreshToken(token), nil
+}
 
-	return signedToken, nil
+// HashRefreshToken is a plain SHA-256: refresh tokens are random, so there is
+// nothing to stretch.
+func (s *tokenService) HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func (s *tokenService) GenerateCentrifugoToken(userID string) (string, error) {
@@ -107,3 +144,60 @@ func (s *tokenService) ValidateToken(tokenString string) (*jwt.Token, error) {
 		return []byte(s.cfg.JWTSecret), nil
 	})
 }
+
+func revokedTokenKey(jti string) string {
+	return fmt.Sprintf("auth:revoked_jti:%s", jti)
+}
+
+func revokedSessionKey(sessionID string) string {
+	return fmt.Sprintf("auth:revoked_session:%s", sessionID)
+}
+
+func (s *tokenService) IsRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	var keys []string
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		keys = append(keys, revokedTokenKey(jti))
+	}
+	if sid, ok := claims["sid"].(string); ok && sid != "" {
+		keys = append(keys, revokedSessionKey(sid))
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	n, err := s.redisClient.Exists(ctx, keys...).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RevokeToken adds one access token to the revocation list until it would have
+// expired anyway.
+func (s *tokenService) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	if err := s.redisClient.Set(ctx, revokedTokenKey(jti), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeSessions rejects every access token already issued for the sessions.
+// No new ones can be issued once their refresh tokens are revoked, so the
+// entries only have to outlive the access token lifetime.
+func (s *tokenService) RevokeSessions(ctx context.Context, sessionIDs ...uuid.UUID) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	pipe := s.redisClient.Pipeline()
+	for _, id := range sessionIDs {
+		pipe.Set(ctx, revokedSessionKey(id.String()), 1, s.AccessTokenTTL())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
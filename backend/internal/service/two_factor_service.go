@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/video-downloader-backend/internal/infrastructure/contextpool"
+	"github.com/user/video-downloader-backend/internal/model"
+	"github.com/user/video-downloader-backend/internal/repository"
+	"github.com/user/video-downloader-backend/pkg/utils"
+)
+
+var (
+	ErrInvalidTwoFactorCode     = errors.New("invalid two-factor code")
+	ErrTooManyTwoFactorAttempts = errors.New("too many invalid two-factor codes, try again later")
+	ErrTwoFactorNotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorSetupExpired    = errors.New("two-factor setup expired, start it again")
+	ErrTwoFactorMandatory       = errors.New("two-factor authentication is mandatory for admin panel users")
+	ErrTwoFactorNotAvailable    = errors.New("two-factor authentication is only available to admin panel users")
+)
+
+const (
+	twoFactorSetupTTL = 10 * time.Minute
+	// Failed codes are counted per user across sign-in challenges and account
+	// pages alike; six digits do not survive unlimited guesses.
+	twoFactorMaxFailures   = 5
+	twoFactorLockout       = 15 * time.Minute
+	twoFactorUsedCodeTTL   = 2 * time.Minute
+	twoFactorRecoveryCodes = 10
+	twoFactorDefaultIssuer = "Video Downloader"
+)
+
+type TwoFactorService interface {
+	Status(ctx context.Context, userID uuid.UUID) (*model.TwoFactorStatus, error)
+	// Required reports whether the user may only sign in with a second factor.
+	Required(ctx context.Context, user *model.User) (bool, error)
+	// Setup generates a secret for the user to confirm with Enable.
+	Setup(ctx context.Context, userID uuid.UUID) (*model.TwoFactorSetup, error)
+	// Enable confirms the pending secret with a code from it and returns the
+	// recovery codes, which are only ever shown here.
+	Enable(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	// Verify accepts a current code or an unused recovery code, which is spent.
+	Verify(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+type twoFactorService struct {
+	repo        repository.TwoFactorRepository
+	userRepo    repository.UserRepository
+	settingRepo repository.SettingRepository
+	keyRing     *utils.KeyRing
+	redisClient *redis.Client
+}
+
+func NewTwoFactorService(repo repository.TwoFactorRepository, userRepo repository.UserRepository, settingRepo repository.SettingRepository, keyRing *utils.KeyRing, redisClient *redis.Client) TwoFactorService {
+	return &twoFactorService{
+		repo:        repo,
+		userRepo:    userRepo,
+		settingRepo: settingRepo,
+		keyRing:     keyRing,
+		redisClient: redisClient,
+	}
+}
+
+func (s *twoFactorService) Status(ctx context.Context, userID uuid.UUID) (*model.TwoFactorStatus, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	user, err := s.findUser(subCtx, userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := s.Required(subCtx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &model.TwoFactorStatus{
+		Enabled:   user.TwoFactorEnabledAt != nil,
+		EnabledAt: user.TwoFactorEnabledAt,
+		Required:  required,
+	}
+	if status.Enabled {
+		if status.RecoveryCodesRemaining, err = s.repo.CountRecoveryCodes(subCtx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+func (s *twoFactorService) Required(ctx context.Context, user *model.User) (bool, error) {
+	if user.Role == nil || !model.HasAdminAccess(user.Role.Permissions) {
+		return false, nil
+	}
+
+	setting, err := s.settingRepo.GetByKey(ctx, "default", model.SettingAdminTwoFactorRequired)
+	if err != nil {
+		return false, fmt.Errorf("failed to read two-factor setting: %w", err)
+	}
+	return setting != nil && setting.Value == "true", nil
+}
+
+func (s *twoFactorService) Setup(ctx context.Context, userID uuid.UUID) (*model.TwoFactorSetup, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	user, err := s.findUser(subCtx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == nil || !model.HasAdminAccess(user.Role.Permissions) {
+		return nil, ErrTwoFactorNotAvailable
+	}
+	if user.TwoFactorEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	// Secrets are only stored encrypted, so fail before the user scans one.
+	if _, _, err := s.keyRing.ActiveKey(); err != nil {
+		return nil, fmt.Errorf("two-factor authentication needs an encryption key: %w", err)
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisClient.Set(subCtx, twoFactorSetupKey(userID), secret, twoFactorSetupTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &model.TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.issuer(subCtx), user.Email, secret),
+		ExpiresIn:  int(twoFactorSetupTTL.Seconds()),
+	}, nil
+}
+
+func (s *twoFactorService) Enable(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	if err := s.checkFailures(subCtx, userID); err != nil {
+		return nil, err
+	}
+
+	secret, err := s.redisClient.Get(subCtx, twoFactorSetupKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, ErrTwoFactorSetupExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.matchCode(subCtx, userID, secret, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.fail(subCtx, userID)
+	}
+
+	sealed, err := s.keyRing.Encrypt([]byte(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt two-factor secret: %w", err)
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Enable(subCtx, userID, sealed, hashes); err != nil {
+		return nil, err
+	}
+
+	s.redisClient.Del(subCtx, twoFactorSetupKey(userID), twoFactorFailuresKey(userID))
+	return codes, nil
+}
+
+func (s *twoFactorService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	user, err := s.findUser(subCtx, userID)
+	if err != nil {
+		return err
+	}
+	required, err := s.Required(subCtx, user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorMandatory
+	}
+
+	if err := s.Verify(subCtx, userID, code); err != nil {
+		return err
+	}
+	return s.repo.Disable(subCtx, userID)
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	if err := s.Verify(subCtx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(subCtx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *twoFactorService) Verify(ctx context.Context, userID uuid.UUID, code string) error {
+	subCtx, cancel := contextpool.WithTimeoutIfNone(ctx, 15*time.Second)
+	defer cancel()
+
+	if err := s.checkFailures(subCtx, userID); err != nil {
+		return err
+	}
+
+	sealed, err := s.repo.GetSecret(subCtx, userID)
+	if err != nil {
+		return err
+	}
+	if sealed == nil {
+		return ErrTwoFactorNotEnabled
+	}
+	secret, err := s.keyRing.Decrypt(sealed)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt two-factor secret: %w", err)
+	}
+
+	ok, err := s.matchCode(subCtx, userID, string(secret), code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if ok, err = s.repo.UseRecoveryCode(subCtx, userID, hashRecoveryCode(code)); err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return s.fail(subCtx, userID)
+	}
+
+	s.redisClient.Del(subCtx, twoFactorFailuresKey(userID))
+	return nil
+}
+
+// matchCode checks a TOTP code and refuses one that was already used, so an
+// observed code cannot be replayed within its validity window.
+func (s *twoFactorService) matchCode(ctx context.Context, userID uuid.UUID, secret, code string) (bool, error) {
+	step, ok := utils.MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	key := fmt.Sprintf("auth:2fa_used:%s:%d", userID, step)
+	return s.redisClient.SetNX(ctx, key, 1, twoFactorUsedCodeTTL).Result()
+}
+
+func (s *twoFactorService) checkFailures(ctx context.Context, userID uuid.UUID) error {
+	failures, err := s.redisClient.Get(ctx, twoFactorFailuresKey(userID)).Int()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if failures >= twoFactorMaxFailures {
+		return ErrTooManyTwoFactorAttempts
+	}
+	return nil
+}
+
+func (s *twoFactorService) fail(ctx context.Context, userID uuid.UUID) error {
+	key := twoFactorFailuresKey(userID)
+	failures, err := s.redisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if failures == 1 {
+		s.redisClient.Expire(ctx, key, twoFactorLockout)
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+func (s *twoFactorService) findUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// issuer names the account in authenticator apps after the site.
+func (s *twoFactorService) issuer(ctx context.Context) string {
+	if setting, err := s.settingRepo.GetByKey(ctx, "default", "site_name"); err == nil && setting != nil && setting.Value != "" {
+		return setting.Value
+	}
+	return twoFactorDefaultIssuer
+}
+
+func newRecoveryCodes() (codes []string, hashes []string, err error) {
+	for range twoFactorRecoveryCodes {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(utils.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+func twoFactorSetupKey(userID uuid.UUID) string {
+	return fmt.Sprintf("auth:2fa_setup:%s", userID)
+}
+
+func twoFactorFailuresKey(userID uuid.UUID) string {
+	return fmt.Sprintf("auth:2fa_failures:%s", userID)
+}
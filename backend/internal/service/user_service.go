@@ -4,9 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/url"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -55,20 +53,10 @@ func (s *userService) UploadAvatar(ctx context.Context, userID uuid.UUID, file i
 
 	currentUser, err := s.repo.FindByID(subCtx, userID)
 	if err == nil && currentUser != nil && currentUser.AvatarURL != nil && *currentUser.AvatarURL != "" {
-		oldAvatarURL := *currentUser.AvatarURL
-
-		parsedURL, err := url.Parse(oldAvatarURL)
-		if err == nil {
-			path := parsedURL.Path
-			path = strings.TrimPrefix(path, "/")
-
-			if strings.HasPrefix(path, s.cfg.MinioBucket+"/") {
-				objectName := strings.TrimPrefix(path, s.cfg.MinioBucket+"/")
-
-				log.Info().Str("userID", userID.String()).Str("object", objectName).Msg("Deleting old avatar")
-				if err := s.storageClient.DeleteFile(subCtx, s.cfg.MinioBucket, objectName); err != nil {
-					log.Error().Err(err).Str("object", objectName).Msg("Failed to delete old avatar")
-				}
+		if objectName, ok := infrastructure.ObjectNameFromURL(*currentUser.AvatarURL, s.cfg.MinioBucket); ok {
+			log.Info().Str("userID", userID.String()).Str("object", objectName).Msg("Deleting old avatar")
+			if err := s.storageClient.DeleteFile(subCtx, s.cfg.MinioBucket, objectName); err != nil {
+				log.Error().Err(err).Str("object", objectName).Msg("Failed to delete old avatar")
 			}
 		}
 	}
@@ -0,0 +1,331 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Chunked encryption format, used for media stored in object storage:
+//
+//	header: "VDSE" | version (1) | chunk size (uint32) | key ID length (1) | key ID
+//	chunks: nonce (12) | AES-GCM ciphertext of up to chunk size bytes | tag (16)
+//
+// Every chunk is authenticated together with the header, its index and a flag
+// marking the last chunk, so chunks cannot be reordered, swapped between files or
+// dropped from the end. Chunks can be decrypted independently to serve ranges.
+const (
+	ChunkedEncryptionScheme = "aead-chunked-v1"
+	DefaultEncryptChunkSize = 64 * 1024
+	ChunkedHeaderMaxSize    = chunkedHeaderFixedSize + 255
+
+	chunkedMagic           = "VDSE"
+	chunkedVersion         = 1
+	chunkedHeaderFixedSize = 10
+	chunkNonceSize         = 12
+	chunkTagSize           = 16
+	chunkOverhead          = chunkNonceSize + chunkTagSize
+)
+
+var ErrInvalidChunkedData = errors.New("invalid chunked ciphertext")
+
+// EncryptionKeyID fingerprints a hex key so ciphertexts can name the key they need
+// without revealing it.
+func EncryptionKeyID(keyHex string) string {
+	sum := sha256.Sum256([]byte(keyHex))
+	return hex.EncodeToString(sum[:4])
+}
+
+func newAEAD(keyHex string) (cipher.AEAD, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key hex: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type ChunkedHeader struct {
+	ChunkSize int
+	KeyID     string
+	raw       []byte
+}
+
+func (h *ChunkedHeader) Len() int64 {
+	return int64(len(h.raw))
+}
+
+// ParseChunkedHeader reads the header from the start of a chunked ciphertext. b may
+// hold more than the header; up to ChunkedHeaderMaxSize bytes are enough.
+func ParseChunkedHeader(b []byte) (*ChunkedHeader, error) {
+	if len(b) < chunkedHeaderFixedSize || string(b[:4]) != chunkedMagic {
+		return nil, ErrInvalidChunkedData
+	}
+	if b[4] != chunkedVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidChunkedData, b[4])
+	}
+	chunkSize := int(binary.BigEndian.Uint32(b[5:9]))
+	keyIDLen := int(b[9])
+	if chunkSize <= 0 || len(b) < chunkedHeaderFixedSize+keyIDLen {
+		return nil, ErrInvalidChunkedData
+	}
+
+	size := chunkedHeaderFixedSize + keyIDLen
+	raw := make([]byte, size)
+	copy(raw, b[:size])
+	return &ChunkedHeader{
+		ChunkSize: chunkSize,
+		KeyID:     string(b[chunkedHeaderFixedSize:size]),
+		raw:       raw,
+	}, nil
+}
+
+func encodeChunkedHeader(chunkSize int, keyID string) ([]byte, error) {
+	if len(keyID) > 255 {
+		return nil, fmt.Errorf("key id too long")
+	}
+	raw := make([]byte, chunkedHeaderFixedSize+len(keyID))
+	copy(raw, chunkedMagic)
+	raw[4] = chunkedVersion
+	binary.BigEndian.PutUint32(raw[5:9], uint32(chunkSize))
+	raw[9] = byte(len(keyID))
+	copy(raw[chunkedHeaderFixedSize:], keyID)
+	return raw, nil
+}
+
+func chunkAAD(header []byte, index uint64, final bool) []byte {
+	aad := make([]byte, len(header)+9)
+	copy(aad, header)
+	binary.BigEndian.PutUint64(aad[len(header):], index)
+	if final {
+		aad[len(aad)-1] = 1
+	}
+	return aad
+}
+
+func (h *ChunkedHeader) chunkCount(cipherSize int64) (int64, error) {
+	body := cipherSize - h.Len()
+	full := int64(h.ChunkSize + chunkOverhead)
+	if body < chunkOverhead {
+		return 0, ErrInvalidChunkedData
+	}
+	n := (body + full - 1) / full
+	if last := body - (n-1)*full; last < chunkOverhead {
+		return 0, ErrInvalidChunkedData
+	}
+	return n, nil
+}
+
+// PlaintextSize derives the decrypted size from the ciphertext size.
+func (h *ChunkedHeader) PlaintextSize(cipherSize int64) (int64, error) {
+	n, err := h.chunkCount(cipherSize)
+	if err != nil {
+		return 0, err
+	}
+	return cipherSize - h.Len() - n*chunkOverhead, nil
+}
+
+// ChunkSpan returns the ciphertext bytes that must be fetched to decrypt the
+// plaintext range [offset, offset+length).
+func (h *ChunkedHeader) ChunkSpan(offset, length, cipherSize int64) (cipherOffset, cipherLength int64) {
+	full := int64(h.ChunkSize + chunkOverhead)
+	first := offset / int64(h.ChunkSize)
+	last := first
+	if length > 0 {
+		last = (offset + length - 1) / int64(h.ChunkSize)
+	}
+
+	cipherOffset = h.Len() + first*full
+	end := h.Len() + (last+1)*full
+	if end > cipherSize {
+		end = cipherSize
+	}
+	return cipherOffset, end - cipherOffset
+}
+
+// ChunkedCiphertextSize returns the size NewChunkedEncryptWriter produces for
+// plainSize bytes under keyID. Even empty input is sealed into one final chunk.
+func ChunkedCiphertextSize(plainSize int64, keyID string) int64 {
+	chunks := (plainSize + DefaultEncryptChunkSize - 1) / DefaultEncryptChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return int64(chunkedHeaderFixedSize+len(keyID)) + plainSize + chunks*chunkOverhead
+}
+
+type chunkedEncryptWriter struct {
+	dst    io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	size   int
+	index  uint64
+	closed bool
+}
+
+// NewChunkedEncryptWriter writes the header to dst and encrypts everything written
+// to the returned writer chunk by chunk. Close must be called to seal the last chunk.
+func NewChunkedEncryptWriter(dst io.Writer, keyHex string, keyID string) (io.WriteCloser, error) {
+	aead, err := newAEAD(keyHex)
+	if err != nil {
+		return nil, err
+	}
+	header, err := encodeChunkedHeader(DefaultEncryptChunkSize, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &chunkedEncryptWriter{
+		dst:    dst,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, DefaultEncryptChunkSize),
+		size:   DefaultEncryptChunkSize,
+	}, nil
+}
+
+func (w *chunkedEncryptWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, because the last
+		// chunk has to carry the final flag.
+		if len(w.buf) == w.size {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := w.size - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *chunkedEncryptWriter) seal(final bool) error {
+	nonce := make([]byte, chunkNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	out := w.aead.Seal(nonce, nonce, w.buf, chunkAAD(w.header, w.index, final))
+	if _, err := w.dst.Write(out); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	w.index++
+	return nil
+}
+
+func (w *chunkedEncryptWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+type chunkedDecryptReader struct {
+	src       io.Reader
+	aead      cipher.AEAD
+	header    *ChunkedHeader
+	index     uint64
+	total     uint64
+	skip      int64
+	remaining int64
+	cipherBuf []byte
+	plain     []byte
+}
+
+// NewChunkedDecryptReader decrypts the plaintext range [offset, offset+length) of a
+// chunked ciphertext of cipherSize bytes. src must yield the ciphertext starting at
+// the offset returned by ChunkSpan.
+func NewChunkedDecryptReader(src io.Reader, keyHex string, header *ChunkedHeader, cipherSize, offset, length int64) (io.Reader, error) {
+	aead, err := newAEAD(keyHex)
+	if err != nil {
+		return nil, err
+	}
+	total, err := header.chunkCount(cipherSize)
+	if err != nil {
+		return nil, err
+	}
+
+	first := offset / int64(header.ChunkSize)
+	return &chunkedDecryptReader{
+		src:       src,
+		aead:      aead,
+		header:    header,
+		index:     uint64(first),
+		total:     uint64(total),
+		skip:      offset - first*int64(header.ChunkSize),
+		remaining: length,
+		cipherBuf: make([]byte, header.ChunkSize+chunkOverhead),
+	}, nil
+}
+
+func (r *chunkedDecryptReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	for len(r.plain) == 0 {
+		if r.index >= r.total {
+			return 0, io.ErrUnexpectedEOF
+		}
+		n, err := io.ReadFull(r.src, r.cipherBuf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		final := r.index == r.total-1
+		if n < chunkOverhead || (!final && n != len(r.cipherBuf)) {
+			return 0, ErrInvalidChunkedData
+		}
+
+		nonce, sealed := r.cipherBuf[:chunkNonceSize], r.cipherBuf[chunkNonceSize:n]
+		plain, err := r.aead.Open(sealed[:0], nonce, sealed, chunkAAD(r.header.raw, r.index, final))
+		if err != nil {
+			return 0, fmt.Errorf("%w: chunk %d: %v", ErrInvalidChunkedData, r.index, err)
+		}
+		r.index++
+
+		if r.skip > 0 {
+			if r.skip >= int64(len(plain)) {
+				r.skip -= int64(len(plain))
+				continue
+			}
+			plain = plain[r.skip:]
+			r.skip = 0
+		}
+		r.plain = plain
+	}
+
+	n := len(p)
+	if n > len(r.plain) {
+		n = len(r.plain)
+	}
+	if int64(n) > r.remaining {
+		n = int(r.remaining)
+	}
+	copy(p, r.plain[:n])
+	r.plain = r.plain[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+const (
+	testKeyHex      = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	otherTestKeyHex = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+)
+
+func encryptChunked(t *testing.T, plain []byte, keyHex string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewChunkedEncryptWriter(&buf, keyHex, EncryptionKeyID(keyHex))
+	if err != nil {
+		t.Fatalf("NewChunkedEncryptWriter: %v", err)
+	}
+	// Odd write sizes make sure chunking does not depend on how data arrives.
+	for rest := plain; len(rest) > 0; {
+		n := min(len(rest), 7919)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decryptChunkedRange(cipherData []byte, keyHex string, offset, length int64) ([]byte, error) {
+	header, err := ParseChunkedHeader(cipherData)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(cipherData))
+	cipherOffset, cipherLength := header.ChunkSpan(offset, length, size)
+	src := bytes.NewReader(cipherData[cipherOffset : cipherOffset+cipherLength])
+	r, err := NewChunkedDecryptReader(src, keyHex, header, size, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestChunkedEncryptionRoundTrip(t *testing.T) {
+	const chunk = DefaultEncryptChunkSize
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"one byte", 1},
+		{"just under a chunk", chunk - 1},
+		{"exactly one chunk", chunk},
+		{"just over a chunk", chunk + 1},
+		{"exactly two chunks", 2 * chunk},
+		{"several chunks", 3*chunk + 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := randomBytes(t, tt.size)
+			cipherData := encryptChunked(t, plain, testKeyHex)
+
+			header, err := ParseChunkedHeader(cipherData)
+			if err != nil {
+				t.Fatalf("ParseChunkedHeader: %v", err)
+			}
+			if header.KeyID != EncryptionKeyID(testKeyHex) {
+				t.Errorf("KeyID = %q, want %q", header.KeyID, EncryptionKeyID(testKeyHex))
+			}
+			if want := ChunkedCiphertextSize(int64(tt.size), header.KeyID); int64(len(cipherData)) != want {
+				t.Errorf("ciphertext is %d bytes, ChunkedCiphertextSize = %d", len(cipherData), want)
+			}
+			size, err := header.PlaintextSize(int64(len(cipherData)))
+			if err != nil {
+				t.Fatalf("PlaintextSize: %v", err)
+			}
+			if size != int64(tt.size) {
+				t.Errorf("PlaintextSize = %d, want %d", size, tt.size)
+			}
+
+			got, err := decryptChunkedRange(cipherData, testKeyHex, 0, size)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decrypted %d bytes differ from the %d written", len(got), len(plain))
+			}
+		})
+	}
+}
+
+func TestChunkedDecryptRange(t *testing.T) {
+	const chunk = DefaultEncryptChunkSize
+	plain := randomBytes(t, 3*chunk+17)
+	cipherData := encryptChunked(t, plain, testKeyHex)
+
+	tests := []struct {
+		name           string
+		offset, length int64
+	}{
+		{"first byte", 0, 1},
+		{"inside the first chunk", 100, 1000},
+		{"across a chunk boundary", chunk - 10, 20},
+		{"starts on a boundary", chunk, chunk},
+		{"spans every chunk", 1, 3*chunk + 15},
+		{"last byte", 3*chunk + 16, 1},
+		{"tail of the last chunk", 3 * chunk, 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptChunkedRange(cipherData, testKeyHex, tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			want := plain[tt.offset : tt.offset+tt.length]
+			if !bytes.Equal(got, want) {
+				t.Errorf("range [%d, %d) decrypted incorrectly", tt.offset, tt.offset+tt.length)
+			}
+		})
+	}
+}
+
+func TestChunkedDecryptRejectsTampering(t *testing.T) {
+	const chunk = DefaultEncryptChunkSize
+	full := int(chunk + chunkOverhead)
+	plain := randomBytes(t, 2*chunk+100)
+	cipherData := encryptChunked(t, plain, testKeyHex)
+	headerLen := chunkedHeaderFixedSize + len(EncryptionKeyID(testKeyHex))
+
+	tests := []struct {
+		name   string
+		keyHex string
+		mutate func([]byte) []byte
+	}{
+		{"wrong key", otherTestKeyHex, func(b []byte) []byte { return b }},
+		{"flipped ciphertext byte", testKeyHex, func(b []byte) []byte {
+			b[headerLen+chunkNonceSize+5] ^= 1
+			return b
+		}},
+		{"altered key id", testKeyHex, func(b []byte) []byte {
+			b[chunkedHeaderFixedSize] ^= 1
+			return b
+		}},
+		{"swapped chunks", testKeyHex, func(b []byte) []byte {
+			first := append([]byte(nil), b[headerLen:headerLen+full]...)
+			copy(b[headerLen:], b[headerLen+full:headerLen+2*full])
+			copy(b[headerLen+full:], first)
+			return b
+		}},
+		{"last chunk dropped", testKeyHex, func(b []byte) []byte {
+			return b[:headerLen+2*full]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.mutate(append([]byte(nil), cipherData...))
+			header, err := ParseChunkedHeader(data)
+			if err != nil {
+				t.Fatalf("ParseChunkedHeader: %v", err)
+			}
+			size, err := header.PlaintextSize(int64(len(data)))
+			if err != nil {
+				t.Fatalf("PlaintextSize: %v", err)
+			}
+			_, err = decryptChunkedRange(data, tt.keyHex, 0, size)
+			if !errors.Is(err, ErrInvalidChunkedData) {
+				t.Errorf("decrypt error = %v, want ErrInvalidChunkedData", err)
+			}
+		})
+	}
+}
+
+func TestParseChunkedHeader(t *testing.T) {
+	valid, err := encodeChunkedHeader(DefaultEncryptChunkSize, "abcd1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"valid with trailing data", append(append([]byte(nil), valid...), 1, 2, 3), false},
+		{"too short", valid[:chunkedHeaderFixedSize-1], true},
+		{"key id cut off", valid[:len(valid)-1], true},
+		{"bad magic", append([]byte("XXXX"), valid[4:]...), true},
+		{"unknown version", append(append([]byte(nil), valid[:4]...), append([]byte{2}, valid[5:]...)...), true},
+		{"zero chunk size", append(append([]byte(nil), valid[:5]...), append([]byte{0, 0, 0, 0}, valid[9:]...)...), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseChunkedHeader(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidChunkedData) {
+					t.Errorf("error = %v, want ErrInvalidChunkedData", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if h.ChunkSize != DefaultEncryptChunkSize || h.KeyID != "abcd1234" || h.Len() != int64(len(valid)) {
+				t.Errorf("got chunk size %d, key id %q, len %d", h.ChunkSize, h.KeyID, h.Len())
+			}
+		})
+	}
+}
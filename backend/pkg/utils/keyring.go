@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Versioned blob format for data kept in the database:
+//
+//	"VDK1" | key ID length (1) | key ID | nonce (12) | AES-GCM ciphertext and tag
+//
+// The header is authenticated as additional data. Blobs without the prefix were
+// written by EncryptData before key IDs existed.
+const versionedBlobMagic = "VDK1"
+
+var ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+
+// KeyRing holds every key still needed to read stored data. New data is encrypted
+// with the active key only; retired keys stay in the ring until the worker's
+// reencrypt command has moved their data to the active one.
+type KeyRing struct {
+	activeID string
+	keys     map[string]string
+	order    []string
+}
+
+// NewKeyRing parses a ring of the form "id:hex,id:hex". activeID selects the key
+// for new data and defaults to the first entry. legacyKey is the single key of
+// deployments without a ring; it stays readable under its EncryptionKeyID and is
+// the active key when the ring is empty. An invalid legacy key is ignored so that
+// unencrypted deployments keep starting.
+func NewKeyRing(spec, activeID, legacyKey string) (*KeyRing, error) {
+	ring := &KeyRing{keys: make(map[string]string)}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, keyHex, found := strings.Cut(entry, ":")
+		id, keyHex = strings.TrimSpace(id), strings.TrimSpace(keyHex)
+		if !found || id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("invalid key ring entry %q", entry)
+		}
+		if err := validateKeyHex(keyHex); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		if _, exists := ring.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		ring.keys[id] = keyHex
+		ring.order = append(ring.order, id)
+	}
+
+	if legacyKey = strings.TrimSpace(legacyKey); legacyKey != "" && validateKeyHex(legacyKey) == nil {
+		id := EncryptionKeyID(legacyKey)
+		if _, exists := ring.keys[id]; !exists {
+			ring.keys[id] = legacyKey
+			ring.order = append(ring.order, id)
+		}
+	}
+
+	activeID = strings.TrimSpace(activeID)
+	if activeID == "" && len(ring.order) > 0 {
+		activeID = ring.order[0]
+	}
+	if activeID != "" {
+		if _, ok := ring.keys[activeID]; !ok {
+			return nil, fmt.Errorf("active key %q is not in the key ring", activeID)
+		}
+	}
+	ring.activeID = activeID
+
+	return ring, nil
+}
+
+func validateKeyHex(keyHex string) error {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return fmt.Errorf("invalid key hex: %w", err)
+	}
+	if len(key) != 32 {
+		return errors.New("encryption key must be 32 bytes (256-bit) after hex decoding")
+	}
+	return nil
+}
+
+func (r *KeyRing) ActiveID() string {
+	return r.activeID
+}
+
+// ActiveKey returns the key new data must be encrypted with.
+func (r *KeyRing) ActiveKey() (id, keyHex string, err error) {
+	if r.activeID == "" {
+		return "", "", errors.New("no encryption key configured")
+	}
+	return r.activeID, r.keys[r.activeID], nil
+}
+
+func (r *KeyRing) Key(id string) (string, error) {
+	keyHex, ok := r.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownEncryptionKey, id)
+	}
+	return keyHex, nil
+}
+
+// Encrypt seals data with the active key into a versioned blob.
+func (r *KeyRing) Encrypt(data []byte) ([]byte, error) {
+	id, keyHex, err := r.ActiveKey()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(keyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(versionedBlobMagic)+1+len(id))
+	header = append(header, versionedBlobMagic...)
+	header = append(header, byte(len(id)))
+	header = append(header, id...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, data, header), nil
+}
+
+// BlobKeyID returns the key ID of a versioned blob, or "" for a legacy blob.
+func BlobKeyID(data []byte) string {
+	id, _, ok := splitVersionedBlob(data)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+func splitVersionedBlob(data []byte) (id string, headerLen int, ok bool) {
+	if !bytes.HasPrefix(data, []byte(versionedBlobMagic)) || len(data) < len(versionedBlobMagic)+1 {
+		return "", 0, false
+	}
+	n := int(data[len(versionedBlobMagic)])
+	headerLen = len(versionedBlobMagic) + 1 + n
+	if len(data) < headerLen+chunkOverhead {
+		return "", 0, false
+	}
+	return string(data[len(versionedBlobMagic)+1 : headerLen]), headerLen, true
+}
+
+// Decrypt opens a versioned blob with the key it names. Legacy blobs carry no key
+// ID, so every key of the ring is tried on them.
+func (r *KeyRing) Decrypt(data []byte) ([]byte, error) {
+	if id, headerLen, ok := splitVersionedBlob(data); ok {
+		if keyHex, found := r.keys[id]; found {
+			aead, err := newAEAD(keyHex)
+			if err != nil {
+				return nil, err
+			}
+			nonce := data[headerLen : headerLen+aead.NonceSize()]
+			plain, err := aead.Open(nil, nonce, data[headerLen+aead.NonceSize():], data[:headerLen])
+			if err == nil {
+				return plain, nil
+			}
+		}
+		// A legacy blob whose random nonce happens to start with the magic falls
+		// through to the legacy path below.
+	}
+
+	for _, id := range r.order {
+		if plain, err := DecryptData(data, r.keys[id]); err == nil {
+			return plain, nil
+		}
+	}
+	if id := BlobKeyID(data); id != "" {
+		if _, found := r.keys[id]; !found {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownEncryptionKey, id)
+		}
+	}
+	return nil, errors.New("failed to decrypt data with any configured key")
+}
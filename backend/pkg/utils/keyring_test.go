@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewKeyRing(t *testing.T) {
+	legacyID := EncryptionKeyID(otherTestKeyHex)
+
+	tests := []struct {
+		name       string
+		spec       string
+		activeID   string
+		legacyKey  string
+		wantActive string
+		wantErr    bool
+	}{
+		{name: "empty", wantActive: ""},
+		{name: "first entry is active by default", spec: "k1:" + testKeyHex + ",k2:" + otherTestKeyHex, wantActive: "k1"},
+		{name: "explicit active key", spec: "k1:" + testKeyHex + ",k2:" + otherTestKeyHex, activeID: "k2", wantActive: "k2"},
+		{name: "whitespace around entries", spec: " k1 : " + testKeyHex + " , ", wantActive: "k1"},
+		{name: "legacy key only", legacyKey: otherTestKeyHex, wantActive: legacyID},
+		{name: "ring wins over legacy key", spec: "k1:" + testKeyHex, legacyKey: otherTestKeyHex, wantActive: "k1"},
+		{name: "invalid legacy key is ignored", legacyKey: "not-hex", wantActive: ""},
+		{name: "missing separator", spec: "k1" + testKeyHex, wantErr: true},
+		{name: "empty id", spec: ":" + testKeyHex, wantErr: true},
+		{name: "short key", spec: "k1:0011", wantErr: true},
+		{name: "duplicate id", spec: "k1:" + testKeyHex + ",k1:" + otherTestKeyHex, wantErr: true},
+		{name: "unknown active key", spec: "k1:" + testKeyHex, activeID: "k9", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := NewKeyRing(tt.spec, tt.activeID, tt.legacyKey)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ring.ActiveID() != tt.wantActive {
+				t.Errorf("ActiveID = %q, want %q", ring.ActiveID(), tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestKeyRingDecrypt(t *testing.T) {
+	oldRing, err := NewKeyRing("old:"+otherTestKeyHex, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewKeyRing("new:"+testKeyHex+",old:"+otherTestKeyHex, "new", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newOnly, err := NewKeyRing("new:"+testKeyHex, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := []byte("totp secret")
+	underOld, err := oldRing.Encrypt(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	underNew, err := rotated.Encrypt(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := EncryptData(plain, otherTestKeyHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), underNew...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name      string
+		ring      *KeyRing
+		data      []byte
+		wantKeyID string
+		wantErr   bool
+		// wantUnknownKey asks for ErrUnknownEncryptionKey in particular.
+		wantUnknownKey bool
+	}{
+		{name: "active key", ring: rotated, data: underNew, wantKeyID: "new"},
+		{name: "retired key still in the ring", ring: rotated, data: underOld, wantKeyID: "old"},
+		{name: "legacy blob", ring: rotated, data: legacy, wantKeyID: ""},
+		{name: "key no longer in the ring", ring: newOnly, data: underOld, wantKeyID: "old", wantErr: true, wantUnknownKey: true},
+		{name: "tampered blob", ring: rotated, data: tampered, wantKeyID: "new", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if id := BlobKeyID(tt.data); id != tt.wantKeyID {
+				t.Errorf("BlobKeyID = %q, want %q", id, tt.wantKeyID)
+			}
+			got, err := tt.ring.Decrypt(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if tt.wantUnknownKey && !errors.Is(err, ErrUnknownEncryptionKey) {
+					t.Errorf("error = %v, want ErrUnknownEncryptionKey", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("Decrypt = %q, want %q", got, plain)
+			}
+		})
+	}
+}
+
+func TestKeyRingWithoutKeys(t *testing.T) {
+	ring, err := NewKeyRing("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Encrypt([]byte("x")); err == nil {
+		t.Error("Encrypt succeeded without a key")
+	}
+	if _, err := ring.Key("missing"); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Errorf("Key error = %v, want ErrUnknownEncryptionKey", err)
+	}
+}
@@ -0,0 +1,15 @@
+package utils
+
+import "strings"
+
+// MaskSecret keeps just enough of a secret to tell two values apart in logs.
+func MaskSecret(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) <= 10 {
+		return s[:min(3, len(s))] + "..."
+	}
+	return s[:6] + "..." + s[len(s)-4:]
+}
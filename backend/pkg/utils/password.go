@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/google/uuid"
@@ -56,3 +57,14 @@ func GenerateRandomString(n int) (string, error) {
 	}
 	return string(bytes), nil
 }
+
+// GenerateNumericCode returns a random code of the given number of digits,
+// zero padded, for people to type in.
+func GenerateNumericCode(digits int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n.Int64()), nil
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890",
+// in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC lists 8-digit codes; a 6-digit code is their last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		t.Run(time.Unix(v.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := TOTPCode(rfc6238Secret, time.Unix(v.unix, 0))
+			if err != nil {
+				t.Fatalf("TOTPCode: %v", err)
+			}
+			if got != v.code {
+				t.Errorf("TOTPCode = %s, want %s", got, v.code)
+			}
+		})
+	}
+}
+
+func TestTOTPCodeSecretFormatting(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{"canonical", rfc6238Secret, false},
+		{"lower case", strings.ToLower(rfc6238Secret), false},
+		{"grouped with spaces", "GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ", false},
+		{"padded", rfc6238Secret + "====", false},
+		{"not base32", "GEZDGNBV1!", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOTPCode(tt.secret, at)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TOTPCode: %v", err)
+			}
+			if got != "287082" {
+				t.Errorf("TOTPCode = %s, want 287082", got)
+			}
+		})
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+	codeAt := func(offset int64) string {
+		code, err := TOTPCode(rfc6238Secret, time.Unix((step+offset)*totpPeriod, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantOK   bool
+		wantStep int64
+	}{
+		{"current period", codeAt(0), true, step},
+		{"previous period", codeAt(-1), true, step - 1},
+		{"next period", codeAt(1), true, step + 1},
+		{"surrounding spaces", " " + codeAt(0) + " ", true, step},
+		{"two periods ago", codeAt(-2), false, 0},
+		{"two periods ahead", codeAt(2), false, 0},
+		{"too short", codeAt(0)[:5], false, 0},
+		{"8-digit RFC code", "14050471", false, 0},
+		{"empty", "", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := MatchTOTP(rfc6238Secret, tt.code, now)
+			if ok != tt.wantOK {
+				t.Fatalf("MatchTOTP ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && gotStep != tt.wantStep {
+				t.Errorf("MatchTOTP step = %d, want %d", gotStep, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 11 || code[5] != '-' {
+		t.Fatalf("GenerateRecoveryCode = %q, want XXXXX-XXXXX", code)
+	}
+
+	want := NormalizeRecoveryCode(code)
+	for _, typed := range []string{
+		code,
+		strings.ToLower(code),
+		strings.ReplaceAll(code, "-", ""),
+		strings.ReplaceAll(code, "-", " "),
+	} {
+		if got := NormalizeRecoveryCode(typed); got != want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", typed, got, want)
+		}
+	}
+}
@@ -234,6 +234,7 @@ declare global {
 		total_users: number;
 		active_users: number;
 		total_revenue: number;
+		revenue_by_currency: Record<string, number>;
 		updated_at: string;
 	};
 